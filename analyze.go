@@ -0,0 +1,268 @@
+// analyze.go
+// 保存したサンプルを別の YRange で分け直して集計する（`go run . analyze -yrange 0.2:0.4 ok.tsv ng.tsv`）
+//
+// 合格の幅だけを変えたいときに、y は記録してあるので探索をやり直さなくてよい。保存した OK / NG をまとめて読み、
+// 新しい YRange で OK / NG を付け直して、件数と割合・元の OK / NG から変わった数・列ごとの統計
+// （新しい OK の最小・平均・最大と、全体の最小・最大）を表示する。-ok-tsv / -ng-tsv で分け直した結果を tsv に保存する。
+// 読めるのは review と同じ xlsx（OK / NG の両シート）・tsv・-machine -machine-samples の JSON（"ok" / "ng"）。
+// 割合は読んだサンプルについてのもの。MaxOKSave / MaxNGSave ですべての点を保存した実行（全件の dump）なら、
+// 探索全体の割合と同じになる。y が NaN の点（INVALID）はどちらにも数えない。
+// 元の OK / NG は、xlsx と JSON では入っていたシート（"ok" / "ng"）、tsv では先頭の "# yRange:"（provenance.go）で分けたもの。
+// tsv に "# yRange:" がなければ今の設定の YRange で分けたものとし、そのことを表示する。
+
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ichijohodaka/wpt-parameter-search2/stats"
+)
+
+// analyzeRow: 読んだサンプル 1 件
+type analyzeRow struct {
+	cells []string
+	y     float64
+	was   bool // 保存したときに OK だったか
+}
+
+// loadAnalyze: name から OK / NG のサンプルを読む（xlsx と JSON は OK と NG の両方）
+// 元の OK / NG は、xlsx と JSON ではシート、tsv では "# yRange:" の行（なければ fallback。guessed を true にする）で決める。
+func loadAnalyze(name string, fallback Range) (header []string, rows []analyzeRow, guessed bool, err error) {
+	var sheets []string
+	old := fallback
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".xlsx", ".json":
+		sheets = []string{"OK", "NG"}
+	case ".tsv":
+		sheets = []string{""}
+		r, ok, err := tsvYRange(name)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		if ok {
+			old = r
+		} else {
+			guessed = true
+		}
+	default:
+		return nil, nil, false, fmt.Errorf("analyze: %s: use .xlsx, .tsv or .json", name)
+	}
+	for _, sheet := range sheets {
+		t, err := loadReview(name, sheet)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		yi := -1
+		for j, h := range t.Header {
+			if h == "y" {
+				yi = j
+			}
+		}
+		if yi < 0 {
+			return nil, nil, false, fmt.Errorf("analyze: %s has no y column", name)
+		}
+		if header == nil {
+			header = t.Header
+		} else if strings.Join(header, "\t") != strings.Join(t.Header, "\t") {
+			return nil, nil, false, fmt.Errorf("analyze: %s: columns differ between OK and NG", name)
+		}
+		for _, r := range t.Rows {
+			y := math.NaN()
+			if yi < len(r) {
+				if v, err := strconv.ParseFloat(strings.TrimSpace(r[yi]), 64); err == nil {
+					y = v
+				}
+			}
+			was := sheet == "OK"
+			if sheet == "" {
+				was = inRange(y, old)
+			}
+			rows = append(rows, analyzeRow{cells: r, y: y, was: was})
+		}
+	}
+	return header, rows, guessed, nil
+}
+
+// tsvYRange: tsv の先頭の "# yRange: min max"（provenance.go）を読む（なければ ok は false）
+func tsvYRange(name string) (r Range, ok bool, err error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return r, false, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<20) // "# args:" の行は長くなることがある
+	for sc.Scan() {
+		line := sc.Text()
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		v, found := strings.CutPrefix(line, "# yRange: ")
+		if !found {
+			continue
+		}
+		var lo, hi float64
+		if _, err := fmt.Sscan(v, &lo, &hi); err != nil {
+			return r, false, fmt.Errorf("analyze: %s: yRange %q: %w", name, v, err)
+		}
+		return Range{Min: lo, Max: hi}, true, nil
+	}
+	return r, false, sc.Err()
+}
+
+// runAnalyze: analyze サブコマンド
+func runAnalyze(cfg Config, args []string) int {
+	fs := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	yRange := fs.String("yrange", "", "new YRange as min:max (default: YRange of the current config)")
+	okTSV := fs.String("ok-tsv", "", "save the relabelled OK samples to this tsv")
+	ngTSV := fs.String("ng-tsv", "", "save the relabelled NG samples to this tsv")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: go run . analyze [-yrange min:max] [-ok-tsv file] [-ng-tsv file] result.xlsx|ok.tsv ng.tsv|result.json ...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return ExitConfigError
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return ExitConfigError
+	}
+	r := cfg.YRange
+	if *yRange != "" {
+		if err := parseYRange(*yRange, &r); err != nil {
+			fmt.Fprintln(os.Stderr, "error: analyze:", err)
+			return ExitConfigError
+		}
+	}
+
+	var header []string
+	var rows []analyzeRow
+	var guessed []string
+	for _, name := range fs.Args() {
+		h, rs, g, err := loadAnalyze(name, cfg.YRange)
+		if g {
+			guessed = append(guessed, name)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			return ExitConfigError
+		}
+		if header != nil && strings.Join(header, "\t") != strings.Join(h, "\t") {
+			fmt.Fprintf(os.Stderr, "error: analyze: columns of %s differ from %s\n", name, fs.Arg(0))
+			return ExitConfigError
+		}
+		header = h
+		rows = append(rows, rs...)
+	}
+
+	var okc, ngc, invalid, gained, lost int64
+	var okRows, ngRows [][]string
+	okStats := make([]stats.Moments, len(header))
+	allStats := make([]stats.Moments, len(header))
+	numeric := make([]bool, len(header))
+	for j := range numeric {
+		numeric[j] = true
+	}
+	for _, row := range rows {
+		if math.IsNaN(row.y) {
+			invalid++
+			continue
+		}
+		ok, was := inRange(row.y, r), row.was
+		switch {
+		case ok && !was:
+			gained++
+		case !ok && was:
+			lost++
+		}
+		if ok {
+			okc++
+			okRows = append(okRows, row.cells)
+		} else {
+			ngc++
+			ngRows = append(ngRows, row.cells)
+		}
+		for j := range header {
+			if j >= len(row.cells) {
+				continue
+			}
+			v, err := strconv.ParseFloat(strings.TrimSpace(row.cells[j]), 64)
+			if err != nil {
+				numeric[j] = false // カテゴリの名前など
+				continue
+			}
+			allStats[j].Add(v)
+			if ok {
+				okStats[j].Add(v)
+			}
+		}
+	}
+
+	fmt.Printf("read %d samples from %s\n\n", len(rows), strings.Join(fs.Args(), ", "))
+	// PrintSummary と同じ形（seed は読んだファイルからは分からないので出さない）
+	var okRatio, ngRatio float64
+	if total := okc + ngc; total > 0 {
+		okRatio, ngRatio = float64(okc)/float64(total), float64(ngc)/float64(total)
+	}
+	fmt.Printf("yRange=[%s, %s]\n", fmt4(r.Min), fmt4(r.Max))
+	fmt.Printf("samples=%d  OK_hits=%d  NG_hits=%d\n", okc+ngc, okc, ngc)
+	fmt.Printf("OK_ratio=%s  NG_ratio=%s\n\n", fmt4(okRatio), fmt4(ngRatio))
+	if invalid > 0 {
+		fmt.Printf("INVALID (y is NaN): %d (not counted)\n", invalid)
+	}
+	fmt.Printf("relabelled from the saved OK / NG: NG -> OK %d, OK -> NG %d\n", gained, lost)
+	if len(guessed) > 0 {
+		fmt.Printf("(no \"# yRange:\" line in %s: saved OK / NG taken as yRange=[%g, %g] of the current config)\n",
+			strings.Join(guessed, ", "), cfg.YRange.Min, cfg.YRange.Max)
+	}
+	fmt.Println()
+
+	fmt.Println("=== columns (new OK / all) ===")
+	fmt.Printf("%-14s %12s %12s %12s %12s %12s\n", "column", "OK min", "OK mean", "OK max", "all min", "all max")
+	for j, h := range header {
+		if !numeric[j] || allStats[j].N == 0 {
+			continue
+		}
+		o, a := &okStats[j], &allStats[j]
+		okMin, okMean, okMax := math.NaN(), math.NaN(), math.NaN()
+		if o.N > 0 {
+			okMin, okMean, okMax = o.Min(), o.Mean(), o.Max()
+		}
+		fmt.Printf("%-14s %12.5g %12.5g %12.5g %12.5g %12.5g\n", h, okMin, okMean, okMax, a.Min(), a.Max())
+	}
+
+	for _, out := range []struct {
+		kind, name string
+		rows       [][]string
+	}{{"tsv (OK)", *okTSV, okRows}, {"tsv (NG)", *ngTSV, ngRows}} {
+		if out.name == "" {
+			continue
+		}
+		if err := saveRowsTSV(out.name, header, out.rows); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			return ExitError
+		}
+		fmt.Printf("%s saved: %s\n", out.kind, out.name)
+	}
+	return ExitOK
+}
+
+// saveRowsTSV: 読んだままの行を tsv に書く
+func saveRowsTSV(name string, header []string, rows [][]string) error {
+	return replaceFile(name, func(w io.Writer) error {
+		cw := csv.NewWriter(w)
+		cw.Comma = '\t'
+		cw.Write(header)
+		cw.WriteAll(rows)
+		return cw.Error()
+	})
+}
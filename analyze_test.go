@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 元の OK / NG は tsv の "# yRange:" から決める（今の設定の YRange ではない）
+func TestAnalyzeSavedLabels(t *testing.T) {
+	dir := t.TempDir()
+	body := "k\ty\n0.1\t1.5\n0.2\t2\n0.3\t2.5\n0.4\t2.9\n"
+	withRange := filepath.Join(dir, "ok.tsv")
+	bare := filepath.Join(dir, "bare.tsv")
+	os.WriteFile(withRange, []byte("# seed: 1\n# yRange: 1 3\n"+body), 0o644)
+	os.WriteFile(bare, []byte(body), 0o644)
+	current := Range{Min: 0.1, Max: 0.5}
+
+	_, rows, guessed, err := loadAnalyze(withRange, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if guessed || len(rows) != 4 {
+		t.Fatalf("guessed, rows = %v, %d: want false, 4", guessed, len(rows))
+	}
+	for i, r := range rows {
+		if !r.was {
+			t.Errorf("row %d (y = %g): want saved as OK under yRange 1..3", i, r.y)
+		}
+	}
+
+	_, rows, guessed, err = loadAnalyze(bare, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !guessed || rows[0].was {
+		t.Errorf("no yRange line: guessed = %v, was = %v: want true, false (current 0.1..0.5)", guessed, rows[0].was)
+	}
+}
@@ -0,0 +1,113 @@
+// anneal.go
+// 焼きなまし法による NG サンプルの救済
+//
+// 探索後、保存した NG サンプルのうち y が YRange のすぐ外にあるものを出発点に、
+// YRange までの距離を小さくする方向へ少しずつ動かす。YRange に入ったら、探索で見つけた OK と同じく
+// MaxOKSave の枠の中で OK リストに追加する（枠が埋まっていれば、救済できた数には数えるが保存はしない）。
+// 追加したサンプルは RefinedKey 列が 1 になる（ランダム探索で見つけたものは 0）。
+//
+// 救済のための評価は探索とは別扱いで、iters / OK_hits / NG_hits には数えない。
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// RefinedKey: 焼きなましで救済したサンプルの印（出力列のキー）
+const RefinedKey = "refined"
+
+// AnnealConfig: NG サンプル救済の設定（Enabled が false なら何もしない）
+type AnnealConfig struct {
+	Enabled bool
+	Margin  float64 // 対象にする NG の範囲（YRange の幅に対する割合。0 なら 0.1）
+	Steps   int     // 1 サンプルあたりの評価回数の上限（0 なら 200）
+	Step    float64 // u 空間での最初の提案幅（0 なら 0.05。温度とともに小さくする）
+}
+
+func (c AnnealConfig) margin() float64 {
+	if c.Margin > 0 {
+		return c.Margin
+	}
+	return 0.1
+}
+
+func (c AnnealConfig) steps() int {
+	if c.Steps > 0 {
+		return c.Steps
+	}
+	return 200
+}
+
+func (c AnnealConfig) step() float64 {
+	if c.Step > 0 {
+		return c.Step
+	}
+	return 0.05
+}
+
+// anneal: 保存した NG のうち YRange に近いものを焼きなましで動かし、救済できたものを OK リストに足す
+func (e *engine) anneal(ctx context.Context) {
+	ac := e.cfg.Anneal
+	params := e.cfg.Params
+	r := e.cfg.YRange
+	limit := ac.margin() * (r.Max - r.Min)
+	rng := newRand(e.cfg.Seed)
+
+	cur := make([]float64, len(params))
+	next := make([]float64, len(params))
+	for _, s := range e.ngList {
+		d0 := rangeDistance(s.Y, r)
+		if d0 > limit || d0 == 0 {
+			continue
+		}
+		e.annealTried++
+
+		for j, p := range params {
+			cur[j] = reflectUnit(paramCDF(p, s.Values[p.Key]))
+		}
+		if e.copula != nil {
+			copy(cur, e.copula.inverse(cur))
+		}
+		d := d0
+		for k := 0; k < ac.steps(); k++ {
+			if ctx.Err() != nil {
+				return
+			}
+			// 温度は d0 から 0 へ線形に下げ、提案幅も温度に合わせて小さくする
+			t := d0 * (1 - float64(k)/float64(ac.steps()))
+			width := ac.step() * math.Max(t/d0, 0.05)
+			for j := range cur {
+				next[j] = reflectUnit(cur[j] + rng.NormFloat64()*width)
+			}
+			cand, err := e.evaluate(params, next)
+			if err != nil {
+				return
+			}
+			e.annealEvals++
+			if cand.Invalid {
+				continue // 制約を満たさない点には動かない
+			}
+
+			dn := rangeDistance(cand.Y, r)
+			if cand.OK {
+				cand = e.filled(cand)
+				cand.Values[RefinedKey] = 1
+				if e.score != nil {
+					cand.Values[ScoreKey] = e.score.of(cand)
+				}
+				e.saveOK(cand) // 探索と同じく MaxOKSave まで（Score があれば上位に入るものだけ）
+				e.annealRecovered++
+				break
+			}
+			if dn <= d || (t > 0 && rng.Float64() < math.Exp(-(dn-d)/t)) {
+				copy(cur, next)
+				d = dn
+			}
+		}
+	}
+	fmt.Printf("[anneal] recovered %d of %d near-boundary NG samples (%d evals)\n",
+		e.annealRecovered, e.annealTried, e.annealEvals)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// 救済した OK も探索と同じく MaxOKSave を超えて保存しない
+func TestAnnealRespectsMaxOKSave(t *testing.T) {
+	cfg := exprConfig(t, "a+b")
+	cfg.MaxOKSave, cfg.MaxNGSave = 3, 200
+	cfg.Anneal = AnnealConfig{Enabled: true, Margin: 1}
+	e, err := newEngine(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	e.anneal(context.Background())
+	if e.annealRecovered == 0 {
+		t.Fatalf("nothing recovered: the test does not exercise the cap")
+	}
+	if len(e.okList) > cfg.MaxOKSave {
+		t.Errorf("len(okList) = %d after anneal, want <= MaxOKSave %d", len(e.okList), cfg.MaxOKSave)
+	}
+}
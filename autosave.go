@@ -0,0 +1,88 @@
+// autosave.go
+// 長時間の探索の途中結果を一定時間ごとに保存する（Config.AutosaveEvery）
+//
+// 保存先は通常の出力ファイル名に ".partial" を付けたもの（例: result.xlsx.partial）。
+// 途中で書き込みが止まっても前回の途中結果が壊れないよう、一時ファイルに書いてから置き換える。
+// 最後まで保存できたら途中結果のファイルは消す。
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+const partialSuffix = ".partial"
+
+// partial: 途中結果の保存先（出力しないものは "" のまま）
+func (o outputFiles) partial() outputFiles {
+	add := func(name string) string {
+		if name == "" {
+			return ""
+		}
+		return name + partialSuffix
+	}
+	return outputFiles{XLSX: add(o.XLSX), OKTSV: add(o.OKTSV), NGTSV: add(o.NGTSV), OKCSV: add(o.OKCSV), NGCSV: add(o.NGCSV),
+		OKParquet: add(o.OKParquet), NGParquet: add(o.NGParquet)}
+}
+
+// savePartial: res を途中結果として保存する（既存の途中結果は上書き）
+func savePartial(files outputFiles, res Result, cfg Config) error {
+	p := files.partial()
+	var errs []error
+	replace := func(name string, save func(tmp string) error) {
+		if name == "" {
+			return
+		}
+		tmp := name + ".tmp"
+		if err := save(tmp); err != nil {
+			os.Remove(tmp)
+			errs = append(errs, err)
+			return
+		}
+		if err := os.Rename(tmp, name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	replace(p.XLSX, func(tmp string) error {
+		_, err := SaveToXLSX(tmp, Overwrite, res.Columns, res.OKList, res.NGList, res.Iters, res.OKHits, res.NGHits, res.Config,
+			cfg.XLSXLimit, p.fullList(true), p.fullList(false))
+		return err
+	})
+	replace(p.OKTSV, func(tmp string) error {
+		_, err := SaveListToTSV(tmp, Overwrite, res.Columns, res.OKList, res.Config)
+		return err
+	})
+	replace(p.NGTSV, func(tmp string) error {
+		_, err := SaveListToTSV(tmp, Overwrite, res.Columns, res.NGList, res.Config)
+		return err
+	})
+	replace(p.OKCSV, func(tmp string) error {
+		_, err := SaveListToCSV(tmp, Overwrite, res.Columns, res.OKList, cfg.CSV)
+		return err
+	})
+	replace(p.NGCSV, func(tmp string) error {
+		_, err := SaveListToCSV(tmp, Overwrite, res.Columns, res.NGList, cfg.CSV)
+		return err
+	})
+	replace(p.OKParquet, func(tmp string) error {
+		_, err := SaveListToParquet(tmp, Overwrite, res.Columns, res.OKList, res.Config)
+		return err
+	})
+	replace(p.NGParquet, func(tmp string) error {
+		_, err := SaveListToParquet(tmp, Overwrite, res.Columns, res.NGList, res.Config)
+		return err
+	})
+	return errors.Join(errs...)
+}
+
+// removePartial: 途中結果のファイルを消す（なければ何もしない）
+func removePartial(files outputFiles) {
+	p := files.partial()
+	for _, name := range []string{p.XLSX, p.OKTSV, p.NGTSV, p.OKCSV, p.NGCSV, p.OKParquet, p.NGParquet} {
+		if name != "" {
+			os.Remove(name)
+		}
+	}
+}
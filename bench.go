@@ -0,0 +1,174 @@
+// bench.go
+// 目的関数の速さを測る（`go run . bench`）
+//
+// 長い探索を始める前に、MaxIters がどのくらいで終わるかを見積もるためのもの。
+// 探索と同じ手順（点を引く → 派生パラメータ → 目的関数 → 判定）を、決めた時間だけ繰り返して
+// 1 秒あたりの評価数と 1 回あたりのメモリ割り当てを示す。1 つのゴルーチンで測ったあと、
+// Workers が 2 以上なら並列でも測る（目的関数の中のロックやメモリ帯域で、CPU 数ほどは速くならないことがある）。
+// 点列は SamplingMethod によらず random で引く（grid などは数が限られるため）。
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// benchResult: 1 回の計測
+type benchResult struct {
+	Workers int
+	Evals   int64
+	Elapsed time.Duration
+	Allocs  uint64 // 割り当て回数（全ワーカーの合計）
+	Bytes   uint64 // 割り当てたバイト数
+}
+
+func (b benchResult) rate() float64 {
+	return float64(b.Evals) / b.Elapsed.Seconds()
+}
+
+// runBench: `go run . bench [-time 2s]`
+func runBench(cfg Config, args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	budget := fs.Duration("time", 2*time.Second, "how long to evaluate for each measurement")
+	if err := fs.Parse(args); err != nil {
+		return ExitConfigError
+	}
+	if *budget <= 0 {
+		fmt.Fprintln(os.Stderr, "error: bench: -time must be positive")
+		return ExitConfigError
+	}
+
+	cc := cfg
+	cc.Sampler, cc.Search, cc.SamplingMethod = nil, "", "random"
+	cc.Antithetic = false
+	cc.Zoom = ZoomConfig{}
+	e, err := newEngine(cc)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return ExitConfigError
+	}
+
+	fmt.Printf("=== bench (%s per measurement) ===\n", *budget)
+	single, err := e.bench(1, *budget)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return ExitError
+	}
+	results := []benchResult{single}
+	if e.workers > 1 {
+		par, err := e.bench(e.workers, *budget)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			return ExitError
+		}
+		results = append(results, par)
+	}
+
+	for _, b := range results {
+		n := float64(max(b.Evals, 1))
+		fmt.Printf("workers=%-3d evals=%-10d %12.0f evals/s  %8.3f µs/eval per worker  allocs/eval=%.1f  bytes/eval=%.0f\n",
+			b.Workers, b.Evals, b.rate(), 1e6/b.rate()*float64(b.Workers), float64(b.Allocs)/n, float64(b.Bytes)/n)
+	}
+	if len(results) > 1 {
+		fmt.Printf("parallel speedup=%.2fx on %d workers\n", results[1].rate()/single.rate(), e.workers)
+	}
+
+	last := results[len(results)-1]
+	fmt.Printf("\nMaxIters=%d: about %s with %d worker(s)\n",
+		cfg.MaxIters, benchETA(cfg.MaxIters, last.rate()), last.Workers)
+	if cfg.Zoom.Phases > 1 {
+		fmt.Printf("(Zoom runs %d phases of MaxIters each: about %s in total)\n",
+			cfg.Zoom.Phases, benchETA(cfg.MaxIters*int64(cfg.Zoom.Phases), last.rate()))
+	}
+	return ExitOK
+}
+
+// bench: workers 個のゴルーチンで budget の間評価する
+func (e *engine) bench(workers int, budget time.Duration) (benchResult, error) {
+	engines := []*engine{e}
+	if workers > 1 {
+		w := *e
+		w.workers = workers
+		ss, err := w.workerSamplers(e.cfg.Seed)
+		if err != nil {
+			return benchResult{}, err
+		}
+		engines = make([]*engine, workers)
+		for i, s := range ss {
+			engines[i] = e.fork(s)
+		}
+	}
+
+	var evals int64
+	var stop int32
+	var firstErr error
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	runtime.GC()
+	var m0, m1 runtime.MemStats
+	runtime.ReadMemStats(&m0)
+	start := time.Now()
+	for _, we := range engines {
+		wg.Add(1)
+		go func(we *engine) {
+			defer wg.Done()
+			u := make([]float64, len(we.params))
+			var n int64
+			// 時刻を見るのは 64 回に 1 回（軽い目的関数で time.Now が効かないように）
+			for atomic.LoadInt32(&stop) == 0 {
+				for range 64 {
+					if _, err := we.draw(we.params, u); err != nil {
+						mu.Lock()
+						if firstErr == nil {
+							firstErr = err
+						}
+						mu.Unlock()
+						atomic.StoreInt32(&stop, 1)
+						break
+					}
+					n++
+				}
+				if time.Since(start) >= budget {
+					atomic.StoreInt32(&stop, 1)
+				}
+			}
+			atomic.AddInt64(&evals, n)
+		}(we)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&m1)
+
+	if firstErr != nil {
+		return benchResult{}, firstErr
+	}
+	return benchResult{
+		Workers: len(engines),
+		Evals:   evals,
+		Elapsed: elapsed,
+		Allocs:  m1.Mallocs - m0.Mallocs,
+		Bytes:   m1.TotalAlloc - m0.TotalAlloc,
+	}, nil
+}
+
+// benchETA: n 回の評価にかかる時間の見積もり
+func benchETA(n int64, rate float64) string {
+	if rate <= 0 {
+		return "unknown"
+	}
+	d := time.Duration(float64(n) / rate * float64(time.Second))
+	switch {
+	case d < time.Second:
+		return d.Round(time.Millisecond).String()
+	case d < time.Hour:
+		return d.Round(time.Second).String()
+	}
+	return d.Round(time.Minute).String()
+}
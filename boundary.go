@@ -0,0 +1,79 @@
+// boundary.go
+// y が YRange の端ちょうど（または端の近く）にあるときの扱い（Config.Boundary）
+//
+// 浮動小数点の y が Min / Max ちょうどになることは実際にあり、従来は <= で OK にしていた。
+// どう扱うかを選べるようにし、端にあったサンプルの数は OK / NG とは別に数えて表示する。
+//
+// 目的関数自体に数値誤差があるときは Config.YEpsilon で YRange の外側に許容幅を取れる。
+// 許容幅に入ったサンプルは "marginal" として別に数え、MarginalKey 列が 1 になる。
+// OK にする（既定）か、NG のまま印だけ付ける（Config.MarginalNG）かを選べる。
+
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+type BoundaryPolicy int
+
+const (
+	Inclusive BoundaryPolicy = iota // Min <= y <= Max を OK（従来通り）。端ちょうどを端として数える
+	Exclusive                       // Min < y < Max を OK。端ちょうどは NG で、端として数える
+	Band                            // 端から BoundaryBand 以内は NG（内側に余裕を取る）。その帯を端として数える
+)
+
+func (p BoundaryPolicy) String() string {
+	switch p {
+	case Inclusive:
+		return "inclusive"
+	case Exclusive:
+		return "exclusive"
+	case Band:
+		return "band"
+	default:
+		return fmt.Sprintf("BoundaryPolicy(%d)", int(p))
+	}
+}
+
+// inYRange: 端の扱いに従って y が YRange に入っているか（NaN / ±Inf は呼ぶ側で除く）
+func inYRange(y float64, cfg Config) bool {
+	r := cfg.YRange
+	switch cfg.Boundary {
+	case Exclusive:
+		return r.Min < y && y < r.Max
+	case Band:
+		return r.Min+cfg.BoundaryBand <= y && y <= r.Max-cfg.BoundaryBand
+	default:
+		return inRange(y, r)
+	}
+}
+
+// yOK: y を OK とするか（許容幅 YEpsilon に入ったものは MarginalNG でなければ OK）
+func yOK(y float64, cfg Config) bool {
+	if math.IsNaN(y) || math.IsInf(y, 0) {
+		return false
+	}
+	return inYRange(y, cfg) || (isMarginal(y, cfg) && !cfg.MarginalNG)
+}
+
+// MarginalKey: 許容幅（Config.YEpsilon）で判定したサンプルの印（出力列のキー）
+const MarginalKey = "marginal"
+
+// isMarginal: y が YRange には入らないが、外側 YEpsilon 以内にあるか
+func isMarginal(y float64, cfg Config) bool {
+	if cfg.YEpsilon <= 0 || inYRange(y, cfg) {
+		return false
+	}
+	return cfg.YRange.Min-cfg.YEpsilon <= y && y <= cfg.YRange.Max+cfg.YEpsilon
+}
+
+// onBoundary: y が端として数える位置にあるか
+func onBoundary(y float64, cfg Config) bool {
+	r := cfg.YRange
+	if cfg.Boundary == Band {
+		eps := cfg.BoundaryBand
+		return (y >= r.Min-eps && y <= r.Min+eps) || (y >= r.Max-eps && y <= r.Max+eps)
+	}
+	return y == r.Min || y == r.Max
+}
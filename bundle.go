@@ -0,0 +1,240 @@
+// bundle.go
+// 実行の成果物を 1 つの .tar.zst にまとめる（Config.Bundle）
+//
+// 1 回の実行を 1 ファイルで保管できるように、最後に次のものを tar にして zstd で圧縮する。
+// 中の名前は設定したファイル名によらず固定（元のファイル名は manifest.json の files に残す）。
+//
+//	manifest.json       形式の版・作成時刻・Seed・乱数・点列・パラメータ・Score の重み・件数・各ファイルの大きさと sha256
+//	result.json         -machine -machine-samples と同じ JSON（保存した OK / NG を含む）
+//	log.txt             この実行で stdout に表示したもの
+//	files/result.xlsx   保存したファイル（あるものだけ）
+//	files/ok.tsv
+//	files/ng.tsv
+//	files/ok.csv
+//	files/ng.csv
+//	files/ok.parquet
+//	files/ng.parquet
+//	files/interaction.tsv
+//	files/evals.tsv
+//	files/progress.tsv
+//	files/config.json
+//
+// 見るときは `tar --zstd -xf run.tar.zst`（または `zstd -dc run.tar.zst | tar x`）。
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// bundleFormat: 中の構成の版（名前や manifest の項目を変えたら上げる）
+const bundleFormat = 2
+
+// bundleNames: 保存したファイルの種類 → bundle の中の名前
+var bundleNames = []struct{ kind, name string }{
+	{"xlsx", "files/result.xlsx"},
+	{"tsv (OK)", "files/ok.tsv"},
+	{"tsv (NG)", "files/ng.tsv"},
+	{"csv (OK)", "files/ok.csv"},
+	{"csv (NG)", "files/ng.csv"},
+	{"parquet (OK)", "files/ok.parquet"},
+	{"parquet (NG)", "files/ng.parquet"},
+	{"tsv (interaction)", "files/interaction.tsv"},
+	{"tsv (evals)", "files/evals.tsv"},
+	{"tsv (progress)", "files/progress.tsv"},
+	{"json (config)", "files/config.json"},
+}
+
+type bundleParam struct {
+	Key   string    `json:"key"`
+	Label string    `json:"label"`
+	Min   jsonFloat `json:"min"`
+	Max   jsonFloat `json:"max"`
+	Scale string    `json:"scale"`
+}
+
+type bundleFile struct {
+	Name   string `json:"name"`             // bundle の中の名前
+	Kind   string `json:"kind,omitempty"`   // 保存したファイルの種類（"xlsx" など）
+	Source string `json:"source,omitempty"` // 元のファイル名
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+type bundleManifest struct {
+	Format         int           `json:"format"`
+	Created        string        `json:"created"`
+	Seed           int64         `json:"seed"`
+	RNG            string        `json:"rng,omitempty"`
+	SamplingMethod string        `json:"samplingMethod,omitempty"`
+	Search         string        `json:"search,omitempty"`
+	Workers        int           `json:"workers"`
+	Deterministic  bool          `json:"deterministic"`
+	MaxIters       int64         `json:"maxIters"`
+	YRange         [2]jsonFloat  `json:"yRange"`
+	Params         []bundleParam `json:"params"`
+	Score          []ScoreTerm   `json:"score,omitempty"` // 重み付きの評価値の重み（score.go）
+	Iters          int64         `json:"iters"`
+	OKHits         int64         `json:"okHits"`
+	NGHits         int64         `json:"ngHits"`
+	Interrupted    bool          `json:"interrupted"`
+	Files          []bundleFile  `json:"files"`
+}
+
+// logTee: stdout に書いたものを写し取る（bundle の log.txt 用）
+type logTee struct {
+	orig *os.File
+	w    *os.File
+	buf  bytes.Buffer
+	done sync.WaitGroup
+}
+
+// teeStdout: 以降 stdout に書いたものを、元の stdout に流しながら覚えておく
+func teeStdout() (*logTee, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	t := &logTee{orig: os.Stdout, w: w}
+	t.done.Add(1)
+	go func() {
+		defer t.done.Done()
+		io.Copy(io.MultiWriter(t.orig, &t.buf), r)
+		r.Close()
+	}()
+	os.Stdout = w
+	return t, nil
+}
+
+// stop: stdout を元に戻し、写し取ったものを返す（2 回目以降は同じものを返す）
+func (t *logTee) stop() []byte {
+	if t.w != nil {
+		os.Stdout = t.orig
+		t.w.Close()
+		t.done.Wait()
+		t.w = nil
+	}
+	return t.buf.Bytes()
+}
+
+// SaveBundle: 成果物を filename（.tar.zst）にまとめ、実際に書いたファイル名を返す
+// saved は保存したファイル（種類 → ファイル名）、machine は result.json の中身、log は log.txt の中身
+func SaveBundle(filename string, policy ExistPolicy, cfg Config, res Result, interrupted bool, saved map[string]string, machine, log []byte) (string, error) {
+	if policy == AppendToExisting {
+		policy = RenameWithSuffix // tar.zst には追記できない
+	}
+	name, _, err := resolveOutput(filename, policy)
+	if err != nil {
+		return "", err
+	}
+
+	type entry struct {
+		name string
+		data []byte
+	}
+	entries := []entry{{"result.json", machine}, {"log.txt", log}}
+	workers := workerCount(cfg, nil)
+	if res.Config != nil {
+		workers = res.Config.Workers // 実際に使った数
+	}
+	man := bundleManifest{
+		Format:         bundleFormat,
+		Created:        time.Now().Format(time.RFC3339),
+		Seed:           res.Seed,
+		RNG:            cfg.RNG,
+		SamplingMethod: cfg.SamplingMethod,
+		Search:         cfg.Search,
+		Workers:        workers,
+		Deterministic:  cfg.Deterministic,
+		MaxIters:       cfg.MaxIters,
+		YRange:         [2]jsonFloat{jsonFloat(res.YRange.Min), jsonFloat(res.YRange.Max)},
+		Score:          cfg.Score,
+		Iters:          res.Iters,
+		OKHits:         res.OKHits,
+		NGHits:         res.NGHits,
+		Interrupted:    interrupted,
+	}
+	for _, p := range cfg.Params {
+		man.Params = append(man.Params, bundleParam{
+			Key: p.Key, Label: p.Label, Min: jsonFloat(p.Min), Max: jsonFloat(p.Max), Scale: p.Scale.String(),
+		})
+	}
+	for _, e := range entries {
+		man.Files = append(man.Files, bundleFileOf(e.name, e.data))
+	}
+	for _, b := range bundleNames {
+		src, ok := saved[b.kind]
+		if !ok {
+			continue
+		}
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return "", err
+		}
+		entries = append(entries, entry{b.name, data})
+		f := bundleFileOf(b.name, data)
+		f.Kind, f.Source = b.kind, src
+		man.Files = append(man.Files, f)
+	}
+	mj, err := json.MarshalIndent(man, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	entries = append([]entry{{"manifest.json", mj}}, entries...)
+
+	// 一時ファイルに書いてから置き換える（途中で失敗しても壊れた bundle を残さない）
+	tmp := name + ".tmp"
+	if err := func() error {
+		f, err := os.Create(tmp)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		zw, err := zstd.NewWriter(f)
+		if err != nil {
+			return err
+		}
+		tw := tar.NewWriter(zw)
+		now := time.Now()
+		for _, e := range entries {
+			hdr := &tar.Header{Name: e.name, Mode: 0o644, Size: int64(len(e.data)), ModTime: now, Format: tar.FormatPAX}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if _, err := tw.Write(e.data); err != nil {
+				return err
+			}
+		}
+		if err := tw.Close(); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		return f.Close()
+	}(); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("bundle: %w", err)
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return name, nil
+}
+
+func bundleFileOf(name string, data []byte) bundleFile {
+	sum := sha256.Sum256(data)
+	return bundleFile{Name: name, Size: int64(len(data)), SHA256: hex.EncodeToString(sum[:])}
+}
@@ -0,0 +1,251 @@
+// checkpoint.go
+// 長時間の探索を途中から再開する（Config.CheckpointEvery と -resume）
+//
+// CheckpointEvery ごと（と Ctrl-C で止めたとき）に、カウンタ・保存したサンプル・多段探索の段・
+// Sampler から引いた点の数・経過時間をファイルに書く。`go run . -resume checkpoint.gob` で、
+// 同じ Seed の Sampler をその点数だけ進めてから続きを探索する。最後まで探索できたらファイルは消す。
+//
+// 1 ワーカーで順に探索するか Deterministic なら、途中で止めなかった場合と同じ点列で続く。
+// Workers が 2 以上（Deterministic でない）なら、各ワーカーの位置は残せないので、再開後は別の seed の系列で続ける。
+// 評価結果を使う探索モード（mcmc / cem / cmaes / ga / gp）と、集計の途中を残せない設定
+// （Strata / NGDistance / Antithetic / CompareYRanges / Prior / StreamTSV）とは組み合わせられない。
+// Importance / Interaction に使う点は、再開後に評価した点から選び直す。
+// 目的関数（Model / Expr / Plugin / WASM / Exec と、そのファイルの中身）が変わっていれば再開しない。
+
+package main
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// checkpointVersion: ファイルの形式（変えたら古いファイルからは再開しない）
+const checkpointVersion = 1
+
+// defaultCheckpointFile: Config.CheckpointFile が "" のときの保存先
+const defaultCheckpointFile = "checkpoint.gob"
+
+// Checkpoint: 再開に必要な探索の状態
+type Checkpoint struct {
+	Version int
+	Key     string // 設定の照合用（checkpointKey）
+	Seed    int64
+
+	Phase      int         // 実行中の段
+	Params     []ParamSpec // 実行中の段の探索範囲
+	Phases     []Phase     // 終わった段
+	PhaseIters int64       // 実行中の段の開始時点の iters
+	PhaseOK    int64       // 実行中の段の開始時点の OK 数
+	BoxN       int64       // 実行中の段の OK サンプルの範囲（多段探索の絞り込み用）
+	BoxLo      []float64
+	BoxHi      []float64
+
+	Draws  int64     // 実行中の段で Sampler から引いた点の数
+	Anchor []float64 // 制約の Repair の寄せ先
+
+	Iters        int64
+	OKHits       int64
+	NGHits       int64
+	BoundaryHits int64
+	MarginalHits int64
+	InvalidHits  int64
+	Rejected     int64
+	Repaired     int64
+	OKList       []Sample
+	NGList       []Sample
+
+	Elapsed time.Duration // それまでの実行時間の合計
+}
+
+func (c Config) checkpointFile() string {
+	if c.CheckpointFile != "" {
+		return c.CheckpointFile
+	}
+	return defaultCheckpointFile
+}
+
+// checkpointKey: 再開してよい設定かを確かめるための値（探索範囲・点数・点列の作り方・目的関数）
+// 分担して探索するとき（distributed.go）も、ワーカーとコーディネータが同じ設定かをこの値で確かめる。
+func checkpointKey(cfg Config) string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d|%v|%q|%q|%q|%v|%v|%v|%v|%d",
+		cfg.MaxIters, cfg.YRange, cfg.SamplingMethod, cfg.Search, cfg.RNG,
+		cfg.Zoom.Phases, cfg.Zoom.Budget, cfg.Deterministic, cfg.Constraint, len(cfg.ParamConstraints))
+	for _, p := range cfg.Params {
+		fmt.Fprintf(h, "|%s:%v:%v:%v:%v:%v:%d:%v:%v", p.Key, p.Min, p.Max, p.Scale, p.Dist, p.Type, p.GridPoints, p.Values, len(p.Choices))
+	}
+	objectiveKey(h, cfg)
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// objectiveKey: 目的関数の出どころを h に書く（Model の名前・Expr の式・Plugin / WASM のパスと中身・Exec のコマンドと
+// 引数のファイルの中身・派生と出力だけの列のキー）。Go で書いた F / F2 / Objective の中身は比べられないので、
+// その場合は同じソースからビルドしたものを使うこと。
+func objectiveKey(h io.Writer, cfg Config) {
+	fmt.Fprintf(h, "|model %q|expr %q %v|plugin %q|wasm %q|exec %q|terms %v",
+		cfg.Model, cfg.Expr, cfg.ExprCompensated, cfg.Plugin, cfg.WASM, cfg.Exec.Command, cfg.TermColumns)
+	for _, name := range append([]string{cfg.Plugin, cfg.WASM}, cfg.Exec.Command...) {
+		hashFileInto(h, name)
+	}
+	for _, d := range cfg.Derived {
+		fmt.Fprintf(h, "|derived %s", d.Key)
+	}
+	for _, d := range cfg.OutputColumns {
+		fmt.Fprintf(h, "|output %s", d.Key)
+	}
+}
+
+// hashFileInto: name が普通のファイルなら中身を h に書く（Exec の "python3" のようなファイルでない引数は飛ばす）
+func hashFileInto(h io.Writer, name string) {
+	if name == "" {
+		return
+	}
+	st, err := os.Stat(name)
+	if err != nil || !st.Mode().IsRegular() {
+		return
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		fmt.Fprintf(h, "|%s: %v", name, err)
+		return
+	}
+	defer f.Close()
+	fmt.Fprintf(h, "|file %s:", name)
+	if _, err := io.Copy(h, f); err != nil {
+		fmt.Fprintf(h, "|%v", err)
+	}
+}
+
+// checkpointable: 途中から再開できる設定か
+func (e *engine) checkpointable() error {
+	if _, ok := e.sampler.(FeedbackSampler); ok {
+		return fmt.Errorf("checkpoint: not available with %T", e.sampler)
+	}
+	var off []string
+	if e.strata != nil {
+		off = append(off, "Strata")
+	}
+	if e.dist != nil {
+		off = append(off, "NGDistance")
+	}
+	if e.cmp != nil {
+		off = append(off, "Antithetic / CompareYRanges")
+	}
+	if e.prior != nil {
+		off = append(off, "Prior")
+	}
+	if e.cfg.StreamTSV {
+		off = append(off, "StreamTSV")
+	}
+	if len(off) > 0 {
+		return fmt.Errorf("checkpoint: not available with %v", off)
+	}
+	return nil
+}
+
+// snapshot: 現在の状態（記録する側のゴルーチンから呼ぶ）
+func (e *engine) snapshot() Checkpoint {
+	return Checkpoint{
+		Version:    checkpointVersion,
+		Key:        checkpointKey(e.cfg),
+		Seed:       e.cfg.Seed,
+		Phase:      e.phase,
+		Params:     e.params,
+		Phases:     e.phases[:min(len(e.phases), e.phase)], // 中断した段の分は除く
+		PhaseIters: e.phaseIters,
+		PhaseOK:    e.phaseOK,
+		BoxN:       e.box.n,
+		BoxLo:      e.box.lo,
+		BoxHi:      e.box.hi,
+		Draws:      e.draws,
+		Anchor:     e.anchor,
+
+		Iters:        atomic.LoadInt64(&e.iters),
+		OKHits:       atomic.LoadInt64(&e.okHits),
+		NGHits:       atomic.LoadInt64(&e.ngHits),
+		BoundaryHits: atomic.LoadInt64(&e.boundaryHits),
+		MarginalHits: atomic.LoadInt64(&e.marginalHits),
+		InvalidHits:  atomic.LoadInt64(&e.invalidHits),
+		Rejected:     e.rejected,
+		Repaired:     e.repaired,
+		OKList:       e.okList,
+		NGList:       e.ngList,
+
+		Elapsed: e.elapsedTotal(),
+	}
+}
+
+// restore: ck の状態から続ける（run の前に呼ぶ）
+func (e *engine) restore(ck *Checkpoint) error {
+	if ck.Version != checkpointVersion {
+		return fmt.Errorf("checkpoint: version %d is not supported (want %d)", ck.Version, checkpointVersion)
+	}
+	if ck.Key != checkpointKey(e.cfg) || ck.Seed != e.cfg.Seed {
+		return errors.New("checkpoint: the config has changed since the checkpoint was written")
+	}
+	if err := e.checkpointable(); err != nil {
+		return err
+	}
+	e.phase = ck.Phase
+	e.params = ck.Params
+	e.phases = ck.Phases
+	e.phaseIters, e.phaseOK = ck.PhaseIters, ck.PhaseOK
+	e.box = okBox{n: ck.BoxN, lo: ck.BoxLo, hi: ck.BoxHi}
+	e.anchor = ck.Anchor
+
+	e.iters, e.okHits, e.ngHits = ck.Iters, ck.OKHits, ck.NGHits
+	e.boundaryHits, e.marginalHits, e.invalidHits = ck.BoundaryHits, ck.MarginalHits, ck.InvalidHits
+	e.rejected, e.repaired = ck.Rejected, ck.Repaired
+	e.okList = append(e.okList[:0], ck.OKList...)
+	e.ngList = append(e.ngList[:0], ck.NGList...)
+	e.elapsed = ck.Elapsed
+	e.resume = ck
+	return nil
+}
+
+// elapsedTotal: 再開前の分も含めた実行時間
+func (e *engine) elapsedTotal() time.Duration {
+	if e.started.IsZero() {
+		return e.elapsed
+	}
+	return e.elapsed + time.Since(e.started)
+}
+
+// SaveCheckpoint: ck を name に書く（一時ファイルに書いてから置き換える）
+func SaveCheckpoint(name string, ck Checkpoint) error {
+	tmp := name + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(f).Encode(ck); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// LoadCheckpoint: name から読む
+func LoadCheckpoint(name string) (*Checkpoint, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var ck Checkpoint
+	if err := gob.NewDecoder(f).Decode(&ck); err != nil {
+		return nil, fmt.Errorf("checkpoint %s: %w", name, err)
+	}
+	return &ck, nil
+}
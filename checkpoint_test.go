@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// exprConfig: a, b ∈ [0, 1] を src の式で探索する設定
+func exprConfig(t *testing.T, src string) Config {
+	t.Helper()
+	cfg := Config{
+		Params: []ParamSpec{
+			{Key: "a", Label: "a", Min: 0, Max: 1, DisplayScale: 1},
+			{Key: "b", Label: "b", Min: 0, Max: 1, DisplayScale: 1},
+		},
+		YRange:   Range{Min: 0.2, Max: 0.5},
+		MaxIters: 1000,
+		Seed:     1,
+		Expr:     src,
+	}
+	if err := applyObjectiveSource(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// 別の式で書いたチェックポイントからは再開しない
+func TestResumeRejectsOtherExpr(t *testing.T) {
+	e, err := newEngine(exprConfig(t, "a+b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ck := e.snapshot()
+
+	same, err := newEngine(exprConfig(t, "a+b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := same.restore(&ck); err != nil {
+		t.Errorf("same expr: %v", err)
+	}
+
+	other, err := newEngine(exprConfig(t, "a*b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.restore(&ck); err == nil || !strings.Contains(err.Error(), "config has changed") {
+		t.Errorf("other expr: err = %v, want config has changed", err)
+	}
+}
+
+func TestCheckpointKeyObjective(t *testing.T) {
+	base := exprConfig(t, "a+b")
+	for name, change := range map[string]func(*Config){
+		"expr":        func(c *Config) { c.Expr = "a*b" },
+		"compensated": func(c *Config) { c.ExprCompensated = true },
+		"model":       func(c *Config) { c.Model = "ss_pn" },
+		"exec":        func(c *Config) { c.Exec.Command = []string{"python3", "sim.py"} },
+		"derived":     func(c *Config) { c.Derived = []DerivedSpec{{Key: "c"}} },
+		"output":      func(c *Config) { c.OutputColumns = []DerivedSpec{{Key: "c"}} },
+	} {
+		c := base
+		change(&c)
+		if checkpointKey(c) == checkpointKey(base) {
+			t.Errorf("%s: key does not change", name)
+		}
+	}
+
+	// 同じパスでも中身が変われば別の目的関数
+	name := filepath.Join(t.TempDir(), "f.wasm")
+	os.WriteFile(name, []byte("v1"), 0o644)
+	c := base
+	c.WASM = name
+	k1 := checkpointKey(c)
+	os.WriteFile(name, []byte("v2"), 0o644)
+	if checkpointKey(c) == k1 {
+		t.Errorf("wasm: key does not change with the file content")
+	}
+}
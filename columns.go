@@ -0,0 +1,96 @@
+// columns.go
+// 出力列の名前の重なりを起動時に調べる
+//
+// params・派生パラメータ（Derived）・目的関数の補助出力（Objective.Aux・exec の aux・TermColumns）・
+// 各機能の列（refined・marginal・y_err・unsure・ng_distance・score）・出力だけの列（OutputColumns）は、
+// どれも Sample.Values の同じ map に入り、同じ表の列になる。同じ Key があると後から書いた値で黙って上書きされ、
+// 同じ Label があると tsv の見出しが重なって読み戻せない。そこで、探索を始める前に全部の列を 1 か所に登録し、
+// 重なっていればどこから来た列同士かを書いて設定エラーにする。No と y は表が使う列なので、Key にも Label にも使えない。
+
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// reservedColumns: 表が自分で使う列の名前
+var reservedColumns = []string{"No", "y"}
+
+// columnRegistry: 登録した列の Key と Label が、どこから来た列のものか
+type columnRegistry struct {
+	keys   map[string]string
+	labels map[string]string
+	errs   []error
+}
+
+func newColumnRegistry() *columnRegistry {
+	r := &columnRegistry{keys: map[string]string{}, labels: map[string]string{}}
+	for _, name := range reservedColumns {
+		r.keys[name] = "the table column " + name
+		r.labels[name] = "the table column " + name
+	}
+	return r
+}
+
+// add: source（例: "param", "aux of the objective"）の列を登録する
+func (r *columnRegistry) add(source string, c Column) {
+	what := fmt.Sprintf("%s %q", source, c.Key)
+	if prev, ok := r.keys[c.Key]; ok {
+		r.errs = append(r.errs, fmt.Errorf("column key %q: %s collides with %s", c.Key, what, prev))
+		if c.Label == "" || c.Label == c.Key {
+			return // 見出しの重なりは同じことなので書かない
+		}
+	} else {
+		r.keys[c.Key] = what
+	}
+	label := c.Label
+	if label == "" {
+		label = c.Key
+	}
+	if prev, ok := r.labels[label]; ok {
+		r.errs = append(r.errs, fmt.Errorf("column label %q: %s collides with %s", label, what, prev))
+		return
+	}
+	r.labels[label] = what
+}
+
+func (r *columnRegistry) addAll(source string, cols []Column) {
+	for _, c := range cols {
+		r.add(source, c)
+	}
+}
+
+// err: 重なりをまとめたエラー（なければ nil）
+func (r *columnRegistry) err() error {
+	if len(r.errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("output columns collide (rename the Key or Label):\n%w", errors.Join(r.errs...))
+}
+
+// checkColumns: cfg と目的関数 obj の出力列（engine.columns と同じ順）が重ならないか調べる
+func checkColumns(cfg Config, obj Objective) error {
+	r := newColumnRegistry()
+	r.addAll("param", paramColumns(cfg.Params))
+	r.addAll("derived param", derivedColumns(cfg.Derived))
+	r.addAll("aux of the objective", obj.Aux)
+	if cfg.Anneal.Enabled {
+		r.add("the anneal column", Column{Key: RefinedKey})
+	}
+	if cfg.YEpsilon > 0 {
+		r.add("the YEpsilon column", Column{Key: MarginalKey})
+	}
+	if cfg.Verify.Enabled {
+		r.add("the verify column", Column{Key: YErrKey})
+		r.add("the verify column", Column{Key: UnsureKey})
+	}
+	if cfg.NGDistance {
+		r.add("the NGDistance column", Column{Key: DistanceKey})
+	}
+	if len(cfg.Score) > 0 {
+		r.add("the Score column", Column{Key: ScoreKey})
+	}
+	r.addAll("output column", derivedColumns(cfg.OutputColumns))
+	return r.err()
+}
@@ -3,6 +3,7 @@ package main
 
 import (
 	"math"
+	"math/rand"
 	"time"
 )
 
@@ -24,11 +25,218 @@ type Config struct {
 	MaxNGSave  int
 	PrintEvery int64
 	Seed       int64
-	XLSXFile   string // "" なら保存しない
-	OKTSVFile  string // "" なら保存しない
-	NGTSVFile  string // "" なら保存しない
-	MaxPrint   int    // コンソールに表示する最大件数（0なら制限なし）
+	XLSX       OutputSpec  // xlsx 出力（Enabled が false なら保存しない）
+	XLSXLimit  XLSXLimit   // xlsx の OK / NG シートの大きさの上限（超えたら間引き、全件は tsv などに。ゼロ値なら 20 万行・500 万セル）
+	OKTSV      OutputSpec  // OK の tsv 出力
+	NGTSV      OutputSpec  // NG の tsv 出力
+	OKCSV      OutputSpec  // OK の csv 出力（既定は無効。tsv と同じ列をカンマ区切りで）
+	NGCSV      OutputSpec  // NG の csv 出力
+	CSV        CSVConfig   // csv の桁数と BOM
+	OKParquet  OutputSpec  // OK の Parquet 出力（既定は無効。parquet.go。数百万件を pandas・DuckDB で開く用）
+	NGParquet  OutputSpec  // NG の Parquet 出力
+	MaxPrint   int         // コンソールに表示する最大件数（0なら制限なし）
+	Page       int         // 保存したサンプルのうちコンソールに表示するページ（1 始まり。0 なら先頭から MaxPrint 件）
+	PageSize   int         // Page の 1 ページの件数（0 なら MaxPrint、それも 0 なら 50）
+	OnExisting ExistPolicy // 出力ファイルが既にある場合（Overwrite / ErrorIfExists / RenameWithSuffix / AppendToExisting）
+	StreamTSV  bool        // OK / NG の tsv を探索中に書き足す（stream.go）。MaxOKSave を大きくしてもメモリを使わない
 	F          func(x map[string]float64) float64
+
+	// 以前の版の出力ファイル名（非推奨。XLSX / OKTSV / NGTSV を使う）。以前の config_local.go をそのまま使えるように残している。
+	// 既定値（"result.xlsx" / "ok.tsv" / "ng.tsv"）から変えたときだけ、対応する OutputSpec に移す（"" なら無効にする。outfile.go）
+	XLSXFile, OKTSVFile, NGTSVFile string
+
+	// 名前で選ぶ組み込み目的関数（models.go）。"ss_pn" / "ss_eta" / "sp_pn" / "ps_pn" / "pp_pn" など。
+	// "" でなければ F / F2 / Objective の代わりに使う
+	Model string
+
+	// F を Go のプラグイン（-buildmode=plugin の .so）から読む（plugin.go。Linux / macOS のみ）
+	// "" でなければ F / F2 / Objective の代わりに使う
+	Plugin string
+
+	// F を WebAssembly のモジュールから読む（wasm.go）。eval(p1, ..., pn f64) -> f64 を公開する .wasm
+	// "" でなければ F / F2 / Objective の代わりに使う
+	WASM string
+
+	// F を外部のプログラムに計算させる（exec.go）。標準入出力で JSON の行をやりとりする
+	// 例: ExecConfig{Command: []string{"python3", "sim.py"}, Batch: 16}（Workers を Batch 以上に）
+	Exec ExecConfig
+
+	// F を式で書く（expr.go）。"" でなければ F / F2 / Objective の代わりに使う
+	// 例: "let w = 2*pi*f; 4*k^2*R1*R2*L1*L2*w^2 / ((R1*R2 + (w*L1 - 1/(w*C1))*(w*L2 - 1/(w*C2)) - w^2*k^2*L1*L2)^2 + ...)"
+	Expr string
+	// Expr の足し算・引き算の並びを誤差を補償して足す（Neumaier 法。桁の違う項が打ち消し合う式用。評価は遅くなる）
+	ExprCompensated bool
+
+	// F の代わりに params の順の []float64 を受け取る目的関数（slice.go）。nil でなければ F より優先
+	// 評価ごとに map を作らないので、軽い目的関数では速い。添字は ParamIndex で起動前に引いておく
+	F2 func(x []float64) float64
+
+	// 並列に評価するゴルーチンの数（parallel.go）。0 なら CPU 数、1 なら従来通り 1 つで順に評価する
+	// F は複数のゴルーチンから同時に呼ばれるので、外の変数に書き込まないこと
+	Workers int
+	// true なら i 番目の点の乱数を Seed と i だけから作り、番号順に記録する（Workers の数によらず同じ結果になる）
+	Deterministic bool
+
+	// 擬似乱数の元（rng.go）。"" / "xoshiro"（xoshiro256**。Go の版によらず同じ系列）/ "pcg"（PCG64）
+	// / "mathrand"（以前の版の既定）。xoshiro / pcg の並列のワーカーには同じ系列を Jump した重ならない部分を使う
+	RNG string
+	// 擬似乱数の元そのもの（Go から使うとき。nil でなければ RNG と Seed より優先し、1 ワーカーで動かす）
+	// ScriptedSource で決めた u を流せば、Go の版による乱数の違いによらず、どの点を引き、どう分類し、何を保存するかを確かめられる
+	Source rand.Source
+
+	// 進行状況表示の更新間隔（時間）。0 でなければ PrintEvery の代わりに使い、評価の速さによらず一定の間隔で表示する
+	ProgressInterval time.Duration
+	// 進行状況の表示ごとに時刻・反復数・件数・速さを書く tsv（progresslog.go。"" なら書かない）
+	// 例: "progress.tsv"。長い探索の後で、どこで速さが落ちたかを確かめる
+	ProgressLog string
+
+	// 途中結果を保存する間隔（0 なら保存しない）。出力ファイル名に ".partial" を付けて上書きする
+	AutosaveEvery time.Duration
+
+	// 再開用の状態を保存する間隔（checkpoint.go。0 なら保存しない）。Ctrl-C で止めたときにも保存する
+	// `go run . -resume checkpoint.gob` で続きから探索する。CheckpointFile が "" なら "checkpoint.gob"
+	CheckpointEvery time.Duration
+	CheckpointFile  string
+
+	// 実行の成果物（manifest・結果の JSON・保存したファイル・表示のログ）を 1 つの .tar.zst にまとめる（bundle.go）
+	// 例: OutputSpec{Enabled: true, File: "run_{date}_{time}.tar.zst"}
+	Bundle OutputSpec
+
+	// 実行ごとのディレクトリ（rundir.go）。"" でなければ OutDir/2026-02-19_1530_<RunTag>/ を作り、
+	// 出力（xlsx・tsv・config.json・checkpoint など）と表示のログ（log.txt）をその中に書く
+	OutDir string
+	RunTag string
+
+	// 実際に使った設定（範囲・seed・反復数・乱数・コマンドライン・プログラムの版）の JSON（provenance.go）
+	// 同じものを xlsx の Config シートと tsv の先頭の "# " の行にも書く
+	ConfigJSON OutputSpec
+
+	// 実行中の探索を操作する Unix ドメインソケット（control.go。"" なら開かない）
+	// `go run . control run.sock status` などで status / flush / save-now / set print-every N / stop を送る
+	ControlSocket string
+
+	// 最初の N 回の評価を、入力・途中の量・y・判定まで tsv に書く（evallog.go。手計算との照合用）
+	EvalLog EvalLogConfig
+
+	// 評価したサンプルを 1 行 1 件の JSON で書き足す（jsonl.go。File が "-" なら標準出力。jq などに流す用）
+	// 例: JSONLConfig{File: "samples.jsonl"}、保存した OK / NG だけなら Saved: true
+	JSONL JSONLConfig
+
+	// 実行・パラメータ・保存した OK / NG を足していく SQLite のデータベース（sqlite.go。"" なら書かない）
+	// 例: "results.db"。実行をまたいで SQL で問い合わせる（OnExisting を AppendToExisting にして足していく）。
+	// ".sql" で終わる名前なら SQL をそのファイルに書く。OutDir を使ってもその中には置かない
+	SQLite string
+
+	// 起動時に試し評価する点数（guard.go。0 なら 10_000、負なら行わない）。MaxIters がその 10 倍より多いときだけ行い、
+	// OK が 0 件なら大きく警告する
+	Pilot int
+
+	// 探索全体の見込み時間がこれより長ければ、MaxIters の 1% を試しに探索して OK の数・時間・出力の大きさを
+	// 見積もり、続けるかを聞く（pilot.go。0 なら 10 分、負なら聞かない）。-yes で聞かずに続ける
+	ConfirmAbove time.Duration
+
+	// 点列の生成方法。"random"（""）/ "sobol"（MaxIters は 2^32 − 1 まで）/ "lhs"（ラテン超方格、MaxIters 分割）
+	// / "grid"（ParamSpec.GridPoints の格子を全列挙）/ "mcmc"（OK の近くを集中的に探す）
+	SamplingMethod string
+	// 独自の Sampler（nil でなければ Search / SamplingMethod より優先）
+	Sampler Sampler
+
+	// 最適化型の探索モード（search.go）。"" なら SamplingMethod に従う。
+	// "cem"（交差エントロピー法）/ "cmaes"（|y − YTarget| を最小化）
+	// / "ga"（遺伝的アルゴリズム、YRange までの距離を最小化）
+	// / "gp"（ガウス過程の代理モデル。重い目的関数向け、MaxIters は数百程度に）
+	Search      string
+	CEM         CEMConfig
+	CMAES       CMAESConfig
+	GA          GAConfig
+	GP          GPConfig
+	YTarget     float64 // cmaes の目標値
+	MaxBestSave int     // 最適化型の探索で覚えておく上位件数（0 なら 10）
+
+	// OK 率の推定のばらつきを減らす（variance.go）
+	// Antithetic: u と 1 − u を対で使う（random / sobol / lhs のみ）
+	// CompareYRanges: YRange の候補を同じサンプルで判定して、OK 率とその差を標準誤差付きで表示する
+	Antithetic     bool
+	CompareYRanges []Range
+
+	// 設計パラメータと環境パラメータ（ParamSpec.Env）の入れ子の探索（scenario.go）
+	// 設計ごとに環境の組 Inner 通りで評価し、YRange に入る割合（歩留まり）が MinYield 以上なら OK
+	Scenario ScenarioConfig
+
+	// 多段探索（OK の範囲に絞り込みながら探索）。ゼロ値なら 1 段のみ
+	Zoom ZoomConfig
+
+	// y が YRange の端にあるときの扱い（boundary.go）。Inclusive（従来通り）/ Exclusive / Band
+	// Band のときは端から BoundaryBand 以内を NG にする
+	Boundary     BoundaryPolicy
+	BoundaryBand float64
+
+	// YRange の外側 YEpsilon 以内は "marginal" として別に数え、OK にする（MarginalNG なら NG のまま）
+	YEpsilon   float64
+	MarginalNG bool
+
+	// NG が YRange からどれだけ外れているか（幅で割った距離）を "dist" 列に書き、分布を表示する（distance.go）
+	NGDistance bool
+
+	// パラメータの範囲を層に分け、層ごと（と 2 つのパラメータの層の組ごと）の OK 率を表示する（strata.go）
+	Strata StrataConfig
+
+	// パラメータの事前分布の密度（prior.go。定数倍は不要）。指定すると、箱の中の OK 率に加えて
+	// 実際の条件の分布で重み付けした OK 率を示す。例: 位置ずれで k が N(0.3, 0.05²) に従うなら
+	// func(x map[string]float64) float64 { z := (Get(x, "k") - 0.3) / 0.05; return math.Exp(-z * z / 2) }
+	Prior func(x map[string]float64) float64
+
+	// 保存した OK サンプルを少しずらして評価し直し、浮動小数点誤差で NG になりうるものに印を付ける（verify.go）
+	Verify VerifyConfig
+
+	// 探索後、YRange のすぐ外の NG を焼きなましで動かして OK を救済する（anneal.go）
+	Anneal AnnealConfig
+
+	// 探索後に評価した点でランダムフォレストを学習し、パラメータの重要度を示す（importance.go）
+	Importance ImportanceConfig
+
+	// 探索後にパラメータの組ごとの交互作用を示す（interaction.go）。行列は InteractionTSV に保存できる
+	Interaction    InteractionConfig
+	InteractionTSV OutputSpec
+
+	// 探索後の推奨仕様（絞った範囲・代表値・確認探索）
+	Recommend RecommendConfig
+
+	// 重み付きの評価値（score.go）。保存する OK サンプルを score の上位にし、最適化型の探索モードも OK どうしを score で比べる
+	// 例: []ScoreTerm{{Key: "eta", Weight: 0.7}, {Key: "margin", Weight: 0.3}}
+	Score []ScoreTerm
+
+	// サンプル同士の距離（metric.go）。代表値のクラスタリングが使う。ゼロ値なら正規化した Euclidean 距離
+	// 例: Metric{Weights: map[string]float64{"f": 4, "C1": 0}, Log: []string{"C1"}}
+	Metric MetricConfig
+
+	// 派生パラメータ（derived.go）。サンプリングした値から計算して F に渡し、出力にも列として出す
+	// 例: {Key: "C1", Label: "C1 [nF]", DisplayScale: 1e9, Func: ResonantC("L1", 85e3)}
+	Derived []DerivedSpec
+
+	// 出力だけの列（derived.go）。保存したサンプルについて、補助出力まで含めた値から計算して最後の列に足す
+	// 例: {Key: "f0_1", Label: "f0_1 [kHz]", DisplayScale: 1e-3, Func: ResonantFreq("L1", "C1")}
+	OutputColumns []DerivedSpec
+
+	// 組み込み目的関数の途中の量（SSPN なら ω, term1, term2, A, B, num, den）を、保存したサンプルの
+	// 出力だけの列として足す（OutputColumns の前に置くので、OutputColumns の Func からも使える）
+	TermColumns bool
+
+	// パラメータどうしの相関（copula.go）。例: {A: "L1", B: "L2", Rho: 0.9}
+	Correlations []Correlation
+
+	// パラメータの組み合わせに対する制約（constraint.go）。派生パラメータまで計算した x で判定する
+	// 例: func(x map[string]float64) bool { return Get(x, "C2") <= Get(x, "C1") }
+	// 満たさない点は Constraint に従って引き直す（Resample）/ 直す（Repair）/ INVALID として数える（CountInvalid）
+	ParamConstraints []func(map[string]float64) bool
+	Constraint       ConstraintPolicy
+	ConstraintTries  int // Resample で 1 点を得るまでに引き直す回数の上限（0 なら 1000）
+
+	// 組み込み目的関数（objective.go）。nil でなければ F の代わりに使う
+	Objective *Objective
+
+	// 探索後・出力前に呼ばれる（nil なら何もしない）。error を返すと出力せずに終了
+	PostProcess func(Result) error
 }
 
 var LocalOverride func(*Config)
@@ -48,18 +256,30 @@ func DefaultConfig() Config {
 
 	maxPrint := 100
 
-	// 進行状況表示の更新間隔（多すぎると遅くなる）
-	printEvery := int64(200_000)
+	// 進行状況表示の更新間隔（多すぎると遅くなる）。AutoPrintEvery なら評価の速さに合わせて 0.5 秒ごと（progress.go）
+	printEvery := AutoPrintEvery
 
 	// 乱数 seed（実行時刻ベース）
 	seed := time.Now().UnixNano()
 
-	// xlsx 出力（空文字なら保存しない）
-	xlsxFile := "result.xlsx"
+	// xlsx 出力（Enabled: false なら保存しない）
+	// File には {seed} {date} {time} を書ける（例: "result_{date}_{time}.xlsx"）
+	xlsx := OutputSpec{Enabled: true, File: "result.xlsx"}
+
+	// tsv 出力（Enabled: false なら保存しない）
+	okTSV := OutputSpec{Enabled: true, File: "ok.tsv"}
+	ngTSV := OutputSpec{Enabled: true, File: "ng.tsv"}
+
+	// csv 出力（Enabled: false なら保存しない。CSV で桁数と BOM を決める）
+	okCSV := OutputSpec{Enabled: false, File: "ok.csv"}
+	ngCSV := OutputSpec{Enabled: false, File: "ng.csv"}
+
+	// Parquet 出力（Enabled: false なら保存しない）
+	okParquet := OutputSpec{Enabled: false, File: "ok.parquet"}
+	ngParquet := OutputSpec{Enabled: false, File: "ng.parquet"}
 
-	// tsv 出力（"" なら保存しない）
-	okTSVFile := "ok.tsv"
-	ngTSVFile := "ng.tsv"
+	// 実際に使った設定の JSON（Enabled: false なら保存しない）
+	configJSON := OutputSpec{Enabled: true, File: "config.json"}
 
 	// params に表示メタ（Label / DisplayScale）も持たせる。
 	// これにより output.go は params を走査するだけで列・単位変換が決まる（switch不要）。
@@ -122,9 +342,17 @@ func DefaultConfig() Config {
 		MaxNGSave:  maxNGSave,
 		PrintEvery: printEvery,
 		Seed:       seed,
-		XLSXFile:   xlsxFile,
-		OKTSVFile:  okTSVFile,
-		NGTSVFile:  ngTSVFile,
+		XLSX:       xlsx,
+		OKTSV:      okTSV,
+		NGTSV:      ngTSV,
+		XLSXFile:   legacyXLSXFile,
+		OKTSVFile:  legacyOKTSVFile,
+		NGTSVFile:  legacyNGTSVFile,
+		OKCSV:      okCSV,
+		NGCSV:      ngCSV,
+		OKParquet:  okParquet,
+		NGParquet:  ngParquet,
+		ConfigJSON: configJSON,
 		MaxPrint:   maxPrint,
 		F:          f,
 	}
@@ -132,6 +360,8 @@ func DefaultConfig() Config {
 	if LocalOverride != nil {
 		LocalOverride(&cfg)
 	}
+	migrateOutputFiles(&cfg)
+	cfg.Params = resolveParams(cfg.Params)
 
 	return cfg
 }
@@ -0,0 +1,302 @@
+// configfile.go
+// 探索の設定を YAML / TOML のファイルから読む（`go run . -config search.yaml`）
+//
+// Go のコードを書かずに探索を定義できるように、
+// パラメータ（key, label, min, max, scale, display-scale, sig-figs, decimal-places）・
+// 目的関数（組み込みの model。models.go / 式の expr / プラグインの plugin / WebAssembly の wasm / 外部プログラムの exec）・
+// YRange・回数・出力ファイル・重み付きの評価値（score。score.go）・サンプル同士の距離（metric。metric.go）をファイルに書ける。
+// 書いた項目だけ DefaultConfig（config_local.go を含む）の値を置き換え、
+// コマンドラインのフラグはさらにその上から上書きする。拡張子が .toml なら TOML、それ以外は YAML として読む。
+// model・expr・plugin・wasm・exec のどれも書かなければ目的関数（F / Objective）は Go のコードのままで、
+// params の key は目的関数が使う名前と合わせる。
+// 知らない項目があればエラーにする（綴りの間違いに気づけるように）。
+//
+//	params:
+//	  - {key: k, min: 0.01, max: 1}
+//	  - {key: f, label: "f [kHz]", min: 10e3, max: 100e3, scale: log, display-scale: 1e-3}
+//	  - {key: C1, min: 10nF, max: 100nF}   # 単位付き（label は "C1 [nF]"、display-scale は 1e9）
+//	expr: "k * sqrt(f / 1e5) * C1 / 47e-9"
+//	yrange: {min: 0.1, max: 0.5}
+//	iters: 1e7
+//	xlsx: result_{date}_{time}.xlsx
+//	ng-tsv: ""   # 保存しない
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig: 設定ファイルの中身（nil の項目は Config のまま）
+type fileConfig struct {
+	Params     []fileParam `yaml:"params" toml:"params"`
+	YRange     *Range      `yaml:"yrange" toml:"yrange"`
+	Model      *string     `yaml:"model" toml:"model"`
+	Plugin     *string     `yaml:"plugin" toml:"plugin"`
+	WASM       *string     `yaml:"wasm" toml:"wasm"`
+	Exec       []string    `yaml:"exec" toml:"exec"`
+	ExecBatch  *int        `yaml:"exec-batch" toml:"exec-batch"`
+	Expr       *string     `yaml:"expr" toml:"expr"`
+	ExprComp   *bool       `yaml:"expr-compensated" toml:"expr-compensated"`
+	Iters      *fileCount  `yaml:"iters" toml:"iters"`
+	Seed       *int64      `yaml:"seed" toml:"seed"`
+	OKSave     *int        `yaml:"ok-save" toml:"ok-save"`
+	NGSave     *int        `yaml:"ng-save" toml:"ng-save"`
+	XLSX       *string     `yaml:"xlsx" toml:"xlsx"`
+	XLSXRows   *int        `yaml:"xlsx-max-rows" toml:"xlsx-max-rows"`
+	XLSXCells  *int        `yaml:"xlsx-max-cells" toml:"xlsx-max-cells"`
+	OKTSV      *string     `yaml:"ok-tsv" toml:"ok-tsv"`
+	NGTSV      *string     `yaml:"ng-tsv" toml:"ng-tsv"`
+	OKCSV      *string     `yaml:"ok-csv" toml:"ok-csv"`
+	NGCSV      *string     `yaml:"ng-csv" toml:"ng-csv"`
+	OKParquet  *string     `yaml:"ok-parquet" toml:"ok-parquet"`
+	NGParquet  *string     `yaml:"ng-parquet" toml:"ng-parquet"`
+	CSVPrec    *int        `yaml:"csv-precision" toml:"csv-precision"`
+	CSVBOM     *bool       `yaml:"csv-bom" toml:"csv-bom"`
+	Bundle     *string     `yaml:"bundle" toml:"bundle"`
+	ConfigJSON *string     `yaml:"config-json" toml:"config-json"`
+	ProgLog    *string     `yaml:"progress-log" toml:"progress-log"`
+	JSONL      *string     `yaml:"jsonl" toml:"jsonl"`
+	JSONLSaved *bool       `yaml:"jsonl-saved" toml:"jsonl-saved"`
+	SQLite     *string     `yaml:"sqlite" toml:"sqlite"`
+	OutDir     *string     `yaml:"out-dir" toml:"out-dir"`
+	Tag        *string     `yaml:"tag" toml:"tag"`
+	OnExisting *string     `yaml:"on-existing" toml:"on-existing"`
+	Metric     *fileMetric `yaml:"metric" toml:"metric"`
+	Score      *string     `yaml:"score" toml:"score"`
+}
+
+// fileMetric: Config.Metric（metric.go）
+type fileMetric struct {
+	Kind    string             `yaml:"kind" toml:"kind"`
+	Weights map[string]float64 `yaml:"weights" toml:"weights"`
+	Log     []string           `yaml:"log" toml:"log"`
+	Linear  []string           `yaml:"linear" toml:"linear"`
+}
+
+// fileParam: ParamSpec のうちファイルに書ける項目（label は省略すると key、display-scale は 1、scale は linear）
+// min / max は "47nF" のように単位を付けてもよく、そのときは label の " [nF]" と display-scale を単位から決める（units.go）
+type fileParam struct {
+	Key           string   `yaml:"key" toml:"key"`
+	Label         string   `yaml:"label" toml:"label"`
+	Min           quantity `yaml:"min" toml:"min"`
+	Max           quantity `yaml:"max" toml:"max"`
+	Scale         string   `yaml:"scale" toml:"scale"`
+	DisplayScale  *float64 `yaml:"display-scale" toml:"display-scale"`
+	SigFigs       int      `yaml:"sig-figs" toml:"sig-figs"`
+	DecimalPlaces int      `yaml:"decimal-places" toml:"decimal-places"`
+}
+
+// fileCount: 回数を 1e7 や "10_000_000" のようにも書ける（-iters と同じ）
+type fileCount int64
+
+func (c *fileCount) UnmarshalYAML(n *yaml.Node) error {
+	return c.set(n.Value)
+}
+
+func (c *fileCount) UnmarshalTOML(v any) error {
+	return c.set(fmt.Sprint(v))
+}
+
+func (c *fileCount) set(v string) error {
+	var n int64
+	if err := (countFlag{&n}).Set(v); err != nil {
+		return err
+	}
+	*c = fileCount(n)
+	return nil
+}
+
+func (q *quantity) UnmarshalYAML(n *yaml.Node) error {
+	return q.set(n.Value)
+}
+
+func (q *quantity) UnmarshalTOML(v any) error {
+	return q.set(fmt.Sprint(v))
+}
+
+// flagArg: コマンドラインから -name の値を探す（-config・-preset は他のフラグより先に読むため、flag.Parse の前に見る）
+func flagArg(args []string, flagName string) string {
+	for i, a := range args {
+		if a == "--" || !strings.HasPrefix(a, "-") {
+			break
+		}
+		name, val, hasVal := strings.Cut(strings.TrimLeft(a, "-"), "=")
+		if name != flagName {
+			continue
+		}
+		if hasVal {
+			return val
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// loadConfigFile: 設定ファイルを読んで cfg に反映する
+func loadConfigFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var fc fileConfig
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		md, err := toml.Decode(string(data), &fc)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if un := md.Undecoded(); len(un) > 0 {
+			return fmt.Errorf("%s: unknown key %q", path, un[0].String())
+		}
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&fc); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if err := fc.apply(cfg); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// apply: ファイルに書いた項目だけ cfg を置き換える
+func (fc fileConfig) apply(cfg *Config) error {
+	if len(fc.Params) > 0 {
+		ps := make([]ParamSpec, len(fc.Params))
+		for j, fp := range fc.Params {
+			p, err := fp.spec()
+			if err != nil {
+				return fmt.Errorf("params[%d]: %w", j, err)
+			}
+			ps[j] = p
+		}
+		cfg.Params = resolveParams(ps)
+	}
+	if fc.YRange != nil {
+		cfg.YRange = *fc.YRange
+	}
+	if fc.Model != nil {
+		cfg.Model = *fc.Model
+	}
+	if fc.Plugin != nil {
+		cfg.Plugin = *fc.Plugin
+	}
+	if fc.WASM != nil {
+		cfg.WASM = *fc.WASM
+	}
+	if len(fc.Exec) > 0 {
+		cfg.Exec.Command = fc.Exec
+	}
+	if fc.ExecBatch != nil {
+		cfg.Exec.Batch = *fc.ExecBatch
+	}
+	if fc.Expr != nil {
+		cfg.Expr = *fc.Expr
+	}
+	if fc.ExprComp != nil {
+		cfg.ExprCompensated = *fc.ExprComp
+	}
+	if fc.Iters != nil {
+		cfg.MaxIters = int64(*fc.Iters)
+	}
+	if fc.Seed != nil {
+		cfg.Seed = *fc.Seed
+	}
+	if m := fc.Metric; m != nil {
+		cfg.Metric = MetricConfig{Kind: m.Kind, Weights: m.Weights, Log: m.Log, Linear: m.Linear}
+	}
+	if fc.Score != nil {
+		if err := (scoreFlag{&cfg.Score}).Set(*fc.Score); err != nil {
+			return err
+		}
+	}
+	if fc.OKSave != nil {
+		cfg.MaxOKSave = *fc.OKSave
+	}
+	if fc.NGSave != nil {
+		cfg.MaxNGSave = *fc.NGSave
+	}
+	for _, o := range []struct {
+		file *string
+		spec *OutputSpec
+	}{{fc.XLSX, &cfg.XLSX}, {fc.OKTSV, &cfg.OKTSV}, {fc.NGTSV, &cfg.NGTSV}, {fc.OKCSV, &cfg.OKCSV}, {fc.NGCSV, &cfg.NGCSV}, {fc.OKParquet, &cfg.OKParquet}, {fc.NGParquet, &cfg.NGParquet}, {fc.Bundle, &cfg.Bundle}, {fc.ConfigJSON, &cfg.ConfigJSON}} {
+		if o.file != nil {
+			outputFlag{o.spec}.Set(*o.file)
+		}
+	}
+	if fc.XLSXRows != nil {
+		cfg.XLSXLimit.MaxRows = *fc.XLSXRows
+	}
+	if fc.XLSXCells != nil {
+		cfg.XLSXLimit.MaxCells = *fc.XLSXCells
+	}
+	if fc.CSVPrec != nil {
+		cfg.CSV.Precision = *fc.CSVPrec
+	}
+	if fc.CSVBOM != nil {
+		cfg.CSV.BOM = *fc.CSVBOM
+	}
+	if fc.ProgLog != nil {
+		cfg.ProgressLog = *fc.ProgLog
+	}
+	if fc.JSONL != nil {
+		cfg.JSONL.File = *fc.JSONL
+	}
+	if fc.JSONLSaved != nil {
+		cfg.JSONL.Saved = *fc.JSONLSaved
+	}
+	if fc.SQLite != nil {
+		cfg.SQLite = *fc.SQLite
+	}
+	if fc.OutDir != nil {
+		cfg.OutDir = *fc.OutDir
+	}
+	if fc.Tag != nil {
+		cfg.RunTag = *fc.Tag
+	}
+	if fc.OnExisting != nil {
+		if err := (policyFlag{&cfg.OnExisting}).Set(*fc.OnExisting); err != nil {
+			return fmt.Errorf("on-existing: %w", err)
+		}
+	}
+	return nil
+}
+
+// spec: ParamSpec にする
+func (fp fileParam) spec() (ParamSpec, error) {
+	if fp.Key == "" {
+		return ParamSpec{}, fmt.Errorf("key is empty")
+	}
+	unit, err := paramUnit(fp.Min, fp.Max)
+	if err != nil {
+		return ParamSpec{}, fmt.Errorf("%s: %w", fp.Key, err)
+	}
+	p := ParamSpec{Key: fp.Key, Label: fp.Label, Min: fp.Min.V, Max: fp.Max.V, DisplayScale: unitScale(unit),
+		SigFigs: fp.SigFigs, DecimalPlaces: fp.DecimalPlaces}
+	if p.Label == "" {
+		p.Label = p.Key
+	}
+	if unit != "" && !strings.Contains(p.Label, "[") {
+		p.Label += " [" + unit + "]"
+	}
+	if fp.DisplayScale != nil {
+		p.DisplayScale = *fp.DisplayScale
+	}
+	if fp.Scale != "" {
+		if p.Scale, err = parseScale(fp.Scale); err != nil {
+			return ParamSpec{}, fmt.Errorf("%s: %w", fp.Key, err)
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,129 @@
+// constraint.go
+// パラメータの組み合わせに対する制約（Config.ParamConstraints）
+//
+// 「L1·C1 が共振の帯に入る」「C2 <= C1」のように、範囲だけでは表せない条件に使う。
+// 制約は派生パラメータ（Config.Derived）まで計算した x で判定し、満たさない点では目的関数を呼ばない。
+// 満たさない点の扱いは Config.Constraint で選ぶ：
+//   - Resample（既定）：捨てて引き直す。iters にも OK / NG にも数えない
+//   - Repair：直前に制約を満たした点との間を二分探索し、満たす側のいちばん近い点に直して評価する
+//   - CountInvalid：評価せずに INVALID として数える（iters には数え、OK / NG には数えない）
+//
+// 格子（FiniteSampler）は引き直すと同じ点をたどり直すことになるので、Resample でも INVALID として数える。
+
+package main
+
+import "fmt"
+
+type ConstraintPolicy int
+
+const (
+	Resample     ConstraintPolicy = iota // 捨てて引き直す
+	Repair                               // 制約を満たす点に寄せて評価する
+	CountInvalid                         // INVALID として数える
+)
+
+func (p ConstraintPolicy) String() string {
+	switch p {
+	case Resample:
+		return "resample"
+	case Repair:
+		return "repair"
+	case CountInvalid:
+		return "invalid"
+	default:
+		return fmt.Sprintf("ConstraintPolicy(%d)", int(p))
+	}
+}
+
+// constraintTries: 1 点を得るまでに引き直す回数の上限（0 なら 1000）
+func (c Config) constraintTries() int {
+	if c.ConstraintTries > 0 {
+		return c.ConstraintTries
+	}
+	return 1000
+}
+
+// repairSteps: Repair の二分探索の回数
+const repairSteps = 30
+
+// feasible: x がすべての制約を満たすか
+func feasible(x map[string]float64, cs []func(map[string]float64) bool) bool {
+	for _, c := range cs {
+		if !c(x) {
+			return false
+		}
+	}
+	return true
+}
+
+// draw: 制約の扱いに従って 1 点を作って評価する
+// 戻り値の Sample が Invalid なら、CountInvalid（または格子）として数える点。
+func (e *engine) draw(params []ParamSpec, u []float64) (Sample, error) {
+	policy := e.cfg.Constraint
+	if e.finite && policy == Resample {
+		policy = CountInvalid
+	}
+
+	for try := 0; ; try++ {
+		e.sampler.Next(u)
+		e.draws++
+		s, err := e.evaluate(params, u)
+		if err != nil {
+			return s, err
+		}
+		if s.Invalid && policy == Repair && e.anchor != nil {
+			s, err = e.repair(params, u)
+			if err != nil {
+				return s, err
+			}
+		}
+		if !s.Invalid {
+			if len(e.cfg.ParamConstraints) > 0 {
+				e.anchor = append(e.anchor[:0], u...)
+			}
+			return s, nil
+		}
+		if policy == CountInvalid {
+			return s, nil
+		}
+
+		// 引き直す（評価結果は探索モードには伝えて、同じあたりを避けられるようにする）
+		e.rejected++
+		if fb, ok := e.sampler.(FeedbackSampler); ok {
+			fb.Observe(u, s)
+		}
+		if try+1 >= e.cfg.constraintTries() {
+			return s, fmt.Errorf("param constraints: no feasible point in %d draws", try+1)
+		}
+	}
+}
+
+// repair: u を、制約を満たした直前の点 anchor との線分上で制約を満たすいちばん近い点に直して評価する
+func (e *engine) repair(params []ParamSpec, u []float64) (Sample, error) {
+	bad := append([]float64(nil), u...)
+	good := append([]float64(nil), e.anchor...)
+	mid := make([]float64, len(u))
+	var s Sample
+	for k := 0; k < repairSteps; k++ {
+		for j := range mid {
+			mid[j] = (good[j] + bad[j]) / 2
+		}
+		if !e.constrained(params, mid) {
+			copy(good, mid)
+		} else {
+			copy(bad, mid)
+		}
+	}
+	copy(u, good)
+	s, err := e.evaluate(params, u)
+	if err == nil && !s.Invalid {
+		e.repaired++
+	}
+	return s, err
+}
+
+// constrained: u の点が制約を満たさないか（目的関数は呼ばない）
+func (e *engine) constrained(params []ParamSpec, u []float64) bool {
+	vals, err := e.values(params, u)
+	return err != nil || !feasible(vals, e.cfg.ParamConstraints)
+}
@@ -0,0 +1,197 @@
+// control.go
+// 実行中の探索を外から操作する制御ソケット（Config.ControlSocket・-control）
+//
+// シグナルを送れない環境（Windows・スクリプトからの操作）でも長い探索を確かめたり止めたりできるように、
+// Unix ドメインソケットで 1 行 1 コマンドを受け付けて、結果を返す（Windows 10 以降も AF_UNIX で動く）。
+//   status             状態を返す（SIGQUIT と同じ。status.go）
+//   flush              書き足している tsv（StreamTSV）と評価の記録（EvalLog）をファイルに書き出す
+//   save-now           途中結果（.partial）と、CheckpointEvery があれば再開用の状態をすぐに保存する
+//   set print-every N  進行状況の表示間隔を変える（負なら速さに合わせる。progress.go）
+//   stop               Ctrl-C と同じく止めて、そこまでの結果を保存する
+// 送り方: `go run . control run.sock status`（nc があれば `echo status | nc -U run.sock` でもよい）。
+// stop 以外は探索ループが次の点を記録するときに実行するので、遅い目的関数では 1 回の評価の分だけ待つ。
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// controlReq: 探索ループで実行するコマンド
+type controlReq struct {
+	args  []string
+	reply chan string
+}
+
+// serveControl: path で制御ソケットを開く（stop は Ctrl-C と同じ止め方。戻り値で閉じる）
+func (e *engine) serveControl(path string, stop func()) (closeFn func(), err error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("control: %s exists and is not a socket", path)
+		}
+		os.Remove(path) // 前の実行が残したもの
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("control: %w", err)
+	}
+	e.control = make(chan controlReq, 16)
+	done := make(chan struct{})
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go e.handleControl(c, stop, done)
+		}
+	}()
+	return func() {
+		close(done)
+		ln.Close()
+		os.Remove(path)
+	}, nil
+}
+
+// handleControl: 1 つの接続のコマンドを順に実行する
+func (e *engine) handleControl(c net.Conn, stop func(), done <-chan struct{}) {
+	defer c.Close()
+	sc := bufio.NewScanner(c)
+	for sc.Scan() {
+		args := strings.Fields(sc.Text())
+		if len(args) == 0 {
+			continue
+		}
+		var out string
+		switch args[0] {
+		case "stop":
+			stop()
+			out = "ok: stopping\n"
+		case "help":
+			out = "commands: status, flush, save-now, set print-every N, stop\n"
+		default:
+			req := controlReq{args: args, reply: make(chan string, 1)}
+			out = "error: the search has finished\n"
+			select {
+			case e.control <- req:
+				atomic.StoreInt32(&e.controlDue, 1) // 入れてから立てる（探索ループは立っていれば取り出す）
+				select {
+				case out = <-req.reply:
+				case <-done:
+				}
+			case <-done:
+			}
+		}
+		if _, err := io.WriteString(c, out); err != nil {
+			return
+		}
+	}
+}
+
+// controlIfDue: 届いたコマンドを実行する（記録する goroutine から呼ぶ）
+func (e *engine) controlIfDue(n int64) {
+	if !atomic.CompareAndSwapInt32(&e.controlDue, 1, 0) {
+		return
+	}
+	for {
+		select {
+		case req := <-e.control:
+			req.reply <- e.runControl(n, req.args)
+		default:
+			return
+		}
+	}
+}
+
+// runControl: コマンドを 1 つ実行して、返す文字列を作る
+func (e *engine) runControl(n int64, args []string) string {
+	switch args[0] {
+	case "status":
+		return e.status(n)
+	case "flush":
+		if err := e.flushStreams(); err != nil {
+			return "error: " + err.Error() + "\n"
+		}
+		return "ok: flushed\n"
+	case "save-now":
+		var saved []string
+		if e.autosave != nil {
+			e.autosave(e.result())
+			saved = append(saved, "partial outputs")
+		}
+		if e.checkpoint != nil {
+			e.checkpoint(e.snapshot())
+			saved = append(saved, "checkpoint")
+		}
+		if len(saved) == 0 {
+			return "error: nothing to save\n"
+		}
+		return "ok: saved " + strings.Join(saved, ", ") + "\n"
+	case "set":
+		if len(args) != 3 || args[1] != "print-every" {
+			return "error: usage: set print-every N\n"
+		}
+		var every int64
+		if err := (countFlag{&every}).Set(args[2]); err != nil {
+			return "error: " + err.Error() + "\n"
+		}
+		e.every = every
+		e.progressNext, e.progressAt = 0, time.Time{}
+		return fmt.Sprintf("ok: print-every %d\n", every)
+	}
+	return fmt.Sprintf("error: unknown command %q (help for the list)\n", args[0])
+}
+
+// flushStreams: 書き足している tsv・評価の記録・進行状況の記録・JSON Lines をファイルに書き出す
+func (e *engine) flushStreams() error {
+	var errs []error
+	for _, t := range []*tsvStream{e.okStream, e.ngStream} {
+		if t != nil && t.f != nil {
+			t.w.Flush()
+			errs = append(errs, t.w.Error(), t.f.Sync())
+		}
+	}
+	if l := e.evalLog; l != nil && l.f != nil {
+		l.w.Flush()
+		errs = append(errs, l.w.Error(), l.f.Sync())
+	}
+	if l := e.progLog; l != nil && l.f != nil {
+		errs = append(errs, l.w.Flush(), l.f.Sync())
+	}
+	if j := e.jsonl; j != nil && j.w != nil {
+		errs = append(errs, j.flush())
+	}
+	return errors.Join(errs...)
+}
+
+// runControlClient: `control <socket> <command...>` で制御ソケットにコマンドを送り、返事を表示する
+func runControlClient(args []string) int {
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: control <socket> <command> (status / flush / save-now / set print-every N / stop)")
+		return ExitConfigError
+	}
+	c, err := net.Dial("unix", args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return ExitError
+	}
+	defer c.Close()
+	if _, err := io.WriteString(c, strings.Join(args[1:], " ")+"\n"); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return ExitError
+	}
+	c.(*net.UnixConn).CloseWrite()
+	if _, err := io.Copy(os.Stdout, c); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return ExitError
+	}
+	return ExitOK
+}
@@ -0,0 +1,97 @@
+// copula.go
+// 相関のあるパラメータ（Config.Correlations）
+//
+// 同じ仕様のコイルの L1 と L2 のように、一緒にばらつく部品を表すためのもの。
+// ガウスコピュラで実装しているので、各パラメータの分布（Scale / Dist / Values など）はそのままで、
+// 順位の相関だけが付く：Sampler が作った u を z = Φ⁻¹(u) に直し、相関行列のコレスキー分解 L で
+// z' = L·z として、u' = Φ(z') を各パラメータの分布で値に変換する。
+
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// Correlation: パラメータ A と B の相関（ガウスコピュラの相関係数。-1 < Rho < 1）
+type Correlation struct {
+	A, B string
+	Rho  float64
+}
+
+// copula: 相関行列のコレスキー分解（指定のない組は相関 0）
+type copula struct {
+	L   [][]float64
+	buf []float64
+}
+
+// newCopula: params の順で相関行列を作る（相関がなければ nil）
+func newCopula(params []ParamSpec, cs []Correlation) (*copula, error) {
+	if len(cs) == 0 {
+		return nil, nil
+	}
+	idx := map[string]int{}
+	for j, p := range params {
+		idx[p.Key] = j
+	}
+	d := len(params)
+	R := make([][]float64, d)
+	for i := range R {
+		R[i] = make([]float64, d)
+		R[i][i] = 1
+	}
+	for _, c := range cs {
+		a, okA := idx[c.A]
+		b, okB := idx[c.B]
+		if !okA || !okB {
+			return nil, fmt.Errorf("correlation %s-%s: unknown param", c.A, c.B)
+		}
+		if a == b || !(c.Rho > -1 && c.Rho < 1) {
+			return nil, fmt.Errorf("correlation %s-%s: requires two different params and -1<Rho<1 (got %g)", c.A, c.B, c.Rho)
+		}
+		R[a][b], R[b][a] = c.Rho, c.Rho
+	}
+	L, ok := cholesky(R)
+	if !ok {
+		return nil, fmt.Errorf("correlations: matrix is not positive definite (inconsistent Rho values)")
+	}
+	return &copula{L: L, buf: make([]float64, d)}, nil
+}
+
+// clampUnit: Φ⁻¹ が ±Inf にならないよう (0,1) に収める
+func clampUnit(u float64) float64 {
+	return math.Max(1e-16, math.Min(math.Nextafter(1, 0), u))
+}
+
+// apply: 独立な u を相関のある u' に変換する（戻り値は次の呼び出しまで有効）
+func (c *copula) apply(u []float64) []float64 {
+	z := c.buf
+	for i := range u {
+		z[i] = normQuantile(clampUnit(u[i]))
+	}
+	// L は下三角なので、下の行から書き換えれば z をそのまま使える
+	for i := len(u) - 1; i >= 0; i-- {
+		var s float64
+		for k := 0; k <= i; k++ {
+			s += c.L[i][k] * z[k]
+		}
+		z[i] = s
+	}
+	for i := range z {
+		z[i] = math.Min(normCDF(z[i]), math.Nextafter(1, 0))
+	}
+	return z
+}
+
+// inverse: apply の逆（相関のある u' を独立な u に戻す）
+func (c *copula) inverse(u []float64) []float64 {
+	z := make([]float64, len(u))
+	for i := range u {
+		z[i] = normQuantile(clampUnit(u[i]))
+	}
+	x := forwardSub(c.L, z)
+	for i := range x {
+		x[i] = math.Min(normCDF(x[i]), math.Nextafter(1, 0))
+	}
+	return x
+}
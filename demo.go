@@ -0,0 +1,123 @@
+// demo.go
+// OK 率が式で分かっている確認用の目的関数（`go run . -objective demo.sphere`）
+//
+// 探索モードや OK 率の推定が正しく動いているかを、厳密な答えと比べて確かめるためのもの。
+// 指定すると Params / YRange / 目的関数を置き換え、パラメータのキーに依存する設定
+// （Derived / Correlations / ParamConstraints / Prior / Strata など）は外す。
+// 表示の最後に厳密な OK 率と推定値の差を標準誤差の何倍かで示す（独立な点なら |z| が 3 を超えることはまれ）。
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// demo: 確認用の目的関数 1 つ分
+type demo struct {
+	About  string
+	Params []ParamSpec
+	YRange Range
+	F      func(x map[string]float64) float64
+	Exact  float64 // 厳密な OK 率
+}
+
+// demoAxes: [lo, hi] の Linear な x1..xd
+func demoAxes(d int, lo, hi float64) []ParamSpec {
+	ps := make([]ParamSpec, d)
+	for j := range ps {
+		key := fmt.Sprintf("x%d", j+1)
+		ps[j] = ParamSpec{Key: key, Label: key, Min: lo, Max: hi, Scale: Linear, DisplayScale: 1}
+	}
+	return ps
+}
+
+// sumSq: x1..xd の 2 乗和
+func sumSq(d int) func(x map[string]float64) float64 {
+	return func(x map[string]float64) float64 {
+		var s float64
+		for j := 1; j <= d; j++ {
+			v := Get(x, fmt.Sprintf("x%d", j))
+			s += v * v
+		}
+		return s
+	}
+}
+
+var demos = map[string]demo{
+	"demo.sphere": {
+		About:  "x in [-1,1]^3, y = |x|^2, OK if y <= 1 (unit ball / cube = π/6)",
+		Params: demoAxes(3, -1, 1),
+		YRange: Range{Min: 0, Max: 1},
+		F:      sumSq(3),
+		Exact:  math.Pi / 6,
+	},
+	"demo.shell": {
+		About:  "x in [-1,1]^2, y = |x|^2, OK if 0.25 <= y <= 1 (annulus / square = 3π/16)",
+		Params: demoAxes(2, -1, 1),
+		YRange: Range{Min: 0.25, Max: 1},
+		F:      sumSq(2),
+		Exact:  3 * math.Pi / 16,
+	},
+	"demo.linear": {
+		About:  "x in [0,1]^2, y = x1 + x2, OK if y <= 0.5 (triangle = 1/8)",
+		Params: demoAxes(2, 0, 1),
+		YRange: Range{Min: 0, Max: 0.5},
+		F: func(x map[string]float64) float64 {
+			return Get(x, "x1") + Get(x, "x2")
+		},
+		Exact: 0.125,
+	},
+	"demo.log": {
+		About:  "x in [1,100] on Log scale, y = x, OK if y <= 10 (half of the decades = 1/2)",
+		Params: []ParamSpec{{Key: "x", Label: "x", Min: 1, Max: 100, Scale: Log, DisplayScale: 1}},
+		YRange: Range{Min: 1, Max: 10},
+		F:      func(x map[string]float64) float64 { return Get(x, "x") },
+		Exact:  0.5,
+	},
+}
+
+// demoNames: 使える名前（表示用に並べたもの）
+func demoNames() string {
+	names := make([]string, 0, len(demos))
+	for k := range demos {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// applyDemo: cfg を確認用の目的関数に置き換える
+func applyDemo(cfg *Config, name string) (demo, error) {
+	d, ok := demos[name]
+	if !ok {
+		return d, fmt.Errorf("unknown objective %q (available: %s)", name, demoNames())
+	}
+	cfg.Params = d.Params
+	cfg.YRange = d.YRange
+	cfg.F = d.F
+	cfg.Objective = nil
+	cfg.Derived = nil
+	cfg.Correlations = nil
+	cfg.ParamConstraints = nil
+	cfg.Prior = nil
+	cfg.Strata = StrataConfig{Enabled: cfg.Strata.Enabled, Bins: cfg.Strata.Bins}
+	cfg.YEpsilon = 0
+	return d, nil
+}
+
+// PrintDemoCheck: 厳密な OK 率と推定値を比べる
+func PrintDemoCheck(name string, d demo, res Result) {
+	fmt.Printf("=== %s: %s ===\n", name, d.About)
+	if res.Iters == 0 {
+		fmt.Println("(no samples)")
+		fmt.Println()
+		return
+	}
+	p := float64(res.OKHits) / float64(res.Iters)
+	se := math.Sqrt(d.Exact * (1 - d.Exact) / float64(res.Iters))
+	fmt.Printf("exact=%s  estimate=%s  diff=%s  z=%s\n", fmt4(d.Exact), fmt4(p), fmt4(p-d.Exact), fmt4((p-d.Exact)/se))
+	fmt.Println()
+}
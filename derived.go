@@ -0,0 +1,95 @@
+// derived.go
+// 派生パラメータ（Config.Derived）：サンプリングした値から計算する値
+//
+// 共振に合わせる C1 = 1/(ω₀² L1) のように、独立に選ばずに他のパラメータから決まる値に使う。
+// 目的関数を呼ぶ前に Derived の順に計算して x に入れるので、F からは普通のパラメータと同じに見え、
+// 出力にも params の後ろの列として出る。後の DerivedSpec は前の DerivedSpec の値も使える。
+//
+// Config.OutputColumns は出力だけの列：保存したサンプルについてだけ、目的関数の補助出力まで含めた
+// 値から計算し、すべての出力（表示・xlsx・tsv・JSON）の最後の列に足す。共振周波数や kQ のように、
+// 毎回表計算ソフトで式を入れ直していた値に使う。
+
+package main
+
+import "math"
+
+// DerivedSpec: 派生パラメータ 1 つ分
+type DerivedSpec struct {
+	Key          string
+	Label        string
+	DisplayScale float64
+	Func         func(x map[string]float64) float64
+}
+
+// derivedColumns: 派生パラメータの出力列
+func derivedColumns(ds []DerivedSpec) []Column {
+	cols := make([]Column, 0, len(ds))
+	for _, d := range ds {
+		cols = append(cols, Column{Key: d.Key, Label: d.Label, DisplayScale: d.DisplayScale})
+	}
+	return cols
+}
+
+// termColumns: 目的関数の途中の量（Objective.Terms）を出力だけの列にする（Config.TermColumns）
+// 列ごとに Terms を呼ぶが、計算するのは保存したサンプルだけなので気にしない
+func termColumns(obj Objective) []DerivedSpec {
+	if obj.Terms == nil {
+		panic("TermColumns needs an objective with Terms (SSPN / RectifierDCLoad)")
+	}
+	ds := make([]DerivedSpec, 0, len(obj.TermCols))
+	for _, c := range obj.TermCols {
+		key := c.Key
+		ds = append(ds, DerivedSpec{
+			Key: c.Key, Label: c.Label, DisplayScale: c.DisplayScale,
+			Func: func(x map[string]float64) float64 { return obj.Terms(x)[key] },
+		})
+	}
+	return ds
+}
+
+// outputValues: 保存したサンプルに出力だけの列の値を書き込む（書き込み済みでも計算し直す）
+func outputValues(ds []DerivedSpec, lists ...[]Sample) {
+	if len(ds) == 0 {
+		return
+	}
+	for _, list := range lists {
+		for _, s := range list {
+			for _, d := range ds {
+				s.Values[d.Key] = d.Func(s.Values)
+			}
+		}
+	}
+}
+
+// ResonantFreq: L（キー lKey）と C（キー cKey）の共振周波数 1/(2π√(LC)) [Hz]
+// 例: {Key: "f0_1", Label: "f0_1 [kHz]", DisplayScale: 1e-3, Func: ResonantFreq("L1", "C1")}
+func ResonantFreq(lKey, cKey string) func(x map[string]float64) float64 {
+	return func(x map[string]float64) float64 {
+		return 1 / (2 * math.Pi * math.Sqrt(Get(x, lKey)*Get(x, cKey)))
+	}
+}
+
+// CoilQ: 周波数 "f" でのコイルの Q = ωL/R（L はキー lKey、R はキー rKey）
+func CoilQ(lKey, rKey string) func(x map[string]float64) float64 {
+	return func(x map[string]float64) float64 {
+		return 2 * math.Pi * Get(x, "f") * Get(x, lKey) / Get(x, rKey)
+	}
+}
+
+// KQ: 性能指数 k·√(Q1·Q2)（Q は CoilQ。キーは k, L1, R1, L2, R2 の順）
+// 例: {Key: "kQ", Label: "kQ", DisplayScale: 1, Func: KQ("k", "L1", "R1", "L2", "R2")}
+func KQ(kKey, l1, r1, l2, r2 string) func(x map[string]float64) float64 {
+	q1, q2 := CoilQ(l1, r1), CoilQ(l2, r2)
+	return func(x map[string]float64) float64 {
+		return Get(x, kKey) * math.Sqrt(q1(x)*q2(x))
+	}
+}
+
+// ResonantC: f0 [Hz] で L（キー lKey）と共振する C = 1/(ω₀² L)
+// 例: {Key: "C1", Label: "C1 [nF]", DisplayScale: 1e9, Func: ResonantC("L1", 85e3)}
+func ResonantC(lKey string, f0 float64) func(x map[string]float64) float64 {
+	w0 := 2 * math.Pi * f0
+	return func(x map[string]float64) float64 {
+		return 1 / (w0 * w0 * Get(x, lKey))
+	}
+}
@@ -0,0 +1,212 @@
+// dist.go
+// パラメータごとの確率分布（ParamSpec.Dist）
+//
+// 一様（Linear / Log）以外に、正規・対数正規・三角・ベータ分布で値を選べる。
+// どれも [Min, Max] に切り詰めた分布で、Sampler が作った u ∈ [0,1) を逆累積分布関数で値に変換する。
+// そのため Sobol や LHS などの点列もそのまま使え、分布の密なところに点が多く集まる。
+
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+type DistKind int
+
+const (
+	Uniform    DistKind = iota // 一様（ParamSpec.Scale に従う。従来通り）
+	Normal                     // 正規分布 N(Mu, Sigma²)
+	LogNormal                  // 対数正規分布（ln x が N(Mu, Sigma²)）
+	Triangular                 // 三角分布（[Min, Max]、最頻値 Mode）
+	Beta                       // ベータ分布 Beta(A, B) を [Min, Max] に伸ばしたもの
+)
+
+// Dist: パラメータの分布（ゼロ値なら一様で、Scale に従う）。Uniform 以外では Scale は使わない
+type Dist struct {
+	Kind  DistKind
+	Mu    float64 // Normal: 平均（元単位）、LogNormal: ln x の平均
+	Sigma float64 // Normal: 標準偏差（元単位）、LogNormal: ln x の標準偏差
+	Mode  float64 // Triangular: 最頻値（元単位）
+	A, B  float64 // Beta: 形状パラメータ
+}
+
+// distQuantile: u ∈ [0,1) を p.Dist を [Min, Max] に切り詰めた分布の値に変換する
+func distQuantile(u float64, p ParamSpec) (float64, error) {
+	d := p.Dist
+	lo, hi := p.Min, p.Max
+	if lo == hi {
+		return lo, nil
+	}
+	switch d.Kind {
+	case Normal:
+		if d.Sigma <= 0 {
+			return 0, fmt.Errorf("param %s: normal dist requires Sigma>0", p.Key)
+		}
+		return truncNormal(u, d.Mu, d.Sigma, lo, hi), nil
+	case LogNormal:
+		if d.Sigma <= 0 || lo <= 0 {
+			return 0, fmt.Errorf("param %s: lognormal dist requires Sigma>0 and Min>0", p.Key)
+		}
+		return math.Exp(truncNormal(u, d.Mu, d.Sigma, math.Log(lo), math.Log(hi))), nil
+	case Triangular:
+		if d.Mode < lo || d.Mode > hi {
+			return 0, fmt.Errorf("param %s: triangular dist requires Min<=Mode<=Max", p.Key)
+		}
+		c := (d.Mode - lo) / (hi - lo)
+		if u < c {
+			return lo + (hi-lo)*math.Sqrt(u*c), nil
+		}
+		return hi - (hi-lo)*math.Sqrt((1-u)*(1-c)), nil
+	case Beta:
+		if d.A <= 0 || d.B <= 0 {
+			return 0, fmt.Errorf("param %s: beta dist requires A>0 and B>0", p.Key)
+		}
+		return lo + (hi-lo)*betaQuantile(u, d.A, d.B), nil
+	default:
+		return 0, fmt.Errorf("param %s: unknown dist", p.Key)
+	}
+}
+
+// distCDF: distQuantile の逆（値 v を u ∈ [0,1] に戻す）
+func distCDF(v float64, p ParamSpec) float64 {
+	d := p.Dist
+	lo, hi := p.Min, p.Max
+	if lo == hi {
+		return 0
+	}
+	v = math.Max(lo, math.Min(hi, v))
+	switch d.Kind {
+	case Normal:
+		return truncNormalCDF(v, d.Mu, d.Sigma, lo, hi)
+	case LogNormal:
+		return truncNormalCDF(math.Log(v), d.Mu, d.Sigma, math.Log(lo), math.Log(hi))
+	case Triangular:
+		c := (d.Mode - lo) / (hi - lo)
+		t := (v - lo) / (hi - lo)
+		if t < c {
+			return t * t / c
+		}
+		return 1 - (1-t)*(1-t)/(1-c)
+	case Beta:
+		return betaInc((v-lo)/(hi-lo), d.A, d.B)
+	default:
+		return normalize(p, v)
+	}
+}
+
+// paramCDF: 値 v を sampleOne の逆で u ∈ [0,1] に戻す（Type / Values / Dist があればそれに従う）
+func paramCDF(p ParamSpec, v float64) float64 {
+	if p.Type != Real {
+		return typedCDF(v, p)
+	}
+	if len(p.Values) > 0 {
+		return valueCDF(v, p)
+	}
+	if p.Dist.Kind != Uniform {
+		return distCDF(v, p)
+	}
+	return normalize(p, v)
+}
+
+// truncNormal: [lo, hi] に切り詰めた N(mu, sigma²) の u 分位点
+func truncNormal(u, mu, sigma, lo, hi float64) float64 {
+	a := normCDF((lo - mu) / sigma)
+	b := normCDF((hi - mu) / sigma)
+	if !(b > a) {
+		// 範囲が分布の裾の遠く（確率がほぼ 0）なら、近い端に寄せる
+		return math.Max(lo, math.Min(hi, mu))
+	}
+	x := mu + sigma*normQuantile(a+u*(b-a))
+	return math.Max(lo, math.Min(hi, x))
+}
+
+func truncNormalCDF(x, mu, sigma, lo, hi float64) float64 {
+	a := normCDF((lo - mu) / sigma)
+	b := normCDF((hi - mu) / sigma)
+	if !(b > a) {
+		return 0
+	}
+	return (normCDF((x-mu)/sigma) - a) / (b - a)
+}
+
+// normQuantile: 標準正規分布の p 分位点
+func normQuantile(p float64) float64 {
+	return math.Sqrt2 * math.Erfinv(2*p-1)
+}
+
+// betaQuantile: Beta(a, b) の u 分位点（二分法）
+func betaQuantile(u, a, b float64) float64 {
+	lo, hi := 0.0, 1.0
+	for i := 0; i < 60; i++ {
+		mid := 0.5 * (lo + hi)
+		if betaInc(mid, a, b) < u {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return 0.5 * (lo + hi)
+}
+
+// betaInc: 正則化不完全ベータ関数 I_x(a, b)（連分数展開）
+func betaInc(x, a, b float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	lab, _ := math.Lgamma(a + b)
+	front := math.Exp(lab - la - lb + a*math.Log(x) + b*math.Log(1-x))
+	// 連分数は x < (a+1)/(a+b+2) で速く収束するので、反対側は対称性を使う
+	if x < (a+1)/(a+b+2) {
+		return front * betaCF(x, a, b) / a
+	}
+	return 1 - front*betaCF(1-x, b, a)/b
+}
+
+// betaCF: 不完全ベータ関数の連分数（修正 Lentz 法）
+func betaCF(x, a, b float64) float64 {
+	const tiny = 1e-300
+	qab, qap, qam := a+b, a+1, a-1
+	c, d := 1.0, 1-qab*x/qap
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1; m <= 300; m++ {
+		fm := float64(m)
+		aa := fm * (b - fm) * x / ((qam + 2*fm) * (a + 2*fm))
+		d = 1 + aa*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+
+		aa = -(a + fm) * (qab + fm) * x / ((a + 2*fm) * (qap + 2*fm))
+		d = 1 + aa*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < 1e-14 {
+			break
+		}
+	}
+	return h
+}
@@ -0,0 +1,109 @@
+// distance.go
+// NG サンプルの「YRange からどれだけ外れているか」（Config.NGDistance）
+//
+// max(YRange.Min − y, y − YRange.Max) を YRange の幅で割ったものを DistanceKey 列に書き（OK は 0）、
+// すべての NG の分布をまとめて表示する。0.1 程度の NG が多ければ仕様はあと少しで達成でき、
+// 1 を大きく超えるものばかりなら仕様か探索範囲を見直したほうがよい。
+
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/ichijohodaka/wpt-parameter-search2/stats"
+)
+
+// DistanceKey: YRange までの距離（幅で割ったもの）の列のキー
+const DistanceKey = "dist"
+
+// DistanceStats: NG の距離の分布
+type DistanceStats struct {
+	N       int64   // 距離が有限の NG の数
+	Invalid int64   // y が NaN / ±Inf の NG の数
+	Min     float64 // 以下は距離が有限の NG についての値
+	Mean    float64
+	Median  float64
+	P90     float64
+	Max     float64
+	Within  []DistanceBand // 距離がしきい値以下の NG の数
+}
+
+// DistanceBand: 距離が Limit 以下の NG の数
+type DistanceBand struct {
+	Limit float64
+	Count int64
+}
+
+// distanceLimits: 表示するしきい値
+var distanceLimits = []float64{0.01, 0.1, 1}
+
+// normDistance: y の YRange までの距離を幅で割ったもの（幅が 0 なら割らない。NaN / ±Inf は +Inf）
+func normDistance(y float64, r Range) float64 {
+	d := rangeDistance(y, r)
+	if w := r.Max - r.Min; w > 0 {
+		d /= w
+	}
+	return d
+}
+
+// distanceAcc: NG の距離を集計する
+type distanceAcc struct {
+	m       stats.Moments
+	q50     *stats.Quantile
+	q90     *stats.Quantile
+	within  []int64
+	invalid int64
+}
+
+func newDistanceAcc() *distanceAcc {
+	return &distanceAcc{q50: stats.NewQuantile(0.5), q90: stats.NewQuantile(0.9), within: make([]int64, len(distanceLimits))}
+}
+
+func (a *distanceAcc) add(d float64) {
+	if math.IsInf(d, 0) || math.IsNaN(d) {
+		a.invalid++
+		return
+	}
+	a.m.Add(d)
+	a.q50.Add(d)
+	a.q90.Add(d)
+	for i, l := range distanceLimits {
+		if d <= l {
+			a.within[i]++
+		}
+	}
+}
+
+func (a *distanceAcc) result() *DistanceStats {
+	ds := &DistanceStats{
+		N: a.m.N, Invalid: a.invalid,
+		Min: a.m.Min(), Mean: a.m.Mean(), Median: a.q50.Value(), P90: a.q90.Value(), Max: a.m.Max(),
+	}
+	for i, l := range distanceLimits {
+		ds.Within = append(ds.Within, DistanceBand{Limit: l, Count: a.within[i]})
+	}
+	return ds
+}
+
+// PrintDistance: NG の距離の分布を表示する
+func PrintDistance(ds *DistanceStats) {
+	fmt.Println("=== NG distance to yRange (÷ width) ===")
+	if ds == nil || ds.N+ds.Invalid == 0 {
+		fmt.Println("(no NG samples)")
+		fmt.Println()
+		return
+	}
+	if ds.N > 0 {
+		fmt.Printf("min=%s  median=%s  p90=%s  mean=%s  max=%s\n",
+			fmt4(ds.Min), fmt4(ds.Median), fmt4(ds.P90), fmt4(ds.Mean), fmt4(ds.Max))
+	}
+	total := ds.N + ds.Invalid
+	for _, b := range ds.Within {
+		fmt.Printf("dist <= %-5g %12d  (%s)\n", b.Limit, b.Count, fmt4(float64(b.Count)/float64(total)))
+	}
+	if ds.Invalid > 0 {
+		fmt.Printf("y NaN/Inf   %12d  (%s)\n", ds.Invalid, fmt4(float64(ds.Invalid)/float64(total)))
+	}
+	fmt.Println()
+}
@@ -0,0 +1,505 @@
+// distributed.go
+// 複数のマシンで分担して探索する（`go run . worker` と `go run . -remote host:port,...`）
+//
+// 研究室の PC を集めて 10 億点規模の探索をするためのもの。
+// 各マシンで同じ設定の `go run . worker -listen :7070` を起動しておき、1 台で
+// `go run . -remote pc1:7070,pc2:7070` とすると、その 1 台（コーディネータ）が番号 0 .. MaxIters を
+// 区間（shard）に分けて空いているワーカーに順に渡し、数と保存サンプルを集めて、いつもの Summary / OK / NG を出す。
+//
+// i 番目の点の乱数は Seed と i だけから作る（Deterministic と同じ）ので、区間の分け方やワーカーの数によらず、
+// 1 台で Deterministic: true にして探索したのと同じ結果になる。保存サンプルは区間の番号順につなぐ。
+// ワーカーとの通信は標準ライブラリの net/rpc（gob）で行う（gRPC のようにコード生成や追加の依存は要らない）。
+// ワーカーの設定がコーディネータと違う（範囲・YRange・MaxIters・目的関数の Model / Expr / Plugin / WASM / Exec など。
+// checkpointKey）ときは接続時にエラーにする。Go で書いた F は比べられないので、ワーカーも同じソースからビルドしたものを使うこと。
+//
+// 評価の結果を使う探索・多段探索・重み付けや層ごとの集計など、区間ごとの結果をつなげないものとは組み合わせられない。
+// 途中で応答しなくなったワーカーの区間は、ほかのワーカーに渡し直し、そのワーカーは外す。応答しないとみなすのは
+//   - 接続が切れたとき
+//   - 区間の評価中に distHeartbeat ごとに送る Ping に distHeartbeatWait のあいだ返事がないとき（マシンが止まった・ネットワークが切れた）
+//   - 1 区間に -shard-timeout より長くかかったとき（0 なら終わった区間のうち最も遅いものの distTimeoutFactor 倍で、
+//     distMinTimeout より短くはしない。負なら時間では切らない。F が返ってこないときなど）
+// Ctrl-C で止めたときは、ワーカーに Cancel を送って評価中の区間を止めさせる。
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/rpc"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ShardArgs: ワーカーに渡す区間（番号 Start .. End−1 を評価する）
+type ShardArgs struct {
+	Key        string // 設定の照合用（checkpointKey）
+	Seed       int64
+	Start, End int64
+	MaxOKSave  int
+	MaxNGSave  int
+}
+
+// ShardReply: 区間の結果（保存サンプルは区間の中で番号順）
+type ShardReply struct {
+	Iters, OKHits, NGHits      int64
+	BoundaryHits, MarginalHits int64
+	InvalidHits                int64
+	Rejected, Repaired         int64
+	OK, NG                     []Sample
+}
+
+// 応答しないワーカーを見つけるための間隔（distributed.go の先頭のコメント）
+const (
+	distHeartbeat     = 10 * time.Second
+	distHeartbeatWait = 30 * time.Second
+	distTimeoutFactor = 10
+	distMinTimeout    = time.Minute
+	distCancelWait    = 2 * time.Second
+)
+
+// CancelArgs: 止める区間（Key と Seed が同じ評価中の区間をすべて止める）
+type CancelArgs struct {
+	Key  string
+	Seed int64
+}
+
+// WorkerInfo: 接続時にワーカーが返す情報
+type WorkerInfo struct {
+	Key  string
+	CPUs int
+}
+
+// Worker: ワーカーの RPC（`go run . worker` で待ち受ける）
+type Worker struct {
+	cfg  Config
+	cpus int
+
+	mu      sync.Mutex
+	running map[*ShardArgs]context.CancelFunc // 評価中の区間（Cancel で止める）
+}
+
+// distributedConfig: 分担して探索するときの設定（番号ごとの乱数を使う）
+func distributedConfig(cfg Config) Config {
+	cfg.Deterministic = true
+	cfg.PrintEvery, cfg.ProgressInterval = 0, 0
+	cfg.AutosaveEvery, cfg.CheckpointEvery = 0, 0
+	cfg.EvalLog = EvalLogConfig{}
+	return cfg
+}
+
+// Info: 設定の照合用の値と CPU 数を返す
+func (w *Worker) Info(_ struct{}, reply *WorkerInfo) error {
+	*reply = WorkerInfo{Key: checkpointKey(w.cfg), CPUs: w.cpus}
+	return nil
+}
+
+// Shard: 区間を評価する
+func (w *Worker) Shard(args ShardArgs, reply *ShardReply) error {
+	if args.Key != checkpointKey(w.cfg) {
+		return errors.New("worker: config differs from the coordinator (rebuild both from the same source)")
+	}
+	cfg := w.cfg
+	cfg.Seed = args.Seed
+	cfg.MaxOKSave, cfg.MaxNGSave = args.MaxOKSave, args.MaxNGSave
+	cfg.StreamTSV = false
+	e, err := newEngine(cfg)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	e.iters = args.Start
+	e.box = newOKBox(len(e.params))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w.mu.Lock()
+	if w.running == nil {
+		w.running = map[*ShardArgs]context.CancelFunc{}
+	}
+	w.running[&args] = cancel
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		delete(w.running, &args)
+		w.mu.Unlock()
+	}()
+	if err := e.loopParallel(ctx, args.End, cfg.Seed); err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		fmt.Printf("[worker] shard %d..%d: canceled by the coordinator\n", args.Start, args.End-1)
+		return errors.New("worker: shard canceled")
+	}
+	*reply = ShardReply{
+		Iters:        e.iters - args.Start,
+		OKHits:       e.okHits,
+		NGHits:       e.ngHits,
+		BoundaryHits: e.boundaryHits,
+		MarginalHits: e.marginalHits,
+		InvalidHits:  e.invalidHits,
+		Rejected:     e.rejected,
+		Repaired:     e.repaired,
+		OK:           e.okList,
+		NG:           e.ngList,
+	}
+	fmt.Printf("[worker] shard %d..%d: OK_hits=%d  NG_hits=%d  (%s)\n",
+		args.Start, args.End-1, e.okHits, e.ngHits, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// Ping: 生きていることを返す（区間の評価中にコーディネータが送る）
+func (w *Worker) Ping(_ struct{}, _ *struct{}) error { return nil }
+
+// Cancel: Key と Seed が同じ評価中の区間を止める（コーディネータが Ctrl-C で止めたとき）
+func (w *Worker) Cancel(args CancelArgs, _ *struct{}) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for s, cancel := range w.running {
+		if s.Key == args.Key && s.Seed == args.Seed {
+			cancel()
+		}
+	}
+	return nil
+}
+
+// runWorker: `go run . worker [-listen :7070]`（Ctrl-C で終了）
+func runWorker(cfg Config, args []string) int {
+	fs := flag.NewFlagSet("worker", flag.ContinueOnError)
+	listen := fs.String("listen", ":7070", "address to accept the coordinator on")
+	if err := fs.Parse(args); err != nil {
+		return ExitConfigError
+	}
+	cfg = distributedConfig(cfg)
+	e, err := newEngine(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return ExitConfigError
+	}
+	if err := e.distributable(); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return ExitConfigError
+	}
+
+	srv := rpc.NewServer()
+	if err := srv.Register(&Worker{cfg: cfg, cpus: e.workers}); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return ExitError
+	}
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return ExitError
+	}
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		ln.Close()
+	}()
+
+	fmt.Printf("[worker] listening on %s (%d workers, config %s)\n", ln.Addr(), e.workers, checkpointKey(cfg))
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return ExitOK
+			}
+			fmt.Fprintln(os.Stderr, "error:", err)
+			return ExitError
+		}
+		fmt.Printf("[worker] coordinator connected from %s\n", conn.RemoteAddr())
+		go srv.ServeConn(conn)
+	}
+}
+
+// distributable: 区間に分けて探索できる設定か
+func (e *engine) distributable() error {
+	if !e.indexed() {
+		return fmt.Errorf("distributed: only available with the random sampler (not %T)", e.sampler)
+	}
+	var off []string
+	if e.cfg.Zoom.Phases > 1 {
+		off = append(off, "Zoom")
+	}
+	if e.strata != nil {
+		off = append(off, "Strata")
+	}
+	if e.dist != nil {
+		off = append(off, "NGDistance")
+	}
+	if e.cmp != nil {
+		off = append(off, "Antithetic / CompareYRanges")
+	}
+	if e.prior != nil {
+		off = append(off, "Prior")
+	}
+	if e.pool != nil {
+		off = append(off, "Importance / Interaction")
+	}
+	if len(off) > 0 {
+		return fmt.Errorf("distributed: not available with %v", off)
+	}
+	return nil
+}
+
+// remoteWorker: コーディネータから見たワーカー 1 台
+type remoteWorker struct {
+	addr   string
+	client *rpc.Client
+}
+
+// callWait: RPC を呼び、wait のあいだに返事がなければエラー
+func (w *remoteWorker) callWait(method string, args any, wait time.Duration) error {
+	call := w.client.Go(method, args, new(struct{}), nil)
+	select {
+	case <-call.Done:
+		return call.Error
+	case <-time.After(wait):
+		return fmt.Errorf("%s: no reply in %s", method, wait)
+	}
+}
+
+// shardTimer: 1 区間にかけてよい時間（timeout が 0 なら、終わった区間のうち最も遅いものから決める）
+type shardTimer struct {
+	timeout time.Duration
+	mu      sync.Mutex
+	slowest time.Duration
+}
+
+// done: 区間が d で終わった
+func (t *shardTimer) done(d time.Duration) {
+	t.mu.Lock()
+	t.slowest = max(t.slowest, d)
+	t.mu.Unlock()
+}
+
+// limit: いまの上限（0 なら上限なし。自動でまだ終わった区間がないときも 0）
+func (t *shardTimer) limit() time.Duration {
+	if t.timeout != 0 {
+		return max(t.timeout, 0)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.slowest == 0 {
+		return 0
+	}
+	return max(distTimeoutFactor*t.slowest, distMinTimeout)
+}
+
+// distribute: run の代わりに、区間を addrs のワーカーに分けて探索する
+// （shard は 1 区間の点数で 0 なら自動、timeout は 1 区間にかけてよい時間で 0 なら自動・負なら上限なし）
+func (e *engine) distribute(ctx context.Context, addrs []string, shard int64, timeout time.Duration) error {
+	if err := e.distributable(); err != nil {
+		return err
+	}
+	if e.checkpoint != nil || e.resume != nil {
+		return errors.New("distributed: not available with CheckpointEvery / -resume")
+	}
+	e.started = time.Now()
+	key := checkpointKey(e.cfg)
+
+	var ws []*remoteWorker
+	defer func() {
+		for _, w := range ws {
+			w.client.Close()
+		}
+	}()
+	var desc []string
+	for _, addr := range addrs {
+		c, err := rpc.Dial("tcp", addr)
+		if err != nil {
+			return fmt.Errorf("distributed: %w", err)
+		}
+		w := &remoteWorker{addr: addr, client: c}
+		ws = append(ws, w)
+		var info WorkerInfo
+		if err := c.Call("Worker.Info", struct{}{}, &info); err != nil {
+			return fmt.Errorf("distributed: %s: %w", addr, err)
+		}
+		if info.Key != key {
+			return fmt.Errorf("distributed: %s: config differs from the coordinator (%s vs %s)", addr, info.Key, key)
+		}
+		desc = append(desc, fmt.Sprintf("%s (%d)", addr, info.CPUs))
+	}
+
+	if shard <= 0 {
+		shard = (e.maxIters + int64(8*len(ws)) - 1) / int64(8*len(ws))
+	}
+	// 制約の Repair の寄せ先はバッチごとに切るので、区間の境目をバッチの境目にそろえる
+	shard = (shard + parallelBatch - 1) / parallelBatch * parallelBatch
+	var shards []ShardArgs
+	for s := int64(0); s < e.maxIters; s += shard {
+		shards = append(shards, ShardArgs{
+			Key: key, Seed: e.cfg.Seed, Start: s, End: min(s+shard, e.maxIters),
+			MaxOKSave: e.cfg.MaxOKSave, MaxNGSave: e.cfg.MaxNGSave,
+		})
+	}
+	fmt.Printf("[distributed] %d workers: %s  %d shards of %d\n", len(ws), strings.Join(desc, ", "), len(shards), shard)
+
+	// 空いているワーカーが区間を取りに来る。失敗した区間は戻して、そのワーカーは外す
+	todo := make(chan int, len(shards))
+	for i := range shards {
+		todo <- i
+	}
+	type done struct {
+		i     int
+		reply *ShardReply
+	}
+	results := make(chan done)
+	var alive int32 = int32(len(ws))
+	var pending int32 = int32(len(shards))
+	var mu sync.Mutex
+	var lastErr error
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	timer := &shardTimer{timeout: timeout}
+	var wg sync.WaitGroup
+	for _, w := range ws {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				var i int
+				select {
+				case i = <-todo:
+				case <-wctx.Done():
+					return
+				}
+				reply := new(ShardReply)
+				start := time.Now()
+				call := w.client.Go("Worker.Shard", shards[i], reply, nil)
+				err := waitShard(wctx, w, call, start, timer)
+				if err == nil && wctx.Err() != nil {
+					// Ctrl-C で止めたとき: 評価中の区間をワーカーにも止めさせる
+					if ctx.Err() != nil {
+						w.callWait("Worker.Cancel", CancelArgs{Key: key, Seed: e.cfg.Seed}, distCancelWait)
+					}
+					return
+				}
+				if err != nil {
+					mu.Lock()
+					lastErr = fmt.Errorf("%s: %w", w.addr, err)
+					mu.Unlock()
+					fmt.Printf("\n[distributed] %s failed on shard %d..%d: %v\n", w.addr, shards[i].Start, shards[i].End-1, err)
+					if call.Error == nil {
+						// 返事のないワーカーはまだ評価しているかもしれないので、止めさせてから切る
+						w.callWait("Worker.Cancel", CancelArgs{Key: key, Seed: e.cfg.Seed}, distCancelWait)
+						w.client.Close()
+					}
+					todo <- i
+					if atomic.AddInt32(&alive, -1) == 0 {
+						cancel()
+					}
+					return
+				}
+				timer.done(time.Since(start))
+				select {
+				case results <- done{i, reply}:
+				case <-wctx.Done():
+					return
+				}
+				if atomic.AddInt32(&pending, -1) == 0 {
+					cancel()
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// 区間の番号順につなぐ（先に終わった区間は前の区間が届くまで待たせる）
+	got := make([]*ShardReply, len(shards))
+	next := 0
+	for d := range results {
+		got[d.i] = d.reply
+		for next < len(got) && got[next] != nil {
+			e.merge(got[next])
+			got[next] = nil
+			next++
+			n := atomic.LoadInt64(&e.iters)
+			e.printProgress(n)
+			e.controlIfDue(n)
+		}
+	}
+	// 中断したときは、番号順につながらない区間も数だけは足す（保存サンプルは番号順の分だけ）
+	for _, r := range got {
+		if r != nil {
+			e.merge(&ShardReply{
+				Iters: r.Iters, OKHits: r.OKHits, NGHits: r.NGHits,
+				BoundaryHits: r.BoundaryHits, MarginalHits: r.MarginalHits, InvalidHits: r.InvalidHits,
+				Rejected: r.Rejected, Repaired: r.Repaired,
+			})
+		}
+	}
+
+	e.phases = append(e.phases, Phase{Params: e.params, Iters: e.iters, OKHits: e.okHits})
+	if next < len(shards) && ctx.Err() == nil {
+		return fmt.Errorf("distributed: every worker failed (last: %v)", lastErr)
+	}
+	return nil
+}
+
+// waitShard: 区間の返事を待つ。ctx が終わったら nil（返事は待たない）、
+// 区間がエラーになった・Ping に返事がない・時間の上限を超えたならエラーを返す
+func waitShard(ctx context.Context, w *remoteWorker, call *rpc.Call, start time.Time, timer *shardTimer) error {
+	beat := time.NewTicker(distHeartbeat)
+	defer beat.Stop()
+	for {
+		select {
+		case <-call.Done:
+			return call.Error
+		case <-ctx.Done():
+			return nil
+		case <-beat.C:
+			if limit := timer.limit(); limit > 0 && time.Since(start) > limit {
+				return fmt.Errorf("shard took longer than %s (-shard-timeout)", limit)
+			}
+			if err := w.callWait("Worker.Ping", struct{}{}, distHeartbeatWait); err != nil {
+				select {
+				case <-call.Done: // 区間の返事が先に届いていた
+					return call.Error
+				default:
+				}
+				return err
+			}
+		}
+	}
+}
+
+// merge: 区間の結果を足す（記録する側のゴルーチンから番号順に呼ぶ）
+func (e *engine) merge(r *ShardReply) {
+	e.iters += r.Iters
+	e.okHits += r.OKHits
+	e.ngHits += r.NGHits
+	e.boundaryHits += r.BoundaryHits
+	e.marginalHits += r.MarginalHits
+	e.invalidHits += r.InvalidHits
+	e.rejected += r.Rejected
+	e.repaired += r.Repaired
+	for _, s := range r.OK {
+		e.saveOK(s)
+	}
+	for _, s := range r.NG {
+		e.save(&e.ngList, e.ngStream, e.cfg.MaxNGSave, s)
+	}
+}
+
+// parseWorkers: "-remote pc1:7070,pc2:7070" を分ける
+func parseWorkers(s string) []string {
+	var out []string
+	for _, a := range strings.Split(s, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			out = append(out, a)
+		}
+	}
+	return out
+}
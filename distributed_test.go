@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/rpc"
+	"strings"
+	"testing"
+)
+
+// startWorker: cfg のワーカーを 127.0.0.1 の空いたポートで待ち受けさせ、アドレスを返す
+func startWorker(t *testing.T, cfg Config) string {
+	t.Helper()
+	srv := rpc.NewServer()
+	if err := srv.Register(&Worker{cfg: distributedConfig(cfg), cpus: 1}); err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go srv.Accept(ln)
+	return ln.Addr().String()
+}
+
+// 別の目的関数（-expr）で起動したワーカーの結果は受け取らない
+func TestDistributeRejectsOtherObjective(t *testing.T) {
+	coord := distributedConfig(exprConfig(t, "a+b"))
+	e, err := newEngine(coord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := startWorker(t, exprConfig(t, "a*b"))
+	err = e.distribute(context.Background(), []string{addr}, 0, 0)
+	if err == nil || !strings.Contains(err.Error(), "config differs") {
+		t.Fatalf("err = %v, want config differs", err)
+	}
+	if e.iters != 0 || e.okHits != 0 {
+		t.Errorf("iters, OK = %d, %d: want nothing counted", e.iters, e.okHits)
+	}
+
+	// Info を通らずに区間だけ頼まれても断る
+	w := &Worker{cfg: distributedConfig(exprConfig(t, "a*b")), cpus: 1}
+	var reply ShardReply
+	if err := w.Shard(ShardArgs{Key: checkpointKey(coord), Seed: 1, End: 10}, &reply); err == nil {
+		t.Errorf("Shard with the a+b key: want an error")
+	}
+}
+
+// 同じ目的関数なら、1 台で探索したときと同じ件数になる
+func TestDistributeSameObjective(t *testing.T) {
+	coord := distributedConfig(exprConfig(t, "a+b"))
+	e, err := newEngine(coord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := startWorker(t, exprConfig(t, "a+b"))
+	if err := e.distribute(context.Background(), []string{addr}, 256, 0); err != nil {
+		t.Fatal(err)
+	}
+	local, err := newEngine(coord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := local.run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if e.iters != local.iters || e.okHits != local.okHits || e.ngHits != local.ngHits {
+		t.Errorf("distributed iters/OK/NG = %d/%d/%d, local %d/%d/%d",
+			e.iters, e.okHits, e.ngHits, local.iters, local.okHits, local.ngHits)
+	}
+}
@@ -0,0 +1,616 @@
+// engine.go
+// 探索ループ
+//
+// main.go から呼ばれ、Sampler で点を作って目的関数を評価し、OK/NG を数えて保存する。
+// Config.Zoom を指定すると、OK サンプルの範囲に探索範囲を絞りながら複数段で探索する。
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// Phase: 多段探索の 1 段分の記録
+type Phase struct {
+	Params []ParamSpec // この段の探索範囲
+	Iters  int64
+	OKHits int64
+}
+
+type engine struct {
+	cfg      Config
+	params   []ParamSpec // 現在の段の探索範囲
+	obj      Objective
+	useF2    bool      // F2 で評価する（slice.go）
+	xbuf     []float64 // F2 に渡す params の値（評価ごとに使い回す）
+	sampler  Sampler
+	finite   bool    // sampler が FiniteSampler（格子）
+	copula   *copula // Config.Correlations（なければ nil）
+	maxIters int64
+	workers  int // 並列に評価するゴルーチンの数（parallel.go）
+
+	okList []Sample
+	ngList []Sample
+	phases []Phase
+
+	// 保存するサンプルを書き足す tsv（stream.go。Config.StreamTSV でなければ nil）
+	okStream *tsvStream
+	ngStream *tsvStream
+
+	// 実行中の段（多段探索）
+	phase      int
+	phaseIters int64 // 段の開始時点の iters
+	phaseOK    int64 // 段の開始時点の okHits
+	box        okBox // 段の OK サンプルの範囲
+	draws      int64 // 段で Sampler から引いた点の数（再開時にこの数だけ進める）
+
+	iters  int64
+	okHits int64
+	ngHits int64
+
+	boundaryHits int64 // y が YRange の端にあった数（boundary.go）
+	marginalHits int64 // y が YRange の外側の許容幅にあった数
+
+	// 途中結果の保存（Config.AutosaveEvery ごとに saveDue を立て、探索ループ側で保存する）
+	autosave func(Result)
+	saveDue  int32
+
+	// 進行状況表示（Config.ProgressInterval ごとに progressDue を立てる）
+	progressDue int32
+	every       int64 // 表示する反復数の間隔（printEvery。制御ソケットの set print-every で変わる）
+	// PrintEvery が負のときの次に表示する回数と、前回表示した回数・時刻（progress.go）
+	progressNext int64
+	progressN    int64
+	progressAt   time.Time
+
+	// 制御ソケットから届いたコマンド（control.go。ControlSocket が "" なら nil）
+	control    chan controlReq
+	controlDue int32
+
+	// SIGQUIT で状態を表示する（status.go）。closest は y が YRange の中央に最も近い点
+	statusDue int32
+	closest   Sample
+	closestD  float64
+
+	// 再開用の状態の保存（checkpoint.go。Config.CheckpointEvery ごとに checkpointDue を立てる）
+	checkpoint    func(Checkpoint)
+	checkpointDue int32
+	resume        *Checkpoint   // 再開した状態（restore。なければ nil）
+	started       time.Time     // run を始めた時刻
+	elapsed       time.Duration // 再開前の実行時間
+
+	// 最初の評価の記録（evallog.go。Config.EvalLog.N が 0 なら nil）
+	evalLog *evalLog
+
+	// JSON Lines の出力（jsonl.go。Config.JSONL.File が "" なら nil）
+	jsonl *jsonlWriter
+
+	// 進行状況の記録（progresslog.go。Config.ProgressLog が "" なら nil）
+	progLog *progressLog
+
+	// NG の YRange までの距離の集計（Config.NGDistance が無効なら nil）
+	dist *distanceAcc
+
+	// 層ごとの OK 率の集計（Config.Strata が無効なら nil）
+	strata *strataAcc
+
+	// 事前分布で重み付けした OK 率の集計（Config.Prior が nil なら nil）
+	prior *priorAcc
+
+	// YRange の候補ごとの OK 率（Config.Antithetic も CompareYRanges もなければ nil）
+	cmp *yrangeCmp
+
+	// 重要度・交互作用の計算用に評価した点から抜き出しておく（nil なら覚えない）
+	pool *reservoir
+
+	// 焼きなましによる NG の救済（anneal.go）
+	annealTried     int
+	annealRecovered int
+	annealEvals     int64
+
+	unsure int // 誤差しだいで NG になりうる OK サンプルの数（verify.go）
+
+	// 重み付きの評価値（score.go。Config.Score が空なら nil）
+	score *scorer
+
+	// パラメータの制約（constraint.go）
+	anchor      []float64 // 直前に制約を満たした u（Repair の寄せ先）
+	invalidHits int64     // INVALID として数えた数
+	rejected    int64     // 引き直しで捨てた数
+	repaired    int64     // 直した数
+}
+
+// preallocSave: 保存リストを最初に確保する件数の上限（MaxOKSave が大きくても一度に確保しない）
+const preallocSave = 4096
+
+func newEngine(cfg Config) (*engine, error) {
+	cfg.Params = resolveParams(cfg.Params)
+	obj := funcObjective(cfg.F)
+	useF2 := cfg.F2 != nil && cfg.Objective == nil
+	if useF2 {
+		if len(cfg.Derived) > 0 || len(cfg.ParamConstraints) > 0 {
+			panic("F2 cannot be combined with Derived or ParamConstraints (use F)")
+		}
+		obj = sliceObjective(cfg.Params, cfg.F2)
+	}
+	if cfg.Objective != nil {
+		obj = *cfg.Objective
+	}
+	if cfg.TermColumns {
+		// 途中の量は出力だけの列の先頭に置く（後の OutputColumns から使える）
+		cfg.OutputColumns = append(termColumns(obj), cfg.OutputColumns...)
+	}
+
+	// params・派生・出力だけの列の定義の確認（列の名前の重なりは checkColumns。columns.go）
+	for _, p := range cfg.Params {
+		if p.Key == "" {
+			panic("param key is empty")
+		}
+		checkValues(p)
+		if p.Type == Categorical && len(p.Choices) == 0 {
+			panic("param " + p.Key + ": categorical param has no Choices")
+		}
+	}
+	for _, d := range cfg.Derived {
+		if d.Key == "" || d.Func == nil {
+			panic("derived param needs Key and Func: " + d.Key)
+		}
+	}
+	for _, d := range cfg.OutputColumns {
+		if d.Key == "" || d.Func == nil {
+			panic("output column needs Key and Func: " + d.Key)
+		}
+	}
+	if err := checkColumns(cfg, obj); err != nil {
+		return nil, err
+	}
+	if err := checkScore(cfg, obj); err != nil {
+		return nil, err
+	}
+	if _, err := newMetric(cfg.Metric, cfg.Params); err != nil {
+		return nil, err // 探索の後で使うが、設定の誤りは先に知らせる
+	}
+
+	sampler, err := newSampler(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := sampler.Init(len(cfg.Params), cfg.Seed); err != nil {
+		return nil, err
+	}
+
+	cop, err := newCopula(cfg.Params, cfg.Correlations)
+	if err != nil {
+		return nil, err
+	}
+
+	// 格子のように点数が決まっている場合は使い切った時点で終了
+	maxIters := cfg.MaxIters
+	fs, finite := sampler.(FiniteSampler)
+	if finite && fs.Len() < maxIters {
+		maxIters = fs.Len()
+	}
+
+	e := &engine{
+		cfg:      cfg,
+		params:   cfg.Params,
+		obj:      obj,
+		useF2:    useF2,
+		xbuf:     make([]float64, len(cfg.Params)),
+		sampler:  sampler,
+		finite:   finite,
+		copula:   cop,
+		workers:  workerCount(cfg, sampler),
+		maxIters: maxIters,
+		okList:   make([]Sample, 0, min(cfg.MaxOKSave, preallocSave)),
+		ngList:   make([]Sample, 0, min(cfg.MaxNGSave, preallocSave)),
+		score:    newScorer(cfg),
+	}
+	poolSize := 0
+	if cfg.Importance.Enabled {
+		poolSize = max(poolSize, cfg.Importance.samples())
+	}
+	if cfg.Interaction.Enabled {
+		poolSize = max(poolSize, cfg.Interaction.samples())
+	}
+	if poolSize > 0 {
+		e.pool = newReservoir(poolSize, cfg.Seed)
+	}
+	if cfg.NGDistance {
+		e.dist = newDistanceAcc()
+	}
+	if cfg.Strata.Enabled {
+		e.strata = newStrataAcc(cfg)
+	}
+	if cfg.Antithetic || len(cfg.CompareYRanges) > 0 {
+		e.cmp = newYRangeCmp(cfg)
+	}
+	if cfg.Prior != nil {
+		e.prior = newPriorAcc(cfg, sampler)
+	}
+	e.every = e.printEvery()
+	return e, nil
+}
+
+// run: 全段を実行する（Ctrl-C で ctx が終了したらその時点で戻る）
+func (e *engine) run(ctx context.Context) error {
+	e.started = time.Now()
+	if e.cfg.AutosaveEvery > 0 && e.autosave != nil {
+		defer e.tick(e.cfg.AutosaveEvery, &e.saveDue)()
+	}
+	if e.cfg.CheckpointEvery > 0 && e.checkpoint != nil {
+		defer e.tick(e.cfg.CheckpointEvery, &e.checkpointDue)()
+	}
+	if e.cfg.ProgressInterval > 0 {
+		defer e.tick(e.cfg.ProgressInterval, &e.progressDue)()
+	}
+	defer e.watchStatus()()
+
+	ends := e.cfg.Zoom.phaseEnds(e.maxIters)
+	for k := e.phase; k < len(ends); k++ {
+		end := ends[k]
+		seed := e.cfg.Seed + int64(k)
+		resumed := e.resume != nil && k == e.resume.Phase
+		if resumed && e.workers > 1 && !e.indexed() {
+			// ワーカーごとの位置は残っていないので、別の seed の系列で続ける
+			seed = int64(splitmix64(uint64(seed) ^ uint64(e.resume.Iters)))
+		}
+		if k > 0 || seed != e.cfg.Seed {
+			if err := e.sampler.Init(len(e.params), seed); err != nil {
+				return err
+			}
+		}
+		if k > 0 && !resumed {
+			fmt.Printf("\n[zoom] phase %d/%d:%s\n", k+1, len(ends), formatRanges(e.params))
+		}
+
+		e.phase = k
+		e.draws = 0
+		if resumed {
+			if e.workers == 1 && !e.indexed() {
+				u := make([]float64, len(e.params))
+				for ; e.draws < e.resume.Draws; e.draws++ {
+					e.sampler.Next(u)
+				}
+			}
+		} else {
+			e.phaseIters = atomic.LoadInt64(&e.iters)
+			e.phaseOK = atomic.LoadInt64(&e.okHits)
+			e.box = newOKBox(len(e.params))
+		}
+
+		var err error
+		if e.workers > 1 || e.indexed() {
+			err = e.loopParallel(ctx, end, seed)
+		} else {
+			err = e.loop(ctx, end)
+		}
+		e.phases = append(e.phases, Phase{
+			Params: e.params,
+			Iters:  atomic.LoadInt64(&e.iters) - e.phaseIters,
+			OKHits: atomic.LoadInt64(&e.okHits) - e.phaseOK,
+		})
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if k+1 < len(ends) {
+			e.params = e.cfg.Zoom.shrink(e.params, e.box)
+		}
+	}
+	return nil
+}
+
+// tick: every ごとに flag を立てるゴルーチンを始め、止める関数を返す
+func (e *engine) tick(every time.Duration, flag *int32) (stop func()) {
+	t := time.NewTicker(every)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-t.C:
+				atomic.StoreInt32(flag, 1)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		t.Stop()
+		close(done)
+	}
+}
+
+// loop: iters が end に達するまで探索する（この段の OK サンプルの範囲は e.box に足す）
+func (e *engine) loop(ctx context.Context, end int64) error {
+	params := e.params
+	u := make([]float64, len(params))
+
+	for {
+		i := atomic.LoadInt64(&e.iters)
+		if i >= end {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		s, err := e.draw(params, u)
+		if err != nil {
+			return err
+		}
+		if fb, ok := e.sampler.(FeedbackSampler); ok {
+			s = e.filled(s) // 上位のリストに残ることがある
+			fb.Observe(u, s)
+		}
+		if s.OK {
+			s = e.filled(s)
+			e.box.add(params, s.Values)
+		}
+		e.record(s)
+
+		n := atomic.AddInt64(&e.iters, 1)
+		e.progress(n)
+		e.saveIfDue()
+	}
+}
+
+// printEvery: 進行状況を表示する反復数の間隔（ProgressInterval を使うなら 0）
+func (e *engine) printEvery() int64 {
+	if e.cfg.ProgressInterval > 0 {
+		return 0
+	}
+	return e.cfg.PrintEvery
+}
+
+// progress: n 回目の後、間隔に達していれば進行状況を表示する（制御ソケットのコマンドもここで実行する）
+func (e *engine) progress(n int64) {
+	e.statusIfDue(n)
+	e.controlIfDue(n)
+	printEvery := e.every
+	if printEvery < 0 {
+		e.adaptiveProgress(n)
+		return
+	}
+	if (printEvery > 0 && n%printEvery == 0) || atomic.CompareAndSwapInt32(&e.progressDue, 1, 0) {
+		e.printProgress(n)
+	}
+}
+
+// saveIfDue: 時間になっていれば途中結果・再開用の状態を保存する
+func (e *engine) saveIfDue() {
+	if atomic.CompareAndSwapInt32(&e.saveDue, 1, 0) {
+		e.autosave(e.result())
+	}
+	if atomic.CompareAndSwapInt32(&e.checkpointDue, 1, 0) {
+		e.checkpoint(e.snapshot())
+	}
+}
+
+// values: u ∈ [0,1)^d を params の値に変換し、派生パラメータを計算する（相関があれば先に u を相関させる）
+func (e *engine) values(params []ParamSpec, u []float64) (map[string]float64, error) {
+	if e.copula != nil {
+		u = e.copula.apply(u)
+	}
+	vals := make(map[string]float64, len(params)+len(e.cfg.Derived)+len(e.obj.Aux))
+	for j, p := range params {
+		v, err := sampleOne(u[j], p)
+		if err != nil {
+			return nil, err
+		}
+		vals[p.Key] = v
+	}
+	for _, d := range e.cfg.Derived {
+		vals[d.Key] = d.Func(vals)
+	}
+	return vals, nil
+}
+
+// evaluate: u の点を評価・判定する（制約を満たさなければ評価せず Invalid にする）
+func (e *engine) evaluate(params []ParamSpec, u []float64) (Sample, error) {
+	if e.useF2 {
+		return e.evaluateSlice(params, u)
+	}
+	vals, err := e.values(params, u)
+	if err != nil {
+		return Sample{}, err
+	}
+	if !feasible(vals, e.cfg.ParamConstraints) {
+		return Sample{Values: vals, Y: math.NaN(), Invalid: true}, nil
+	}
+
+	y, aux := e.obj.Eval(vals)
+	for k, v := range aux {
+		vals[k] = v
+	}
+	ok := yOK(y, e.cfg)
+	if e.cfg.YEpsilon > 0 {
+		vals[MarginalKey] = 0
+		if isMarginal(y, e.cfg) {
+			vals[MarginalKey] = 1
+		}
+	}
+	if e.dist != nil {
+		vals[DistanceKey] = normDistance(y, e.cfg.YRange)
+	}
+	return Sample{Values: vals, Y: y, OK: ok}, nil
+}
+
+// record: カウンタを進め、枠が空いていれば保存する
+func (e *engine) record(s Sample) {
+	if e.evalLog != nil {
+		e.logEval(atomic.LoadInt64(&e.iters), s)
+	}
+	if s.Invalid {
+		atomic.AddInt64(&e.invalidHits, 1)
+		if e.jsonl != nil {
+			e.writeJSONL(s, false)
+		}
+		return
+	}
+	// F2 のときは、保存するか集計に使うときだけ Values を作る（score の上位を残すなら OK はすべて）
+	if s.Values == nil {
+		if e.saving(s.OK) || e.strata != nil || e.pool != nil || e.prior != nil || (e.score != nil && s.OK) {
+			s = e.filled(s)
+		}
+	}
+	if e.score != nil && s.Values != nil {
+		s.Values[ScoreKey] = e.score.of(s)
+	}
+	if s.OK {
+		atomic.AddInt64(&e.okHits, 1)
+	} else {
+		atomic.AddInt64(&e.ngHits, 1)
+	}
+	if onBoundary(s.Y, e.cfg) {
+		atomic.AddInt64(&e.boundaryHits, 1)
+	}
+	if s.Values[MarginalKey] == 1 {
+		atomic.AddInt64(&e.marginalHits, 1)
+	}
+	e.trackClosest(s)
+	if e.jsonl != nil {
+		e.writeJSONL(s, e.saving(s.OK))
+	}
+
+	// 保存は「枠が空いているときだけ」。枠が埋まっても探索は続行。
+	if s.OK {
+		e.saveOK(s)
+	} else {
+		e.save(&e.ngList, e.ngStream, e.cfg.MaxNGSave, s)
+	}
+
+	if e.strata != nil {
+		e.strata.add(s)
+	}
+	if e.cmp != nil {
+		e.cmp.add(s)
+	}
+	if e.prior != nil {
+		e.prior.add(s)
+	}
+	if e.dist != nil && !s.OK {
+		e.dist.add(s.Values[DistanceKey])
+	}
+	if e.pool != nil {
+		e.pool.add(s)
+	}
+}
+
+// 進捗表示（固定幅・行の残りを消す）
+func (e *engine) printProgress(i int64) {
+	var pct float64
+	if e.maxIters > 0 {
+		pct = float64(i) / float64(e.maxIters) * 100.0
+	}
+	okh := atomic.LoadInt64(&e.okHits)
+	ngh := atomic.LoadInt64(&e.ngHits)
+
+	line := fmt.Sprintf(
+		"\riter=%12d (%6.2f%%)  OK_hits=%12d  NG_hits=%12d",
+		i, pct, okh, ngh,
+	)
+	fmt.Print(line + "                                    ")
+	if e.progLog != nil {
+		e.logProgress(i)
+	}
+}
+
+func (e *engine) result() Result {
+	cols := e.columns()
+	var dist *DistanceStats
+	if e.dist != nil {
+		dist = e.dist.result()
+	}
+	best := e.best()
+	okList := e.rankedOK()
+	outputValues(e.cfg.OutputColumns, okList, e.ngList, best)
+	var cmp []YRangeStat
+	if e.cmp != nil {
+		cmp = e.cmp.result()
+	}
+	var prior *PriorStats
+	if e.prior != nil {
+		prior = e.prior.result()
+	}
+	var strata *Strata
+	if e.strata != nil {
+		strata = e.strata.res
+	}
+	return Result{
+		Params:  e.cfg.Params,
+		Config:  newRunConfig(e.cfg, e.workers),
+		Columns: cols,
+		YRange:  e.cfg.YRange,
+		Seed:    e.cfg.Seed,
+		Iters:   atomic.LoadInt64(&e.iters),
+		OKHits:  atomic.LoadInt64(&e.okHits),
+		NGHits:  atomic.LoadInt64(&e.ngHits),
+
+		BoundaryHits: atomic.LoadInt64(&e.boundaryHits),
+		MarginalHits: atomic.LoadInt64(&e.marginalHits),
+		InvalidHits:  atomic.LoadInt64(&e.invalidHits),
+		Rejected:     e.rejected,
+		Repaired:     e.repaired,
+		OKList:       okList,
+		NGList:       e.ngList,
+		Phases:       e.phases,
+		Best:         best,
+		Refined:      e.annealRecovered,
+		Unsure:       e.unsure,
+
+		Distance: dist,
+		Strata:   strata,
+		Prior:    prior,
+		YRanges:  cmp,
+	}
+}
+
+// columns: 出力列（params・派生・補助出力・各機能の列・出力だけの列の順）
+func (e *engine) columns() []Column {
+	cols := append(paramColumns(e.cfg.Params), derivedColumns(e.cfg.Derived)...)
+	cols = append(cols, e.obj.Aux...)
+	if e.cfg.Anneal.Enabled {
+		cols = append(cols, Column{Key: RefinedKey, Label: RefinedKey, DisplayScale: 1})
+	}
+	if e.cfg.YEpsilon > 0 {
+		cols = append(cols, Column{Key: MarginalKey, Label: MarginalKey, DisplayScale: 1})
+	}
+	if e.cfg.Verify.Enabled {
+		cols = append(cols,
+			Column{Key: YErrKey, Label: YErrKey, DisplayScale: 1},
+			Column{Key: UnsureKey, Label: UnsureKey, DisplayScale: 1})
+	}
+	if e.dist != nil {
+		cols = append(cols, Column{Key: DistanceKey, Label: DistanceKey, DisplayScale: 1})
+	}
+	if e.score != nil {
+		cols = append(cols, Column{Key: ScoreKey, Label: ScoreKey, DisplayScale: 1})
+	}
+	cols = append(cols, derivedColumns(e.cfg.OutputColumns)...)
+	return cols
+}
+
+// samples: 評価した点から一様に抜き出したもの（Importance も Interaction も無効なら nil）
+func (e *engine) samples() []Sample {
+	if e.pool == nil {
+		return nil
+	}
+	return e.pool.items
+}
+
+// best: 最適化型の探索モードが覚えている上位サンプル（なければ nil）
+func (e *engine) best() []Sample {
+	if br, ok := e.sampler.(BestReporter); ok {
+		return br.Best()
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+// evallog.go
+// 最初の N 回の評価をそのまま tsv に書く（Config.EvalLog）
+//
+// ツールの出力と手計算が合わないときに、printf を足して作り直さなくても確かめられるように、
+// 記録した順（Deterministic なら番号順）に、入力・派生パラメータ・補助出力・途中の量
+// （組み込み目的関数の Objective.Terms。SS 方式なら ω, term1, term2, A, B, num, den）・y・判定を書く。
+// 値は DisplayScale を掛けない元の単位で、読み戻して同じ値になる桁数で書く。
+// 既存のファイルはほかの出力と同じく OnExisting に従う。append なら見出しが同じときだけ行を足し（i は実行ごとに 0 から）、
+// 違えば rename と同じ新しい名前に書く（resolveAppend）。
+
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"strconv"
+)
+
+// EvalLogConfig: 評価の記録の設定（N が 0 なら記録しない）
+type EvalLogConfig struct {
+	N    int    // 記録する評価の数
+	File string // 書き出すファイル（"" なら "evals.tsv"）
+}
+
+func (c EvalLogConfig) file() string {
+	if c.File != "" {
+		return c.File
+	}
+	return "evals.tsv"
+}
+
+// evalLog: 書いている評価の記録
+type evalLog struct {
+	name string
+	f    *os.File
+	w    *csv.Writer
+	keys []string // 値の列（params, Derived, Aux, Terms の順）
+	left int      // あと何件書くか
+	err  error
+}
+
+// openEvalLog: EvalLog が有効なら記録するファイルを開く（探索の前に呼ぶ）
+func (e *engine) openEvalLog() error {
+	c := e.cfg.EvalLog
+	if c.N <= 0 {
+		return nil
+	}
+	var keys []string
+	for _, p := range e.cfg.Params {
+		keys = append(keys, p.Key)
+	}
+	for _, d := range e.cfg.Derived {
+		keys = append(keys, d.Key)
+	}
+	for _, a := range e.obj.Aux {
+		keys = append(keys, a.Key)
+	}
+	for _, c := range e.obj.TermCols {
+		keys = append(keys, c.Key)
+	}
+	head := append(append([]string{"i", "phase"}, keys...), "y", "class")
+
+	name, appendMode, err := resolveAppend(c.file(), e.cfg.OnExisting, head, textHeader('\t'))
+	if err != nil {
+		return err
+	}
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendMode {
+		flag = os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(name, flag, 0o644)
+	if err != nil {
+		return err
+	}
+	l := &evalLog{name: name, f: f, w: csv.NewWriter(f), keys: keys, left: c.N}
+	l.w.Comma = '\t'
+	if !appendMode {
+		if err := l.w.Write(head); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	e.evalLog = l
+	return nil
+}
+
+// logEval: i 番目に記録したサンプルを書く（N 件書いたら閉じる）
+func (e *engine) logEval(i int64, s Sample) {
+	l := e.evalLog
+	if l == nil || l.left <= 0 || l.err != nil {
+		return
+	}
+	s = e.filled(s)
+	var terms map[string]float64
+	if e.obj.Terms != nil && !s.Invalid {
+		terms = e.obj.Terms(s.Values)
+	}
+	row := []string{strconv.FormatInt(i, 10), strconv.Itoa(e.phase)}
+	for _, k := range l.keys {
+		v, ok := s.Values[k]
+		if !ok {
+			v, ok = terms[k]
+		}
+		if !ok {
+			row = append(row, "")
+			continue
+		}
+		row = append(row, strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	row = append(row, strconv.FormatFloat(s.Y, 'g', -1, 64), e.evalClass(s))
+	l.err = l.w.Write(row)
+	l.left--
+	if l.left == 0 {
+		e.closeEvalLog()
+	}
+}
+
+// evalClass: 判定（OK / NG / INVALID。許容幅・端にあれば付記する）
+func (e *engine) evalClass(s Sample) string {
+	if s.Invalid {
+		return "INVALID"
+	}
+	c := "NG"
+	if s.OK {
+		c = "OK"
+	}
+	if s.Values[MarginalKey] == 1 {
+		c += " marginal"
+	}
+	if onBoundary(s.Y, e.cfg) {
+		c += " boundary"
+	}
+	return c
+}
+
+// closeEvalLog: 書き残しを書いて閉じ、ファイル名と最初のエラーを返す（2 回目以降は何もしない）
+func (e *engine) closeEvalLog() (string, error) {
+	l := e.evalLog
+	if l == nil {
+		return "", nil
+	}
+	if l.f == nil {
+		return l.name, l.err
+	}
+	l.w.Flush()
+	if l.err == nil {
+		l.err = l.w.Error()
+	}
+	if err := l.f.Close(); l.err == nil {
+		l.err = err
+	}
+	l.f = nil
+	l.left = 0
+	return l.name, l.err
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// append なら評価の記録も上書きせずに行を足す（見出しが違えば別の名前）
+func TestEvalLogAppend(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "evals.tsv")
+	run := func(src string) string {
+		cfg := exprConfig(t, src)
+		cfg.Workers = 1
+		cfg.EvalLog = EvalLogConfig{N: 5, File: name}
+		cfg.OnExisting = AppendToExisting
+		e, err := newEngine(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := e.openEvalLog(); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.run(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		got, err := e.closeEvalLog()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	lines := func(name string) []string {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	}
+
+	run("a+b")
+	if got := run("a+b"); got != name {
+		t.Errorf("second run wrote %s, want %s", got, name)
+	}
+	ls := lines(name)
+	if len(ls) != 11 || !strings.HasPrefix(ls[0], "i\tphase\ta\tb") || strings.HasPrefix(ls[6], "i\t") {
+		t.Errorf("want 1 header and 10 rows, got %d lines:\n%s", len(ls), strings.Join(ls, "\n"))
+	}
+
+	// 派生パラメータが増えて列が変わったら、足さずに evals_1.tsv に書く
+	cfg := exprConfig(t, "a+b")
+	cfg.Derived = []DerivedSpec{{Key: "c", Label: "c", DisplayScale: 1, Func: func(x map[string]float64) float64 { return x["a"] * 2 }}}
+	cfg.Workers = 1
+	cfg.EvalLog = EvalLogConfig{N: 5, File: name}
+	cfg.OnExisting = AppendToExisting
+	e, err := newEngine(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.openEvalLog(); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := e.closeEvalLog()
+	if want := filepath.Join(dir, "evals_1.tsv"); got != want {
+		t.Errorf("other columns: wrote %s, want %s", got, want)
+	}
+	if len(lines(name)) != 11 {
+		t.Errorf("%s changed", name)
+	}
+}
@@ -0,0 +1,270 @@
+// exec.go
+// 目的関数を外部のプログラムに計算させる（Config.Exec・-exec・設定ファイルの exec）
+//
+// Python のスクリプトや MATLAB のラッパー、回路シミュレータなど、Go から呼べない計算を F にするため、
+// コマンドを起動したままにして、標準入出力で 1 行 1 つの JSON をやりとりする。
+//
+//	→ [{"id": 1, "x": {"k": 0.3, "f": 85000, ...}}, {"id": 2, "x": {...}}]
+//	← [{"id": 1, "y": 0.42, "aux": {"Pdc": 3.1}}, {"id": 2, "y": null, "error": "did not converge"}]
+//
+// 1 行に最大 Batch 点をまとめて送る（同時に評価を待っている点があるときだけまとまるので、Workers を Batch 以上にする）。
+// 返事の順番は問わず id で対応付ける。y が null / "error" がある点は NaN（INVALID ではなく NG）になる。
+// プロセスが終了した・返事が壊れている・Timeout を過ぎたときは、起動し直して同じ行を送り直す
+// （起動し直すのは合わせて Restarts 回まで。超えたら以降の評価は NaN）。Procs 個のプロセスで並べて計算できる。
+// プログラムの標準エラー出力はそのまま stderr に出す。
+//
+// Python の例:
+//
+//	import json, sys
+//	for line in sys.stdin:
+//	    out = [{"id": r["id"], "y": f(**r["x"])} for r in json.loads(line)]
+//	    print(json.dumps(out), flush=True)
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ExecConfig: 外部プログラムの目的関数の設定（Command が空なら使わない）
+type ExecConfig struct {
+	Command  []string      // 起動するコマンドと引数（例: {"python3", "sim.py"}）
+	Batch    int           // 1 行にまとめる点の数の上限（0 なら 1）
+	Procs    int           // 同時に動かすプロセスの数（0 なら 1）
+	Timeout  time.Duration // 1 行の返事を待つ時間（0 なら待ち続ける）
+	Restarts int           // 起動し直す回数の上限（0 なら 3、負なら起動し直さない）
+	Aux      []string      // 返事の "aux" から補助出力として列にするキー
+}
+
+func (c ExecConfig) batch() int { return max(c.Batch, 1) }
+func (c ExecConfig) procs() int { return max(c.Procs, 1) }
+
+func (c ExecConfig) restarts() int64 {
+	switch {
+	case c.Restarts < 0:
+		return 0
+	case c.Restarts == 0:
+		return 3
+	}
+	return int64(c.Restarts)
+}
+
+// execReq: 評価を待っている 1 点
+type execReq struct {
+	x     map[string]float64
+	reply chan execReply
+}
+
+type execReply struct {
+	Y   float64
+	Aux map[string]float64
+}
+
+// execWire: 送受信する 1 点（y が null なら NaN）
+type execWire struct {
+	ID    int64              `json:"id"`
+	X     map[string]float64 `json:"x,omitempty"`
+	Y     *float64           `json:"y,omitempty"`
+	Aux   map[string]float64 `json:"aux,omitempty"`
+	Error string             `json:"error,omitempty"`
+}
+
+// execPool: 外部プログラムのプロセスと、評価を待っている点
+type execPool struct {
+	cfg      ExecConfig
+	reqs     chan execReq
+	restarts int64 // 起動し直した回数（全プロセスの合計）
+}
+
+// execProc: 起動している 1 つのプロセス
+type execProc struct {
+	cmd   *exec.Cmd
+	in    io.WriteCloser
+	lines chan []byte // 返事の行（プロセスが終わると閉じる）
+}
+
+// newExecObjective: 外部プログラムを起動して、評価を送る Objective を作る
+func newExecObjective(c ExecConfig) (Objective, error) {
+	p := &execPool{cfg: c, reqs: make(chan execReq)}
+	for i := 0; i < c.procs(); i++ {
+		proc, err := p.start()
+		if err != nil {
+			return Objective{}, err
+		}
+		go p.serve(proc)
+	}
+	aux := make([]Column, len(c.Aux))
+	for j, k := range c.Aux {
+		aux[j] = Column{Key: k, Label: k, DisplayScale: 1}
+	}
+	return Objective{
+		Aux: aux,
+		Eval: func(x map[string]float64) (float64, map[string]float64) {
+			r := p.eval(x)
+			return r.Y, r.Aux
+		},
+	}, nil
+}
+
+// start: プロセスを 1 つ起動する
+func (p *execPool) start() (*execProc, error) {
+	cmd := exec.Command(p.cfg.Command[0], p.cfg.Command[1:]...)
+	cmd.Stderr = os.Stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("exec: %w", err)
+	}
+	proc := &execProc{cmd: cmd, in: in, lines: make(chan []byte)}
+	go func() {
+		defer close(proc.lines)
+		r := bufio.NewReaderSize(out, 1<<16)
+		for {
+			line, err := r.ReadBytes('\n')
+			if len(line) > 0 {
+				proc.lines <- line
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return proc, nil
+}
+
+// stop: プロセスを止める
+func (proc *execProc) stop() {
+	proc.in.Close()
+	proc.cmd.Process.Kill()
+	for range proc.lines {
+	}
+	proc.cmd.Wait()
+}
+
+// eval: 1 点を送って返事を待つ
+func (p *execPool) eval(x map[string]float64) execReply {
+	r := execReq{x: x, reply: make(chan execReply, 1)}
+	p.reqs <- r
+	return <-r.reply
+}
+
+// serve: 待っている点を Batch 個までまとめて proc に送り、返事を配る（プロセスごとに 1 つの goroutine）
+func (p *execPool) serve(proc *execProc) {
+	var id int64
+	for first := range p.reqs {
+		batch := []execReq{first}
+	gather:
+		for len(batch) < p.cfg.batch() {
+			select {
+			case r := <-p.reqs:
+				batch = append(batch, r)
+			default:
+				break gather
+			}
+		}
+		wire := make([]execWire, len(batch))
+		for j, r := range batch {
+			id++
+			wire[j] = execWire{ID: id, X: r.x}
+		}
+		replies, next := p.exchange(proc, wire)
+		proc = next
+		for j, r := range batch {
+			out, ok := replies[wire[j].ID]
+			if !ok || out.Y == nil || out.Error != "" {
+				r.reply <- execReply{Y: math.NaN(), Aux: out.Aux}
+				continue
+			}
+			r.reply <- execReply{Y: *out.Y, Aux: out.Aux}
+		}
+	}
+}
+
+// exchange: 1 行を送って返事を読む（失敗したら起動し直して送り直す）。使い続けるプロセスも返す
+func (p *execPool) exchange(proc *execProc, wire []execWire) (map[int64]execWire, *execProc) {
+	line, err := json.Marshal(wire)
+	if err != nil {
+		return nil, proc
+	}
+	line = append(line, '\n')
+	for {
+		if proc != nil {
+			got, err := p.roundTrip(proc, line)
+			if err == nil {
+				return got, proc
+			}
+			fmt.Fprintf(os.Stderr, "\n[exec] %s: %v\n", strings.Join(p.cfg.Command, " "), err)
+			proc.stop()
+			proc = nil
+		}
+		if atomic.AddInt64(&p.restarts, 1) > p.cfg.restarts() {
+			return nil, nil // 以降は NaN
+		}
+		fmt.Fprintf(os.Stderr, "[exec] restarting (%d of %d)\n", atomic.LoadInt64(&p.restarts), p.cfg.restarts())
+		next, err := p.start()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[exec] %v\n", err)
+			continue
+		}
+		proc = next
+	}
+}
+
+// roundTrip: 1 行を送って、返事の 1 行を id ごとの map にする
+func (p *execPool) roundTrip(proc *execProc, line []byte) (map[int64]execWire, error) {
+	if _, err := proc.in.Write(line); err != nil {
+		return nil, err
+	}
+	var timeout <-chan time.Time
+	if p.cfg.Timeout > 0 {
+		t := time.NewTimer(p.cfg.Timeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+	select {
+	case reply, ok := <-proc.lines:
+		if !ok {
+			return nil, errors.New("the process exited")
+		}
+		var got []execWire
+		if err := json.Unmarshal(reply, &got); err != nil {
+			return nil, fmt.Errorf("bad reply %q: %w", strings.TrimSpace(string(reply)), err)
+		}
+		m := make(map[int64]execWire, len(got))
+		for _, w := range got {
+			m[w.ID] = w
+		}
+		return m, nil
+	case <-timeout:
+		return nil, fmt.Errorf("no reply within %s", p.cfg.Timeout)
+	}
+}
+
+// applyExec: Config.Exec.Command があれば外部プログラムを目的関数にする（F / F2 / Objective より優先）
+func applyExec(cfg *Config) error {
+	if len(cfg.Exec.Command) == 0 {
+		return nil
+	}
+	obj, err := newExecObjective(cfg.Exec)
+	if err != nil {
+		return err
+	}
+	cfg.Objective, cfg.F2 = &obj, nil
+	return nil
+}
@@ -0,0 +1,234 @@
+// expr.go
+// 目的関数を式で書く（Config.Expr・-expr・設定ファイルの expr）
+//
+// Go のコードを書き直さずに、設定ファイルだけで探索を最後まで定義できるように、F を
+// "4*k^2*R1*R2*L1*L2*w^2 / den" のような式で書けるようにする（github.com/expr-lang/expr で評価する）。
+// 式の中では params と派生パラメータの key、pi、次の関数を使える。
+//   sqrt pow exp log（自然対数）log10 sin cos tan atan atan2 hypot（abs min max などは expr の組み込み）
+// let w = 2*pi*f; ... のように途中の量に名前を付けられる。未知の名前や文法の誤りは起動時に設定エラーにする。
+// 毎回 map を引いて解釈するので、同じ式を Go で書いた F より数倍遅い。
+// ExprCompensated なら、a + b - c + ... のような足し算・引き算の並びを 1 つの和にまとめ、
+// Neumaier 法（Kahan 法の改良）で誤差を補償しながら足す。桁の大きく違う項が打ち消し合う式で、
+// 順に足すと消えてしまう小さな項を残せる。そのぶん評価は遅くなる。
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/vm"
+)
+
+// exprFuncs: 式で使える関数（引数・戻り値とも float64）
+var exprFuncs = map[string]any{
+	"sqrt":  math.Sqrt,
+	"pow":   math.Pow,
+	"exp":   math.Exp,
+	"log":   math.Log,
+	"log10": math.Log10,
+	"sin":   math.Sin,
+	"cos":   math.Cos,
+	"tan":   math.Tan,
+	"atan":  math.Atan,
+	"atan2": math.Atan2,
+	"hypot": math.Hypot,
+}
+
+// exprConsts: 式で使える定数（同じ名前のパラメータがあればそちらを使う）
+var exprConsts = map[string]float64{"pi": math.Pi}
+
+// exprSumFunc: ExprCompensated で足し算の並びを置き換える関数の名前
+const exprSumFunc = "_compensated_sum"
+
+// exprFunc: 式を F にする（keys は式の中で使える変数の名前。compensated なら足し算を Neumaier 法で行う）
+func exprFunc(src string, keys []string, compensated bool) (func(map[string]float64) float64, error) {
+	env := make(map[string]float64, len(keys))
+	for _, k := range keys {
+		env[k] = 0
+	}
+	opts := []expr.Option{expr.Env(env), expr.Patch(constPatcher{env})}
+	for name, fn := range exprFuncs {
+		opts = append(opts, floatFunction(name, fn))
+	}
+	if compensated {
+		opts = append(opts, expr.Patch(sumPatcher{}), expr.Function(exprSumFunc, func(args ...any) (any, error) {
+			v := make([]float64, len(args))
+			for j, a := range args {
+				switch a := a.(type) {
+				case float64:
+					v[j] = a
+				case int:
+					v[j] = float64(a)
+				default:
+					return nil, fmt.Errorf("sum: not a number: %v", a)
+				}
+			}
+			return neumaierSum(v), nil
+		}, new(func(...float64) float64)))
+	}
+	prog, err := expr.Compile(src, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("expr: %w", err)
+	}
+	return func(x map[string]float64) float64 {
+		return runExpr(prog, x)
+	}, nil
+}
+
+// runExpr: 式を x で評価する（評価できなければ NaN）
+func runExpr(prog *vm.Program, x map[string]float64) float64 {
+	out, err := expr.Run(prog, x)
+	if err != nil {
+		return math.NaN()
+	}
+	switch v := out.(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	}
+	return math.NaN()
+}
+
+// floatFunction: float64 の関数を、整数の引数（sqrt(2) など）も受け取る expr の関数にする
+func floatFunction(name string, fn any) expr.Option {
+	call := func(args ...any) (any, error) {
+		v := make([]float64, len(args))
+		for j, a := range args {
+			switch a := a.(type) {
+			case float64:
+				v[j] = a
+			case int:
+				v[j] = float64(a)
+			default:
+				return nil, fmt.Errorf("%s: not a number: %v", name, a)
+			}
+		}
+		switch f := fn.(type) {
+		case func(float64) float64:
+			return f(v[0]), nil
+		case func(float64, float64) float64:
+			return f(v[0], v[1]), nil
+		}
+		return nil, fmt.Errorf("%s: unsupported function", name)
+	}
+	switch fn.(type) {
+	case func(float64) float64:
+		return expr.Function(name, call, new(func(float64) float64), new(func(int) float64))
+	}
+	return expr.Function(name, call,
+		new(func(float64, float64) float64), new(func(int, float64) float64),
+		new(func(float64, int) float64), new(func(int, int) float64))
+}
+
+// constPatcher: pi などの名前を値に置き換える
+type constPatcher struct{ env map[string]float64 }
+
+func (p constPatcher) Visit(node *ast.Node) {
+	id, ok := (*node).(*ast.IdentifierNode)
+	if !ok {
+		return
+	}
+	if _, isParam := p.env[id.Value]; isParam {
+		return
+	}
+	if v, ok := exprConsts[id.Value]; ok {
+		ast.Patch(node, &ast.FloatNode{Value: v})
+	}
+}
+
+// neumaierSum: 誤差を補償した和（Neumaier 法。項が打ち消し合っても小さな項を落とさない）
+func neumaierSum(v []float64) float64 {
+	var sum, c float64
+	for _, x := range v {
+		t := sum + x
+		if math.Abs(sum) >= math.Abs(x) {
+			c += (sum - t) + x
+		} else {
+			c += (x - t) + sum
+		}
+		sum = t
+	}
+	return sum + c
+}
+
+// sumPatcher: 数の足し算・引き算の並びを exprSumFunc の呼び出し 1 つにまとめる
+// （子から先に訪れるので、左右がすでにまとめた呼び出しなら、その項を取り込む）
+type sumPatcher struct{}
+
+func (sumPatcher) Visit(node *ast.Node) {
+	b, ok := (*node).(*ast.BinaryNode)
+	if !ok || (b.Operator != "+" && b.Operator != "-") || !isNumberNode(b.Left) || !isNumberNode(b.Right) {
+		return
+	}
+	args := sumTerms(b.Left, false)
+	args = append(args, sumTerms(b.Right, b.Operator == "-")...)
+	ast.Patch(node, &ast.CallNode{Callee: &ast.IdentifierNode{Value: exprSumFunc}, Arguments: args})
+}
+
+// sumTerms: n を和の項に分ける（neg なら符号を反転する）
+func sumTerms(n ast.Node, neg bool) []ast.Node {
+	if call, ok := n.(*ast.CallNode); ok {
+		if id, ok := call.Callee.(*ast.IdentifierNode); ok && id.Value == exprSumFunc {
+			if !neg {
+				return call.Arguments
+			}
+			out := make([]ast.Node, len(call.Arguments))
+			for i, a := range call.Arguments {
+				out[i] = &ast.UnaryNode{Operator: "-", Node: a}
+			}
+			return out
+		}
+	}
+	if neg {
+		return []ast.Node{&ast.UnaryNode{Operator: "-", Node: n}}
+	}
+	return []ast.Node{n}
+}
+
+// isNumberNode: n が数の式か（まとめた和の呼び出しも数とみなす）
+func isNumberNode(n ast.Node) bool {
+	if call, ok := n.(*ast.CallNode); ok {
+		if id, ok := call.Callee.(*ast.IdentifierNode); ok && id.Value == exprSumFunc {
+			return true
+		}
+	}
+	t := n.Type()
+	if t == nil {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Float64, reflect.Int:
+		return true
+	}
+	return false
+}
+
+// applyExpr: Config.Expr があれば F にする（F2・Objective より優先）
+func applyExpr(cfg *Config) error {
+	if cfg.Expr == "" {
+		return nil
+	}
+	var keys []string
+	for _, p := range cfg.Params {
+		keys = append(keys, p.Key)
+	}
+	for _, d := range cfg.Derived {
+		keys = append(keys, d.Key)
+	}
+	f, err := exprFunc(cfg.Expr, keys, cfg.ExprCompensated)
+	if err != nil {
+		return err
+	}
+	cfg.F, cfg.F2, cfg.Objective = f, nil, nil
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func evalExpr(t *testing.T, src string, compensated bool, x map[string]float64) float64 {
+	t.Helper()
+	var keys []string
+	for k := range x {
+		keys = append(keys, k)
+	}
+	f, err := exprFunc(src, keys, compensated)
+	if err != nil {
+		t.Fatalf("exprFunc(%q): %v", src, err)
+	}
+	return f(x)
+}
+
+// 大きな項が打ち消し合い、小さな項だけが残る和。順に足すと小さな項は大きな項の丸めで消える
+func TestExprCompensatedIllConditioned(t *testing.T) {
+	x := map[string]float64{"a": 1e16, "b": 1, "c": 0.5}
+	const src = "a + b + b + b + c - a"
+	const want = 3.5
+
+	naive := evalExpr(t, src, false, x)
+	comp := evalExpr(t, src, true, x)
+	if comp != want {
+		t.Errorf("compensated = %.17g, want %g", comp, want)
+	}
+	if math.Abs(naive-want) <= math.Abs(comp-want) {
+		t.Errorf("naive error %g is not larger than compensated error %g", math.Abs(naive-want), math.Abs(comp-want))
+	}
+
+	// 相対誤差が 1 に近い例（条件数の大きい和）
+	x = map[string]float64{"a": 1e100, "b": 1, "c": -1e100}
+	if got := evalExpr(t, "a + b + c", true, x); got != 1 {
+		t.Errorf("1e100 + 1 - 1e100 = %g, want 1", got)
+	}
+	if got := evalExpr(t, "a + b + c", false, x); got != 0 {
+		t.Errorf("naive 1e100 + 1 - 1e100 = %g, want 0 (the case compensation fixes)", got)
+	}
+}
+
+// 補償しても、打ち消しのない式の値は変わらない（符号・括弧・整数・関数の中の和も）
+func TestExprCompensatedSameValue(t *testing.T) {
+	x := map[string]float64{"f": 85e3, "k": 0.2, "L1": 140e-6, "R1": 0.5}
+	for _, src := range []string{
+		"f + k",
+		"f - k - L1",
+		"f - (k - L1) + R1",
+		"-(f + k) - R1",
+		"1 + k",
+		"2 - 1",
+		"sqrt(k + 1) * (R1 + L1)",
+		"let w = 2*pi*f; w*L1 + R1 - 3",
+		"k > 0.1 ? k + 1 : k - 1",
+	} {
+		naive := evalExpr(t, src, false, x)
+		comp := evalExpr(t, src, true, x)
+		if math.Abs(naive-comp) > 1e-12*math.Max(1, math.Abs(naive)) {
+			t.Errorf("%s: compensated %.17g, naive %.17g", src, comp, naive)
+		}
+	}
+}
+
+func TestNeumaierSum(t *testing.T) {
+	if got := neumaierSum([]float64{1, 1e100, 1, -1e100}); got != 2 {
+		t.Errorf("neumaierSum = %g, want 2", got)
+	}
+	if got := neumaierSum(nil); got != 0 {
+		t.Errorf("neumaierSum(nil) = %g, want 0", got)
+	}
+}
@@ -0,0 +1,248 @@
+// flags.go
+// よく変える Config の値をコマンドラインで上書きする（`go run . -iters 1e7 -seed 3 ...`）
+//
+// 既定値は DefaultConfig（config_local.go の LocalOverride まで反映したもの）の値で、
+// `go run . -h` で今の値が見える。指定しなかったものは Config のまま。
+// ファイルの指定（-xlsx など）は名前を渡すと有効になり、空（-xlsx=）で無効になる。
+// パラメータの項目は -set key.Field=value で 1 つずつ書き換えられる（-set L1.Min=100u -set f.Scale=linear）。
+// 目的関数など、Go のコードでしか書けないものは config_local.go で変える。
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// outputFlag: OutputSpec を 1 つの値で指定する（"" なら無効）
+type outputFlag struct{ spec *OutputSpec }
+
+func (f outputFlag) String() string {
+	if f.spec == nil || !f.spec.Enabled {
+		return ""
+	}
+	return f.spec.File
+}
+
+func (f outputFlag) Set(v string) error {
+	f.spec.Enabled = v != ""
+	if v != "" {
+		f.spec.File = v
+	}
+	return nil
+}
+
+// policyFlag: ExistPolicy を名前（overwrite / error / rename / append）で指定する
+type policyFlag struct{ p *ExistPolicy }
+
+func (f policyFlag) String() string {
+	if f.p == nil {
+		return ""
+	}
+	return f.p.String()
+}
+
+func (f policyFlag) Set(v string) error {
+	for _, p := range []ExistPolicy{Overwrite, ErrorIfExists, RenameWithSuffix, AppendToExisting} {
+		if p.String() == v {
+			*f.p = p
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown policy %q (overwrite / error / rename / append)", v)
+}
+
+// countFlag: 回数を 1e7 や 10_000_000 のようにも書ける int64
+type countFlag struct{ n *int64 }
+
+func (f countFlag) String() string {
+	if f.n == nil {
+		return "0"
+	}
+	return strconv.FormatInt(*f.n, 10)
+}
+
+func (f countFlag) Set(v string) error {
+	v = strings.ReplaceAll(v, "_", "")
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		*f.n = n
+		return nil
+	}
+	x, err := strconv.ParseFloat(v, 64)
+	if err != nil || x != float64(int64(x)) {
+		return fmt.Errorf("not a whole number: %q", v)
+	}
+	*f.n = int64(x)
+	return nil
+}
+
+// execFlag: コマンドを空白で区切って指定する（"" なら使わない）
+type execFlag struct{ cmd *[]string }
+
+func (f execFlag) String() string {
+	if f.cmd == nil {
+		return ""
+	}
+	return strings.Join(*f.cmd, " ")
+}
+
+func (f execFlag) Set(v string) error {
+	*f.cmd = strings.Fields(v)
+	return nil
+}
+
+// parseScale: Scale を名前（linear / log / auto）で指定する
+func parseScale(v string) (Scale, error) {
+	for _, sc := range []Scale{Linear, Log, Auto} {
+		if strings.EqualFold(v, sc.String()) {
+			return sc, nil
+		}
+	}
+	return Linear, fmt.Errorf("unknown scale %q (linear / log / auto)", v)
+}
+
+// setFlag: 繰り返し指定できる -set key.Field=value。cfg.Params のそのパラメータの項目を書き換える
+// 数値は 100u・85k・47nF のように書ける（units.go）。Min / Max を書き換えると Center / TolPercent は使わなくなる
+type setFlag struct{ cfg *Config }
+
+func (f setFlag) String() string { return "" }
+
+func (f setFlag) Set(v string) error {
+	lhs, val, ok := strings.Cut(v, "=")
+	key, field, ok2 := strings.Cut(lhs, ".")
+	if !ok || !ok2 {
+		return fmt.Errorf("want key.Field=value (e.g. L1.Min=100u), got %q", v)
+	}
+	j := -1
+	for i, p := range f.cfg.Params {
+		if p.Key == key {
+			j = i
+		}
+	}
+	if j < 0 {
+		keys := make([]string, len(f.cfg.Params))
+		for i, p := range f.cfg.Params {
+			keys[i] = p.Key
+		}
+		return fmt.Errorf("no param %q (%s)", key, strings.Join(keys, ", "))
+	}
+	p := f.cfg.Params[j]
+	num := func() (float64, error) { return parseNumber(val) }
+	var err error
+	switch strings.ToLower(field) {
+	case "min", "max":
+		var x float64
+		if x, err = num(); err == nil {
+			if strings.EqualFold(field, "min") {
+				p.Min = x
+			} else {
+				p.Max = x
+			}
+			p.Center, p.TolPercent = 0, 0
+		}
+	case "center", "tolpercent":
+		var x float64
+		if x, err = num(); err == nil {
+			if strings.EqualFold(field, "center") {
+				p.Center = x
+			} else if p.TolPercent = x; x < 0 {
+				err = fmt.Errorf("TolPercent must be >= 0")
+			}
+			p.Min, p.Max = 0, 0
+		}
+	case "step":
+		p.Step, err = num()
+	case "displayscale":
+		p.DisplayScale, err = num()
+	case "scale":
+		p.Scale, err = parseScale(val)
+	case "label":
+		p.Label = val
+	case "gridpoints", "sigfigs", "decimalplaces":
+		var n int
+		if n, err = strconv.Atoi(val); err == nil {
+			switch strings.ToLower(field) {
+			case "gridpoints":
+				p.GridPoints = n
+			case "sigfigs":
+				p.SigFigs = n
+			default:
+				p.DecimalPlaces = n
+			}
+		}
+	default:
+		return fmt.Errorf("%s: unknown field %q (Min / Max / Center / TolPercent / Scale / Step / DisplayScale / Label / GridPoints / SigFigs / DecimalPlaces)", key, field)
+	}
+	if err != nil {
+		return fmt.Errorf("%s.%s: %w", key, field, err)
+	}
+	// Min > Max などは -set を全部当てた後の範囲で、探索のときに確かめる（L1.Min と L1.Max の順によらないように）
+	f.cfg.Params[j] = resolveScale(resolveTolerance(p))
+	return nil
+}
+
+// configFlags: cfg のフィールドを fs のフラグにする（Parse で cfg に直接書き込む）
+func configFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.Var(countFlag{&cfg.MaxIters}, "iters", "MaxIters: number of points to evaluate (1e7 and 10_000_000 are accepted)")
+	fs.Int64Var(&cfg.Seed, "seed", cfg.Seed, "Seed")
+	fs.Float64Var(&cfg.YRange.Min, "ymin", cfg.YRange.Min, "YRange.Min")
+	fs.Float64Var(&cfg.YRange.Max, "ymax", cfg.YRange.Max, "YRange.Max")
+	fs.Float64Var(&cfg.YEpsilon, "yeps", cfg.YEpsilon, "YEpsilon: count y within this distance outside YRange as marginal")
+
+	fs.Var(setFlag{cfg}, "set", "patch one field of a param, e.g. L1.Min=100u, f.Scale=linear, k.Max=0.3 (repeatable)")
+
+	fs.StringVar(&cfg.Model, "model", cfg.Model, "Model: built-in objective by name ("+modelNames()+"; replaces F)")
+	fs.StringVar(&cfg.Plugin, "plugin", cfg.Plugin, "Plugin: Go plugin (.so) exporting F func(map[string]float64) float64 (replaces F)")
+	fs.StringVar(&cfg.WASM, "wasm", cfg.WASM, "WASM: WebAssembly module exporting eval(f64 per param) -> f64 (replaces F)")
+	fs.Var(execFlag{&cfg.Exec.Command}, "exec", "Exec.Command: external program exchanging JSON lines on stdin/stdout, e.g. \"python3 sim.py\" (replaces F)")
+	fs.IntVar(&cfg.Exec.Batch, "exec-batch", cfg.Exec.Batch, "Exec.Batch: points per line sent to -exec (0: 1; use -workers >= this)")
+	fs.StringVar(&cfg.Expr, "expr", cfg.Expr, "Expr: objective as a formula of the param keys, e.g. \"sqrt(k)*pi\" (replaces F)")
+	fs.BoolVar(&cfg.ExprCompensated, "expr-compensated", cfg.ExprCompensated, "ExprCompensated: evaluate sums in -expr with compensated (Neumaier) summation")
+
+	fs.Var(scoreFlag{&cfg.Score}, "score", "Score: weighted score ranking the OK samples, e.g. eta=0.7,margin=0.3 (keys: y, margin, params, aux outputs)")
+	fs.IntVar(&cfg.MaxOKSave, "ok-save", cfg.MaxOKSave, "MaxOKSave: OK samples to keep")
+	fs.IntVar(&cfg.MaxNGSave, "ng-save", cfg.MaxNGSave, "MaxNGSave: NG samples to keep")
+	fs.IntVar(&cfg.MaxPrint, "max-print", cfg.MaxPrint, "MaxPrint: rows to print per table (0: all)")
+	fs.IntVar(&cfg.Page, "page", cfg.Page, "Page: print only this page of the saved samples (1-based; 0: first MaxPrint rows)")
+	fs.IntVar(&cfg.PageSize, "page-size", cfg.PageSize, "PageSize: rows per page with -page (0: MaxPrint, or 50)")
+	fs.Var(countFlag{&cfg.PrintEvery}, "print-every", "PrintEvery: iterations between progress lines (negative: adapt to the speed, about every 0.5 s; 0: none)")
+	fs.DurationVar(&cfg.ProgressInterval, "progress", cfg.ProgressInterval, "ProgressInterval: time between progress lines (overrides -print-every)")
+	fs.StringVar(&cfg.ProgressLog, "progress-log", cfg.ProgressLog, "ProgressLog: write time, iterations, counts and rate at each progress line to this tsv (empty: off)")
+
+	fs.Var(outputFlag{&cfg.XLSX}, "xlsx", "XLSX.File (empty: do not save)")
+	fs.IntVar(&cfg.XLSXLimit.MaxRows, "xlsx-max-rows", cfg.XLSXLimit.MaxRows, "XLSXLimit.MaxRows: thin the OK/NG sheets above this many rows (0: 200000, negative: never)")
+	fs.IntVar(&cfg.XLSXLimit.MaxCells, "xlsx-max-cells", cfg.XLSXLimit.MaxCells, "XLSXLimit.MaxCells: thin the OK/NG sheets above this many cells (0: 5000000, negative: never)")
+	fs.Var(outputFlag{&cfg.OKTSV}, "ok-tsv", "OKTSV.File (empty: do not save)")
+	fs.Var(outputFlag{&cfg.NGTSV}, "ng-tsv", "NGTSV.File (empty: do not save)")
+	fs.Var(outputFlag{&cfg.OKCSV}, "ok-csv", "OKCSV.File (empty: do not save)")
+	fs.Var(outputFlag{&cfg.NGCSV}, "ng-csv", "NGCSV.File (empty: do not save)")
+	fs.Var(outputFlag{&cfg.OKParquet}, "ok-parquet", "OKParquet.File (empty: do not save)")
+	fs.Var(outputFlag{&cfg.NGParquet}, "ng-parquet", "NGParquet.File (empty: do not save)")
+	fs.IntVar(&cfg.CSV.Precision, "csv-precision", cfg.CSV.Precision, "CSV.Precision: significant digits in csv (0: 10, as in tsv)")
+	fs.BoolVar(&cfg.CSV.BOM, "csv-bom", cfg.CSV.BOM, "CSV.BOM: start csv with a UTF-8 BOM (for Excel in Japanese)")
+	fs.StringVar(&cfg.OutDir, "out-dir", cfg.OutDir, "OutDir: write all outputs into OutDir/<date>_<time>_<tag>/ (empty: the current directory)")
+	fs.StringVar(&cfg.RunTag, "tag", cfg.RunTag, "RunTag: name appended to the run directory with -out-dir")
+	fs.Var(outputFlag{&cfg.ConfigJSON}, "config-json", "ConfigJSON.File: the effective config as JSON (empty: do not save)")
+	fs.Var(outputFlag{&cfg.Bundle}, "bundle", "Bundle.File: pack all outputs into one .tar.zst (empty: off)")
+	fs.Var(policyFlag{&cfg.OnExisting}, "on-existing", "OnExisting: overwrite / error / rename / append")
+	fs.BoolVar(&cfg.StreamTSV, "stream-tsv", cfg.StreamTSV, "StreamTSV: append saved samples to the tsv during the run")
+	fs.StringVar(&cfg.JSONL.File, "jsonl", cfg.JSONL.File, "JSONL.File: write every evaluated sample as JSON Lines during the run (-: stdout; empty: off)")
+	fs.BoolVar(&cfg.JSONL.Saved, "jsonl-saved", cfg.JSONL.Saved, "JSONL.Saved: write only the saved OK/NG samples to -jsonl")
+	fs.StringVar(&cfg.SQLite, "sqlite", cfg.SQLite, "SQLite: write the run, its params and the saved OK/NG samples to this SQLite database (needs sqlite3; *.sql: write the SQL instead; use -on-existing append to collect runs; empty: off)")
+
+	fs.IntVar(&cfg.Workers, "workers", cfg.Workers, "Workers: goroutines evaluating in parallel (0: number of CPUs)")
+	fs.BoolVar(&cfg.Deterministic, "deterministic", cfg.Deterministic, "Deterministic: same result for any number of workers")
+	fs.StringVar(&cfg.RNG, "rng", cfg.RNG, "RNG: xoshiro (default) / pcg / mathrand")
+	fs.StringVar(&cfg.SamplingMethod, "sampling", cfg.SamplingMethod, "SamplingMethod: random / sobol / lhs / grid / mcmc")
+	fs.StringVar(&cfg.Search, "search", cfg.Search, "Search: cem / cmaes / ga / gp (empty: use -sampling)")
+	fs.IntVar(&cfg.Zoom.Phases, "zoom", cfg.Zoom.Phases, "Zoom.Phases: number of zoom phases (0 or 1: single phase)")
+
+	fs.IntVar(&cfg.Pilot, "pilot", cfg.Pilot, "Pilot: points to try before the search (0: 10000, negative: skip)")
+	fs.DurationVar(&cfg.ConfirmAbove, "confirm-above", cfg.ConfirmAbove, "ConfirmAbove: ask before runs expected to take longer (0: 10m, negative: never)")
+	fs.DurationVar(&cfg.AutosaveEvery, "autosave", cfg.AutosaveEvery, "AutosaveEvery: interval for .partial outputs (0: off)")
+	fs.DurationVar(&cfg.CheckpointEvery, "checkpoint-every", cfg.CheckpointEvery, "CheckpointEvery: interval for checkpoints (0: off)")
+	fs.StringVar(&cfg.ControlSocket, "control", cfg.ControlSocket, "ControlSocket: Unix socket accepting status / flush / save-now / set print-every N / stop (empty: off)")
+	fs.StringVar(&cfg.CheckpointFile, "checkpoint-file", cfg.CheckpointFile, "CheckpointFile (empty: checkpoint.gob)")
+}
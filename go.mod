@@ -2,7 +2,14 @@ module github.com/ichijohodaka/wpt-parameter-search2
 
 go 1.25.5
 
-require github.com/xuri/excelize/v2 v2.10.0
+require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/expr-lang/expr v1.17.8
+	github.com/klauspost/compress v1.18.0
+	github.com/tetratelabs/wazero v1.12.0
+	github.com/xuri/excelize/v2 v2.10.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/richardlehane/mscfb v1.0.4 // indirect
@@ -12,5 +19,6 @@ require (
 	github.com/xuri/nfp v0.0.2-0.20250530014748-2ddeb826f9a9 // indirect
 	golang.org/x/crypto v0.43.0 // indirect
 	golang.org/x/net v0.46.0 // indirect
+	golang.org/x/sys v0.44.0 // indirect
 	golang.org/x/text v0.30.0 // indirect
 )
@@ -0,0 +1,259 @@
+// gp.go
+// ガウス過程（GP）の代理モデルを使うベイズ最適化型の探索モード（Config.Search = "gp"）
+//
+// 評価済みの点に GP を当てはめ、候補点の中から「y が YRange に入る確率」が最大のものを次に評価する。
+// 1 点ごとに GP を当てはめ直すので 1 回の提案は重いが、評価回数は大きく減らせる。
+// 目的関数が重い（回路シミュレータを呼ぶなど）ときに MaxIters を数百程度にして使う。
+
+package main
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/ichijohodaka/wpt-parameter-search2/stats"
+)
+
+// GPConfig: GP 探索の設定
+type GPConfig struct {
+	Initial     int     // 最初に一様に評価する点数（0 なら 10）
+	Candidates  int     // 1 回の提案で比べる候補点の数（0 なら 500）
+	MaxPoints   int     // GP に使う点数の上限。超えたら古い点から捨てる（0 なら 200）
+	LengthScale float64 // RBF カーネルの長さ（u 空間。0 なら 0.2）
+	Noise       float64 // 観測ノイズ（y を標準化した単位の分散。0 なら 1e-6）
+}
+
+// GPSampler: GP 代理モデルで YRange に入る確率が最大の点を提案する
+//
+// 候補の半分は一様な点、残り半分はこれまでに最も YRange に近い点の周りの点。
+// 確率が同じ（どこも確実に OK など）ときは予測の不確かさが大きい点を選ぶ。
+// NaN / ±Inf になった点は GP には使わない。
+type GPSampler struct {
+	GPConfig
+	YRange Range
+	Score  *scorer // あれば上位と探す中心を、OK どうしは score で比べる（score.go）
+	BestN  int
+	Fixed  []bool // 範囲が 1 点（Min == Max）の軸。距離に入れない（nil ならすべて使う）
+
+	rng  *rand.Rand
+	dim  int
+	xs   [][]float64 // GP に使う点（u 空間）
+	ys   []float64
+	seen int // 受け取った点の数（NaN なども含む）
+
+	// 当てはめの結果（dirty なら作り直す）
+	dirty  bool
+	mean   float64
+	sd     float64
+	chol   [][]float64 // K + Noise·I のコレスキー分解（下三角）
+	alpha  []float64   // (K + Noise·I)⁻¹ (y − mean)/sd
+	center []float64   // 最も YRange に近い点
+	bestD  float64
+
+	best bestList
+}
+
+func (s *GPSampler) Init(dim int, seed int64) error {
+	if s.Initial <= 0 {
+		s.Initial = 10
+	}
+	if s.Candidates <= 0 {
+		s.Candidates = 500
+	}
+	if s.MaxPoints <= 0 {
+		s.MaxPoints = 200
+	}
+	if s.LengthScale <= 0 {
+		s.LengthScale = 0.2
+	}
+	if s.Noise <= 0 {
+		s.Noise = 1e-6
+	}
+	s.rng = newRand(seed)
+	s.dim = dim
+	s.xs, s.ys = nil, nil
+	s.seen = 0
+	s.dirty = true
+	s.center, s.bestD = nil, math.Inf(1)
+	s.best = bestList{n: s.BestN}
+	return nil
+}
+
+func (s *GPSampler) Next(u []float64) {
+	if s.seen < s.Initial || len(s.ys) < 2 {
+		for j := range u {
+			u[j] = s.rng.Float64()
+		}
+		return
+	}
+	if s.dirty {
+		s.fit()
+		s.dirty = false
+	}
+
+	lo := (s.YRange.Min - s.mean) / s.sd
+	hi := (s.YRange.Max - s.mean) / s.sd
+	cand := make([]float64, s.dim)
+	bestScore := math.Inf(-1)
+	for c := 0; c < s.Candidates; c++ {
+		for j := range cand {
+			if c%2 == 0 || s.center == nil {
+				cand[j] = s.rng.Float64()
+			} else {
+				cand[j] = reflectUnit(s.center[j] + s.LengthScale*s.rng.NormFloat64())
+			}
+		}
+		mu, sigma := s.predict(cand)
+		p := normCDF((hi-mu)/sigma) - normCDF((lo-mu)/sigma)
+		// 確率が同じなら不確かな点を優先（sigma ≤ 1 なので p の大小は変えない程度）
+		score := p + 1e-6*sigma
+		if score > bestScore {
+			bestScore = score
+			copy(u, cand)
+		}
+	}
+}
+
+func (s *GPSampler) Observe(u []float64, smp Sample) {
+	s.seen++
+	d := searchFitness(smp, s.YRange, s.Score)
+	s.best.add(smp, d)
+	if math.IsNaN(smp.Y) || math.IsInf(smp.Y, 0) {
+		return
+	}
+	if d < s.bestD {
+		s.bestD = d
+		s.center = append([]float64(nil), u...)
+	}
+	s.xs = append(s.xs, append([]float64(nil), u...))
+	s.ys = append(s.ys, smp.Y)
+	if len(s.xs) > s.MaxPoints {
+		s.xs = s.xs[1:]
+		s.ys = s.ys[1:]
+	}
+	s.dirty = true
+}
+
+// Best: YRange までの距離の小さい順（OK どうしは Score があれば score の大きい順、なければ見つかった順）
+func (s *GPSampler) Best() []Sample { return s.best.items }
+
+func (s *GPSampler) kernel(a, b []float64) float64 {
+	var d2 float64
+	for j := range a {
+		if j < len(s.Fixed) && s.Fixed[j] {
+			continue
+		}
+		d2 += (a[j] - b[j]) * (a[j] - b[j])
+	}
+	return math.Exp(-d2 / (2 * s.LengthScale * s.LengthScale))
+}
+
+// fit: y を標準化して GP を当てはめる
+func (s *GPSampler) fit() {
+	n := len(s.ys)
+	var m stats.Moments
+	for _, y := range s.ys {
+		m.Add(y)
+	}
+	s.mean, s.sd = m.Mean(), m.Std()
+	if s.sd == 0 {
+		s.sd = 1
+	}
+
+	// 数値的に分解できなければノイズを増やしてやり直す
+	for noise := s.Noise; ; noise *= 10 {
+		K := make([][]float64, n)
+		for i := range K {
+			K[i] = make([]float64, n)
+			for j := 0; j <= i; j++ {
+				K[i][j] = s.kernel(s.xs[i], s.xs[j])
+				K[j][i] = K[i][j]
+			}
+			K[i][i] += noise
+		}
+		if L, ok := cholesky(K); ok {
+			s.chol = L
+			break
+		}
+	}
+
+	r := make([]float64, n)
+	for i, y := range s.ys {
+		r[i] = (y - s.mean) / s.sd
+	}
+	s.alpha = cholSolve(s.chol, r)
+}
+
+// predict: 候補点での予測平均と標準偏差（標準化した y の単位）
+func (s *GPSampler) predict(x []float64) (float64, float64) {
+	n := len(s.xs)
+	k := make([]float64, n)
+	var mu float64
+	for i, xi := range s.xs {
+		k[i] = s.kernel(x, xi)
+		mu += k[i] * s.alpha[i]
+	}
+	v := forwardSub(s.chol, k)
+	var vv float64
+	for _, vi := range v {
+		vv += vi * vi
+	}
+	return mu, math.Sqrt(math.Max(1-vv, 0) + s.Noise)
+}
+
+// cholesky: 対称正定値行列 A = L·Lᵀ の L（正定値でなければ ok = false）
+func cholesky(A [][]float64) ([][]float64, bool) {
+	n := len(A)
+	L := make([][]float64, n)
+	for i := range L {
+		L[i] = make([]float64, i+1)
+		for j := 0; j <= i; j++ {
+			sum := A[i][j]
+			for k := 0; k < j; k++ {
+				sum -= L[i][k] * L[j][k]
+			}
+			if i == j {
+				if sum <= 0 {
+					return nil, false
+				}
+				L[i][i] = math.Sqrt(sum)
+			} else {
+				L[i][j] = sum / L[j][j]
+			}
+		}
+	}
+	return L, true
+}
+
+// forwardSub: L·x = b を解く（L は下三角）
+func forwardSub(L [][]float64, b []float64) []float64 {
+	x := make([]float64, len(b))
+	for i := range b {
+		sum := b[i]
+		for k := 0; k < i; k++ {
+			sum -= L[i][k] * x[k]
+		}
+		x[i] = sum / L[i][i]
+	}
+	return x
+}
+
+// cholSolve: L·Lᵀ·x = b を解く
+func cholSolve(L [][]float64, b []float64) []float64 {
+	y := forwardSub(L, b)
+	n := len(y)
+	x := make([]float64, n)
+	for i := n - 1; i >= 0; i-- {
+		sum := y[i]
+		for k := i + 1; k < n; k++ {
+			sum -= L[k][i] * x[k]
+		}
+		x[i] = sum / L[i][i]
+	}
+	return x
+}
+
+// normCDF: 標準正規分布の累積分布関数
+func normCDF(z float64) float64 {
+	return 0.5 * math.Erfc(-z/math.Sqrt2)
+}
@@ -0,0 +1,98 @@
+// guard.go
+// 起動時の設定の確認（YRange の向き・とりうる y との重なり・試し評価で OK が出るか）
+//
+// YRange の Min と Max の取り違えや、PN（0〜1）に 1 より大きい YRange を渡すような設定は、
+// 何時間も探索してから OK が 0 件と分かることが多い。探索の前に次を確かめる。
+//   - YRange.Min <= YRange.Max（NaN も不可）。違えば設定エラー
+//   - 組み込み目的関数でとりうる y の範囲（Objective.YBounds。SSPN なら [0, 1]）と YRange が重なるか。重ならなければ設定エラー
+//   - MaxIters が Pilot の 10 倍より多ければ、先に Pilot 点だけ試しに評価し、OK が 0 件なら stderr に大きく警告する
+//     （探索はそのまま続ける。評価の結果を使う探索モードでは行わない）
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"time"
+)
+
+// defaultPilot: Config.Pilot が 0 のときの試し評価の点数
+const defaultPilot = 10_000
+
+func (c Config) pilot() int64 {
+	switch {
+	case c.Pilot < 0:
+		return 0
+	case c.Pilot == 0:
+		return defaultPilot
+	}
+	return int64(c.Pilot)
+}
+
+// yRangeError: YRange が使えない設定なら理由を返す（問題なければ ""）
+func yRangeError(cfg Config) string {
+	r := cfg.YRange
+	if math.IsNaN(r.Min) || math.IsNaN(r.Max) {
+		return fmt.Sprintf("yRange is NaN ([%g, %g])", r.Min, r.Max)
+	}
+	if r.Min > r.Max {
+		return fmt.Sprintf("yRange Max (%g) < Min (%g); swap them", r.Max, r.Min)
+	}
+	if cfg.Objective != nil && cfg.Objective.YBounds != nil {
+		b := *cfg.Objective.YBounds
+		eps := cfg.YEpsilon
+		if r.Max+eps < b.Min || r.Min-eps > b.Max {
+			return fmt.Sprintf("yRange [%g, %g] is outside the possible output of the objective [%g, %g]; no point can be OK",
+				r.Min, r.Max, b.Min, b.Max)
+		}
+	}
+	return ""
+}
+
+// checkConfig: 探索の前に設定を確かめる（設定エラーなら error。試し評価の警告は stderr に書く）
+// 長くかかりそうなら 1% の試し探索をして続けるかを聞き（pilot.go。ask が false なら聞かない）、続けるなら true
+func checkConfig(cfg Config, pilot, ask bool) (bool, error) {
+	if msg := yRangeError(cfg); msg != "" {
+		return false, fmt.Errorf("config: %s", msg)
+	}
+	if !pilot || cfg.Search != "" || cfg.Sampler != nil {
+		return true, nil
+	}
+	n := cfg.pilot()
+	var rate float64
+	if n > 0 && cfg.MaxIters > 10*n {
+		start := time.Now()
+		notes, ok, err := pilotRun(cfg, n)
+		if err != nil {
+			return false, err
+		}
+		rate = float64(n) / time.Since(start).Seconds()
+		if ok == 0 {
+			warnNoOK(notes, n, cfg.MaxIters)
+		}
+	} else if cfg.confirmAbove() > 0 {
+		r, err := quickRate(cfg)
+		if err != nil {
+			return false, err
+		}
+		rate = r
+	}
+	return confirmRun(cfg, rate, ask)
+}
+
+// warnNoOK: 試し評価で OK が 0 件だったことを大きく警告する
+func warnNoOK(notes []lintNote, n, maxIters int64) {
+	fmt.Fprintf(os.Stderr, "\n!!! pilot: 0 OK in %d points (of MaxIters=%d) !!!\n", n, maxIters)
+	for _, nt := range notes {
+		if nt.Level == "hint" {
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "!!! %s\n", nt.Msg)
+		if nt.Fix != "" {
+			fmt.Fprintf(os.Stderr, "    -> %s\n", nt.Fix)
+		}
+	}
+	fmt.Fprintln(os.Stderr, "!!! continuing with the full search (Ctrl-C to stop; Pilot: -1 to skip this check)")
+	fmt.Fprintln(os.Stderr)
+}
@@ -0,0 +1,234 @@
+// importance.go
+// パラメータの重要度：評価した点（OK/NG のラベル付き）でランダムフォレストを学習し、
+// 各パラメータの不純度減少（Gini）の合計を重要度として示す。
+// 木は分岐を重ねるので、相関だけでは見えない組み合わせの効果も拾える。
+//
+// 全部の点は覚えられないので、評価した点から一様に Samples 個だけ抜き出して使う（リザーバサンプリング）。
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/ichijohodaka/wpt-parameter-search2/stats"
+)
+
+// ImportanceConfig: 重要度の設定（Enabled が false なら何もしない）
+type ImportanceConfig struct {
+	Enabled bool
+	Samples int // 学習に使う点数（0 なら 2000。Interaction と共用で、多いほうを覚える）
+	Trees   int // 木の数（0 なら 50）
+	Depth   int // 木の深さの上限（0 なら 8）
+}
+
+// Importance: 1 パラメータ分の重要度
+type Importance struct {
+	Key    string
+	Label  string
+	Forest float64 // ランダムフォレストの重要度（全パラメータで合計 1）
+	Corr   float64 // 累積分布で [0,1] に直した値と OK（1/0）の相関
+}
+
+// reservoir: 評価した点から一様に n 個を抜き出して覚える
+type reservoir struct {
+	n     int
+	seen  int64
+	items []Sample
+	rng   *rand.Rand
+}
+
+func newReservoir(n int, seed int64) *reservoir {
+	return &reservoir{n: n, rng: newRand(seed)}
+}
+
+func (r *reservoir) add(s Sample) {
+	r.seen++
+	if len(r.items) < r.n {
+		r.items = append(r.items, s)
+		return
+	}
+	if j := r.rng.Int63n(r.seen); j < int64(r.n) {
+		r.items[j] = s
+	}
+}
+
+func (c ImportanceConfig) samples() int {
+	if c.Samples > 0 {
+		return c.Samples
+	}
+	return 2000
+}
+
+// ComputeImportance: 抜き出した点から各パラメータの重要度を求める（値が変わらないパラメータは除く）
+func ComputeImportance(cfg Config, list []Sample) []Importance {
+	ic := cfg.Importance
+	trees := ic.Trees
+	if trees <= 0 {
+		trees = 50
+	}
+	depth := ic.Depth
+	if depth <= 0 {
+		depth = 8
+	}
+
+	var params []ParamSpec
+	for _, p := range cfg.Params {
+		if !isFixed(p) {
+			params = append(params, p)
+		}
+	}
+	n, d := len(list), len(params)
+	if n == 0 || d == 0 {
+		return nil
+	}
+	X := make([][]float64, n)
+	y := make([]bool, n)
+	for i, s := range list {
+		X[i] = make([]float64, d)
+		for j, p := range params {
+			X[i][j] = paramCDF(p, s.Values[p.Key])
+		}
+		y[i] = s.OK
+	}
+
+	f := forest{X: X, y: y, depth: depth, mtry: max(1, int(math.Ceil(math.Sqrt(float64(d))))),
+		rng: newRand(cfg.Seed), gain: make([]float64, d)}
+	for t := 0; t < trees; t++ {
+		idx := make([]int, n)
+		for i := range idx {
+			idx[i] = f.rng.Intn(n)
+		}
+		f.grow(idx, 0)
+	}
+
+	var total float64
+	for _, g := range f.gain {
+		total += g
+	}
+	out := make([]Importance, d)
+	for j, p := range params {
+		out[j] = Importance{Key: p.Key, Label: p.Label, Corr: pointBiserial(X, y, j)}
+		if total > 0 {
+			out[j].Forest = f.gain[j] / total
+		}
+	}
+	sort.SliceStable(out, func(a, b int) bool { return out[a].Forest > out[b].Forest })
+	return out
+}
+
+// forest: 分類木を育てながら、特徴ごとの Gini 不純度の減少（点数で重み付け）を足し込む
+type forest struct {
+	X     [][]float64
+	y     []bool
+	depth int
+	mtry  int
+	rng   *rand.Rand
+	gain  []float64
+}
+
+const (
+	forestMinLeaf = 5  // 葉の最小点数
+	forestCuts    = 16 // 1 特徴あたり試す分割位置の数
+)
+
+func gini(ok, n int) float64 {
+	if n == 0 {
+		return 0
+	}
+	p := float64(ok) / float64(n)
+	return 2 * p * (1 - p)
+}
+
+func (f *forest) grow(idx []int, level int) {
+	n := len(idx)
+	ok := 0
+	for _, i := range idx {
+		if f.y[i] {
+			ok++
+		}
+	}
+	if level >= f.depth || n < 2*forestMinLeaf || ok == 0 || ok == n {
+		return
+	}
+	parent := gini(ok, n)
+
+	bestGain, bestJ, bestT := 0.0, -1, 0.0
+	for _, j := range f.rng.Perm(len(f.gain))[:f.mtry] {
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for _, i := range idx {
+			lo = math.Min(lo, f.X[i][j])
+			hi = math.Max(hi, f.X[i][j])
+		}
+		if !(hi > lo) {
+			continue
+		}
+		for c := 0; c < forestCuts; c++ {
+			t := lo + f.rng.Float64()*(hi-lo)
+			nl, okl := 0, 0
+			for _, i := range idx {
+				if f.X[i][j] < t {
+					nl++
+					if f.y[i] {
+						okl++
+					}
+				}
+			}
+			nr := n - nl
+			if nl < forestMinLeaf || nr < forestMinLeaf {
+				continue
+			}
+			g := parent - (float64(nl)*gini(okl, nl)+float64(nr)*gini(ok-okl, nr))/float64(n)
+			if g > bestGain {
+				bestGain, bestJ, bestT = g, j, t
+			}
+		}
+	}
+	if bestJ < 0 {
+		return
+	}
+	f.gain[bestJ] += bestGain * float64(n)
+
+	var left, right []int
+	for _, i := range idx {
+		if f.X[i][bestJ] < bestT {
+			left = append(left, i)
+		} else {
+			right = append(right, i)
+		}
+	}
+	f.grow(left, level+1)
+	f.grow(right, level+1)
+}
+
+// pointBiserial: 特徴 j と OK（1/0）の相関係数（どちらかが一定なら 0）
+func pointBiserial(X [][]float64, y []bool, j int) float64 {
+	var c stats.Cov
+	for i, row := range X {
+		var v float64
+		if y[i] {
+			v = 1
+		}
+		c.Add(row[j], v)
+	}
+	return c.Corr()
+}
+
+// PrintImportance: 重要度を大きい順に表示する
+func PrintImportance(imp []Importance) {
+	fmt.Println("=== Parameter importance (random forest) ===")
+	if len(imp) == 0 {
+		fmt.Println("(no varying params or no samples)")
+		fmt.Println()
+		return
+	}
+	fmt.Printf("%-12s %10s %10s\n", "param", "forest", "corr(OK)")
+	for _, m := range imp {
+		bar := strings.Repeat("#", int(math.Round(m.Forest*40)))
+		fmt.Printf("%-12s %s %s  %s\n", m.Label, fmt4(m.Forest), fmt4(m.Corr), bar)
+	}
+	fmt.Println()
+}
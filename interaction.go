@@ -0,0 +1,185 @@
+// interaction.go
+// パラメータの組ごとの交互作用：2 つのパラメータを粗い格子（Bins × Bins）に分け、
+// 各マスの OK 確率が「それぞれ単独の効果の積」からどれだけずれるかを測る。
+// 値が大きい組は、片方ずつではなく一緒に調整する必要がある。
+//
+// マス (i, j) で、交互作用がなければ P(OK | a=i, b=j) ≈ P(OK | a=i)·P(OK | b=j) / P(OK)。
+// その差の絶対値をマスの点数で重み付けして平均したものを交互作用の値とする。
+// 点は重要度（importance.go）と同じく、評価した点から一様に抜き出したものを使う。
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+)
+
+// InteractionConfig: 交互作用の設定（Enabled が false なら何もしない）
+type InteractionConfig struct {
+	Enabled bool
+	Bins    int // 1 軸あたりの分割数（0 なら 5）
+	Samples int // 使う点数（0 なら 2000）
+}
+
+func (c InteractionConfig) samples() int {
+	if c.Samples > 0 {
+		return c.Samples
+	}
+	return 2000
+}
+
+// Interaction: 交互作用の行列（値が変わらないパラメータは除く。対角は 0）
+type Interaction struct {
+	Params []ParamSpec
+	Score  [][]float64
+}
+
+// ComputeInteraction: 抜き出した点から交互作用の行列を作る
+func ComputeInteraction(cfg Config, list []Sample) *Interaction {
+	bins := cfg.Interaction.Bins
+	if bins <= 0 {
+		bins = 5
+	}
+	var params []ParamSpec
+	for _, p := range cfg.Params {
+		if !isFixed(p) {
+			params = append(params, p)
+		}
+	}
+	d, n := len(params), len(list)
+	if d == 0 || n == 0 {
+		return nil
+	}
+
+	// 各点の各パラメータのマス番号
+	bin := make([][]int, n)
+	okTotal := 0
+	for i, s := range list {
+		bin[i] = make([]int, d)
+		for j, p := range params {
+			t := paramCDF(p, s.Values[p.Key])
+			bin[i][j] = min(max(int(t*float64(bins)), 0), bins-1)
+		}
+		if s.OK {
+			okTotal++
+		}
+	}
+	pAll := float64(okTotal) / float64(n)
+
+	// 単独の OK 確率
+	marg := make([][]float64, d)
+	for j := range params {
+		cnt := make([]int, bins)
+		ok := make([]int, bins)
+		for i, s := range list {
+			cnt[bin[i][j]]++
+			if s.OK {
+				ok[bin[i][j]]++
+			}
+		}
+		marg[j] = make([]float64, bins)
+		for b := range cnt {
+			if cnt[b] > 0 {
+				marg[j][b] = float64(ok[b]) / float64(cnt[b])
+			}
+		}
+	}
+
+	score := make([][]float64, d)
+	for a := range score {
+		score[a] = make([]float64, d)
+	}
+	if pAll > 0 {
+		for a := 0; a < d; a++ {
+			for b := a + 1; b < d; b++ {
+				cnt := make([]int, bins*bins)
+				ok := make([]int, bins*bins)
+				for i, s := range list {
+					c := bin[i][a]*bins + bin[i][b]
+					cnt[c]++
+					if s.OK {
+						ok[c]++
+					}
+				}
+				var sum float64
+				for c := range cnt {
+					if cnt[c] == 0 {
+						continue
+					}
+					joint := float64(ok[c]) / float64(cnt[c])
+					indep := marg[a][c/bins] * marg[b][c%bins] / pAll
+					sum += float64(cnt[c]) * math.Abs(joint-indep)
+				}
+				score[a][b] = sum / float64(n)
+				score[b][a] = score[a][b]
+			}
+		}
+	}
+	return &Interaction{Params: params, Score: score}
+}
+
+// PrintInteraction: 交互作用の行列を表示する（列見出しは Key）
+func PrintInteraction(m *Interaction) {
+	fmt.Println("=== Parameter interaction (OK probability, joint vs. product of marginals) ===")
+	if m == nil {
+		fmt.Println("(no varying params or no samples)")
+		fmt.Println()
+		return
+	}
+	fmt.Printf("%-12s", "")
+	for _, p := range m.Params {
+		fmt.Printf(" %10s", p.Key)
+	}
+	fmt.Println()
+	for a, p := range m.Params {
+		fmt.Printf("%-12s", p.Label)
+		for b := range m.Params {
+			if a == b {
+				fmt.Printf(" %10s", "-")
+			} else {
+				fmt.Printf(" %s", fmt4(m.Score[a][b]))
+			}
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+}
+
+// SaveInteractionTSV: 交互作用の行列を TSV で保存し、実際に保存したファイル名を返す
+func SaveInteractionTSV(filename string, policy ExistPolicy, m *Interaction) (string, error) {
+	// 行列は追記しても意味がないので、AppendToExisting でも上書きする
+	name, _, err := resolveOutput(filename, policy)
+	if err != nil {
+		return "", err
+	}
+	fp, err := os.Create(name)
+	if err != nil {
+		return "", err
+	}
+	defer fp.Close()
+
+	w := csv.NewWriter(fp)
+	w.Comma = '\t'
+	if m != nil {
+		header := []string{""}
+		for _, p := range m.Params {
+			header = append(header, p.Label)
+		}
+		if err := w.Write(header); err != nil {
+			return "", err
+		}
+		for a, p := range m.Params {
+			row := []string{p.Label}
+			for b := range m.Params {
+				row = append(row, fmt.Sprintf("%.10g", m.Score[a][b]))
+			}
+			if err := w.Write(row); err != nil {
+				return "", err
+			}
+		}
+	}
+	w.Flush()
+	return name, w.Error()
+}
@@ -0,0 +1,116 @@
+// jsonl.go
+// 評価したサンプルを JSON Lines で書き足す（Config.JSONL）
+//
+// 探索中の結果を jq・Python・データベースの読み込みにそのまま流せるように、記録した順に 1 行 1 件の
+// {"values":{...},"y":...,"ok":...} を書く（values の列は tsv と同じ。-machine -machine-samples の 1 件と同じ形）。
+// 既定ではすべての評価を書き、Saved なら OK / NG の保存の枠が空いていて保存したものだけを書く。
+// File が "-" なら標準出力に書き、ふだんの表示は標準エラーに回す（`go run . -jsonl - | jq ...`）。
+// 書いた分は 1 秒ごとに書き出すので、実行中でも読める。INVALID の点は y が null で ok が false になる。
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// jsonlFlushEvery: 書きためた行を書き出す間隔
+const jsonlFlushEvery = time.Second
+
+// JSONLConfig: JSON Lines の出力の設定（File が "" なら書かない）
+type JSONLConfig struct {
+	File  string // 書き出すファイル（"-" なら標準出力）
+	Saved bool   // 保存したサンプルだけを書く（false ならすべての評価）
+}
+
+// jsonlWriter: 書いている JSON Lines
+type jsonlWriter struct {
+	name    string
+	f       *os.File // 標準出力なら nil（閉じない）
+	w       *bufio.Writer
+	enc     *json.Encoder
+	cols    []Column
+	flushed time.Time
+	err     error
+}
+
+// openJSONL: JSONL が有効なら書き出し先を開く（探索の前に呼ぶ。stdout は "-" のときの書き出し先）
+func (e *engine) openJSONL(stdout *os.File) error {
+	c := e.cfg.JSONL
+	if c.File == "" {
+		return nil
+	}
+	j := &jsonlWriter{name: c.File, cols: e.columns(), flushed: time.Now()}
+	if c.File == "-" {
+		j.name = "stdout"
+		j.w = bufio.NewWriter(stdout)
+	} else {
+		name, appendMode, err := resolveOutput(c.File, e.cfg.OnExisting)
+		if err != nil {
+			return err
+		}
+		flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if appendMode {
+			flag = os.O_WRONLY | os.O_APPEND
+		}
+		f, err := os.OpenFile(name, flag, 0o644)
+		if err != nil {
+			return err
+		}
+		j.name, j.f, j.w = name, f, bufio.NewWriter(f)
+	}
+	j.enc = json.NewEncoder(j.w)
+	e.jsonl = j
+	return nil
+}
+
+// writeJSONL: 記録したサンプルを 1 行書く（saved は保存の枠に入れるか）
+func (e *engine) writeJSONL(s Sample, saved bool) {
+	j := e.jsonl
+	if j == nil || j.w == nil || j.err != nil || (e.cfg.JSONL.Saved && !saved) {
+		return
+	}
+	s = e.filled(s)
+	j.err = j.enc.Encode(toMachineSamples(j.cols, []Sample{s})[0])
+	if j.err == nil && time.Since(j.flushed) >= jsonlFlushEvery {
+		j.err = j.w.Flush()
+		j.flushed = time.Now()
+	}
+}
+
+// flush: 書きためた行を書き出す（制御ソケットの flush から）
+func (j *jsonlWriter) flush() error {
+	if j.err != nil {
+		return j.err
+	}
+	if err := j.w.Flush(); err != nil {
+		return err
+	}
+	if j.f != nil {
+		return j.f.Sync()
+	}
+	return nil
+}
+
+// closeJSONL: 書き残しを書いて閉じ、書き出し先と最初のエラーを返す（2 回目以降は何もしない）
+func (e *engine) closeJSONL() (string, error) {
+	j := e.jsonl
+	if j == nil {
+		return "", nil
+	}
+	if j.w == nil {
+		return j.name, j.err
+	}
+	if err := j.w.Flush(); j.err == nil {
+		j.err = err
+	}
+	if j.f != nil {
+		if err := j.f.Close(); j.err == nil {
+			j.err = err
+		}
+	}
+	j.w = nil
+	return j.name, j.err
+}
@@ -0,0 +1,201 @@
+// lint.go
+// 設定の点検（`go run . lint`）
+//
+// 学生の設定を見るときにいつも確認していることをまとめたもの。探索はせず、
+// 範囲の広さと Linear / Log の選び方、固定なのに Log にしているパラメータなどを調べ、
+// 少しだけ試しに評価（パイロット）して、YRange が実際に出る y と噛み合っているかを見る。
+// 直し方の提案を表示し、error があれば ExitConfigError で終わる。
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// lintPilot: パイロットで評価する点数
+const lintPilot = 2000
+
+// lintNote: 点検で見つかったこと 1 つ
+type lintNote struct {
+	Level string // "error" / "warn" / "hint"
+	Where string // "param f" / "yRange" など
+	Msg   string // 何が問題か
+	Fix   string // どう直すか（"" なら提案なし）
+}
+
+// lintConfig: 設定だけを見て点検する（目的関数は呼ばない）
+func lintConfig(cfg Config) []lintNote {
+	var notes []lintNote
+	add := func(level, where, msg, fix string) {
+		notes = append(notes, lintNote{Level: level, Where: where, Msg: msg, Fix: fix})
+	}
+
+	if msg := yRangeError(cfg); msg != "" {
+		add("error", "yRange", msg, "")
+	}
+	if cfg.MaxIters <= 0 {
+		add("error", "MaxIters", "MaxIters <= 0: nothing will be evaluated", "set MaxIters (e.g. 1_000_000)")
+	}
+	if cfg.Sampler == nil && cfg.Search == "" && cfg.SamplingMethod == "sobol" {
+		if err := sobolPointsError(cfg.MaxIters); err != nil {
+			add("error", "MaxIters", err.Error(), "")
+		}
+	}
+	if cfg.Sampler == nil && cfg.Search == "" && cfg.SamplingMethod == "grid" {
+		points := make([]int, len(cfg.Params))
+		for j, p := range cfg.Params {
+			points[j] = p.GridPoints
+		}
+		if _, err := gridLen(points); err != nil {
+			add("error", "GridPoints", err.Error(), "")
+		}
+	}
+	if cfg.F == nil && cfg.Objective == nil {
+		add("error", "F", "no objective: F and Objective are both nil", "set F or Objective")
+	}
+
+	varying := 0
+	for _, p := range cfg.Params {
+		where := "param " + p.Key
+		if p.DisplayScale == 0 && p.Type != Categorical {
+			add("warn", where, "DisplayScale is 0: every value is shown as 0", "set DisplayScale: 1 (or the unit factor, e.g. 1e-3 for Hz→kHz)")
+		}
+		if p.Type != Real || len(p.Values) > 0 {
+			if !isFixed(p) {
+				varying++
+			}
+			continue
+		}
+		if p.Max < p.Min {
+			add("error", where, fmt.Sprintf("Max (%g) < Min (%g)", p.Max, p.Min), "swap Min and Max")
+			continue
+		}
+		if p.Min == p.Max {
+			if p.Scale == Log {
+				add("hint", where, "fixed value but Scale is Log (has no effect, and requires Min>0)", "use Scale: Linear for fixed params")
+			}
+			if p.GridPoints > 1 {
+				add("warn", where, fmt.Sprintf("fixed value but GridPoints=%d: the grid repeats the same point", p.GridPoints), "set GridPoints: 1")
+			}
+			continue
+		}
+		varying++
+		if p.Dist.Kind == Uniform && p.Scale == Log && p.Min <= 0 {
+			add("error", where, fmt.Sprintf("Scale is Log but Min=%g <= 0", p.Min), "use Scale: Linear, or make Min > 0")
+		}
+		if msg, fix := scaleSuggestion(p); msg != "" {
+			level := "hint"
+			if p.Scale == Linear {
+				level = "warn"
+			}
+			add(level, where, msg, fix)
+		}
+	}
+	if varying == 0 && len(cfg.Params) > 0 {
+		add("warn", "params", "no param varies (every Min == Max): the search evaluates one point", "widen the range of the params you want to search")
+	}
+	return notes
+}
+
+// pilotRun: n 点だけ試しに評価し、YRange と実際の y を比べる（OK の数も返す）
+func pilotRun(cfg Config, n int64) ([]lintNote, int, error) {
+	cc := cfg
+	cc.MaxIters = n
+	cc.Sampler, cc.Search, cc.SamplingMethod = nil, "", "random"
+	cc.Antithetic = false
+	cc.Zoom = ZoomConfig{}
+	e, err := newEngine(cc)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var ys []float64
+	bad, ok := 0, 0
+	u := make([]float64, len(cc.Params))
+	for i := int64(0); i < n; i++ {
+		s, err := e.draw(e.params, u)
+		if err != nil {
+			return nil, 0, err
+		}
+		if s.Invalid {
+			continue
+		}
+		if math.IsNaN(s.Y) || math.IsInf(s.Y, 0) {
+			bad++
+			continue
+		}
+		if s.OK {
+			ok++
+		}
+		ys = append(ys, s.Y)
+	}
+
+	var notes []lintNote
+	add := func(level, msg, fix string) {
+		notes = append(notes, lintNote{Level: level, Where: "pilot", Msg: msg, Fix: fix})
+	}
+	total := len(ys) + bad
+	if total == 0 {
+		add("warn", "every pilot point was rejected by ParamConstraints", "check ParamConstraints against the param ranges")
+		return notes, 0, nil
+	}
+	if bad > 0 {
+		add("warn", fmt.Sprintf("F returned NaN/Inf for %d of %d points", bad, total),
+			"check for division by zero or log of a negative value near the range ends")
+	}
+	if len(ys) == 0 {
+		return notes, 0, nil
+	}
+	sort.Float64s(ys)
+	q := func(p float64) float64 { return ys[min(int(p*float64(len(ys))), len(ys)-1)] }
+	add("hint", fmt.Sprintf("y over %d points: min=%.4g p1=%.4g median=%.4g p99=%.4g max=%.4g; OK_ratio=%.4g",
+		len(ys), ys[0], q(0.01), q(0.5), q(0.99), ys[len(ys)-1], float64(ok)/float64(total)), "")
+
+	r := cfg.YRange
+	switch {
+	case r.Min > ys[len(ys)-1] || r.Max < ys[0]:
+		add("warn", "no pilot point is near yRange: the search will likely find no OK",
+			fmt.Sprintf("check the units of F and yRange, or widen the param ranges (pilot y is in [%.4g, %.4g])", ys[0], ys[len(ys)-1]))
+	case ok == 0:
+		add("warn", "yRange overlaps the pilot y range, but no pilot point is OK",
+			"expect a low OK ratio; consider MaxIters in the millions, or Search: \"mcmc\"/\"gp\" to concentrate near OK")
+	case float64(ok)/float64(total) > 0.99:
+		add("hint", "almost every point is OK: yRange does not constrain the design",
+			fmt.Sprintf("tighten yRange (pilot p1=%.4g, p99=%.4g)", q(0.01), q(0.99)))
+	}
+	return notes, ok, nil
+}
+
+// runLint: 点検して結果を表示し、終了コードを返す
+func runLint(cfg Config) int {
+	notes := lintConfig(cfg)
+	hasErr := false
+	for _, nt := range notes {
+		hasErr = hasErr || nt.Level == "error"
+	}
+	if !hasErr {
+		more, _, err := pilotRun(cfg, lintPilot)
+		if err != nil {
+			notes = append(notes, lintNote{Level: "error", Where: "pilot", Msg: err.Error()})
+			hasErr = true
+		}
+		notes = append(notes, more...)
+	}
+
+	fmt.Println("=== lint ===")
+	if len(notes) == 0 {
+		fmt.Println("(no findings)")
+	}
+	for _, nt := range notes {
+		fmt.Printf("[%s] %s: %s\n", nt.Level, nt.Where, nt.Msg)
+		if nt.Fix != "" {
+			fmt.Printf("       -> %s\n", nt.Fix)
+		}
+	}
+	if hasErr {
+		return ExitConfigError
+	}
+	return ExitOK
+}
@@ -0,0 +1,104 @@
+// machine.go
+// -machine: 人間向けの表示をすべて止め、最後に JSON を 1 つだけ stdout に書く
+// （シェルのパイプや CI からパラメータスタディを回すため）
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+)
+
+// jsonFloat: NaN / ±Inf を null として書く float64（JSON には NaN がないため）
+type jsonFloat float64
+
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	v := float64(f)
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return []byte("null"), nil
+	}
+	return strconv.AppendFloat(nil, v, 'g', -1, 64), nil
+}
+
+type machineSample struct {
+	Values map[string]jsonFloat `json:"values"`
+	Y      jsonFloat            `json:"y"`
+	OK     bool                 `json:"ok"`
+}
+
+type machineReport struct {
+	Seed        int64             `json:"seed"`
+	YRange      [2]jsonFloat      `json:"yRange"`
+	Iters       int64             `json:"iters"`
+	OKHits      int64             `json:"okHits"`
+	NGHits      int64             `json:"ngHits"`
+	Boundary    int64             `json:"boundaryHits"`
+	Marginal    int64             `json:"marginalHits"`
+	Invalid     int64             `json:"invalidHits"`
+	OKRatio     jsonFloat         `json:"okRatio"`
+	NGRatio     jsonFloat         `json:"ngRatio"`
+	Interrupted bool              `json:"interrupted"`
+	Columns     []string          `json:"columns"`
+	OK          *[]machineSample  `json:"ok,omitempty"`
+	NG          *[]machineSample  `json:"ng,omitempty"`
+	Best        []machineSample   `json:"best,omitempty"`
+	Files       map[string]string `json:"files,omitempty"`
+	Errors      []string          `json:"errors,omitempty"`
+}
+
+func toMachineSamples(cols []Column, list []Sample) []machineSample {
+	out := make([]machineSample, 0, len(list))
+	for _, s := range list {
+		vals := make(map[string]jsonFloat, len(cols))
+		for _, c := range cols {
+			vals[c.Key] = jsonFloat(s.Values[c.Key])
+		}
+		out = append(out, machineSample{Values: vals, Y: jsonFloat(s.Y), OK: s.OK})
+	}
+	return out
+}
+
+// WriteMachineJSON: 結果を JSON 1 つにして書く（withSamples なら保存した OK/NG も含める）
+// files は保存したファイル（種類 → ファイル名）、errs は保存時などのエラー
+func WriteMachineJSON(w io.Writer, res Result, interrupted, withSamples bool, files map[string]string, errs []string) error {
+	var okRatio, ngRatio float64
+	if res.Iters > 0 {
+		okRatio = float64(res.OKHits) / float64(res.Iters)
+		ngRatio = float64(res.NGHits) / float64(res.Iters)
+	}
+	cols := make([]string, 0, len(res.Columns))
+	for _, c := range res.Columns {
+		cols = append(cols, c.Key)
+	}
+
+	rep := machineReport{
+		Seed:        res.Seed,
+		YRange:      [2]jsonFloat{jsonFloat(res.YRange.Min), jsonFloat(res.YRange.Max)},
+		Iters:       res.Iters,
+		OKHits:      res.OKHits,
+		NGHits:      res.NGHits,
+		Boundary:    res.BoundaryHits,
+		Marginal:    res.MarginalHits,
+		Invalid:     res.InvalidHits,
+		OKRatio:     jsonFloat(okRatio),
+		NGRatio:     jsonFloat(ngRatio),
+		Interrupted: interrupted,
+		Columns:     cols,
+		Files:       files,
+		Errors:      errs,
+	}
+	if withSamples {
+		ok := toMachineSamples(res.Columns, res.OKList)
+		ng := toMachineSamples(res.Columns, res.NGList)
+		rep.OK, rep.NG = &ok, &ng
+		if len(res.Best) > 0 {
+			rep.Best = toMachineSamples(res.Columns, res.Best)
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rep)
+}
@@ -7,18 +7,20 @@
 // - OK/NG をそれぞれ最大 N 件保存（枠が埋まっても探索は継続）
 // - 終了条件：繰り返し回数到達 or Ctrl-C
 //
-// 表示は output.go 側で params の DisplayScale/Label を使って自動化する
+// 探索ループは engine.go、表示は output.go 側で params の DisplayScale/Label を使って自動化する
 
 package main
 
 import (
+	"bytes"
 	"context"
+	"flag"
 	"fmt"
 	"math"
-	"math/rand"
 	"os"
 	"os/signal"
-	"sync/atomic"
+	"syscall"
+	"time"
 )
 
 type Scale int
@@ -26,8 +28,22 @@ type Scale int
 const (
 	Linear Scale = iota
 	Log
+	Auto // Max/Min から Linear / Log を選ぶ（scale.go）
 )
 
+func (s Scale) String() string {
+	switch s {
+	case Linear:
+		return "linear"
+	case Log:
+		return "log"
+	case Auto:
+		return "auto"
+	default:
+		return fmt.Sprintf("Scale(%d)", int(s))
+	}
+}
+
 // ParamSpec: 変数の定義（探索範囲 + サンプリング方式 + 表示用メタ）
 type ParamSpec struct {
 	Key          string  // map のキー（例: "f"）
@@ -36,12 +52,74 @@ type ParamSpec struct {
 	Max          float64 // 探索範囲 max（元単位）
 	Scale        Scale   // Linear / Log（サンプリング用）
 	DisplayScale float64 // 表示用スケール（例: Hz→kHz は 1e-3）
+	GridPoints   int     // grid モードでの分点数（0, 1 なら中央 1 点）
+	Step         float64 // mcmc モードの提案幅（Linear は元単位、Log は ln の幅。0 なら範囲の 5%）
+	Dist         Dist    // 一様以外の分布（dist.go。ゼロ値なら Scale に従う一様）
+
+	// 中心値 ± 許容差 [%] での指定（tolerance.go）。どちらかを指定すると Min / Max を計算する
+	// 例: {Key: "L1", Label: "L1 [µH]", Center: 140e-6, TolPercent: 20, Scale: Linear, DisplayScale: 1e6}
+	Center     float64
+	TolPercent float64
+
+	// 離散値（昇順。values.go）。指定すると [Min, Max] に入る値から等確率で選び、Scale / Dist は使わない
+	// 例: Values: E12(10e-9, 100e-9)
+	Values []float64
+
+	// 整数・カテゴリ（paramtype.go）。Int は [Min, Max] の整数、Categorical は Choices から選ぶ
+	Type    ParamType
+	Choices []Choice
+
+	// 環境パラメータ（scenario.go）。設計ごとに Config.Scenario.Inner 通りの値で評価し、歩留まりを求める
+	Env bool
+
+	// 表示の桁数（表示単位での値に対して。0 なら %.4g）。DecimalPlaces は小数点以下の桁数で、SigFigs より優先
+	// 例: k は SigFigs: 2、f [kHz] は SigFigs: 5。コンソールの表と xlsx の表示形式に使う（TSV は常に %.10g）
+	SigFigs       int
+	DecimalPlaces int
 }
 
 type Sample struct {
 	Values map[string]float64 // 元単位で保持
 	Y      float64
 	OK     bool
+
+	Invalid bool // パラメータの制約を満たさず評価しなかった（Y は NaN。constraint.go）
+
+	x []float64 // F2 で評価したときの params の値（Values を作るまで。slice.go）
+}
+
+// Result: 探索 1 回分の結果（PostProcess や出力に渡す）
+type Result struct {
+	Params  []ParamSpec
+	Columns []Column // 出力列（params + 目的関数の補助出力）
+	YRange  Range
+	Seed    int64
+	Iters   int64
+	OKHits  int64
+	NGHits  int64
+	OKList  []Sample
+	NGList  []Sample
+	Phases  []Phase  // 多段探索の各段（絞り込みなしなら 1 段）
+	Best    []Sample // 最適化型の探索モードでの上位（評価値の良い順）
+	Refined int      // OKList のうち焼きなましで救済したサンプル数（anneal.go）
+	Unsure  int      // OKList のうち浮動小数点誤差しだいで NG になりうる数（verify.go）
+
+	BoundaryHits int64 // y が YRange の端にあった数（OK / NG のどちらかにも数えている。boundary.go）
+	MarginalHits int64 // y が YRange の外側 YEpsilon 以内にあった数（同上）
+	InvalidHits  int64 // パラメータの制約を満たさず INVALID として数えた数（iters に含み、OK / NG には含まない）
+	Rejected     int64 // 制約を満たさず引き直した数（iters に含まない）
+	Repaired     int64 // 制約を満たす点に直して評価した数（OK / NG に含む）
+
+	Distance    *DistanceStats // NG の YRange までの距離の分布（Config.NGDistance が無効なら nil）
+	Strata      *Strata        // 層ごとの OK 率（Config.Strata が無効なら nil）
+	Prior       *PriorStats    // 事前分布で重み付けした OK 率（Config.Prior が nil なら nil）
+	YRanges     []YRangeStat   // YRange の候補ごとの OK 率（Antithetic も CompareYRanges もなければ nil）
+	Importance  []Importance   // パラメータの重要度（Config.Importance が無効なら nil）
+	Interaction *Interaction   // パラメータの組ごとの交互作用（Config.Interaction が無効なら nil）
+
+	Recommendation *Recommendation // 推奨仕様（Config.Recommend が無効なら nil）
+
+	Config *RunConfig // 実際に使った設定（xlsx の Config シート・tsv の先頭・ConfigJSON に書く。provenance.go）
 }
 
 type Range struct {
@@ -53,13 +131,22 @@ func inRange(x float64, r Range) bool {
 	return r.Min <= x && x <= r.Max
 }
 
-func sampleOne(rng *rand.Rand, p ParamSpec) (float64, error) {
-	if p.Max < p.Min {
+// sampleOne: Sampler が作った u ∈ [0,1) を p の範囲・スケールに変換する
+func sampleOne(u float64, p ParamSpec) (float64, error) {
+	if p.Max < p.Min && p.Type != Categorical {
 		return 0, fmt.Errorf("param %s: Max < Min", p.Key)
 	}
+	if p.Type != Real {
+		return typedQuantile(u, p)
+	}
+	if len(p.Values) > 0 {
+		return valueQuantile(u, p)
+	}
+	if p.Dist.Kind != Uniform {
+		return distQuantile(u, p)
+	}
 	switch p.Scale {
 	case Linear:
-		u := rng.Float64()
 		return p.Min + u*(p.Max-p.Min), nil
 	case Log:
 		if p.Min <= 0 || p.Max <= 0 {
@@ -67,158 +154,554 @@ func sampleOne(rng *rand.Rand, p ParamSpec) (float64, error) {
 		}
 		lnMin := math.Log(p.Min)
 		lnMax := math.Log(p.Max)
-		u := rng.Float64()
 		return math.Exp(lnMin + u*(lnMax-lnMin)), nil
 	default:
 		return 0, fmt.Errorf("param %s: unknown scale", p.Key)
 	}
 }
 
+// 終了コード（ラッパースクリプトが出力を読まずに分岐できるように）
+// 設定ミスの panic も Go のランタイムにより 2 で終了する
+const (
+	ExitOK          = 0 // 最後まで探索し、OK が 1 件以上
+	ExitError       = 1 // 実行時エラー（評価・PostProcess・出力の保存など）
+	ExitConfigError = 2 // 設定エラー
+	ExitNoOK        = 3 // 最後まで探索したが OK が 0 件
+	ExitInterrupted = 4 // Ctrl-C などのシグナルで中断
+)
+
 func main() {
+	os.Exit(runMain())
+}
+
+// runMain: main の本体。defer を実行してから終了コードを返す
+func runMain() int {
+	// Config の値はフラグで上書きできる（flags.go）
 	cfg := DefaultConfig()
+	// preset（presets.go）と設定ファイル（configfile.go）はフラグより先に読み、フラグで上書きできるようにする
+	flag.String("preset", "", "apply named presets in order, comma-separated ("+presetNames()+")")
+	flag.String("config", "", "read params, yrange, iters and output files from a YAML / TOML file (flags override it)")
+	if list := flagArg(os.Args[1:], "preset"); list != "" {
+		if err := applyPresets(list, &cfg); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			return ExitConfigError
+		}
+	}
+	if path := flagArg(os.Args[1:], "config"); path != "" {
+		if err := loadConfigFile(path, &cfg); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			return ExitConfigError
+		}
+	}
+	configFlags(flag.CommandLine, &cfg)
 
-	params := cfg.Params
-	yRange := cfg.YRange
-	maxIters := cfg.MaxIters
-	maxOKSave := cfg.MaxOKSave
-	maxNGSave := cfg.MaxNGSave
-	printEvery := cfg.PrintEvery
-	seed := cfg.Seed
-	xlsxFile := cfg.XLSXFile
-	f := cfg.F
-
-	// params のキー重複チェック
-	{
-		seen := map[string]bool{}
-		for _, p := range params {
-			if p.Key == "" {
-				panic("param key is empty")
-			}
-			if seen[p.Key] {
-				panic("duplicate param key: " + p.Key)
-			}
-			seen[p.Key] = true
+	machine := flag.Bool("machine", false, "suppress human-readable output and write one JSON document to stdout")
+	machineSamples := flag.Bool("machine-samples", false, "with -machine, include saved OK/NG samples in the JSON")
+	resume := flag.String("resume", "", "continue an interrupted run from a checkpoint file (see CheckpointEvery)")
+	cpuProfile := flag.String("cpuprofile", "", "write a CPU profile of the search to this file")
+	memProfile := flag.String("memprofile", "", "write a memory (allocs) profile after the search to this file")
+	traceFile := flag.String("trace", "", "write an execution trace of the search to this file")
+	remote := flag.String("remote", "", "comma-separated worker addresses (host:port) to distribute the search over (see `worker`)")
+	shard := flag.Int64("shard", 0, "with -remote, points per shard (0: MaxIters / (8 × workers))")
+	shardTimeout := flag.Duration("shard-timeout", 0, "with -remote, hand a shard to another worker after this long (0: 10 × the slowest finished shard, at least 1m; negative: never)")
+	yes := flag.Bool("yes", false, "do not ask before a long run (see ConfirmAbove)")
+	objective := flag.String("objective", "", "replace the objective with a check whose OK ratio is known ("+demoNames()+")")
+	dryRun := flag.Bool("dry-run", false, "check the config, try the objective on a few points and print the effective config without searching (same as `validate`)")
+	flag.Parse()
+
+	// -machine: 表示は全部捨て、JSON だけを本来の stdout に書く
+	jsonOut := os.Stdout
+	if *machine {
+		devnull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			return ExitError
+		}
+		defer devnull.Close()
+		os.Stdout = devnull
+	}
+	// -jsonl -: JSON Lines を本来の stdout に書き、表示は stderr に回す（jsonl.go）
+	if cfg.JSONL.File == "-" {
+		if *machine {
+			fmt.Fprintln(os.Stderr, "error: -jsonl - and -machine both write to stdout")
+			return ExitConfigError
+		}
+		os.Stdout = os.Stderr
+	}
+
+	// F の代わりに名前・プラグイン・WebAssembly・外部プログラム・式で指定した目的関数（models.go）
+	if err := applyObjectiveSource(&cfg); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return ExitConfigError
+	}
+
+	// 確認用の目的関数（demo.go）
+	var dm demo
+	if *objective != "" {
+		d, err := applyDemo(&cfg, *objective)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			return ExitConfigError
+		}
+		dm = d
+	}
+
+	// サブコマンド
+	switch flag.Arg(0) {
+	case "":
+		if *dryRun {
+			return runValidate(cfg)
+		}
+	case "lint":
+		return runLint(cfg)
+	case "validate":
+		return runValidate(cfg)
+	case "regen":
+		return runRegen(cfg, flag.Args()[1:])
+	case "analyze":
+		return runAnalyze(cfg, flag.Args()[1:])
+	case "meta":
+		return runMeta(cfg, flag.Args()[1:])
+	case "init":
+		return runInit(flag.Args()[1:])
+	case "review":
+		return runReview(flag.Args()[1:])
+	case "bench":
+		return runBench(cfg, flag.Args()[1:])
+	case "worker":
+		return runWorker(cfg, flag.Args()[1:])
+	case "control":
+		return runControlClient(flag.Args()[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "error: unknown command %q\n", flag.Arg(0))
+		return ExitConfigError
+	}
+
+	// 表示のログを bundle に入れるため、stdout を写し取る（bundle.go）
+	var tee *logTee
+	if cfg.Bundle.Enabled || cfg.OutDir != "" {
+		t, err := teeStdout()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			return ExitError
+		}
+		tee = t
+		defer tee.stop()
+	}
+	printScaleSuggestions(cfg.Params)
+
+	// 設計パラメータと環境パラメータの入れ子の探索（scenario.go）
+	sc, err := applyScenario(&cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return ExitConfigError
+	}
+	if sc != nil {
+		sc.PrintScenario()
+	}
+
+	// 複数のマシンで分担する（distributed.go）。番号ごとの乱数を使う
+	addrs := parseWorkers(*remote)
+	if len(addrs) > 0 {
+		if sc != nil {
+			fmt.Fprintln(os.Stderr, "error: distributed: not available with Env params (scenario)")
+			return ExitConfigError
+		}
+		cfg.Deterministic = true
+	}
+
+	// 実行ごとのディレクトリ（rundir.go）。出力のファイル名をその中にする
+	runDir, err := applyOutDir(&cfg, time.Now())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return ExitConfigError
+	}
+
+	// 途中から再開（checkpoint.go）。Seed は保存した状態のものを使う
+	var ck *Checkpoint
+	if *resume != "" {
+		c, err := LoadCheckpoint(*resume)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			return ExitConfigError
 		}
+		ck = c
+		cfg.Seed = ck.Seed
+		cfg.CheckpointFile = *resume
 	}
 
-	// Ctrl-C 対応
+	// YRange の確認と試し評価（guard.go / pilot.go）。再開・分担のときは試し評価しない
+	proceed, err := checkConfig(cfg, ck == nil && len(addrs) == 0, !*yes && !*machine)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return ExitConfigError
+	}
+	if !proceed {
+		fmt.Fprintln(os.Stderr, "stopped before the search")
+		return ExitInterrupted
+	}
+	if runDir != "" {
+		if err := os.MkdirAll(runDir, 0o755); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			return ExitError
+		}
+		fmt.Printf("output directory: %s\n", runDir)
+		defer func() {
+			if err := writeRunLog(runDir, tee.stop()); err != nil {
+				fmt.Fprintln(os.Stderr, "log save error:", err)
+			}
+		}()
+	}
+
+	files, err := resolveOutputs(cfg, time.Now())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return ExitConfigError
+	}
+
+	// Ctrl-C 対応（ジョブスケジューラや docker stop の SIGTERM も同じく、途中結果を保存して ExitInterrupted で終わる）
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, os.Interrupt)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
 	go func() {
-		<-sigCh
-		fmt.Println("\n[Ctrl-C] interrupt received. stopping...")
+		sig := <-sigCh
+		if sig == os.Interrupt {
+			fmt.Println("\n[Ctrl-C] interrupt received. stopping...")
+		} else {
+			fmt.Printf("\n[%v] received. stopping...\n", sig)
+		}
 		cancel()
 	}()
 
-	rng := rand.New(rand.NewSource(seed))
-
-	okList := make([]Sample, 0, maxOKSave)
-	ngList := make([]Sample, 0, maxNGSave)
+	e, err := newEngine(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return ExitConfigError
+	}
+	checkpointFile := cfg.checkpointFile()
+	if ck != nil || cfg.CheckpointEvery > 0 {
+		if ck != nil {
+			err = e.restore(ck)
+		} else {
+			err = e.checkpointable()
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			return ExitConfigError
+		}
+		e.checkpoint = func(c Checkpoint) {
+			if err := SaveCheckpoint(checkpointFile, c); err != nil {
+				fmt.Println("\ncheckpoint error:", err)
+			}
+		}
+	}
+	if ck != nil {
+		fmt.Printf("[resume] %s: iters=%d  OK_hits=%d  NG_hits=%d  elapsed=%s\n",
+			*resume, ck.Iters, ck.OKHits, ck.NGHits, ck.Elapsed.Round(time.Second))
+	}
+	partial := files
+	if cfg.StreamTSV {
+		// tsv は書き足しているので途中結果は xlsx だけ
+		if err := e.openStreams(files); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			return ExitError
+		}
+		partial.OKTSV, partial.NGTSV = "", ""
+		defer e.closeStreams() // エラーで途中で戻っても書いた分は残す
+	}
+	if err := e.openEvalLog(); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return ExitError
+	}
+	defer e.closeEvalLog()
+	if err := e.openJSONL(jsonOut); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return ExitError
+	}
+	defer e.closeJSONL()
+	if err := e.openProgressLog(); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return ExitError
+	}
+	defer e.closeProgressLog()
+	e.autosave = func(res Result) {
+		if err := savePartial(partial, res, cfg); err != nil {
+			fmt.Println("\nautosave error:", err)
+		}
+	}
+	// 制御ソケット（control.go）
+	if cfg.ControlSocket != "" {
+		closeControl, err := e.serveControl(cfg.ControlSocket, cancel)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			return ExitConfigError
+		}
+		defer closeControl()
+	}
+	prof, err := startProfile(*cpuProfile, *memProfile, *traceFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return ExitError
+	}
+	if len(addrs) > 0 {
+		err = e.distribute(ctx, addrs, *shard, *shardTimeout)
+	} else {
+		err = e.run(ctx)
+	}
+	e.endProgressLog()
+	if perr := prof.stop(); perr != nil {
+		fmt.Fprintln(os.Stderr, "\nprofile error:", perr)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\nerror:", err)
+		return ExitError
+	}
+	fmt.Println()
+	if e.checkpoint != nil && ctx.Err() != nil {
+		c := e.snapshot()
+		e.checkpoint(c)
+		fmt.Printf("[checkpoint] saved: %s (iters=%d, elapsed=%s; continue with -resume %s)\n",
+			checkpointFile, c.Iters, c.Elapsed.Round(time.Second), checkpointFile)
+	}
 
-	var iters int64
-	var okHits int64
-	var ngHits int64
+	if cfg.Anneal.Enabled && ctx.Err() == nil {
+		e.anneal(ctx)
+	}
+	if cfg.Verify.Enabled {
+		e.unsure = e.verify()
+	}
 
-	// 進捗表示（固定幅・行の残りを消す）
-	printProgress := func(i int64) {
-		var pct float64
-		if maxIters > 0 {
-			pct = float64(i) / float64(maxIters) * 100.0
-		}
-		okh := atomic.LoadInt64(&okHits)
-		ngh := atomic.LoadInt64(&ngHits)
+	res := e.result()
+	if sc != nil {
+		res.Best = sc.best()
+		outputValues(cfg.OutputColumns, nil, nil, res.Best)
+	}
 
-		line := fmt.Sprintf(
-			"\riter=%12d (%6.2f%%)  OK_hits=%12d  NG_hits=%12d",
-			i, pct, okh, ngh,
-		)
-		fmt.Print(line + "                                    ")
+	if cfg.Importance.Enabled {
+		res.Importance = ComputeImportance(cfg, e.samples())
+	}
+	if cfg.Interaction.Enabled {
+		res.Interaction = ComputeInteraction(cfg, e.samples())
 	}
 
-	for {
-		i := atomic.LoadInt64(&iters)
-		if i >= maxIters {
-			break
+	if cfg.Recommend.Enabled {
+		rec, err := Recommend(ctx, cfg, res.OKList)
+		if err != nil {
+			fmt.Println("recommend error:", err)
 		}
-		select {
-		case <-ctx.Done():
-			goto DONE
-		default:
+		res.Recommendation = rec
+	}
+
+	// 探索後・出力前のユーザー処理（派生列の追加、アップロードなど）
+	if cfg.PostProcess != nil {
+		if err := cfg.PostProcess(res); err != nil {
+			fmt.Fprintln(os.Stderr, "postprocess error:", err)
+			return ExitError
 		}
+	}
 
-		vals := make(map[string]float64, len(params))
-		for _, p := range params {
-			v, err := sampleOne(rng, p)
-			if err != nil {
-				fmt.Println("\nerror:", err)
-				return
-			}
-			vals[p.Key] = v
+	PrintSummary(res.Seed, res.YRange, res.Iters, res.OKHits, res.NGHits)
+	if res.BoundaryHits > 0 || cfg.Boundary != Inclusive {
+		fmt.Printf("boundary_hits=%d  (policy=%v)\n\n", res.BoundaryHits, cfg.Boundary)
+	}
+	if cfg.YEpsilon > 0 {
+		as := "OK"
+		if cfg.MarginalNG {
+			as = "NG"
 		}
+		fmt.Printf("marginal_hits=%d  (within %s outside yRange, counted as %s)\n\n", res.MarginalHits, fmt4(cfg.YEpsilon), as)
+	}
+	if len(cfg.ParamConstraints) > 0 {
+		fmt.Printf("INVALID=%d  rejected=%d  repaired=%d  (constraints=%d, policy=%v)\n\n",
+			res.InvalidHits, res.Rejected, res.Repaired, len(cfg.ParamConstraints), cfg.Constraint)
+	}
 
-		y := f(vals)
-		ok := !math.IsNaN(y) && !math.IsInf(y, 0) && inRange(y, yRange)
+	if *objective != "" {
+		PrintDemoCheck(*objective, dm, res)
+	}
 
-		if ok {
-			atomic.AddInt64(&okHits, 1)
-		} else {
-			atomic.AddInt64(&ngHits, 1)
+	printSamples(cfg, "=== OK (saved) ===", res.Columns, res.OKList)
+	fmt.Println()
+	printSamples(cfg, "=== NG (saved) ===", res.Columns, res.NGList)
+
+	if len(res.Best) > 0 {
+		fmt.Println()
+		printSamples(cfg, "=== Best ===", res.Columns, res.Best)
+	}
+
+	if cfg.NGDistance {
+		fmt.Println()
+		PrintDistance(res.Distance)
+	}
+
+	if cfg.Strata.Enabled {
+		fmt.Println()
+		PrintStrata(res.Strata)
+	}
+
+	if res.Prior != nil {
+		fmt.Println()
+		PrintPrior(res.Prior, res.Iters, res.OKHits)
+	}
+
+	if res.YRanges != nil {
+		fmt.Println()
+		PrintYRangeComparison(res.YRanges, cfg.Antithetic)
+	}
+
+	if cfg.Importance.Enabled {
+		fmt.Println()
+		PrintImportance(res.Importance)
+	}
+
+	if cfg.Interaction.Enabled {
+		fmt.Println()
+		PrintInteraction(res.Interaction)
+	}
+
+	if cfg.Recommend.Enabled {
+		fmt.Println()
+		PrintRecommendation(res.Recommendation)
+	}
+
+	saved := map[string]string{}
+	var saveErrs []string
+	report := func(kind string, name string, err error) {
+		if err != nil {
+			fmt.Printf("%s save error: %v\n", kind, err)
+			saveErrs = append(saveErrs, kind+": "+err.Error())
+			return
 		}
+		fmt.Printf("%s saved: %s\n", kind, name)
+		saved[kind] = name
+	}
 
-		// 保存は「枠が空いているときだけ」。枠が埋まっても探索は続行。
-		s := Sample{Values: vals, Y: y, OK: ok}
-		if ok {
-			if maxOKSave > 0 && len(okList) < maxOKSave {
-				okList = append(okList, s)
-			}
-		} else {
-			if maxNGSave > 0 && len(ngList) < maxNGSave {
-				ngList = append(ngList, s)
+	if files.XLSX != "" {
+		// 大きすぎる OK / NG シートは間引く（output.go）
+		for _, l := range []struct {
+			sheet string
+			list  []Sample
+			full  string
+		}{{"OK", res.OKList, files.fullList(true)}, {"NG", res.NGList, files.fullList(false)}} {
+			if k := cfg.XLSXLimit.stride(len(l.list), len(res.Columns)+2); k > 1 {
+				fmt.Println("xlsx:", xlsxThinNote(l.sheet, k, len(l.list), l.full))
 			}
 		}
-
-		n := atomic.AddInt64(&iters, 1)
-		if printEvery > 0 && (n%printEvery == 0) {
-			printProgress(n)
+		name, err := SaveToXLSX(files.XLSX, cfg.OnExisting, res.Columns, res.OKList, res.NGList, res.Iters, res.OKHits, res.NGHits, res.Config,
+			cfg.XLSXLimit, files.fullList(true), files.fullList(false))
+		report("xlsx", name, err)
+		if full := files.fullList(true); err != nil && full != "" {
+			fmt.Printf("xlsx: the OK samples are still saved in %s\n", full)
 		}
 	}
 
-DONE:
-	fmt.Println()
+	switch {
+	case e.okStream != nil:
+		name, err := e.okStream.close()
+		report("tsv (OK)", name, err)
+	case files.OKTSV != "":
+		name, err := SaveListToTSV(files.OKTSV, cfg.OnExisting, res.Columns, res.OKList, res.Config)
+		report("tsv (OK)", name, err)
+	}
 
-	total := atomic.LoadInt64(&iters)
-	okc := atomic.LoadInt64(&okHits)
-	ngc := atomic.LoadInt64(&ngHits)
+	switch {
+	case e.ngStream != nil:
+		name, err := e.ngStream.close()
+		report("tsv (NG)", name, err)
+	case files.NGTSV != "":
+		name, err := SaveListToTSV(files.NGTSV, cfg.OnExisting, res.Columns, res.NGList, res.Config)
+		report("tsv (NG)", name, err)
+	}
 
-	PrintSummary(seed, yRange, total, okc, ngc)
+	if files.OKCSV != "" {
+		name, err := SaveListToCSV(files.OKCSV, cfg.OnExisting, res.Columns, res.OKList, cfg.CSV)
+		report("csv (OK)", name, err)
+	}
+	if files.NGCSV != "" {
+		name, err := SaveListToCSV(files.NGCSV, cfg.OnExisting, res.Columns, res.NGList, cfg.CSV)
+		report("csv (NG)", name, err)
+	}
 
-	PrintSampleTable("=== OK (saved) ===", params, okList, cfg.MaxPrint)
-	fmt.Println()
-	PrintSampleTable("=== NG (saved) ===", params, ngList, cfg.MaxPrint)
+	if files.OKParquet != "" {
+		name, err := SaveListToParquet(files.OKParquet, cfg.OnExisting, res.Columns, res.OKList, res.Config)
+		report("parquet (OK)", name, err)
+	}
+	if files.NGParquet != "" {
+		name, err := SaveListToParquet(files.NGParquet, cfg.OnExisting, res.Columns, res.NGList, res.Config)
+		report("parquet (NG)", name, err)
+	}
 
-	if xlsxFile != "" {
-		if err := SaveToXLSX(xlsxFile, params, okList, ngList, total, okc, ngc); err != nil {
-			fmt.Println("xlsx save error:", err)
-		} else {
-			fmt.Println("xlsx saved:", xlsxFile)
+	if files.Interaction != "" {
+		name, err := SaveInteractionTSV(files.Interaction, cfg.OnExisting, res.Interaction)
+		report("tsv (interaction)", name, err)
+	}
+
+	if e.evalLog != nil {
+		name, err := e.closeEvalLog()
+		report("tsv (evals)", name, err)
+	}
+
+	if e.jsonl != nil {
+		name, err := e.closeJSONL()
+		report("jsonl", name, err)
+	}
+
+	if e.progLog != nil {
+		name, err := e.closeProgressLog()
+		report("tsv (progress)", name, err)
+	}
+
+	if cfg.SQLite != "" {
+		name, err := SaveToSQLite(cfg.SQLite, cfg.OnExisting, res, cfg.RunTag)
+		report("sqlite", name, err)
+	}
+
+	if files.ConfigJSON != "" {
+		name, err := SaveRunConfig(files.ConfigJSON, cfg.OnExisting, res.Config)
+		report("json (config)", name, err)
+	}
+
+	if files.Bundle != "" {
+		var js bytes.Buffer
+		err := WriteMachineJSON(&js, res, ctx.Err() != nil, true, saved, saveErrs)
+		if err == nil {
+			var name string
+			name, err = SaveBundle(files.Bundle, cfg.OnExisting, cfg, res, ctx.Err() != nil, saved, js.Bytes(), tee.stop())
+			if err == nil {
+				fmt.Printf("bundle saved: %s\n", name)
+				saved["bundle"] = name
+			}
+		}
+		if err != nil {
+			fmt.Printf("bundle save error: %v\n", err)
+			saveErrs = append(saveErrs, "bundle: "+err.Error())
 		}
 	}
 
-	if cfg.OKTSVFile != "" {
-		if err := SaveListToTSV(cfg.OKTSVFile, params, okList); err != nil {
-			fmt.Println("tsv save error (OK):", err)
-		} else {
-			fmt.Println("tsv saved (OK):", cfg.OKTSVFile)
+	// 最後まで保存できたら途中結果は不要
+	if len(saveErrs) == 0 {
+		removePartial(files)
+		if e.checkpoint != nil && ctx.Err() == nil {
+			os.Remove(checkpointFile)
 		}
 	}
 
-	if cfg.NGTSVFile != "" {
-		if err := SaveListToTSV(cfg.NGTSVFile, params, ngList); err != nil {
-			fmt.Println("tsv save error (NG):", err)
-		} else {
-			fmt.Println("tsv saved (NG):", cfg.NGTSVFile)
+	if *machine {
+		if err := WriteMachineJSON(jsonOut, res, ctx.Err() != nil, *machineSamples, saved, saveErrs); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
 		}
 	}
+
+	// 保存できなかった出力があれば、中断や OK の数より先に知らせる（結果が残っていないので）
+	switch {
+	case len(saveErrs) > 0:
+		fmt.Fprintf(os.Stderr, "error: %d output(s) could not be saved (see the save errors above)\n", len(saveErrs))
+		return ExitError
+	case ctx.Err() != nil:
+		return ExitInterrupted
+	case res.OKHits == 0:
+		return ExitNoOK
+	}
+	return ExitOK
 }
@@ -0,0 +1,296 @@
+// meta.go
+// 仕様の 2 つのつまみを格子で振って OK 率の表を作る（`go run . meta -x ymin=0.1:0.5:5 -y k.max=0.2:0.4:3`）
+//
+// 「YRange の下限をここまで上げると、k の上限をどこまで広げれば OK 率が保てるか」のような仕様の兼ね合いを、
+// 1 回のコマンドで見るためのもの。-x と -y のつまみの値の組ごとに探索を 1 回ずつ行い、OK 率
+// （OK / (OK + NG)）を行が -y・列が -x の表にして、濃淡の文字を添えて表示する。-tsv で表を保存する
+// （gnuplot の `plot 'heat.tsv' matrix nonuniform with image` でそのまま heatmap にできる）。
+// つまみは ymin・ymax（YRange の下限・上限）と <key>.min・<key>.max（パラメータの範囲）。値は
+// min:max:n（両端を含む n 点の等間隔）か、カンマ区切りの列挙で書く。
+// どの組も同じ Seed で探索するので（共通乱数）、隣の組との差に点の引き方のばらつきが乗りにくい。
+// 1 組の探索は -iters 回（0 なら MaxIters）。結果のファイル・途中保存・進行状況の表示はしない。
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// metaShades: OK 率の濃淡（0 から表の最大へ。OK 率が小さくても差が見えるように）
+const metaShades = " .:-=+*#%@"
+
+// metaKnob: 格子で振るつまみ
+type metaKnob struct {
+	Name   string
+	Values []float64
+}
+
+// parseMetaKnob: "name=min:max:n" か "name=v1,v2,..." を読む
+func parseMetaKnob(s string) (metaKnob, error) {
+	name, spec, ok := strings.Cut(s, "=")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return metaKnob{}, fmt.Errorf("knob %q: want name=min:max:n or name=v1,v2,...", s)
+	}
+	k := metaKnob{Name: name}
+	if parts := strings.Split(spec, ":"); len(parts) == 3 {
+		lo, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+		hi, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		n, err3 := strconv.Atoi(strings.TrimSpace(parts[2]))
+		if err1 != nil || err2 != nil || err3 != nil || n < 1 {
+			return metaKnob{}, fmt.Errorf("knob %q: want min:max:n with n >= 1", s)
+		}
+		for i := range n {
+			v := lo
+			if n > 1 {
+				v = lo + (hi-lo)*float64(i)/float64(n-1)
+			}
+			k.Values = append(k.Values, v)
+		}
+		return k, nil
+	}
+	for _, f := range strings.Split(spec, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil {
+			return metaKnob{}, fmt.Errorf("knob %q: %q is not a number", s, f)
+		}
+		k.Values = append(k.Values, v)
+	}
+	return k, nil
+}
+
+// checkMetaKnob: cfg で使えるつまみか
+func checkMetaKnob(cfg Config, name string) error {
+	cc := cfg
+	cc.Params = append([]ParamSpec(nil), cfg.Params...)
+	return setMetaKnob(&cc, name, 0)
+}
+
+// setMetaKnob: cfg のつまみを v にする（cfg.Params は呼び出し側で複製しておく）
+func setMetaKnob(cfg *Config, name string, v float64) error {
+	switch name {
+	case "ymin":
+		cfg.YRange.Min = v
+		return nil
+	case "ymax":
+		cfg.YRange.Max = v
+		return nil
+	}
+	key, end, _ := strings.Cut(name, ".")
+	for i := range cfg.Params {
+		p := &cfg.Params[i]
+		if p.Key != key {
+			continue
+		}
+		if p.Type == Categorical {
+			return fmt.Errorf("knob %s: %s is categorical", name, key)
+		}
+		switch end {
+		case "min":
+			p.Min = v
+		case "max":
+			p.Max = v
+		default:
+			return fmt.Errorf("knob %s: want %s.min or %s.max", name, key, key)
+		}
+		p.Center, p.TolPercent = 0, 0 // 中心値 ± 許容差より、振った範囲を使う
+		return nil
+	}
+	return fmt.Errorf("knob %s: want ymin, ymax, <key>.min or <key>.max (no param %q)", name, key)
+}
+
+// metaCell: x = xv・y = yv の組で探索し、OK 率を返す（範囲が逆さまなら NaN）
+func metaCell(ctx context.Context, cfg Config, x, y string, xv, yv float64) (float64, error) {
+	cc := cfg
+	cc.Params = append([]ParamSpec(nil), cfg.Params...)
+	if err := setMetaKnob(&cc, x, xv); err != nil {
+		return math.NaN(), err
+	}
+	if err := setMetaKnob(&cc, y, yv); err != nil {
+		return math.NaN(), err
+	}
+	if cc.YRange.Min > cc.YRange.Max {
+		return math.NaN(), nil
+	}
+	for _, p := range cc.Params {
+		if p.Max < p.Min || (p.Scale == Log && p.Min <= 0) {
+			return math.NaN(), nil
+		}
+	}
+	if _, err := applyScenario(&cc); err != nil {
+		return math.NaN(), err
+	}
+	e, err := newEngine(cc)
+	if err != nil {
+		return math.NaN(), err
+	}
+	if err := e.run(ctx); err != nil {
+		return math.NaN(), err
+	}
+	res := e.result()
+	if res.OKHits+res.NGHits == 0 {
+		return math.NaN(), nil
+	}
+	return float64(res.OKHits) / float64(res.OKHits+res.NGHits), nil
+}
+
+// metaShade: OK 率の濃淡の文字（top は表の最大）
+func metaShade(r, top float64) byte {
+	if math.IsNaN(r) {
+		return '?'
+	}
+	if top <= 0 {
+		return metaShades[0]
+	}
+	i := int(r / top * float64(len(metaShades)-1))
+	return metaShades[min(max(i, 0), len(metaShades)-1)]
+}
+
+// runMeta: meta サブコマンド
+func runMeta(cfg Config, args []string) int {
+	fs := flag.NewFlagSet("meta", flag.ContinueOnError)
+	xSpec := fs.String("x", "", "knob for the columns: name=min:max:n or name=v1,v2,... (name: ymin, ymax, <key>.min, <key>.max)")
+	ySpec := fs.String("y", "", "knob for the rows (same form as -x)")
+	iters := fs.Int64("iters", 0, "iterations per cell (0: MaxIters)")
+	tsv := fs.String("tsv", "", "save the OK ratio matrix to this tsv (gnuplot: matrix nonuniform)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: go run . [flags of the run] meta -x name=min:max:n -y name=min:max:n [-iters N] [-tsv file]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return ExitConfigError
+	}
+	if *xSpec == "" || *ySpec == "" || fs.NArg() > 0 {
+		fs.Usage()
+		return ExitConfigError
+	}
+	xk, err := parseMetaKnob(*xSpec)
+	var yk metaKnob
+	if err == nil {
+		yk, err = parseMetaKnob(*ySpec)
+	}
+	if err == nil && xk.Name == yk.Name {
+		err = fmt.Errorf("-x and -y are both %s", xk.Name)
+	}
+	if err == nil {
+		err = errors.Join(checkMetaKnob(cfg, xk.Name), checkMetaKnob(cfg, yk.Name))
+	}
+	if err == nil && *iters < 0 {
+		err = fmt.Errorf("-iters must not be negative")
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error: meta:", err)
+		return ExitConfigError
+	}
+
+	// 1 組ごとの探索は結果を残さず、表示もしない
+	cc := cfg
+	if *iters > 0 {
+		cc.MaxIters = *iters
+	}
+	cc.PrintEvery, cc.ProgressInterval = 0, 0
+	cc.AutosaveEvery, cc.CheckpointEvery = 0, 0
+	cc.MaxOKSave, cc.MaxNGSave = 0, 0
+	cc.StreamTSV = false
+	cc.ControlSocket = ""
+	cc.EvalLog = EvalLogConfig{}
+	cc.Importance.Enabled, cc.Interaction.Enabled = false, false
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	n := len(xk.Values) * len(yk.Values)
+	fmt.Printf("=== meta: %s × %s, %d cells × %d iters (seed %d) ===\n", yk.Name, xk.Name, n, cc.MaxIters, cc.Seed)
+	ratio := make([][]float64, len(yk.Values))
+	done := 0
+	for i, yv := range yk.Values {
+		ratio[i] = make([]float64, len(xk.Values))
+		for j, xv := range xk.Values {
+			ratio[i][j] = math.NaN()
+			if ctx.Err() != nil {
+				continue
+			}
+			r, err := metaCell(ctx, cc, xk.Name, yk.Name, xv, yv)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "\nerror: meta: %s=%.6g %s=%.6g: %v\n", xk.Name, xv, yk.Name, yv, err)
+				return ExitError
+			}
+			if ctx.Err() != nil {
+				continue // 途中で止めた組は数えない
+			}
+			ratio[i][j] = r
+			done++
+			fmt.Printf("\r[meta] %d/%d  %s=%.6g  %s=%.6g  OK_ratio=%s      ", done, n, xk.Name, xv, yk.Name, yv, fmt4(r))
+		}
+	}
+	fmt.Println()
+	if ctx.Err() != nil {
+		fmt.Printf("[Ctrl-C] stopped after %d of %d cells (the rest are NaN)\n", done, n)
+	}
+
+	top := 0.0
+	for _, row := range ratio {
+		for _, r := range row {
+			if r > top {
+				top = r
+			}
+		}
+	}
+	fmt.Printf("\n=== OK ratio (rows: %s, columns: %s) ===\n", yk.Name, xk.Name)
+	fmt.Printf("%12s", yk.Name+"\\"+xk.Name)
+	for _, xv := range xk.Values {
+		fmt.Printf(" %12.5g", xv)
+	}
+	fmt.Println()
+	for i, yv := range yk.Values {
+		fmt.Printf("%12.5g", yv)
+		for _, r := range ratio[i] {
+			fmt.Printf(" %10.4f %c", r, metaShade(r, top))
+		}
+		fmt.Println()
+	}
+	fmt.Printf("(shade: %q from 0 to the largest ratio %s; ? = no samples or an empty range)\n", metaShades, strings.TrimSpace(fmt4(top)))
+
+	if *tsv != "" {
+		err := replaceFile(*tsv, func(w io.Writer) error {
+			return writeMetaTSV(w, xk, yk, ratio)
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			return ExitError
+		}
+		fmt.Printf("tsv saved: %s\n", *tsv)
+	}
+	return ExitOK
+}
+
+// writeMetaTSV: gnuplot の matrix nonuniform の形（先頭の行は列の数と -x の値、各行は -y の値と OK 率）
+func writeMetaTSV(w io.Writer, xk, yk metaKnob, ratio [][]float64) error {
+	g := func(v float64) string { return strconv.FormatFloat(v, 'g', 10, 64) }
+	var b strings.Builder
+	fmt.Fprintf(&b, "# OK ratio: rows %s, columns %s\n", yk.Name, xk.Name)
+	b.WriteString(strconv.Itoa(len(xk.Values)))
+	for _, xv := range xk.Values {
+		b.WriteString("\t" + g(xv))
+	}
+	b.WriteString("\n")
+	for i, yv := range yk.Values {
+		b.WriteString(g(yv))
+		for _, r := range ratio[i] {
+			b.WriteString("\t" + g(r))
+		}
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
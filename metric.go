@@ -0,0 +1,115 @@
+// metric.go
+// サンプル同士の距離（Config.Metric）
+//
+// 代表値のクラスタリング（Recommend.Clusters の k-means。recommend.go）のように、
+// 点と点の近さを使う機能は、ここで決めた同じ距離を使う。
+// 各パラメータを探索範囲で [0, 1] に正規化し（Log の軸なら対数で）、重みをかけて距離にする。
+//   - Kind: "euclidean"（既定）/ "manhattan" / "chebyshev"
+//   - Weights: パラメータごとの重み（書かなければ 1。0 ならその軸を無視する。Euclidean では差の 2 乗にかける）
+//   - Log / Linear: 距離を測る軸を ParamSpec.Scale と変える（例: 探索は Linear でも C1 は比で近さを見たい）
+// 固定値のパラメータ（Min == Max）は距離に入らない。
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// MetricConfig: サンプル同士の距離の設定（ゼロ値なら正規化した Euclidean 距離）
+type MetricConfig struct {
+	Kind    string             // "euclidean" / "manhattan" / "chebyshev"（空なら euclidean）
+	Weights map[string]float64 // パラメータのキーごとの重み（0 以上）
+	Log     []string           // 対数の軸で測るパラメータ（Min > 0 のもの）
+	Linear  []string           // 線形の軸で測るパラメータ
+}
+
+// metric: params に合わせて用意した距離
+type metric struct {
+	params []ParamSpec // Scale を距離の軸に置き換えたもの
+	w      []float64
+	kind   string
+}
+
+// newMetric: params の距離を用意する（知らないキー・負の重み・Min <= 0 の Log ならエラー）
+func newMetric(mc MetricConfig, params []ParamSpec) (metric, error) {
+	m := metric{params: slices.Clone(params), w: make([]float64, len(params)), kind: strings.ToLower(mc.Kind)}
+	switch m.kind {
+	case "":
+		m.kind = "euclidean"
+	case "euclidean", "manhattan", "chebyshev":
+	default:
+		return metric{}, fmt.Errorf("metric: unknown kind %q (euclidean / manhattan / chebyshev)", mc.Kind)
+	}
+	index := map[string]int{}
+	for j, p := range params {
+		index[p.Key] = j
+		m.w[j] = 1
+	}
+	for k, w := range mc.Weights {
+		j, ok := index[k]
+		if !ok {
+			return metric{}, fmt.Errorf("metric: weight for unknown param %q", k)
+		}
+		if !(w >= 0) || math.IsInf(w, 0) {
+			return metric{}, fmt.Errorf("metric: weight for %s must be >= 0 (got %g)", k, w)
+		}
+		m.w[j] = w
+	}
+	for _, axis := range []struct {
+		keys  []string
+		scale Scale
+	}{{mc.Log, Log}, {mc.Linear, Linear}} {
+		for _, k := range axis.keys {
+			j, ok := index[k]
+			if !ok {
+				return metric{}, fmt.Errorf("metric: unknown param %q", k)
+			}
+			if axis.scale == Log && m.params[j].Min <= 0 {
+				return metric{}, fmt.Errorf("metric: %s cannot use a log axis (Min = %g)", k, m.params[j].Min)
+			}
+			m.params[j].Scale = axis.scale
+		}
+	}
+	return m, nil
+}
+
+// coords: サンプルの値を距離の軸で [0, 1] に正規化した座標にする
+func (m metric) coords(values map[string]float64) []float64 {
+	x := make([]float64, len(m.params))
+	for j, p := range m.params {
+		x[j] = normalize(p, values[p.Key])
+	}
+	return x
+}
+
+// values: coords の逆（座標を元の単位の値にする）
+func (m metric) values(x []float64) map[string]float64 {
+	v := make(map[string]float64, len(m.params))
+	for j, p := range m.params {
+		v[p.Key] = denormalize(p, x[j])
+	}
+	return v
+}
+
+// dist: 座標 a, b の距離
+func (m metric) dist(a, b []float64) float64 {
+	var s float64
+	for j := range a {
+		d := math.Abs(a[j] - b[j])
+		switch m.kind {
+		case "manhattan":
+			s += m.w[j] * d
+		case "chebyshev":
+			s = math.Max(s, m.w[j]*d)
+		default:
+			s += m.w[j] * d * d
+		}
+	}
+	if m.kind == "euclidean" {
+		return math.Sqrt(s)
+	}
+	return s
+}
@@ -0,0 +1,224 @@
+// models.go
+// 名前で選べる組み込み目的関数（Config.Model・-model・設定ファイルの model）
+//
+// よく使う回路の式を config_local.go に写さなくてよいように、名前から Objective を作れるようにしておく。
+//   ss_pn: SS 方式の正規化電力（SSPN の理想部品）
+//   ss_eta: SS 方式の効率 P_R2 / P_in（P_in は電源が出す電力で、内部抵抗 R1 の損失を含む）
+//   sp_pn, ps_pn, pp_pn: 一次・二次の補償方式（S: 直列、P: 並列）を変えた正規化電力
+// どれも必要なキーは k, f, R1, R2, L1, L2, C1, C2。P 補償では C を L と並列につなぐ。
+// 独自のモデルは RegisterModel で足せる（init で呼ぶ）。
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"sort"
+	"strings"
+)
+
+// Model: 名前で選べる目的関数
+type Model struct {
+	About string   // 一覧に出す説明
+	Keys  []string // 必要なキー（params か派生パラメータにないと設定エラー）
+	New   func() Objective
+}
+
+// wptKeys: 2 コイルのモデルが使うキー
+var wptKeys = []string{"k", "f", "R1", "R2", "L1", "L2", "C1", "C2"}
+
+var models = map[string]Model{
+	"ss_pn": {
+		About: "SS normalized power P_R2 / (V²/4R1)",
+		Keys:  wptKeys,
+		New:   func() Objective { return SSPN(Losses{}) },
+	},
+	"ss_eta": {
+		About: "SS efficiency P_R2 / P_in (P_in includes the loss in R1)",
+		Keys:  wptKeys,
+		New:   func() Objective { return twoCoilObjective(true, true, twoCoil.eta) },
+	},
+	"sp_pn": {
+		About: "SP (C2 parallel to L2) normalized power",
+		Keys:  wptKeys,
+		New:   func() Objective { return twoCoilObjective(true, false, twoCoil.pn) },
+	},
+	"ps_pn": {
+		About: "PS (C1 parallel to L1) normalized power",
+		Keys:  wptKeys,
+		New:   func() Objective { return twoCoilObjective(false, true, twoCoil.pn) },
+	},
+	"pp_pn": {
+		About: "PP (C1, C2 parallel) normalized power",
+		Keys:  wptKeys,
+		New:   func() Objective { return twoCoilObjective(false, false, twoCoil.pn) },
+	},
+}
+
+// RegisterModel: 独自のモデルを名前で選べるようにする（同じ名前があれば panic）
+func RegisterModel(name string, m Model) {
+	if _, dup := models[name]; dup {
+		panic("RegisterModel: duplicate name " + name)
+	}
+	if m.New == nil {
+		panic("RegisterModel: New is nil for " + name)
+	}
+	models[name] = m
+}
+
+// modelNames: 登録されたモデルの名前（アルファベット順）
+func modelNames() string {
+	names := make([]string, 0, len(models))
+	for k := range models {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// applyObjectiveSource: F の代わりの指定（Model / Plugin / WASM / Exec / Expr。どれか 1 つだけ）を cfg に反映する
+func applyObjectiveSource(cfg *Config) error {
+	var set []string
+	for _, s := range []struct {
+		name string
+		on   bool
+	}{
+		{"Model", cfg.Model != ""}, {"Plugin", cfg.Plugin != ""}, {"WASM", cfg.WASM != ""},
+		{"Exec", len(cfg.Exec.Command) > 0}, {"Expr", cfg.Expr != ""},
+	} {
+		if s.on {
+			set = append(set, s.name)
+		}
+	}
+	if len(set) > 1 {
+		return fmt.Errorf("objective: set only one of %s", strings.Join(set, ", "))
+	}
+	for _, apply := range []func(*Config) error{applyModel, applyPlugin, applyWASM, applyExec, applyExpr} {
+		if err := apply(cfg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyModel: Config.Model があれば Objective にする（F / F2 / Objective より優先）
+func applyModel(cfg *Config) error {
+	if cfg.Model == "" {
+		return nil
+	}
+	m, ok := models[cfg.Model]
+	if !ok {
+		return fmt.Errorf("unknown model %q (available: %s)", cfg.Model, modelNames())
+	}
+	have := map[string]bool{}
+	for _, p := range cfg.Params {
+		have[p.Key] = true
+	}
+	for _, d := range cfg.Derived {
+		have[d.Key] = true
+	}
+	var missing []string
+	for _, k := range m.Keys {
+		if !have[k] {
+			missing = append(missing, k)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("model %s: params lack %s", cfg.Model, strings.Join(missing, ", "))
+	}
+	obj := m.New()
+	cfg.Objective, cfg.F2 = &obj, nil
+	return nil
+}
+
+// applyPlugin: Config.Plugin があればプラグインの F を使う（plugin.go。F2・Objective より優先）
+func applyPlugin(cfg *Config) error {
+	if cfg.Plugin == "" {
+		return nil
+	}
+	f, err := loadPluginF(cfg.Plugin)
+	if err != nil {
+		return err
+	}
+	cfg.F, cfg.F2, cfg.Objective = f, nil, nil
+	return nil
+}
+
+// twoCoil: 2 コイルの回路を V = 1 [V] で解いた電流・電力
+type twoCoil struct {
+	R1, pLoad, pIn float64
+}
+
+// pn: 正規化電力 P_R2 / (V²/(4·R1))
+func (t twoCoil) pn() float64 { return 4 * t.R1 * t.pLoad }
+
+// eta: 効率 P_R2 / P_in
+func (t twoCoil) eta() float64 {
+	if t.pIn == 0 {
+		return math.NaN()
+	}
+	return t.pLoad / t.pIn
+}
+
+// solveTwoCoil: 一次・二次の補償方式（series1 / series2 が true なら C を L と直列、false なら並列）で回路を解く
+func solveTwoCoil(x map[string]float64, series1, series2 bool) (twoCoil, bool) {
+	w := 2 * math.Pi * Get(x, "f")
+	R1 := Get(x, "R1")
+	R2 := Get(x, "R2")
+	L1 := Get(x, "L1")
+	L2 := Get(x, "L2")
+	zc1 := complex(0, -1/(w*Get(x, "C1")))
+	zc2 := complex(0, -1/(w*Get(x, "C2")))
+	wm := w * Get(x, "k") * math.Sqrt(L1*L2)
+	zr := complex(R2, 0)
+
+	// 二次側: 誘起電圧から見たループのインピーダンスと、負荷にかかる電圧の比
+	z2 := complex(0, w*L2)
+	load := complex(1, 0)
+	if series2 {
+		z2 += zc2 + zr
+	} else {
+		zp := zc2 * zr / (zc2 + zr)
+		z2 += zp
+		load = zp / zr // 負荷の電流 = ループの電流 × zp / R2
+	}
+	if z2 == 0 {
+		return twoCoil{}, false
+	}
+
+	// 一次側: L1 の枝（反射インピーダンスを含む）
+	zl1 := complex(0, w*L1) + complex(wm*wm, 0)/z2
+	var zin complex128
+	if series1 {
+		zin = complex(R1, 0) + zc1 + zl1
+	} else {
+		zin = complex(R1, 0) + zc1*zl1/(zc1+zl1)
+	}
+	if zin == 0 || zl1 == 0 {
+		return twoCoil{}, false
+	}
+	iSrc := 1 / zin
+	i1 := iSrc
+	if !series1 {
+		i1 = (1 - iSrc*complex(R1, 0)) / zl1 // L1 の枝の電流
+	}
+	i2 := complex(0, -wm) * i1 / z2
+	iLoad := cmplx.Abs(i2 * load)
+
+	return twoCoil{R1: R1, pLoad: iLoad * iLoad * R2, pIn: real(iSrc)}, true
+}
+
+// twoCoilObjective: 補償方式と y の取り出し方から Objective を作る（y は [0, 1]）
+func twoCoilObjective(series1, series2 bool, y func(twoCoil) float64) Objective {
+	return Objective{
+		Eval: func(x map[string]float64) (float64, map[string]float64) {
+			t, ok := solveTwoCoil(x, series1, series2)
+			if !ok {
+				return math.NaN(), nil
+			}
+			return y(t), nil
+		},
+		YBounds: &Range{Min: 0, Max: 1},
+	}
+}
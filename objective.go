@@ -0,0 +1,690 @@
+// objective.go
+// 組み込み目的関数（WPT の等価回路モデル）
+//
+// Config.F は y だけを返すが、組み込みモデルは y に加えて補助出力（Aux）を返せる。
+// Aux の値は Sample.Values に同じキーで格納され、params の後ろに列として出力される。
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Objective: y と補助出力を返す目的関数
+type Objective struct {
+	Aux  []Column // 補助出力の列定義（Eval が返す map のキーと一致させる）
+	Eval func(x map[string]float64) (y float64, aux map[string]float64)
+
+	// 理論上とりうる y の範囲（分かっていれば。guard.go で YRange と重なるかを確かめる）
+	YBounds *Range
+
+	// 途中の量（手計算との照合用。Config.EvalLog / TermColumns で出す）。nil なら出さない
+	// TermCols は Terms が返す map のキーの列定義（この順に出力する）
+	TermCols []Column
+	Terms    func(x map[string]float64) map[string]float64
+}
+
+// funcObjective: 従来の F を Objective として扱う
+func funcObjective(f func(x map[string]float64) float64) Objective {
+	return Objective{
+		Eval: func(x map[string]float64) (float64, map[string]float64) {
+			return f(x), nil
+		},
+	}
+}
+
+// ssTerms: SS 方式の正規化電力の途中の量（PN = Num / Den）
+type ssTerms struct {
+	W, Term1, Term2, A, B, Num, Den float64
+}
+
+// ssTermCols: ssTerms を map にしたときのキーと列（この順に出力する）
+var ssTermCols = []Column{
+	{Key: "w", Label: "ω [rad/s]", DisplayScale: 1},
+	{Key: "term1", Label: "term1 [Ω]", DisplayScale: 1},
+	{Key: "term2", Label: "term2 [Ω]", DisplayScale: 1},
+	{Key: "A", Label: "A [Ω²]", DisplayScale: 1},
+	{Key: "B", Label: "B [Ω²]", DisplayScale: 1},
+	{Key: "num", Label: "num", DisplayScale: 1},
+	{Key: "den", Label: "den", DisplayScale: 1},
+}
+
+// pn: 正規化電力（Den が 0 なら NaN）
+func (t ssTerms) pn() float64 {
+	if t.Den == 0 {
+		return math.NaN()
+	}
+	return t.Num / t.Den
+}
+
+func (t ssTerms) values() map[string]float64 {
+	return map[string]float64{
+		"w": t.W, "term1": t.Term1, "term2": t.Term2,
+		"A": t.A, "B": t.B, "num": t.Num, "den": t.Den,
+	}
+}
+
+// ssCalc: SS 方式の正規化電力の途中の量（電源内部抵抗 R1、負荷 R2 に対する |S21|^2）
+// r1, r2 は一次・二次ループの部品損失（直列抵抗）で、電力は R2 で消費される分だけを数える
+func ssCalc(k, w, R1, R2, L1, L2, C1, C2, r1, r2 float64) ssTerms {
+	term1 := w*L1 - 1.0/(w*C1)
+	term2 := w*L2 - 1.0/(w*C2)
+
+	R1t := R1 + r1
+	R2t := R2 + r2
+
+	A := (R1t * R2t) + (term1 * term2) - (w * w * k * k * L1 * L2)
+	B := (R1t * term2) - (R2t * term1)
+
+	num := 4.0 * k * k * R1 * R2 * L1 * L2 * w * w
+	den := (A * A) + (B * B) + 4.0*k*k*R1t*R2t*L1*L2*w*w
+
+	return ssTerms{W: w, Term1: term1, Term2: term2, A: A, B: B, Num: num, Den: den}
+}
+
+// ESR: 部品 1 個分の損失の指定（ゼロ値なら無損失）
+// 優先順位は RKey > Rac > Q。
+//   - RKey: params の値を直列抵抗 [Ω] として使う（探索対象にできる）
+//   - Rac: 周波数依存の抵抗（表皮効果・近接効果など）
+//   - Q: L は ωL/Q、C は 1/(ωCQ) を直列抵抗とする
+type ESR struct {
+	RKey string
+	Rac  RacModel
+	Q    float64
+}
+
+// RacModel: 周波数 f [Hz] における交流抵抗 [Ω]
+type RacModel func(f float64) float64
+
+// SkinEffectRac: Rac(f) = Rdc·(1 + (f/f0)^α)
+func SkinEffectRac(rdc, f0, alpha float64) RacModel {
+	return func(f float64) float64 {
+		return rdc * (1 + math.Pow(f/f0, alpha))
+	}
+}
+
+// TableRac: 周波数と抵抗の表から Rac(f) を線形補間で求める（範囲外は端の値）
+// freqs は昇順であること
+func TableRac(freqs, rs []float64) RacModel {
+	if len(freqs) == 0 || len(freqs) != len(rs) {
+		panic("TableRac: freqs and rs must have the same non-zero length")
+	}
+	for i := 1; i < len(freqs); i++ {
+		if freqs[i] <= freqs[i-1] {
+			panic("TableRac: freqs must be strictly increasing")
+		}
+	}
+	fs := append([]float64{}, freqs...)
+	rv := append([]float64{}, rs...)
+	return func(f float64) float64 {
+		if f <= fs[0] {
+			return rv[0]
+		}
+		n := len(fs)
+		if f >= fs[n-1] {
+			return rv[n-1]
+		}
+		i := sort.SearchFloat64s(fs, f)
+		t := (f - fs[i-1]) / (fs[i] - fs[i-1])
+		return rv[i-1] + t*(rv[i]-rv[i-1])
+	}
+}
+
+// Losses: 組み込みモデルの部品損失（ゼロ値なら理想部品）
+type Losses struct {
+	L1, L2, C1, C2 ESR
+}
+
+// esrL, esrC: 角周波数 w における直列抵抗
+func (e ESR) esrL(x map[string]float64, w, L float64) float64 {
+	switch {
+	case e.RKey != "":
+		return Get(x, e.RKey)
+	case e.Rac != nil:
+		return e.Rac(w / (2 * math.Pi))
+	case e.Q > 0:
+		return w * L / e.Q
+	}
+	return 0
+}
+
+func (e ESR) esrC(x map[string]float64, w, C float64) float64 {
+	switch {
+	case e.RKey != "":
+		return Get(x, e.RKey)
+	case e.Rac != nil:
+		return e.Rac(w / (2 * math.Pi))
+	case e.Q > 0:
+		return 1.0 / (w * C * e.Q)
+	}
+	return 0
+}
+
+// ssEval: x から SS 回路の値を取り出して PN を計算する（負荷は R2 で与える）
+func ssEval(x map[string]float64, R2 float64, loss Losses) float64 {
+	return ssEvalTerms(x, R2, loss).pn()
+}
+
+// ssEvalTerms: ssEval の途中の量
+func ssEvalTerms(x map[string]float64, R2 float64, loss Losses) ssTerms {
+	w := 2 * math.Pi * Get(x, "f")
+	L1 := Get(x, "L1")
+	L2 := Get(x, "L2")
+	C1 := Get(x, "C1")
+	C2 := Get(x, "C2")
+
+	r1 := loss.L1.esrL(x, w, L1) + loss.C1.esrC(x, w, C1)
+	r2 := loss.L2.esrL(x, w, L2) + loss.C2.esrC(x, w, C2)
+
+	return ssCalc(Get(x, "k"), w, Get(x, "R1"), R2, L1, L2, C1, C2, r1, r2)
+}
+
+// SSPN: SS 方式の正規化電力 PN（loss のゼロ値で理想部品）
+// 必要なキー: k, f, R1, R2, L1, L2, C1, C2（+ loss で指定したキー）
+func SSPN(loss Losses) Objective {
+	return Objective{
+		Eval: func(x map[string]float64) (float64, map[string]float64) {
+			return ssEval(x, Get(x, "R2"), loss), nil
+		},
+		YBounds:  &Range{Min: 0, Max: 1},
+		TermCols: ssTermCols,
+		Terms: func(x map[string]float64) map[string]float64 {
+			return ssEvalTerms(x, Get(x, "R2"), loss).values()
+		},
+	}
+}
+
+// RectifierConfig: 二次側整流器 + DC 負荷の設定
+type RectifierConfig struct {
+	RdcKey string  // DC 負荷抵抗のキー（params に含める。"" なら "Rdc"）
+	Vin    float64 // インバータの DC 入力電圧 [V]（方形波出力を仮定）
+	Vf     float64 // 整流ダイオードの順方向電圧 [V]（0 なら理想ダイオード）
+	Losses Losses  // 部品損失（ゼロ値なら理想部品）
+}
+
+// RectifierDCLoad: SS 方式の二次側に全波整流器 + DC 負荷をつないだ等価モデル
+//
+// 二次側の負荷を R_ac = 8/π²·R_dc に置き換えて PN を計算する（y = PN）。
+// 一次側は方形波の基本波（実効値 2√2/π·Vin）で駆動されるとして、
+// 有能電力 V²/(4·R1) から DC 出力電力を求め、補助出力として返す。
+// ダイオードは Vf の定電圧降下（全波整流で 2 個導通）として扱う。
+//
+// 必要なキー: k, f, R1, L1, L2, C1, C2, RdcKey
+func RectifierDCLoad(rc RectifierConfig) Objective {
+	rdcKey := rc.RdcKey
+	if rdcKey == "" {
+		rdcKey = "Rdc"
+	}
+	return Objective{
+		Aux: []Column{
+			{Key: "Rac", Label: "Rac [Ω]", DisplayScale: 1.0},
+			{Key: "Pdc", Label: "Pdc [W]", DisplayScale: 1.0},
+			{Key: "Vdc", Label: "Vdc [V]", DisplayScale: 1.0},
+		},
+		Eval: func(x map[string]float64) (float64, map[string]float64) {
+			R1 := Get(x, "R1")
+			Rdc := Get(x, rdcKey)
+			Rac := 8.0 / (math.Pi * math.Pi) * Rdc
+
+			pn := ssEval(x, Rac, rc.Losses)
+
+			v1 := 2 * math.Sqrt2 / math.Pi * rc.Vin
+			pac := pn * v1 * v1 / (4 * R1)
+
+			// pac = Idc²·Rdc + 2·Vf·Idc を Idc について解く
+			idc := (-2*rc.Vf + math.Sqrt(4*rc.Vf*rc.Vf+4*Rdc*pac)) / (2 * Rdc)
+			pdc := idc * idc * Rdc
+
+			return pn, map[string]float64{
+				"Rac": Rac,
+				"Pdc": pdc,
+				"Vdc": idc * Rdc,
+			}
+		},
+		YBounds:  &Range{Min: 0, Max: 1},
+		TermCols: ssTermCols,
+		Terms: func(x map[string]float64) map[string]float64 {
+			Rac := 8.0 / (math.Pi * math.Pi) * Get(x, rdcKey)
+			return ssEvalTerms(x, Rac, rc.Losses).values()
+		},
+	}
+}
+
+// SquareWave: 方形波入力を第 n 高調波まで分解して評価する（基本波近似の代わり）
+//
+// 方形波の第 h 高調波（h = 1, 3, 5, ...）の振幅は基本波の 1/h なので、
+// 有能電力は 1/h² になる。obj の y を正規化電力とみなし、
+// "f" を h 倍して評価した y に 1/h² を掛けて合計したものを y とする
+// （基本波の有能電力で正規化した値）。
+//
+// 補助出力として各高調波の寄与率 H1, H3, ... を追加する。
+// obj 自身の補助出力は基本波で評価した値をそのまま返す。
+func SquareWave(obj Objective, n int) Objective {
+	if n < 1 {
+		n = 1
+	}
+	aux := append([]Column{}, obj.Aux...)
+	for i := 0; i < n; i++ {
+		h := 2*i + 1
+		key := fmt.Sprintf("H%d", h)
+		aux = append(aux, Column{Key: key, Label: key + " share", DisplayScale: 1.0})
+	}
+	return Objective{
+		Aux: aux,
+		Eval: func(x map[string]float64) (float64, map[string]float64) {
+			f0 := Get(x, "f")
+			xh := make(map[string]float64, len(x))
+			for k, v := range x {
+				xh[k] = v
+			}
+
+			parts := make([]float64, n)
+			var total float64
+			var out map[string]float64
+			for i := 0; i < n; i++ {
+				h := float64(2*i + 1)
+				xh["f"] = h * f0
+				y, a := obj.Eval(xh)
+				if i == 0 {
+					out = make(map[string]float64, len(a)+n)
+					for k, v := range a {
+						out[k] = v
+					}
+				}
+				parts[i] = y / (h * h)
+				total += parts[i]
+			}
+
+			for i, p := range parts {
+				out[fmt.Sprintf("H%d", 2*i+1)] = p / total
+			}
+			return total, out
+		},
+	}
+}
+
+// CouplingModel: 横ずれ dx [m] と縦方向距離 dz [m] から結合係数 k を求める
+type CouplingModel func(dx, dz float64) float64
+
+// LoopCoupling: 半径 r [m] の円形コイル対の簡易モデル
+// 位置 (0, dz0) で k = k0 とし、
+// k = k0 · ((r² + dz0²)/(r² + dz²))^{3/2} · exp(-(dx/r)²)
+// で縦方向の距離と横ずれによる減少を近似する。
+func LoopCoupling(k0, r, dz0 float64) CouplingModel {
+	return func(dx, dz float64) float64 {
+		gap := math.Pow((r*r+dz0*dz0)/(r*r+dz*dz), 1.5)
+		return k0 * gap * math.Exp(-(dx/r)*(dx/r))
+	}
+}
+
+// TableCoupling: 測定表 ks[i][j] = k(dxs[i], dzs[j]) を双線形補間する（範囲外は端の値）
+// dxs, dzs は昇順であること
+func TableCoupling(dxs, dzs []float64, ks [][]float64) CouplingModel {
+	if len(dxs) == 0 || len(dzs) == 0 || len(ks) != len(dxs) {
+		panic("TableCoupling: table size mismatch")
+	}
+	for _, row := range ks {
+		if len(row) != len(dzs) {
+			panic("TableCoupling: table size mismatch")
+		}
+	}
+	// 補間位置（左端のインデックスと比率）
+	locate := func(xs []float64, v float64) (int, float64) {
+		n := len(xs)
+		if n == 1 || v <= xs[0] {
+			return 0, 0
+		}
+		if v >= xs[n-1] {
+			return n - 2, 1
+		}
+		i := sort.SearchFloat64s(xs, v)
+		return i - 1, (v - xs[i-1]) / (xs[i] - xs[i-1])
+	}
+	return func(dx, dz float64) float64 {
+		i, tx := locate(dxs, dx)
+		j, tz := locate(dzs, dz)
+		at := func(a, b int) float64 {
+			a = min(a, len(dxs)-1)
+			b = min(b, len(dzs)-1)
+			return ks[a][b]
+		}
+		k0 := at(i, j)*(1-tz) + at(i, j+1)*tz
+		k1 := at(i+1, j)*(1-tz) + at(i+1, j+1)*tz
+		return k0*(1-tx) + k1*tx
+	}
+}
+
+// MisalignmentConfig: 位置ずれから k を求めるための設定
+type MisalignmentConfig struct {
+	DxKey    string // 横ずれのキー（"" なら "dx"）
+	DzKey    string // 縦方向距離のキー（"" なら "dz"）
+	Coupling CouplingModel
+
+	// 最悪ケース探索（SweepN > 0 のとき有効）
+	// dx ∈ [0, DxMax], dz ∈ [DzMin, DzMax] を SweepN × SweepN 点で評価し、
+	// y が最小になる位置を dx_worst, dz_worst, y_worst、最大になる位置を dx_peak, dz_peak, y_peak として出力する
+	DxMax  float64
+	DzMin  float64
+	DzMax  float64
+	SweepN int
+
+	// 掃引の両端で判定する範囲（ふつうは Config.YRange と同じ。Min < Max のときだけ有効）
+	// 有効なら、params の位置と掃引したすべての位置で y が YRange に入るときだけ OK になるよう、
+	// 下に外れたら最小の y を、上に外れたら最大の y を y として返す（外れなければ params の位置の y）。
+	// 無効なら y は params の位置の値のままで、掃引の結果は補助出力にだけ出る。
+	YRange Range
+}
+
+// Misalignment: params の dx, dz から k を計算して obj を評価する
+// params には "k" を入れず、dx, dz を探索範囲として与える。k は補助出力になる。
+func Misalignment(obj Objective, mc MisalignmentConfig) Objective {
+	dxKey, dzKey := mc.DxKey, mc.DzKey
+	if dxKey == "" {
+		dxKey = "dx"
+	}
+	if dzKey == "" {
+		dzKey = "dz"
+	}
+	if mc.Coupling == nil {
+		panic("Misalignment: Coupling is nil")
+	}
+
+	aux := append([]Column{{Key: "k", Label: "k", DisplayScale: 1.0}}, obj.Aux...)
+	if mc.SweepN > 0 {
+		aux = append(aux,
+			Column{Key: "dx_worst", Label: "dx_worst [mm]", DisplayScale: 1e3},
+			Column{Key: "dz_worst", Label: "dz_worst [mm]", DisplayScale: 1e3},
+			Column{Key: "y_worst", Label: "y_worst", DisplayScale: 1.0},
+			Column{Key: "dx_peak", Label: "dx_peak [mm]", DisplayScale: 1e3},
+			Column{Key: "dz_peak", Label: "dz_peak [mm]", DisplayScale: 1e3},
+			Column{Key: "y_peak", Label: "y_peak", DisplayScale: 1.0},
+		)
+	}
+
+	return Objective{
+		Aux: aux,
+		Eval: func(x map[string]float64) (float64, map[string]float64) {
+			xk := make(map[string]float64, len(x)+1)
+			for k, v := range x {
+				xk[k] = v
+			}
+			xk["k"] = mc.Coupling(Get(x, dxKey), Get(x, dzKey))
+			y, a := obj.Eval(xk)
+
+			out := make(map[string]float64, len(a)+4)
+			for k, v := range a {
+				out[k] = v
+			}
+			out["k"] = xk["k"]
+
+			if mc.SweepN > 0 {
+				worstY, peakY := math.Inf(1), math.Inf(-1)
+				var worstDx, worstDz, peakDx, peakDz float64
+				n := mc.SweepN
+				for i := 0; i < n; i++ {
+					dx := mc.DxMax * float64(i) / float64(max(n-1, 1))
+					for j := 0; j < n; j++ {
+						dz := mc.DzMin + (mc.DzMax-mc.DzMin)*float64(j)/float64(max(n-1, 1))
+						xk["k"] = mc.Coupling(dx, dz)
+						yw, _ := obj.Eval(xk)
+						if yw < worstY {
+							worstY, worstDx, worstDz = yw, dx, dz
+						}
+						if yw > peakY {
+							peakY, peakDx, peakDz = yw, dx, dz
+						}
+					}
+				}
+				out["dx_worst"] = worstDx
+				out["dz_worst"] = worstDz
+				out["y_worst"] = worstY
+				out["dx_peak"] = peakDx
+				out["dz_peak"] = peakDz
+				out["y_peak"] = peakY
+				if mc.YRange.Min < mc.YRange.Max {
+					switch lo, hi := math.Min(y, worstY), math.Max(y, peakY); {
+					case lo < mc.YRange.Min:
+						y = lo
+					case hi > mc.YRange.Max:
+						y = hi
+					}
+				}
+			}
+			return y, out
+		},
+	}
+}
+
+// FrequencyTracking: 周波数追従制御を想定し、帯域 [fMin, fMax] で y が最大になる f で評価する
+//
+// 部品値の組ごとに、対数軸上で粗く grid 点を調べてから黄金分割探索で最大点を詰める
+// （多峰性のある y でも大域的な山を外しにくくするため）。
+// params には "f" を入れない。最適周波数は f_opt として補助出力になる。
+func FrequencyTracking(obj Objective, fMin, fMax float64) Objective {
+	if !(0 < fMin && fMin < fMax) {
+		panic("FrequencyTracking: require 0 < fMin < fMax")
+	}
+	const grid = 32
+	const iters = 40
+
+	aux := append([]Column{{Key: "f_opt", Label: "f_opt [kHz]", DisplayScale: 1e-3}}, obj.Aux...)
+
+	return Objective{
+		Aux: aux,
+		Eval: func(x map[string]float64) (float64, map[string]float64) {
+			xf := make(map[string]float64, len(x)+1)
+			for k, v := range x {
+				xf[k] = v
+			}
+			// ln f を変数として評価（NaN は最小扱い）
+			eval := func(lf float64) float64 {
+				xf["f"] = math.Exp(lf)
+				y, _ := obj.Eval(xf)
+				if math.IsNaN(y) {
+					return math.Inf(-1)
+				}
+				return y
+			}
+
+			lo, hi := math.Log(fMin), math.Log(fMax)
+			step := (hi - lo) / grid
+			best := 0
+			bestY := math.Inf(-1)
+			for i := 0; i <= grid; i++ {
+				if y := eval(lo + step*float64(i)); y > bestY {
+					best, bestY = i, y
+				}
+			}
+
+			// 最大の grid 点の両隣を区間として黄金分割探索
+			a := lo + step*float64(max(best-1, 0))
+			b := lo + step*float64(min(best+1, grid))
+			g := (math.Sqrt(5) - 1) / 2
+			c := b - g*(b-a)
+			d := a + g*(b-a)
+			yc, yd := eval(c), eval(d)
+			for i := 0; i < iters; i++ {
+				if yc > yd {
+					b, d, yd = d, c, yc
+					c = b - g*(b-a)
+					yc = eval(c)
+				} else {
+					a, c, yc = c, d, yd
+					d = a + g*(b-a)
+					yd = eval(d)
+				}
+			}
+			fOpt := math.Exp((a + b) / 2)
+			if y := eval(math.Log(fOpt)); y < bestY {
+				fOpt = math.Exp(lo + step*float64(best))
+			}
+
+			xf["f"] = fOpt
+			y, a2 := obj.Eval(xf)
+			out := make(map[string]float64, len(a2)+1)
+			for k, v := range a2 {
+				out[k] = v
+			}
+			out["f_opt"] = fOpt
+			return y, out
+		},
+	}
+}
+
+// LoadSweepConfig: 負荷掃引の設定
+type LoadSweepConfig struct {
+	Key string  // 掃引する負荷のキー（"" なら "R2"）
+	Min float64 // 掃引範囲 [Ω]
+	Max float64
+	N   int // 掃引点数（対数等間隔、2 未満なら 2）
+
+	// 「掃引全体で y がこの範囲に入る」ことを判定に使う（必須。ふつうは Config.YRange と同じ）。
+	// 範囲外の点があれば最も外れた y（下に外れたなら小さい側、上に外れたなら大きい側）を、なければ y_min を y として返す。
+	// y_min だけでは上に外れた負荷を見落とすので、nil は受け付けない。
+	Require *Range
+}
+
+// LoadSweep: 部品値の組ごとに負荷を掃引して評価する（電池充電などで負荷が大きく変わる場合）
+// 掃引中の y の最小・最大を y_min, y_max として出力する。
+// 負荷（Key）は掃引で決めるので params に入れないこと。入れてもサンプリングした値は使わずに上書きされ、
+// その次元の分だけ点が無駄になる（出力のその列も評価に使った値ではない）。`-param` などで残すなら Min = Max に固定する。
+func LoadSweep(obj Objective, lc LoadSweepConfig) Objective {
+	key := lc.Key
+	if key == "" {
+		key = "R2"
+	}
+	if !(0 < lc.Min && lc.Min <= lc.Max) {
+		panic("LoadSweep: require 0 < Min <= Max")
+	}
+	if lc.Require == nil {
+		panic("LoadSweep: Require is nil (set it to the YRange the sweep must stay in)")
+	}
+	n := max(lc.N, 2)
+
+	aux := append([]Column{
+		{Key: "y_min", Label: "y_min", DisplayScale: 1.0},
+		{Key: "y_max", Label: "y_max", DisplayScale: 1.0},
+	}, obj.Aux...)
+
+	return Objective{
+		Aux: aux,
+		Eval: func(x map[string]float64) (float64, map[string]float64) {
+			xl := make(map[string]float64, len(x)+1)
+			for k, v := range x {
+				xl[k] = v
+			}
+
+			yMin, yMax := math.Inf(1), math.Inf(-1)
+			worst, worstDist := math.NaN(), 0.0
+			var out map[string]float64
+			lnMin, lnMax := math.Log(lc.Min), math.Log(lc.Max)
+			for i := 0; i < n; i++ {
+				xl[key] = math.Exp(lnMin + (lnMax-lnMin)*float64(i)/float64(n-1))
+				y, a := obj.Eval(xl)
+				if math.IsNaN(y) {
+					return math.NaN(), nil
+				}
+				if i == 0 {
+					out = make(map[string]float64, len(a)+2)
+					for k, v := range a {
+						out[k] = v
+					}
+				}
+				yMin = math.Min(yMin, y)
+				yMax = math.Max(yMax, y)
+				if d := math.Max(lc.Require.Min-y, y-lc.Require.Max); d > worstDist {
+					worst, worstDist = y, d
+				}
+			}
+			out["y_min"] = yMin
+			out["y_max"] = yMax
+
+			if !math.IsNaN(worst) {
+				return worst, out
+			}
+			return yMin, out
+		},
+	}
+}
+
+// RatedEfficiencyConfig: 定格電力での効率評価の設定
+type RatedEfficiencyConfig struct {
+	Prated  float64 // 定格出力 [W]（負荷 R2 での電力）
+	Vdc     float64 // インバータの DC 電圧 [V]（位相シフト制御のフルブリッジを仮定）
+	DutyMax float64 // デューティの上限（0 なら 1）
+	Losses  Losses  // 部品損失（効率の計算に使う）
+}
+
+// EfficiencyAtRatedPower: 定格電力を出せるかを確認してから、その動作点の効率を y とする
+//
+// 1. 負荷で Prated を得るのに必要な入力電圧（基本波実効値）V_req を求める
+// 2. 位相シフト制御の基本波 V = (2√2/π)·Vdc·sin(πD/2) から必要なデューティ D を求める
+// 3. D <= DutyMax なら y = η = P_load / P_in（P_in は電源が出す電力、R1 の損失を含む）
+// D が上限を超える（定格電力を出せない）場合は y = 0 とする。
+// 中間値 V_req, duty, Pin, eta を補助出力とする（出せない場合の duty, Pin, eta は NaN）。
+//
+// 必要なキー: k, f, R1, R2, L1, L2, C1, C2（+ Losses で指定したキー）
+func EfficiencyAtRatedPower(rc RatedEfficiencyConfig) Objective {
+	dutyMax := rc.DutyMax
+	if dutyMax <= 0 {
+		dutyMax = 1
+	}
+	return Objective{
+		Aux: []Column{
+			{Key: "V_req", Label: "V_req [V]", DisplayScale: 1.0},
+			{Key: "duty", Label: "duty", DisplayScale: 1.0},
+			{Key: "Pin", Label: "Pin [W]", DisplayScale: 1.0},
+			{Key: "eta", Label: "eta", DisplayScale: 1.0},
+		},
+		Eval: func(x map[string]float64) (float64, map[string]float64) {
+			w := 2 * math.Pi * Get(x, "f")
+			R1 := Get(x, "R1")
+			R2 := Get(x, "R2")
+			L1 := Get(x, "L1")
+			L2 := Get(x, "L2")
+			C1 := Get(x, "C1")
+			C2 := Get(x, "C2")
+			M := Get(x, "k") * math.Sqrt(L1*L2)
+
+			r1 := rc.Losses.L1.esrL(x, w, L1) + rc.Losses.C1.esrC(x, w, C1)
+			r2 := rc.Losses.L2.esrL(x, w, L2) + rc.Losses.C2.esrC(x, w, C2)
+			z1 := complex(R1+r1, w*L1-1.0/(w*C1))
+			z2 := complex(R2+r2, w*L2-1.0/(w*C2))
+			det := z1*z2 + complex(w*w*M*M, 0)
+			if det == 0 {
+				return math.NaN(), nil
+			}
+
+			// V = 1 [V] あたりの電流・電力
+			i1 := z2 / det
+			i2 := complex(0, -w*M) / det
+			pLoad := real(i2)*real(i2) + imag(i2)*imag(i2)
+			pLoad *= R2
+			pIn := real(i1)
+
+			out := map[string]float64{
+				"V_req": math.Sqrt(rc.Prated / pLoad),
+				"duty":  math.NaN(),
+				"Pin":   math.NaN(),
+				"eta":   math.NaN(),
+			}
+			vMax := 2 * math.Sqrt2 / math.Pi * rc.Vdc
+			ratio := out["V_req"] / vMax
+			if !(ratio <= 1) {
+				return 0, out
+			}
+			duty := 2 / math.Pi * math.Asin(ratio)
+			if duty > dutyMax {
+				return 0, out
+			}
+
+			v2 := out["V_req"] * out["V_req"]
+			out["duty"] = duty
+			out["Pin"] = pIn * v2
+			out["eta"] = pLoad / pIn
+			return out["eta"], out
+		},
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// y = k = 1 − dx − dz。dx ∈ [0, 0.5], dz ∈ [0, 0.2] を掃引すると y は 0.3〜1
+func TestMisalignmentBothExtremes(t *testing.T) {
+	obj := funcObjective(func(x map[string]float64) float64 { return x["k"] })
+	mc := MisalignmentConfig{
+		Coupling: func(dx, dz float64) float64 { return 1 - dx - dz },
+		DxMax:    0.5, DzMin: 0, DzMax: 0.2, SweepN: 3,
+	}
+	x := map[string]float64{"dx": 0.1, "dz": 0.1}
+
+	y, aux := Misalignment(obj, mc).Eval(x)
+	if y != 0.8 {
+		t.Errorf("y without YRange = %g, want 0.8 (the value at params)", y)
+	}
+	if aux["y_worst"] != 0.3 || aux["dx_worst"] != 0.5 || aux["dz_worst"] != 0.2 {
+		t.Errorf("worst = %g at (%g, %g), want 0.3 at (0.5, 0.2)", aux["y_worst"], aux["dx_worst"], aux["dz_worst"])
+	}
+	if aux["y_peak"] != 1 || aux["dx_peak"] != 0 || aux["dz_peak"] != 0 {
+		t.Errorf("peak = %g at (%g, %g), want 1 at (0, 0)", aux["y_peak"], aux["dx_peak"], aux["dz_peak"])
+	}
+
+	for _, c := range []struct {
+		r    Range
+		want float64
+	}{
+		{Range{Min: 0.2, Max: 1.1}, 0.8}, // 両端とも入る
+		{Range{Min: 0.5, Max: 1.1}, 0.3}, // 下に外れる
+		{Range{Min: 0.2, Max: 0.9}, 1},   // 上に外れる（最小だけ見ると OK になってしまう）
+	} {
+		mc.YRange = c.r
+		if y, _ := Misalignment(obj, mc).Eval(x); y != c.want {
+			t.Errorf("YRange %v: y = %g, want %g", c.r, y, c.want)
+		}
+	}
+}
+
+// y = 1/R2 を R2 ∈ [1, 10] で掃引すると y は 0.1〜1。上に外れても下に外れても判定に出る
+func TestLoadSweepBothExtremes(t *testing.T) {
+	obj := funcObjective(func(x map[string]float64) float64 { return 1 / x["R2"] })
+	x := map[string]float64{"R2": 5}
+	for _, c := range []struct {
+		r    Range
+		want float64
+	}{
+		{Range{Min: 0.05, Max: 2}, 0.1}, // 両端とも入る: y_min
+		{Range{Min: 0.2, Max: 2}, 0.1},  // 下に外れる
+		{Range{Min: 0.05, Max: 0.5}, 1}, // 上に外れる（y_min だけなら OK になってしまう）
+	} {
+		r := c.r
+		y, aux := LoadSweep(obj, LoadSweepConfig{Min: 1, Max: 10, N: 5, Require: &r}).Eval(x)
+		if math.Abs(y-c.want) > 1e-12 {
+			t.Errorf("Require %v: y = %g, want %g", c.r, y, c.want)
+		}
+		if math.Abs(aux["y_min"]-0.1) > 1e-12 || math.Abs(aux["y_max"]-1) > 1e-12 {
+			t.Errorf("y_min, y_max = %g, %g, want 0.1, 1", aux["y_min"], aux["y_max"])
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Require nil: want a panic")
+		}
+	}()
+	LoadSweep(obj, LoadSweepConfig{Min: 1, Max: 10})
+}
@@ -0,0 +1,281 @@
+// outfile.go
+// 出力ファイルが既にある場合の扱い（xlsx / tsv など全ての出力で共通）
+
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OutputSpec: 出力先 1 つ分の設定（有効/無効とファイル名を分けて持つ）
+type OutputSpec struct {
+	Enabled bool
+	File    string // ファイル名のテンプレート。{seed} {date} {time} を展開する
+}
+
+// 以前の版の XLSXFile / OKTSVFile / NGTSVFile の既定値
+const (
+	legacyXLSXFile  = "result.xlsx"
+	legacyOKTSVFile = "ok.tsv"
+	legacyNGTSVFile = "ng.tsv"
+)
+
+// migrateOutputFiles: 以前の版の XLSXFile / OKTSVFile / NGTSVFile を XLSX / OKTSV / NGTSV に移す
+// （既定値から変えたものだけ。"" なら無効、それ以外ならそのファイル名で有効）。移したものは既定値に戻すので、何度呼んでもよい
+func migrateOutputFiles(cfg *Config) {
+	for _, m := range []struct {
+		name, def string
+		old       *string
+		spec      *OutputSpec
+	}{
+		{"XLSXFile", legacyXLSXFile, &cfg.XLSXFile, &cfg.XLSX},
+		{"OKTSVFile", legacyOKTSVFile, &cfg.OKTSVFile, &cfg.OKTSV},
+		{"NGTSVFile", legacyNGTSVFile, &cfg.NGTSVFile, &cfg.NGTSV},
+	} {
+		if *m.old == m.def {
+			continue
+		}
+		if *m.old == "" {
+			m.spec.Enabled = false
+		} else {
+			*m.spec = OutputSpec{Enabled: true, File: *m.old}
+		}
+		fmt.Fprintf(os.Stderr, "warning: Config.%s is deprecated; use %s: OutputSpec{Enabled: %v, File: %q}\n",
+			m.name, strings.TrimSuffix(m.name, "File"), m.spec.Enabled, m.spec.File)
+		*m.old = m.def
+	}
+}
+
+var placeholderRe = regexp.MustCompile(`\{[^{}]*\}`)
+
+// expandFilename: ファイル名のテンプレートを展開する（未知の {...} はエラー）
+func expandFilename(tmpl string, seed int64, now time.Time) (string, error) {
+	var bad []string
+	name := placeholderRe.ReplaceAllStringFunc(tmpl, func(m string) string {
+		switch m {
+		case "{seed}":
+			return strconv.FormatInt(seed, 10)
+		case "{date}":
+			return now.Format("20060102")
+		case "{time}":
+			return now.Format("150405")
+		}
+		bad = append(bad, m)
+		return m
+	})
+	if len(bad) > 0 {
+		return "", fmt.Errorf("unknown placeholder %s in %q", strings.Join(bad, ", "), tmpl)
+	}
+	return name, nil
+}
+
+// outputFiles: 実際に使う出力ファイル名（"" なら出力しない）
+type outputFiles struct {
+	XLSX        string
+	OKTSV       string
+	NGTSV       string
+	OKCSV       string
+	NGCSV       string
+	OKParquet   string
+	NGParquet   string
+	Interaction string
+	ConfigJSON  string
+	Bundle      string
+}
+
+// fullList: OK（ok が false なら NG）の全件を書くファイル（xlsx を間引いたときに Summary に書く。なければ ""）
+func (o outputFiles) fullList(ok bool) string {
+	if ok {
+		return cmp.Or(o.OKTSV, o.OKParquet, o.OKCSV)
+	}
+	return cmp.Or(o.NGTSV, o.NGParquet, o.NGCSV)
+}
+
+// resolveOutputs: 出力設定を検証してファイル名を決める（起動時に 1 回）
+// 有効なのにファイル名が空、未知のテンプレート、有効な出力どうしのファイル名の重複はエラー。
+// 保存件数が 0 なのに tsv が有効な場合などは警告を表示する。
+func resolveOutputs(cfg Config, now time.Time) (outputFiles, error) {
+	migrateOutputFiles(&cfg)
+	var out outputFiles
+	var errs []string
+	used := map[string]string{}
+
+	resolve := func(what string, spec OutputSpec) string {
+		if !spec.Enabled {
+			return ""
+		}
+		if spec.File == "" {
+			errs = append(errs, what+": enabled but File is empty")
+			return ""
+		}
+		name, err := expandFilename(spec.File, cfg.Seed, now)
+		if err != nil {
+			errs = append(errs, what+": "+err.Error())
+			return ""
+		}
+		if other, ok := used[name]; ok {
+			errs = append(errs, fmt.Sprintf("%s: file %q is also used by %s", what, name, other))
+			return ""
+		}
+		used[name] = what
+		return name
+	}
+
+	out.XLSX = resolve("xlsx", cfg.XLSX)
+	out.OKTSV = resolve("tsv (OK)", cfg.OKTSV)
+	out.NGTSV = resolve("tsv (NG)", cfg.NGTSV)
+	out.OKCSV = resolve("csv (OK)", cfg.OKCSV)
+	out.NGCSV = resolve("csv (NG)", cfg.NGCSV)
+	out.OKParquet = resolve("parquet (OK)", cfg.OKParquet)
+	out.NGParquet = resolve("parquet (NG)", cfg.NGParquet)
+	if cfg.Interaction.Enabled {
+		out.Interaction = resolve("tsv (interaction)", cfg.InteractionTSV)
+	}
+	out.ConfigJSON = resolve("json (config)", cfg.ConfigJSON)
+	out.Bundle = resolve("bundle", cfg.Bundle)
+	if err := checkSQLite(cfg.SQLite); err != nil {
+		errs = append(errs, "sqlite: "+err.Error())
+	}
+
+	if len(errs) > 0 {
+		return outputFiles{}, fmt.Errorf("output config: %s", strings.Join(errs, "; "))
+	}
+
+	if out.OKTSV != "" && cfg.MaxOKSave <= 0 {
+		fmt.Println("warning: tsv (OK) is enabled but MaxOKSave is 0 (file will have header only)")
+	}
+	if out.NGTSV != "" && cfg.MaxNGSave <= 0 {
+		fmt.Println("warning: tsv (NG) is enabled but MaxNGSave is 0 (file will have header only)")
+	}
+	if cfg.StreamTSV && (out.OKCSV != "" || out.NGCSV != "") {
+		fmt.Println("warning: with StreamTSV, csv gets only the samples kept in memory (MaxPrint, or 100)")
+	}
+	if cfg.StreamTSV && (out.OKParquet != "" || out.NGParquet != "") {
+		fmt.Println("warning: with StreamTSV, parquet gets only the samples kept in memory (MaxPrint, or 100)")
+	}
+	return out, nil
+}
+
+type ExistPolicy int
+
+const (
+	Overwrite        ExistPolicy = iota // 上書き（従来通り）
+	ErrorIfExists                       // エラーにして保存しない
+	RenameWithSuffix                    // result_1.xlsx のように空いている名前で保存
+	AppendToExisting                    // 既存ファイルに追記
+)
+
+func (p ExistPolicy) String() string {
+	switch p {
+	case Overwrite:
+		return "overwrite"
+	case ErrorIfExists:
+		return "error"
+	case RenameWithSuffix:
+		return "rename"
+	case AppendToExisting:
+		return "append"
+	default:
+		return fmt.Sprintf("ExistPolicy(%d)", int(p))
+	}
+}
+
+// resolveOutput: 既存ファイルの扱いに従って、実際に書き込むファイル名と追記するかを決める
+// 追記は既存ファイルがあるときだけ true になる
+func resolveOutput(filename string, policy ExistPolicy) (string, bool, error) {
+	if _, err := os.Stat(filename); err != nil {
+		if os.IsNotExist(err) {
+			return filename, false, nil
+		}
+		return "", false, err
+	}
+
+	switch policy {
+	case Overwrite:
+		return filename, false, nil
+	case ErrorIfExists:
+		return "", false, fmt.Errorf("%s already exists", filename)
+	case RenameWithSuffix:
+		ext := filepath.Ext(filename)
+		base := strings.TrimSuffix(filename, ext)
+		for i := 1; ; i++ {
+			name := fmt.Sprintf("%s_%d%s", base, i, ext)
+			if _, err := os.Stat(name); os.IsNotExist(err) {
+				return name, false, nil
+			}
+		}
+	case AppendToExisting:
+		return filename, true, nil
+	default:
+		return "", false, fmt.Errorf("unknown exist policy: %v", policy)
+	}
+}
+
+// resolveAppend: resolveOutput と同じだが、追記するときは既存ファイルの見出しを header と比べる
+// 見出しが違う（別の params・列で書いたファイル）なら、行がずれて混ざらないように rename と同じ新しい名前に書く。
+// 既存ファイルが空なら追記せずに見出しから書く。
+func resolveAppend(filename string, policy ExistPolicy, header []string, read func(name string) ([]string, error)) (string, bool, error) {
+	name, appendMode, err := resolveOutput(filename, policy)
+	if err != nil || !appendMode {
+		return name, appendMode, err
+	}
+	old, err := read(name)
+	switch {
+	case err == nil && old == nil:
+		return name, false, nil
+	case err == nil && slices.Equal(old, header):
+		return name, true, nil
+	}
+	reason := "columns differ from this run"
+	if err != nil {
+		reason = "cannot read the header: " + err.Error()
+	}
+	alt, _, rerr := resolveOutput(filename, RenameWithSuffix)
+	if rerr != nil {
+		return "", false, rerr
+	}
+	fmt.Fprintf(os.Stderr, "[append] %s: %s; writing %s instead\n", name, reason, alt)
+	return alt, false, nil
+}
+
+// textHeader: tsv / csv の見出しの行（先頭の BOM と "#" の行は飛ばす）。空のファイルなら nil
+func textHeader(comma rune) func(name string) ([]string, error) {
+	return func(name string) ([]string, error) {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		br := bufio.NewReader(f)
+		if r, _, err := br.ReadRune(); err == nil && r != '\uFEFF' {
+			br.UnreadRune()
+		}
+		for {
+			line, err := br.ReadString('\n')
+			if line == "" && err != nil {
+				if err == io.EOF {
+					return nil, nil
+				}
+				return nil, err
+			}
+			if strings.HasPrefix(line, "#") {
+				continue
+			}
+			r := csv.NewReader(strings.NewReader(line))
+			r.Comma = comma
+			r.FieldsPerRecord = -1
+			return r.Read()
+		}
+	}
+}
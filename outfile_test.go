@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 以前の版の config_local.go（XLSXFile / OKTSVFile / NGTSVFile）がそのまま効く
+func TestMigrateOutputFiles(t *testing.T) {
+	cfg := Config{
+		XLSX:      OutputSpec{Enabled: true, File: "result.xlsx"},
+		OKTSV:     OutputSpec{Enabled: true, File: "ok.tsv"},
+		NGTSV:     OutputSpec{Enabled: true, File: "ng.tsv"},
+		XLSXFile:  "run.xlsx",
+		OKTSVFile: legacyOKTSVFile,
+		NGTSVFile: "",
+	}
+	migrateOutputFiles(&cfg)
+	if cfg.XLSX != (OutputSpec{Enabled: true, File: "run.xlsx"}) {
+		t.Errorf("XLSX = %+v, want run.xlsx enabled", cfg.XLSX)
+	}
+	if cfg.OKTSV != (OutputSpec{Enabled: true, File: "ok.tsv"}) {
+		t.Errorf("OKTSV = %+v, want unchanged", cfg.OKTSV)
+	}
+	if cfg.NGTSV.Enabled {
+		t.Errorf("NGTSV = %+v, want disabled", cfg.NGTSV)
+	}
+
+	// 移した後で新しい書き方（フラグなど）で変えたものは、もう一度呼んでも戻さない
+	cfg.XLSX.Enabled = false
+	migrateOutputFiles(&cfg)
+	if cfg.XLSX.Enabled {
+		t.Errorf("second migrateOutputFiles re-enabled XLSX")
+	}
+}
+
+// 追記は見出しが同じときだけ。別の列で書いたファイルには足さずに新しい名前に書く
+func TestAppendChecksHeader(t *testing.T) {
+	dir := t.TempDir()
+	kf := []Column{{Key: "k", Label: "k", DisplayScale: 1}, {Key: "f", Label: "f [kHz]", DisplayScale: 1e-3}}
+	ab := []Column{{Key: "a", Label: "a", DisplayScale: 1}, {Key: "b", Label: "b", DisplayScale: 1}}
+	s1 := []Sample{{Values: map[string]float64{"k": 0.1, "f": 85e3, "a": 1, "b": 2}, Y: 0.3, OK: true}}
+	rc := &RunConfig{Objective: "test"}
+
+	lines := func(name string) int {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Count(string(b), "\n")
+	}
+
+	for _, c := range []struct {
+		ext  string
+		save func(name string, cols []Column) (string, error)
+	}{
+		{".tsv", func(name string, cols []Column) (string, error) {
+			return SaveListToTSV(name, AppendToExisting, cols, s1, rc)
+		}},
+		{".csv", func(name string, cols []Column) (string, error) {
+			return SaveListToCSV(name, AppendToExisting, cols, s1, CSVConfig{BOM: true})
+		}},
+	} {
+		name := filepath.Join(dir, "ok"+c.ext)
+		for i := 0; i < 2; i++ {
+			if got, err := c.save(name, kf); err != nil || got != name {
+				t.Fatalf("%s append #%d: %q, %v", c.ext, i+1, got, err)
+			}
+		}
+		before := lines(name)
+		got, err := c.save(name, ab)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := filepath.Join(dir, "ok_1"+c.ext); got != want {
+			t.Errorf("%s with other columns: wrote %s, want %s", c.ext, got, want)
+		}
+		if lines(name) != before {
+			t.Errorf("%s: rows were added under a different header", c.ext)
+		}
+	}
+
+	name := filepath.Join(dir, "result.xlsx")
+	save := func(cols []Column) string {
+		got, err := SaveToXLSX(name, AppendToExisting, cols, s1, nil, 1, 1, 0, nil, XLSXLimit{}, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	if save(kf) != name || save(kf) != name {
+		t.Errorf("xlsx: appending the same columns should keep %s", name)
+	}
+	if got := save(ab); got != filepath.Join(dir, "result_1.xlsx") {
+		t.Errorf("xlsx with other columns: wrote %s, want result_1.xlsx", got)
+	}
+	if h, err := readXLSXHeader(name); err != nil || strings.Join(h, ",") != "No,k,f,y" {
+		t.Errorf("xlsx header = %v, %v: want the original No,k,f,y", h, err)
+	}
+}
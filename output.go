@@ -2,15 +2,43 @@
 package main
 
 import (
+	"cmp"
 	"encoding/csv"
 	"fmt"
 	"math"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/xuri/excelize/v2"
 )
 
+// Column: 出力列の定義（params と目的関数の補助出力を同じ形で並べる）
+type Column struct {
+	Key          string  // Sample.Values のキー
+	Label        string  // 表示ヘッダ
+	DisplayScale float64 // 表示用スケール
+
+	// 整数・カテゴリの列（paramtype.go）。整数は DisplayScale をかけずに整数で、カテゴリは Name で書く
+	Type    ParamType
+	Choices []Choice
+
+	// 表示の桁数（ParamSpec.SigFigs / DecimalPlaces。0 なら %.4g）
+	SigFigs       int
+	DecimalPlaces int
+}
+
+// paramColumns: params をそのまま出力列に変換する
+func paramColumns(params []ParamSpec) []Column {
+	cols := make([]Column, 0, len(params))
+	for _, p := range params {
+		cols = append(cols, Column{Key: p.Key, Label: p.Label, DisplayScale: p.DisplayScale, Type: p.Type, Choices: p.Choices,
+			SigFigs: p.SigFigs, DecimalPlaces: p.DecimalPlaces})
+	}
+	return cols
+}
+
 func fmt4(x float64) string { return fmt.Sprintf("%10.4g", x) }
 
 func fmtCell(x float64) string {
@@ -26,6 +54,41 @@ func fmtCell(x float64) string {
 	return fmt4(x)
 }
 
+// fmtValue: 表示単位の値 v をコンソールの表のセルにする（SigFigs / DecimalPlaces があれば従う）
+func (c Column) fmtValue(v float64) string {
+	switch {
+	case math.IsNaN(v) || math.IsInf(v, 0):
+		return fmtCell(v)
+	case c.DecimalPlaces > 0:
+		return fmt.Sprintf("%10.*f", c.DecimalPlaces, v)
+	case c.SigFigs > 0:
+		return fmt.Sprintf("%10.*g", c.SigFigs, v)
+	}
+	return fmtCell(v)
+}
+
+// xlsxNumFmt: xlsx のセルの表示形式（元単位の値に対して。指定がなければ ""）
+// DecimalPlaces は DisplayScale が 10 のべき乗のときだけ元単位の桁数に直せる（それ以外は SigFigs と同じく指数表記）
+func (c Column) xlsxNumFmt() string {
+	digits := func(n int) string {
+		if n <= 0 {
+			return "0"
+		}
+		return "0." + strings.Repeat("0", n)
+	}
+	if c.DecimalPlaces > 0 {
+		shift := math.Log10(c.DisplayScale)
+		if c.DisplayScale > 0 && shift == math.Round(shift) {
+			return digits(c.DecimalPlaces + int(shift))
+		}
+		return digits(c.DecimalPlaces-1) + "E+00"
+	}
+	if c.SigFigs > 0 {
+		return digits(c.SigFigs-1) + "E+00"
+	}
+	return ""
+}
+
 func PrintSummary(seed int64, yRange Range, total, okc, ngc int64) {
 	var okRatio, ngRatio float64
 	if total > 0 {
@@ -39,22 +102,69 @@ func PrintSummary(seed int64, yRange Range, total, okc, ngc int64) {
 	fmt.Printf("OK_ratio=%s  NG_ratio=%s\n\n", fmt4(okRatio), fmt4(ngRatio))
 }
 
-func PrintSampleTable(title string, params []ParamSpec, list []Sample, maxPrint int) {
+func PrintSampleTable(title string, cols []Column, list []Sample, maxPrint int) {
+	n := len(list)
+	if maxPrint > 0 && n > maxPrint {
+		n = maxPrint
+	}
+	printSampleRows(title, cols, list, 0, n)
+	if n < len(list) {
+		fmt.Printf("(printed %d of %d; truncated for console)\n\n", n, len(list))
+	}
+}
 
-	fmt.Println(title)
+// defaultPageSize: Config.Page を指定して PageSize も MaxPrint も 0 のときの 1 ページの件数
+const defaultPageSize = 50
+
+// printSamples: Config.Page があればそのページ、なければ先頭から MaxPrint 件を表示する
+func printSamples(cfg Config, title string, cols []Column, list []Sample) {
+	if cfg.Page <= 0 {
+		PrintSampleTable(title, cols, list, cfg.MaxPrint)
+		return
+	}
+	size := cfg.PageSize
+	if size <= 0 {
+		size = cfg.MaxPrint
+	}
+	if size <= 0 {
+		size = defaultPageSize
+	}
+	PrintSamplePage(title, cols, list, cfg.Page, size)
+}
+
+// PrintSamplePage: list を size 件ずつに分けた page ページ目（1 始まり）だけを表示する（No は list の通し番号）
+func PrintSamplePage(title string, cols []Column, list []Sample, page, size int) {
 	if len(list) == 0 {
-		fmt.Println("(none)")
+		printSampleRows(title, cols, list, 0, 0)
 		return
 	}
-	origLen := len(list)
-	if maxPrint > 0 && len(list) > maxPrint {
-		list = list[:maxPrint]
+	size = max(size, 1)
+	pages := (len(list) + size - 1) / size
+	start := (page - 1) * size
+	if page < 1 || start >= len(list) {
+		fmt.Println(title)
+		fmt.Printf("(page %d is out of range: %d rows, %d pages of %d)\n\n", page, len(list), pages, size)
+		return
+	}
+	end := min(start+size, len(list))
+	printSampleRows(title, cols, list, start, end)
+	fmt.Printf("(page %d of %d: rows %d-%d of %d)\n\n", page, pages, start+1, end, len(list))
+}
+
+// printSampleRows: list[start:end] を表にする
+func printSampleRows(title string, cols []Column, all []Sample, start, end int) {
+
+	fmt.Println(title)
+	if len(all) == 0 {
+		fmt.Println("(none)")
+		return
 	}
+	list := all[start:end]
 
-	// ヘッダ（No + params + y）
-	headers := make([]string, 0, len(params)+2)
+	// ヘッダ（No + cols + y）
+	headers := make([]string, 0, len(cols)+2)
 	headers = append(headers, "No")
-	for _, p := range params {
+	for _, p := range cols {
 		headers = append(headers, p.Label)
 	}
 	headers = append(headers, "y")
@@ -63,10 +173,14 @@ func PrintSampleTable(title string, params []ParamSpec, list []Sample, maxPrint
 	rows := make([][]string, len(list))
 	for i, s := range list {
 		row := make([]string, 0, len(headers))
-		row = append(row, fmt.Sprintf("%d", i+1))
-		for _, p := range params {
+		row = append(row, fmt.Sprintf("%d", start+i+1))
+		for _, p := range cols {
+			if text, ok := p.cellText(s.Values[p.Key]); ok {
+				row = append(row, fmt.Sprintf("%10s", text))
+				continue
+			}
 			v := s.Values[p.Key] * p.DisplayScale
-			row = append(row, fmtCell(v))
+			row = append(row, p.fmtValue(v))
 		}
 		row = append(row, fmtCell(s.Y))
 		rows[i] = row
@@ -128,27 +242,132 @@ func PrintSampleTable(title string, params []ParamSpec, list []Sample, maxPrint
 	}
 	printLine()
 	fmt.Println()
+}
+
+// xlsx の 1 シートの既定の上限（XLSXLimit が 0 のとき）。これを超えるブックは Excel で開くのに時間がかかるか開けない
+const (
+	defaultXLSXRows  = 200_000
+	defaultXLSXCells = 5_000_000
+)
+
+// XLSXLimit: xlsx の OK / NG シートに書く大きさの上限。超えたら等間隔に間引いて書き、全件のファイルを Summary に書く
+type XLSXLimit struct {
+	MaxRows  int // 1 シートの行数の上限（0 なら defaultXLSXRows、負なら間引かない）
+	MaxCells int // 1 シートのセル数（行 × 列）の上限（0 なら defaultXLSXCells、負なら間引かない）
+}
+
+// stride: n 行・ncols 列のシートを何件に 1 件書くか（1 なら全件。xlsx の行数の上限 xlsxMaxRows は常に守る）
+func (l XLSXLimit) stride(n, ncols int) int {
+	rows := min(n, xlsxMaxRows)
+	if l.MaxRows >= 0 {
+		rows = min(rows, cmp.Or(l.MaxRows, defaultXLSXRows))
+	}
+	if l.MaxCells >= 0 {
+		rows = min(rows, cmp.Or(l.MaxCells, defaultXLSXCells)/max(ncols, 1))
+	}
+	if rows >= n {
+		return 1
+	}
+	return (n + max(rows, 1) - 1) / max(rows, 1)
+}
 
-	if maxPrint > 0 && origLen > maxPrint {
-		fmt.Printf("(printed %d of %d; truncated for console)\n\n", maxPrint, origLen)
+// xlsxThinNote: 間引いたシートの説明（full は全件を保存したファイル）
+func xlsxThinNote(sheet string, k, n int, full string) string {
+	if full == "" {
+		full = "not saved (enable the tsv or parquet output)"
 	}
+	return fmt.Sprintf("%s sheet has 1 in %d of %d samples (No is the row in the full list); full list: %s", sheet, k, n, full)
+}
 
+// xlsxHeader: OK / NG シートの見出し（No・列の Key・y）
+func xlsxHeader(cols []Column) []string {
+	header := []string{"No"}
+	for _, p := range cols {
+		header = append(header, p.Key)
+	}
+	return append(header, "y")
 }
 
+// readXLSXHeader: 既存の xlsx の OK / NG シートの見出し（両方あれば同じであること）
+func readXLSXHeader(name string) ([]string, error) {
+	f, err := excelize.OpenFile(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var header []string
+	for _, sheet := range []string{"OK", "NG"} {
+		if idx, _ := f.GetSheetIndex(sheet); idx < 0 {
+			continue
+		}
+		rows, err := f.Rows(sheet)
+		if err != nil {
+			return nil, err
+		}
+		var h []string
+		if rows.Next() {
+			h, err = rows.Columns()
+		}
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+		if header != nil && !slices.Equal(header, h) {
+			return nil, fmt.Errorf("OK and NG sheets have different columns")
+		}
+		header = h
+	}
+	if header == nil {
+		return []string{}, nil // シートがなければ比べられないので、見出しが違うものとして扱う
+	}
+	return header, nil
+}
+
+// SaveToXLSX: Summary / OK / NG シート（rc があれば Config シートも）に保存し、実際に保存したファイル名を返す
+// 追記の場合は OK / NG シートの末尾に行を足し、Summary の件数を合算し、Config シートは今回の設定で書き直す
+// （OK / NG シートの見出しが今回の列と違えば追記せずに別の名前にする。resolveAppend）
+// OK / NG が lim を超えたら等間隔に間引き、Summary に全件のファイル（okFull / ngFull）を書く
 func SaveToXLSX(
 	filename string,
-	params []ParamSpec,
+	policy ExistPolicy,
+	cols []Column,
 	okList []Sample,
 	ngList []Sample,
 	total, okc, ngc int64,
-) error {
+	rc *RunConfig,
+	lim XLSXLimit,
+	okFull, ngFull string,
+) (string, error) {
 
-	f := excelize.NewFile()
+	name, appendMode, err := resolveAppend(filename, policy, xlsxHeader(cols), readXLSXHeader)
+	if err != nil {
+		return "", err
+	}
 
-	// Summary
+	var f *excelize.File
 	summary := "Summary"
-	f.SetSheetName("Sheet1", summary)
+	if appendMode {
+		f, err = excelize.OpenFile(name)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
 
+		// 既存の件数を合算
+		prev := func(cell string) int64 {
+			v, _ := f.GetCellValue(summary, cell)
+			n, _ := strconv.ParseInt(v, 10, 64)
+			return n
+		}
+		okc += prev("B2")
+		ngc += prev("B3")
+		total += prev("B4")
+	} else {
+		f = excelize.NewFile()
+		f.SetSheetName("Sheet1", summary)
+	}
+
+	// Summary
 	f.SetCellValue(summary, "A1", "Type")
 	f.SetCellValue(summary, "B1", "Count")
 	f.SetCellValue(summary, "C1", "Ratio")
@@ -173,33 +392,71 @@ func SaveToXLSX(
 	f.SetCellValue(summary, "C4", 1.0)
 
 	// OK / NG
-	writeList := func(sheet string, list []Sample) {
-		f.NewSheet(sheet)
+	notes := 0
+	writeList := func(sheet string, list []Sample, full string) {
+		// 追記なら既存の行の後ろから（No も続き番号。間引いていれば最後の行の No から）
+		start, prev := 0, 0
+		if idx, _ := f.GetSheetIndex(sheet); appendMode && idx >= 0 {
+			rows, _ := f.GetRows(sheet)
+			start = max(len(rows)-1, 0)
+			prev = start
+			if start > 0 && len(rows[start]) > 0 {
+				if n, err := strconv.Atoi(rows[start][0]); err == nil {
+					prev = n
+				}
+			}
+		} else {
+			f.NewSheet(sheet)
+		}
 
-		col := 1
-		f.SetCellValue(sheet, "A1", "No")
-		col++
+		// 大きすぎるなら k 件に 1 件だけ書く（No は全件での番号のまま）
+		k := lim.stride(prev+len(list), len(cols)+2)
+		if k > 1 {
+			notes++
+			f.SetCellValue(summary, fmt.Sprintf("A%d", 5+notes), "Note")
+			f.SetCellValue(summary, fmt.Sprintf("B%d", 5+notes), xlsxThinNote(sheet, k, prev+len(list), full))
+		}
 
 		// xlsx は「元単位で保存」する（見出しは Key にするのが無難）
-		for _, p := range params {
-			cell, _ := excelize.CoordinatesToCellName(col, 1)
-			f.SetCellValue(sheet, cell, p.Key)
-			col++
+		for j, h := range xlsxHeader(cols) {
+			cell, _ := excelize.CoordinatesToCellName(j+1, 1)
+			f.SetCellValue(sheet, cell, h)
+		}
+
+		// 桁数の指定がある列は表示形式を付ける（値は元単位のまま）
+		for j, p := range cols {
+			numFmt := p.xlsxNumFmt()
+			if numFmt == "" || p.Type != Real {
+				continue
+			}
+			style, err := f.NewStyle(&excelize.Style{CustomNumFmt: &numFmt})
+			if err != nil {
+				continue
+			}
+			name, _ := excelize.ColumnNumberToName(j + 2)
+			f.SetColStyle(sheet, name, style)
 		}
-		cell, _ := excelize.CoordinatesToCellName(col, 1)
-		f.SetCellValue(sheet, cell, "y")
 
-		for i, s := range list {
-			row := i + 2
-			col = 1
+		for i := 0; i < len(list); i += k {
+			s := list[i]
+			row := start + i/k + 2
+			col := 1
 
 			cell, _ := excelize.CoordinatesToCellName(col, row)
-			f.SetCellValue(sheet, cell, i+1)
+			f.SetCellValue(sheet, cell, prev+i+1)
 			col++
 
-			for _, p := range params {
+			for _, p := range cols {
 				cell, _ := excelize.CoordinatesToCellName(col, row)
-				f.SetCellValue(sheet, cell, s.Values[p.Key]) // 元単位
+				v := s.Values[p.Key]
+				switch text, ok := p.cellText(v); {
+				case ok && p.Type == Categorical:
+					f.SetCellValue(sheet, cell, text)
+				case ok:
+					f.SetCellValue(sheet, cell, int64(math.Round(v)))
+				default:
+					f.SetCellValue(sheet, cell, v) // 元単位
+				}
 				col++
 			}
 			cell, _ = excelize.CoordinatesToCellName(col, row)
@@ -207,22 +464,57 @@ func SaveToXLSX(
 		}
 	}
 
-	writeList("OK", okList)
-	writeList("NG", ngList)
+	writeList("OK", okList, okFull)
+	writeList("NG", ngList, ngFull)
+
+	// Config（実際に使った設定。provenance.go）
+	if rc != nil {
+		const sheet = "Config"
+		if idx, _ := f.GetSheetIndex(sheet); idx >= 0 {
+			f.DeleteSheet(sheet)
+		}
+		f.NewSheet(sheet)
+		f.SetCellValue(sheet, "A1", "Item")
+		f.SetCellValue(sheet, "B1", "Value")
+		for i, r := range rc.rows() {
+			f.SetCellValue(sheet, fmt.Sprintf("A%d", i+2), r[0])
+			f.SetCellValue(sheet, fmt.Sprintf("B%d", i+2), r[1])
+		}
+	}
 
-	return f.SaveAs(filename)
+	// SaveAs は拡張子が .xlsx でないと保存しないので（途中結果の result.xlsx.partial など）、直接書く
+	fp, err := os.Create(name)
+	if err != nil {
+		return "", err
+	}
+	if err := f.Write(fp); err != nil {
+		fp.Close()
+		return "", err
+	}
+	return name, fp.Close()
 }
 
-// list を TSV で保存する（params の順で出力）
+// list を TSV で保存する（cols の順で出力）。実際に保存したファイル名を返す
 // TSV は「表示単位で保存」する（DisplayScale を適用）
-func SaveListToTSV(filename string, params []ParamSpec, list []Sample) error {
+// rc があれば見出しの前に "# 項目: 値" の行で設定を書く（provenance.go）
+// 追記の場合はヘッダを書かずに行だけを足す（既存のヘッダが違えば追記せずに別の名前にする。resolveAppend）
+func SaveListToTSV(filename string, policy ExistPolicy, cols []Column, list []Sample, rc *RunConfig) (string, error) {
 	if filename == "" {
-		return nil
+		return "", nil
 	}
 
-	fp, err := os.Create(filename)
+	name, appendMode, err := resolveAppend(filename, policy, tsvHeader(cols), textHeader('\t'))
+	if err != nil {
+		return "", err
+	}
+
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendMode {
+		flag = os.O_WRONLY | os.O_APPEND
+	}
+	fp, err := os.OpenFile(name, flag, 0o644)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer fp.Close()
 
@@ -230,27 +522,105 @@ func SaveListToTSV(filename string, params []ParamSpec, list []Sample) error {
 	w.Comma = '\t'
 
 	// ヘッダ：Label
-	header := make([]string, 0, len(params)+1)
-	for _, p := range params {
-		header = append(header, p.Label)
-	}
-	header = append(header, "y")
-	if err := w.Write(header); err != nil {
-		return err
+	if !appendMode {
+		if err := writeTSVComments(fp, rc); err != nil {
+			return "", err
+		}
+		if err := w.Write(tsvHeader(cols)); err != nil {
+			return "", err
+		}
 	}
 
 	for _, s := range list {
-		row := make([]string, 0, len(params)+1)
-		for _, p := range params {
-			v := s.Values[p.Key] * p.DisplayScale
-			row = append(row, fmt.Sprintf("%.10g", v)) // TSV は桁少し多め（解析向け）
+		if err := w.Write(tsvRow(cols, s)); err != nil {
+			return "", err
+		}
+	}
+
+	w.Flush()
+	return name, w.Error()
+}
+
+// CSVConfig: csv 出力の形式
+type CSVConfig struct {
+	Precision int  // 有効数字の桁数（0 なら tsv と同じ 10 桁）
+	BOM       bool // 先頭に UTF-8 の BOM を付ける（日本語版の Excel で µ や Ω を文字化けさせずに開くため）
+}
+
+// SaveListToCSV: list を CSV で保存する（列と単位は tsv と同じ。先頭に設定の行は書かない）。実際に保存したファイル名を返す
+// 追記の場合はヘッダも BOM も書かずに行だけを足す（既存のヘッダが違えば追記せずに別の名前にする）
+func SaveListToCSV(filename string, policy ExistPolicy, cols []Column, list []Sample, c CSVConfig) (string, error) {
+	if filename == "" {
+		return "", nil
+	}
+
+	name, appendMode, err := resolveAppend(filename, policy, tsvHeader(cols), textHeader(','))
+	if err != nil {
+		return "", err
+	}
+
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendMode {
+		flag = os.O_WRONLY | os.O_APPEND
+	}
+	fp, err := os.OpenFile(name, flag, 0o644)
+	if err != nil {
+		return "", err
+	}
+	defer fp.Close()
+
+	if !appendMode && c.BOM {
+		if _, err := fp.WriteString("\uFEFF"); err != nil {
+			return "", err
 		}
-		row = append(row, fmt.Sprintf("%.10g", s.Y))
-		if err := w.Write(row); err != nil {
-			return err
+	}
+	w := csv.NewWriter(fp)
+	if !appendMode {
+		if err := w.Write(tsvHeader(cols)); err != nil {
+			return "", err
+		}
+	}
+	prec := c.Precision
+	if prec <= 0 {
+		prec = tsvPrecision
+	}
+	for _, s := range list {
+		if err := w.Write(formatRow(cols, s, prec)); err != nil {
+			return "", err
 		}
 	}
 
 	w.Flush()
-	return w.Error()
+	return name, w.Error()
+}
+
+// tsvPrecision: tsv に書く有効数字の桁数（解析向けに少し多め）
+const tsvPrecision = 10
+
+// tsvHeader: tsv の見出し行（列の Label と y）
+func tsvHeader(cols []Column) []string {
+	header := make([]string, 0, len(cols)+1)
+	for _, p := range cols {
+		header = append(header, p.Label)
+	}
+	return append(header, "y")
+}
+
+// tsvRow: サンプル 1 件分の tsv の行
+func tsvRow(cols []Column, s Sample) []string {
+	return formatRow(cols, s, tsvPrecision)
+}
+
+// formatRow: サンプル 1 件分の tsv / csv の行（表示単位、有効数字 prec 桁）
+func formatRow(cols []Column, s Sample, prec int) []string {
+	row := make([]string, 0, len(cols)+1)
+	for _, p := range cols {
+		if text, ok := p.cellText(s.Values[p.Key]); ok {
+			row = append(row, text)
+			continue
+		}
+		v := s.Values[p.Key] * p.DisplayScale
+		row = append(row, strconv.FormatFloat(v, 'g', prec, 64))
+	}
+	return append(row, strconv.FormatFloat(s.Y, 'g', prec, 64))
 }
@@ -0,0 +1,312 @@
+// parallel.go
+// 複数のゴルーチンでの評価（Config.Workers）
+//
+// 各ワーカーは自分の Sampler（擬似乱数ならワーカーごとに別の seed の系列）で点を作って評価し、
+// 結果をまとめて 1 つの記録側に送る。記録（カウンタ・保存・集計）は探索ループと同じく 1 か所で行うので、
+// 保存や集計のコードは並列を意識しなくてよい。iters はワーカーが先に番号を確保するので MaxIters を超えない。
+//
+// 評価結果を使う探索モード（mcmc / cem / cmaes / ga / gp）と独自の Sampler は 1 ワーカーで動かす。
+// sobol / lhs / grid は点列を 1 つに保つため、同じ Sampler をロックして共有する。
+// ワーカーが 2 以上だと、同じ Seed でも評価の順（保存されるサンプル）が実行ごとに変わる。
+// F は複数のゴルーチンから同時に呼ばれるので、外の変数に書き込まないこと。
+//
+// Config.Deterministic なら、i 番目の点の乱数を seed と i だけから作り（IndexedSampler）、
+// 記録も番号順に並べ直すので、ワーカーの数によらずビット単位で同じ結果になる。
+// 制約の Repair の寄せ先も parallelBatch ごとに忘れる（どのワーカーが受け持っても同じになるように）。
+// 擬似乱数でない Sampler は 1 ワーカーで順に動かす（それで同じ結果になる）。
+
+package main
+
+import (
+	"context"
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// parallelBatch: ワーカーが一度に確保・送信する点数
+const parallelBatch = 64
+
+// resolvedWorkers: cfg で探索したときに使うワーカーの数（engine を作らずに。validate の表示用）
+func resolvedWorkers(cfg Config) int {
+	s, err := newSampler(cfg)
+	if err != nil {
+		s = nil
+	}
+	return workerCount(cfg, s)
+}
+
+// workerCount: 使うワーカーの数（0 なら CPU 数。並列にできない Sampler なら 1）
+func workerCount(cfg Config, s Sampler) int {
+	n := cfg.Workers
+	if n <= 0 {
+		n = runtime.NumCPU()
+	}
+	if cfg.Sampler != nil || cfg.Source != nil {
+		return 1
+	}
+	if _, ok := s.(FeedbackSampler); ok {
+		return 1
+	}
+	if cfg.Deterministic && !independent(s) {
+		return 1
+	}
+	return n
+}
+
+// indexed: 番号ごとの乱数で並列に探索するか（ワーカーが 1 でも loopParallel を使う）
+func (e *engine) indexed() bool {
+	return e.cfg.Deterministic && independent(e.sampler)
+}
+
+// IndexedSampler: i 番目の点の乱数を seed と i から作る（SetIndex で番号を指定してから Next）
+// 同じ番号で続けて Next を呼ぶと（制約での引き直し）、その番号の系列の続きを返す。
+// Antithetic なら奇数番目の最初の点は直前の偶数番目の 1 − u。
+type IndexedSampler struct {
+	Antithetic bool
+
+	seed    uint64
+	state   uint64
+	reflect bool // 次の Next で 1 − u を返す
+}
+
+func (s *IndexedSampler) Init(dim int, seed int64) error {
+	s.seed = uint64(seed)
+	return nil
+}
+
+// SetIndex: i 番目の点の系列に切り替える
+func (s *IndexedSampler) SetIndex(i int64) {
+	base := uint64(i)
+	s.reflect = false
+	if s.Antithetic {
+		base &^= 1
+		s.reflect = i&1 == 1
+	}
+	s.state = splitmix64(s.seed ^ splitmix64(base+1))
+}
+
+func (s *IndexedSampler) Next(u []float64) {
+	for j := range u {
+		s.state += 0x9e3779b97f4a7c15
+		u[j] = float64(splitmix64(s.state)>>11) * 0x1p-53
+		if s.reflect {
+			u[j] = math.Min(1-u[j], math.Nextafter(1, 0))
+		}
+	}
+	s.reflect = false
+}
+
+// independent: ワーカーごとに別の seed の Sampler を作ってよいか（擬似乱数。渡された乱数の元は作り直せない）
+func independent(s Sampler) bool {
+	switch t := s.(type) {
+	case *RandomSampler:
+		return t.Src == nil
+	case *AntitheticSampler:
+		return independent(t.base)
+	}
+	return false
+}
+
+// withStream: Jump できる乱数の元なら、ワーカー w 用に w 回 Jump した系列にする（できたら true）
+func withStream(s Sampler, w int) bool {
+	switch t := s.(type) {
+	case *RandomSampler:
+		if jumpable(t.RNG) {
+			t.Stream = w
+			return true
+		}
+	case *AntitheticSampler:
+		return withStream(t.base, w)
+	}
+	return false
+}
+
+// workerSeed: ワーカー w の seed（w = 0 は seed そのもの）
+func workerSeed(seed int64, w int) int64 {
+	if w == 0 {
+		return seed
+	}
+	return int64(splitmix64(uint64(seed) + uint64(w)))
+}
+
+// lockedSampler: 1 つの Sampler を複数のワーカーで共有する
+type lockedSampler struct {
+	mu sync.Mutex
+	s  Sampler
+}
+
+func (l *lockedSampler) Init(dim int, seed int64) error { return nil } // 共有元は初期化済み
+
+func (l *lockedSampler) Next(u []float64) {
+	l.mu.Lock()
+	l.s.Next(u)
+	l.mu.Unlock()
+}
+
+// fork: ワーカー用の engine（Sampler・相関の作業領域・制約の状態だけを自分で持つ）
+func (e *engine) fork(s Sampler) *engine {
+	w := *e
+	w.sampler = s
+	w.anchor = nil
+	w.rejected, w.repaired = 0, 0
+	w.xbuf = make([]float64, len(e.xbuf))
+	if e.copula != nil {
+		w.copula = &copula{L: e.copula.L, buf: make([]float64, len(e.copula.buf))}
+	}
+	return &w
+}
+
+// workerSamplers: この段のワーカーごとの Sampler（e.sampler は seed で初期化済み）
+func (e *engine) workerSamplers(seed int64) ([]Sampler, error) {
+	ss := make([]Sampler, e.workers)
+	if e.indexed() {
+		for w := range ss {
+			s := &IndexedSampler{Antithetic: e.cfg.Antithetic}
+			if err := s.Init(len(e.params), seed); err != nil {
+				return nil, err
+			}
+			ss[w] = s
+		}
+		return ss, nil
+	}
+	if !independent(e.sampler) {
+		shared := &lockedSampler{s: e.sampler}
+		for w := range ss {
+			ss[w] = shared
+		}
+		return ss, nil
+	}
+	ss[0] = e.sampler
+	for w := 1; w < len(ss); w++ {
+		s, err := newSampler(e.cfg)
+		if err != nil {
+			return nil, err
+		}
+		ws := workerSeed(seed, w)
+		if withStream(s, w) {
+			ws = seed // 同じ系列を w 回 Jump したもの
+		}
+		if err := s.Init(len(e.params), ws); err != nil {
+			return nil, err
+		}
+		ss[w] = s
+	}
+	return ss, nil
+}
+
+// loopParallel: loop の並列版（iters が end に達するまで探索する）
+func (e *engine) loopParallel(ctx context.Context, end int64, seed int64) error {
+	params := e.params
+
+	ss, err := e.workerSamplers(seed)
+	if err != nil {
+		return err
+	}
+
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	indexed := e.indexed()
+	claim := atomic.LoadInt64(&e.iters) // 次にワーカーが確保する番号
+	out := make(chan sampleBatch, 2*e.workers)
+	errs := make(chan error, e.workers)
+	forks := make([]*engine, e.workers)
+	var wg sync.WaitGroup
+	for w := range forks {
+		f := e.fork(ss[w])
+		forks[w] = f
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u := make([]float64, len(params))
+			for wctx.Err() == nil {
+				start := atomic.AddInt64(&claim, parallelBatch) - parallelBatch
+				if start >= end {
+					return
+				}
+				batch := sampleBatch{start: start, list: make([]Sample, 0, min(parallelBatch, end-start))}
+				// F2 の x は使い回すバッファなので、バッチごとにまとめてコピーする
+				var xs []float64
+				if f.useF2 {
+					xs = make([]float64, 0, cap(batch.list)*len(params))
+				}
+				if indexed {
+					f.anchor = nil
+				}
+				for i := start; i < end && i < start+parallelBatch; i++ {
+					if is, ok := f.sampler.(*IndexedSampler); ok {
+						is.SetIndex(i)
+					}
+					s, err := f.draw(params, u)
+					if err != nil {
+						errs <- err
+						cancel()
+						return
+					}
+					if s.x != nil {
+						xs = append(xs, s.x...)
+						s.x = xs[len(xs)-len(s.x):]
+					}
+					batch.list = append(batch.list, s)
+				}
+				select {
+				case out <- batch:
+				case <-wctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	// 番号順に記録する（indexed でなければ届いた順）
+	next := atomic.LoadInt64(&e.iters)
+	pending := map[int64][]Sample{}
+	for b := range out {
+		if !indexed {
+			e.recordBatch(b.list, params)
+			continue
+		}
+		pending[b.start] = b.list
+		for list, ok := pending[next]; ok; list, ok = pending[next] {
+			delete(pending, next)
+			e.recordBatch(list, params)
+			next += int64(len(list))
+		}
+	}
+	for _, f := range forks {
+		e.rejected += f.rejected
+		e.repaired += f.repaired
+	}
+	select {
+	case err := <-errs:
+		return err
+	default:
+		return nil
+	}
+}
+
+// sampleBatch: ワーカーが番号 start から評価した点
+type sampleBatch struct {
+	start int64
+	list  []Sample
+}
+
+// recordBatch: ワーカーから届いた点を記録する
+func (e *engine) recordBatch(batch []Sample, params []ParamSpec) {
+	for _, s := range batch {
+		if s.OK {
+			s = e.filled(s)
+			e.box.add(params, s.Values)
+		}
+		e.record(s)
+
+		n := atomic.AddInt64(&e.iters, 1)
+		e.progress(n)
+		e.saveIfDue()
+	}
+}
@@ -0,0 +1,116 @@
+// paramtype.go
+// 整数・カテゴリのパラメータ（ParamSpec.Type）
+//
+// Int は [Min, Max] の整数だけを選ぶ（コイルの巻数など）。Log なら対数軸で選んで整数に丸める。
+// Categorical は名前の付いた選択肢（Choices）から等確率で選ぶ（回路方式の切り替えなど）。
+// 目的関数には選択肢の Value が渡り、表示・保存では Name を書く。
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+type ParamType int
+
+const (
+	Real        ParamType = iota // 連続値（従来通り）
+	Int                          // 整数
+	Categorical                  // 名前の付いた選択肢
+)
+
+// Choice: カテゴリの選択肢 1 つ（目的関数には Value が渡る）
+type Choice struct {
+	Name  string
+	Value float64
+}
+
+// intRange: Int のパラメータが取れる整数の範囲 [lo, hi]
+func intRange(p ParamSpec) (float64, float64) {
+	return math.Ceil(p.Min), math.Floor(p.Max)
+}
+
+// typedQuantile: u ∈ [0,1) を Int / Categorical の値に変換する
+func typedQuantile(u float64, p ParamSpec) (float64, error) {
+	switch p.Type {
+	case Int:
+		lo, hi := intRange(p)
+		if hi < lo {
+			return 0, fmt.Errorf("param %s: no integer in [%g, %g]", p.Key, p.Min, p.Max)
+		}
+		if p.Scale == Log {
+			if lo <= 0 {
+				return 0, fmt.Errorf("param %s: log sampling requires Min>0 (got Min=%g)", p.Key, p.Min)
+			}
+			// 丸めで両端が半分になるので、±0.5 広げた対数軸で選ぶ
+			a, b := math.Log(lo-0.5), math.Log(hi+0.5)
+			v := math.Round(math.Exp(a + u*(b-a)))
+			return math.Max(lo, math.Min(hi, v)), nil
+		}
+		n := hi - lo + 1
+		return lo + math.Min(math.Floor(u*n), n-1), nil
+	case Categorical:
+		n := len(p.Choices)
+		if n == 0 {
+			return 0, fmt.Errorf("param %s: categorical param has no Choices", p.Key)
+		}
+		return p.Choices[min(int(u*float64(n)), n-1)].Value, nil
+	default:
+		return 0, fmt.Errorf("param %s: unknown type", p.Key)
+	}
+}
+
+// typedCDF: typedQuantile の逆（値 v のマスの中央）
+func typedCDF(v float64, p ParamSpec) float64 {
+	switch p.Type {
+	case Int:
+		lo, hi := intRange(p)
+		if hi <= lo {
+			return 0
+		}
+		if p.Scale == Log {
+			a, b := math.Log(lo-0.5), math.Log(hi+0.5)
+			return (math.Log(v) - a) / (b - a)
+		}
+		return (math.Round(v) - lo + 0.5) / (hi - lo + 1)
+	case Categorical:
+		for i, c := range p.Choices {
+			if c.Value == v {
+				return (float64(i) + 0.5) / float64(len(p.Choices))
+			}
+		}
+	}
+	return 0
+}
+
+// isFixed: 探索しても値が変わらないパラメータ（範囲が 1 点、選択肢が 1 つ）
+func isFixed(p ParamSpec) bool {
+	switch p.Type {
+	case Categorical:
+		return len(p.Choices) <= 1
+	case Int:
+		lo, hi := intRange(p)
+		return hi <= lo
+	}
+	return p.Min == p.Max
+}
+
+// cellText: Int / Categorical の列の表示用の文字列（それ以外は ok = false）
+func (c Column) cellText(v float64) (string, bool) {
+	switch c.Type {
+	case Int:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return "", false
+		}
+		return strconv.FormatInt(int64(math.Round(v)), 10), true
+	case Categorical:
+		for _, ch := range c.Choices {
+			if ch.Value == v {
+				return ch.Name, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,336 @@
+// parquet.go
+// OK / NG のサンプルを Parquet で保存する（Config.OKParquet / NGParquet）
+//
+// 数百万件の結果は xlsx では開けず、tsv も読み込みに時間がかかる。Parquet（列ごと・zstd 圧縮）なら
+// pandas・Polars・DuckDB ですぐに開ける（pd.read_parquet("ok.parquet")、DuckDB なら SELECT * FROM 'ok.parquet'）。
+// 列は tsv と同じ（名前は Label、値は表示単位）で、実数は DOUBLE、整数は INT64、カテゴリは名前の文字列。
+// ファイルのメタデータの parquetConfigKey に、実際に使った設定の JSON（provenance.go）を入れる。
+// 書き方は仕様の最小限（必須の列だけ・PLAIN エンコーディング・データページ v1・統計なし）で、
+// メタデータの Thrift（compact protocol）もここで書く。追記はできないので、append なら別の名前にする。
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	parquetMagic     = "PAR1"
+	parquetConfigKey = "wpt.config" // 設定の JSON を入れるメタデータのキー
+	parquetGroupRows = 1 << 20      // 行グループの行数
+	parquetPageRows  = 1 << 16      // データページの行数
+)
+
+// Parquet の型・エンコーディング・圧縮の番号（parquet.thrift）
+const (
+	pqInt64        = 2
+	pqDouble       = 5
+	pqByteArray    = 6
+	pqRequired     = 0
+	pqUTF8         = 0
+	pqPlain        = 0
+	pqRLE          = 3
+	pqZstd         = 6
+	pqDataPage     = 0
+	pqThriftI32    = 5
+	pqThriftI64    = 6
+	pqThriftBin    = 8
+	pqThriftList   = 9
+	pqThriftStruct = 12
+)
+
+// thriftWriter: Thrift の compact protocol で構造体を書く
+type thriftWriter struct {
+	b    []byte
+	last []int16 // 入れ子の構造体ごとの直前のフィールド番号
+}
+
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{last: []int16{0}}
+}
+
+func zigzag(v int64) uint64 { return uint64(v<<1) ^ uint64(v>>63) }
+
+func (t *thriftWriter) uvarint(v uint64) { t.b = binary.AppendUvarint(t.b, v) }
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	last := &t.last[len(t.last)-1]
+	if d := id - *last; d > 0 && d <= 15 {
+		t.b = append(t.b, byte(d)<<4|typ)
+	} else {
+		t.b = append(t.b, typ)
+		t.uvarint(zigzag(int64(id)))
+	}
+	*last = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, pqThriftI32)
+	t.uvarint(zigzag(int64(v)))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, pqThriftI64)
+	t.uvarint(zigzag(v))
+}
+
+func (t *thriftWriter) str(id int16, s string) {
+	t.field(id, pqThriftBin)
+	t.rawStr(s)
+}
+
+func (t *thriftWriter) rawStr(s string) {
+	t.uvarint(uint64(len(s)))
+	t.b = append(t.b, s...)
+}
+
+// list: 要素の型と数。続けて要素を書く（構造体なら elem と end で囲む）
+func (t *thriftWriter) list(id int16, typ byte, n int) {
+	t.field(id, pqThriftList)
+	if n < 15 {
+		t.b = append(t.b, byte(n)<<4|typ)
+	} else {
+		t.b = append(t.b, 0xf0|typ)
+		t.uvarint(uint64(n))
+	}
+}
+
+// begin: 構造体のフィールドを始める
+func (t *thriftWriter) begin(id int16) {
+	t.field(id, pqThriftStruct)
+	t.elem()
+}
+
+// elem: リストの要素の構造体を始める
+func (t *thriftWriter) elem() { t.last = append(t.last, 0) }
+
+// end: 構造体を終える
+func (t *thriftWriter) end() {
+	t.b = append(t.b, 0)
+	t.last = t.last[:len(t.last)-1]
+}
+
+// parquetColumn: 書く列 1 つ
+type parquetColumn struct {
+	name  string
+	typ   int32
+	value func(s Sample) float64 // DOUBLE / INT64 の値
+	text  func(s Sample) string  // BYTE_ARRAY の値
+}
+
+// parquetColumns: tsv と同じ列（最後が y）
+func parquetColumns(cols []Column) []parquetColumn {
+	out := make([]parquetColumn, 0, len(cols)+1)
+	for _, c := range cols {
+		pc := parquetColumn{name: c.Label, typ: pqDouble}
+		switch c.Type {
+		case Int:
+			pc.typ = pqInt64
+			pc.value = func(s Sample) float64 { return s.Values[c.Key] }
+		case Categorical:
+			pc.typ = pqByteArray
+			pc.text = func(s Sample) string {
+				v := s.Values[c.Key]
+				if text, ok := c.cellText(v); ok {
+					return text
+				}
+				return strconv.FormatFloat(v, 'g', -1, 64)
+			}
+		default:
+			pc.value = func(s Sample) float64 { return s.Values[c.Key] * c.DisplayScale }
+		}
+		out = append(out, pc)
+	}
+	return append(out, parquetColumn{name: "y", typ: pqDouble, value: func(s Sample) float64 { return s.Y }})
+}
+
+// plain: list の列 c を PLAIN エンコーディングで並べる
+func (c parquetColumn) plain(list []Sample) []byte {
+	var b []byte
+	for _, s := range list {
+		switch c.typ {
+		case pqDouble:
+			b = binary.LittleEndian.AppendUint64(b, math.Float64bits(c.value(s)))
+		case pqInt64:
+			v := c.value(s)
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				v = 0
+			}
+			b = binary.LittleEndian.AppendUint64(b, uint64(int64(math.Round(v))))
+		case pqByteArray:
+			text := c.text(s)
+			b = binary.LittleEndian.AppendUint32(b, uint32(len(text)))
+			b = append(b, text...)
+		}
+	}
+	return b
+}
+
+// parquetChunk: 書いた列の塊の位置と大きさ
+type parquetChunk struct {
+	offset       int64
+	values       int64
+	uncompressed int64
+	compressed   int64
+}
+
+// SaveListToParquet: list を Parquet で保存し、実際に保存したファイル名を返す（追記はできないので別の名前にする）
+func SaveListToParquet(filename string, policy ExistPolicy, cols []Column, list []Sample, rc *RunConfig) (string, error) {
+	if filename == "" {
+		return "", nil
+	}
+	if policy == AppendToExisting {
+		policy = RenameWithSuffix
+	}
+	name, _, err := resolveOutput(filename, policy)
+	if err != nil {
+		return "", err
+	}
+	fp, err := os.Create(name)
+	if err != nil {
+		return "", err
+	}
+	defer fp.Close()
+	if err := writeParquet(fp, cols, list, rc); err != nil {
+		return "", err
+	}
+	return name, fp.Close()
+}
+
+// writeParquet: Parquet のファイル全体を書く
+func writeParquet(f *os.File, cols []Column, list []Sample, rc *RunConfig) error {
+	w := bufio.NewWriterSize(f, 1<<20)
+	var offset int64
+	write := func(b []byte) error {
+		n, err := w.Write(b)
+		offset += int64(n)
+		return err
+	}
+	if err := write([]byte(parquetMagic)); err != nil {
+		return err
+	}
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		return err
+	}
+	defer enc.Close()
+
+	pcs := parquetColumns(cols)
+	var groups [][]parquetChunk
+	for lo := 0; lo < len(list); lo += parquetGroupRows {
+		rows := list[lo:min(lo+parquetGroupRows, len(list))]
+		chunks := make([]parquetChunk, len(pcs))
+		for j, pc := range pcs {
+			ch := parquetChunk{offset: offset, values: int64(len(rows))}
+			for p := 0; p < len(rows); p += parquetPageRows {
+				page := rows[p:min(p+parquetPageRows, len(rows))]
+				raw := pc.plain(page)
+				comp := enc.EncodeAll(raw, nil)
+				h := newThriftWriter()
+				h.i32(1, pqDataPage)
+				h.i32(2, int32(len(raw)))
+				h.i32(3, int32(len(comp)))
+				h.begin(5) // DataPageHeader
+				h.i32(1, int32(len(page)))
+				h.i32(2, pqPlain)
+				h.i32(3, pqRLE)
+				h.i32(4, pqRLE)
+				h.end()
+				h.b = append(h.b, 0)
+				if err := write(h.b); err != nil {
+					return err
+				}
+				if err := write(comp); err != nil {
+					return err
+				}
+				ch.uncompressed += int64(len(h.b) + len(raw))
+				ch.compressed += int64(len(h.b) + len(comp))
+			}
+			chunks[j] = ch
+		}
+		groups = append(groups, chunks)
+	}
+
+	meta := parquetFooter(pcs, groups, int64(len(list)), rc)
+	if err := write(meta); err != nil {
+		return err
+	}
+	if err := write(binary.LittleEndian.AppendUint32(nil, uint32(len(meta)))); err != nil {
+		return err
+	}
+	if err := write([]byte(parquetMagic)); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// parquetFooter: FileMetaData（スキーマ・行グループ・設定の JSON）
+func parquetFooter(pcs []parquetColumn, groups [][]parquetChunk, rows int64, rc *RunConfig) []byte {
+	t := newThriftWriter()
+	t.i32(1, 1) // version
+	t.list(2, pqThriftStruct, len(pcs)+1)
+	t.elem()
+	t.str(4, "schema")
+	t.i32(5, int32(len(pcs)))
+	t.end()
+	for _, pc := range pcs {
+		t.elem()
+		t.i32(1, pc.typ)
+		t.i32(3, pqRequired)
+		t.str(4, pc.name)
+		if pc.typ == pqByteArray {
+			t.i32(6, pqUTF8)
+		}
+		t.end()
+	}
+	t.i64(3, rows)
+	t.list(4, pqThriftStruct, len(groups))
+	for _, chunks := range groups {
+		t.elem() // RowGroup
+		var total int64
+		t.list(1, pqThriftStruct, len(chunks))
+		for j, ch := range chunks {
+			pc := pcs[j]
+			t.elem() // ColumnChunk
+			t.i64(2, ch.offset)
+			t.begin(3) // ColumnMetaData
+			t.i32(1, pc.typ)
+			t.list(2, pqThriftI32, 2)
+			t.uvarint(zigzag(pqPlain))
+			t.uvarint(zigzag(pqRLE))
+			t.list(3, pqThriftBin, 1)
+			t.rawStr(pc.name)
+			t.i32(4, pqZstd)
+			t.i64(5, ch.values)
+			t.i64(6, ch.uncompressed)
+			t.i64(7, ch.compressed)
+			t.i64(9, ch.offset)
+			t.end()
+			t.end()
+			total += ch.uncompressed
+		}
+		t.i64(2, total)
+		t.i64(3, chunks[0].values)
+		t.end()
+	}
+	if rc != nil {
+		if js, err := json.Marshal(rc); err == nil {
+			t.list(5, pqThriftStruct, 1)
+			t.elem() // KeyValue
+			t.str(1, parquetConfigKey)
+			t.str(2, string(js))
+			t.end()
+		}
+	}
+	version, _ := buildVersion()
+	t.str(6, "wpt-parameter-search2 version "+version)
+	t.b = append(t.b, 0)
+	return t.b
+}
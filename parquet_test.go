@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// thriftReader: テスト用の compact protocol の読み手（フィールド番号 → 値。構造体は map、リストは []any）
+type thriftReader struct {
+	b   []byte
+	err error
+}
+
+func (r *thriftReader) byte() byte {
+	if len(r.b) == 0 {
+		r.err = fmt.Errorf("unexpected end")
+		return 0
+	}
+	c := r.b[0]
+	r.b = r.b[1:]
+	return c
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.err = fmt.Errorf("bad varint")
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *thriftReader) varint() int64 {
+	u := r.uvarint()
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+func (r *thriftReader) value(typ byte) any {
+	switch typ {
+	case pqThriftI32, pqThriftI64:
+		return r.varint()
+	case pqThriftBin:
+		n := int(r.uvarint())
+		if n > len(r.b) {
+			r.err = fmt.Errorf("binary length %d past the end", n)
+			return ""
+		}
+		s := string(r.b[:n])
+		r.b = r.b[n:]
+		return s
+	case pqThriftList:
+		h := r.byte()
+		n, et := int(h>>4), h&0x0f
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		list := make([]any, n)
+		for i := range list {
+			list[i] = r.value(et)
+		}
+		return list
+	case pqThriftStruct:
+		return r.structure()
+	}
+	r.err = fmt.Errorf("unexpected type %d", typ)
+	return nil
+}
+
+func (r *thriftReader) structure() map[int16]any {
+	m := map[int16]any{}
+	var last int16
+	for r.err == nil {
+		h := r.byte()
+		if h == 0 {
+			return m
+		}
+		typ := h & 0x0f
+		if d := int16(h >> 4); d != 0 {
+			last += d
+		} else {
+			last = int16(r.varint())
+		}
+		m[last] = r.value(typ)
+	}
+	return m
+}
+
+func TestParquetFile(t *testing.T) {
+	cols := []Column{
+		{Key: "f", Label: "f [kHz]", DisplayScale: 1e-3},
+		{Key: "n", Label: "n", DisplayScale: 1, Type: Int},
+		{Key: "core", Label: "core", DisplayScale: 1, Type: Categorical, Choices: []Choice{{Name: "ferrite", Value: 0}, {Name: "air", Value: 1}}},
+	}
+	var list []Sample
+	for i := range 20 {
+		list = append(list, Sample{Values: map[string]float64{"f": 80e3 + float64(i)*1e3, "n": float64(i), "core": float64(i % 2)}, Y: 0.1 * float64(i)})
+	}
+	name := filepath.Join(t.TempDir(), "ok.parquet")
+	if _, err := SaveListToParquet(name, Overwrite, cols, list, &RunConfig{Objective: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 先頭と末尾の PAR1、末尾の 4 バイトがフッタの長さ
+	if string(b[:4]) != parquetMagic || string(b[len(b)-4:]) != parquetMagic {
+		t.Fatalf("magic: %q ... %q", b[:4], b[len(b)-4:])
+	}
+	n := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	if n <= 0 || n > len(b)-12 {
+		t.Fatalf("footer length %d out of range (file %d bytes)", n, len(b))
+	}
+	r := &thriftReader{b: b[len(b)-8-n : len(b)-8]}
+	meta := r.structure()
+	if r.err != nil || len(r.b) != 0 {
+		t.Fatalf("FileMetaData: err %v, %d bytes left", r.err, len(r.b))
+	}
+
+	if meta[3] != int64(len(list)) {
+		t.Errorf("num_rows = %v, want %d", meta[3], len(list))
+	}
+	schema := meta[2].([]any)
+	want := []string{"schema", "f [kHz]", "n", "core", "y"}
+	if len(schema) != len(want) {
+		t.Fatalf("schema has %d elements, want %d", len(schema), len(want))
+	}
+	for i, el := range schema {
+		if got := el.(map[int16]any)[4]; got != want[i] {
+			t.Errorf("schema[%d].name = %v, want %q", i, got, want[i])
+		}
+	}
+	if schema[0].(map[int16]any)[5] != int64(4) {
+		t.Errorf("num_children = %v, want 4", schema[0].(map[int16]any)[5])
+	}
+	types := []int64{pqDouble, pqInt64, pqByteArray, pqDouble}
+	for i, typ := range types {
+		if got := schema[i+1].(map[int16]any)[1]; got != typ {
+			t.Errorf("schema[%d].type = %v, want %d", i+1, got, typ)
+		}
+	}
+
+	groups := meta[4].([]any)
+	if len(groups) != 1 {
+		t.Fatalf("%d row groups, want 1", len(groups))
+	}
+	chunks := groups[0].(map[int16]any)[1].([]any)
+	for j, ch := range chunks {
+		md := ch.(map[int16]any)[3].(map[int16]any)
+		if md[4] != int64(pqZstd) {
+			t.Errorf("column %d codec = %v, want zstd (%d)", j, md[4], pqZstd)
+		}
+		if md[5] != int64(len(list)) {
+			t.Errorf("column %d num_values = %v", j, md[5])
+		}
+	}
+	if kv := meta[5].([]any)[0].(map[int16]any); kv[1] != parquetConfigKey {
+		t.Errorf("key_value_metadata key = %v", kv[1])
+	}
+
+	// 最初の列（f [kHz]）のページを読んで値を確かめる
+	md := chunks[0].(map[int16]any)[3].(map[int16]any)
+	pr := &thriftReader{b: b[md[9].(int64):]}
+	page := pr.structure()
+	if pr.err != nil {
+		t.Fatal(pr.err)
+	}
+	comp := pr.b[:page[3].(int64)]
+	dec, _ := zstd.NewReader(nil)
+	defer dec.Close()
+	raw, err := dec.DecodeAll(comp, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(raw)) != page[2].(int64) || len(raw) != 8*len(list) {
+		t.Fatalf("page: %d bytes, header says %v", len(raw), page[2])
+	}
+	for i, s := range list {
+		got := math.Float64frombits(binary.LittleEndian.Uint64(raw[8*i:]))
+		if want := s.Values["f"] * 1e-3; got != want {
+			t.Errorf("f[%d] = %g, want %g", i, got, want)
+		}
+	}
+	if dph := page[5].(map[int16]any); dph[1] != int64(len(list)) {
+		t.Errorf("data page num_values = %v", dph[1])
+	}
+}
@@ -0,0 +1,185 @@
+// pilot.go
+// 長い探索の前の 1% の試し探索と、続けるかの確認（Config.ConfirmAbove・-yes）
+//
+// 起動時の試し評価（guard.go）の速さから、探索全体が ConfirmAbove より長くかかりそうなら、
+// MaxIters の 1% だけ同じ設定で実際に探索し（並列も含めて）、全体での OK の数・時間・出力の大きさを見積もって、
+// 続けるかを聞く。OK が 0 件のまま何時間も回してしまうのを防ぐため。
+// -yes を付けたとき、-machine のとき、標準入力が端末でないとき（パイプ・CI）は試し探索もせずに続ける。
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// defaultConfirmAbove: Config.ConfirmAbove が 0 のとき、確認する見込み時間
+const defaultConfirmAbove = 10 * time.Minute
+
+// xlsxMaxRows: xlsx の 1 シートの行数の上限（見出しを除く）
+const xlsxMaxRows = 1_048_575
+
+func (c Config) confirmAbove() time.Duration {
+	switch {
+	case c.ConfirmAbove < 0:
+		return 0
+	case c.ConfirmAbove == 0:
+		return defaultConfirmAbove
+	}
+	return c.ConfirmAbove
+}
+
+// pilotEstimate: 1% の試し探索から見積もった全体の値
+type pilotEstimate struct {
+	Iters    int64 // 試し探索の点数
+	Elapsed  time.Duration
+	OKHits   int64
+	Runtime  time.Duration // 全体の見込み時間
+	OK, NG   int64         // 全体の OK / NG の見込み数
+	OKRows   int64         // 保存する OK の行数
+	NGRows   int64
+	OKBytes  int64 // OK の tsv の大きさの見込み
+	NGBytes  int64
+	XLSXRows int64 // xlsx の行数（OK と NG のシートの多い方）
+}
+
+// pilotPercent: cfg の MaxIters の 1% を同じ設定で探索して、全体を見積もる
+func pilotPercent(cfg Config) (pilotEstimate, error) {
+	cc := cfg
+	cc.MaxIters = max(cfg.MaxIters/100, 1)
+	cc.PrintEvery, cc.ProgressInterval = 0, 0
+	cc.AutosaveEvery, cc.CheckpointEvery = 0, 0
+	cc.StreamTSV = false
+	cc.EvalLog = EvalLogConfig{}
+	e, err := newEngine(cc)
+	if err != nil {
+		return pilotEstimate{}, err
+	}
+	start := time.Now()
+	if err := e.run(context.Background()); err != nil {
+		return pilotEstimate{}, err
+	}
+	est := pilotEstimate{Iters: e.iters, Elapsed: time.Since(start), OKHits: e.okHits}
+	if e.iters == 0 {
+		return est, nil
+	}
+	scale := float64(cfg.MaxIters) / float64(e.iters)
+	est.Runtime = time.Duration(float64(est.Elapsed) * scale)
+	est.OK = int64(float64(e.okHits) * scale)
+	est.NG = int64(float64(e.ngHits) * scale)
+	est.OKRows = min(est.OK, int64(cfg.MaxOKSave))
+	est.NGRows = min(est.NG, int64(cfg.MaxNGSave))
+	cols := e.columns()
+	est.OKBytes = est.OKRows * rowBytes(cols, e.okList)
+	est.NGBytes = est.NGRows * rowBytes(cols, e.ngList)
+	est.XLSXRows = max(est.OKRows, est.NGRows)
+	return est, nil
+}
+
+// quickRate: 1 ゴルーチンで評価する速さ（点/秒）を手短に測る（1000 点か 200ms まで）
+func quickRate(cfg Config) (float64, error) {
+	cc := cfg
+	cc.Sampler, cc.Search, cc.SamplingMethod = nil, "", "random"
+	cc.Zoom = ZoomConfig{}
+	e, err := newEngine(cc)
+	if err != nil {
+		return 0, err
+	}
+	u := make([]float64, len(e.params))
+	start := time.Now()
+	n := 0
+	for ; n < 1000 && time.Since(start) < 200*time.Millisecond; n++ {
+		if _, err := e.draw(e.params, u); err != nil {
+			return 0, err
+		}
+	}
+	return float64(n) / time.Since(start).Seconds(), nil
+}
+
+// rowBytes: tsv の 1 行の平均の大きさ（サンプルがなければ列数から見積もる）
+func rowBytes(cols []Column, list []Sample) int64 {
+	if len(list) == 0 {
+		return int64(12 * (len(cols) + 1))
+	}
+	var n int
+	for _, s := range list {
+		n += len(strings.Join(tsvRow(cols, s), "\t")) + 1
+	}
+	return int64(n / len(list))
+}
+
+// confirmRun: 全体が ConfirmAbove より長くかかりそうなら 1% の試し探索をして、続けるかを聞く
+// rate は起動時の試し評価の速さ（1 ゴルーチンでの点/秒。0 なら測っていない）。続けるなら true
+func confirmRun(cfg Config, rate float64, ask bool) (bool, error) {
+	limit := cfg.confirmAbove()
+	if limit == 0 || rate <= 0 || !ask {
+		return true, nil
+	}
+	if !interactive() {
+		return true, nil // 端末でなければ聞かない（試し探索もしない）
+	}
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	// 並列にしても CPU 数ほどは速くならないことが多いので、半分の速さで見込む
+	guess := time.Duration(float64(cfg.MaxIters) / (rate * max(float64(workers)/2, 1)) * float64(time.Second))
+	if guess <= limit {
+		return true, nil
+	}
+
+	fmt.Fprintf(os.Stderr, "[pilot] MaxIters=%d may take about %s; running 1%% first...\n", cfg.MaxIters, benchETA(cfg.MaxIters, rate*max(float64(workers)/2, 1)))
+	est, err := pilotPercent(cfg)
+	if err != nil {
+		return false, err
+	}
+	fmt.Fprintf(os.Stderr, "[pilot] %d points in %s: OK_hits=%d\n", est.Iters, est.Elapsed.Round(time.Millisecond), est.OKHits)
+	fmt.Fprintf(os.Stderr, "[pilot] full run: about %s,  OK≈%d  NG≈%d\n", est.Runtime.Round(time.Second), est.OK, est.NG)
+	fmt.Fprintf(os.Stderr, "[pilot] saved rows: OK=%d (tsv≈%s)  NG=%d (tsv≈%s)\n",
+		est.OKRows, formatBytes(est.OKBytes), est.NGRows, formatBytes(est.NGBytes))
+	if cfg.XLSX.Enabled {
+		if k := cfg.XLSXLimit.stride(int(est.XLSXRows), len(cfg.Params)+2); k > 1 {
+			fmt.Fprintf(os.Stderr, "[pilot] note: %d rows are too many for xlsx; the sheets will have 1 in %d (full list in tsv / parquet)\n",
+				est.XLSXRows, k)
+		}
+	}
+	if est.OKHits == 0 {
+		fmt.Fprintln(os.Stderr, "[pilot] warning: no OK in the pilot; the full run will likely find none")
+	}
+	fmt.Fprint(os.Stderr, "Proceed with the full search? [y/N] ")
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+// interactive: 標準入力が端末か（/dev/null も文字デバイスなので除く）
+func interactive() bool {
+	fi, err := os.Stdin.Stat()
+	if err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(fi, null)
+}
+
+// formatBytes: 1.2 MB のような表記
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	v, i := float64(n), 0
+	for v >= unit && i < 4 {
+		v /= unit
+		i++
+	}
+	return fmt.Sprintf("%.1f %cB", v, "kMGT"[i-1])
+}
@@ -0,0 +1,44 @@
+// plugin.go
+// 目的関数を Go のプラグイン（go build -buildmode=plugin で作った .so）から読む（Config.Plugin・-plugin）
+//
+// 研究室ごとの回路モデルを探索ツールとは別に管理できるように、F だけを別にビルドして渡せるようにする。
+// プラグインは package main で、次のどちらかを公開する（Get のような補助関数はプラグイン側に書く）。
+//
+//	func F(x map[string]float64) float64
+//	var F = func(x map[string]float64) float64 { ... }
+//
+// ビルド: go build -buildmode=plugin -o ss.so ./mymodel
+// Go のプラグインは Linux / macOS / FreeBSD で cgo を有効にしたときだけ使え、プラグインは
+// 探索ツールと同じ Go のバージョン・同じ版の依存パッケージでビルドする必要がある（plugin パッケージの制約）。
+// それ以外の環境では起動時に設定エラーにする（plugin_other.go）。
+
+//go:build (linux || darwin || freebsd) && cgo
+
+package main
+
+import (
+	"fmt"
+	"plugin"
+)
+
+// loadPluginF: path のプラグインから F を取り出す
+func loadPluginF(path string) (func(map[string]float64) float64, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("plugin: %w", err)
+	}
+	sym, err := p.Lookup("F")
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", path, err)
+	}
+	switch f := sym.(type) {
+	case func(map[string]float64) float64:
+		return f, nil
+	case *func(map[string]float64) float64:
+		if *f == nil {
+			return nil, fmt.Errorf("plugin %s: F is nil", path)
+		}
+		return *f, nil
+	}
+	return nil, fmt.Errorf("plugin %s: F has type %T; want func(map[string]float64) float64", path, sym)
+}
@@ -0,0 +1,15 @@
+// plugin_other.go
+// Go のプラグインが使えない環境（Windows や cgo なし）での Config.Plugin（plugin.go）
+
+//go:build !((linux || darwin || freebsd) && cgo)
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func loadPluginF(path string) (func(map[string]float64) float64, error) {
+	return nil, fmt.Errorf("plugin %s: Go plugins are not supported on %s (or without cgo); use Expr or Model instead", path, runtime.GOOS)
+}
@@ -0,0 +1,136 @@
+// presets.go
+// 名前で選べる設定のまとまり（-preset）
+//
+// 「まず粗く見る」「本番で細かく回す」「Qi の周波数帯に絞る」のような、よく使う設定を
+// そのたびに config_local.go で書き換えなくてよいように、名前を付けて登録しておき -preset で選ぶ。
+//   - コードで登録したもの（下の presets と RegisterPreset）
+//   - presets/<name>.yaml（.yml / .toml）。中身は -config と同じ書き方（configfile.go）で、同じ名前ならこちらが優先
+// -preset coarse,qi_band のようにカンマで区切ると順に重ねる。
+// 反映の順は DefaultConfig（config_local.go を含む）→ -preset → -config → フラグで、後のものが上書きする。
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// PresetDir: 設定ファイルの preset を探すディレクトリ
+const PresetDir = "presets"
+
+// Preset: 名前で選べる設定の変更
+type Preset struct {
+	About string              // -preset の説明に出す 1 行
+	Apply func(*Config) error // cfg を書き換える（使えない設定ならエラー）
+}
+
+var presets = map[string]Preset{
+	"coarse": {
+		About: "quick look: 1e5 Sobol points",
+		Apply: func(c *Config) error {
+			c.MaxIters = 100_000
+			c.SamplingMethod = "sobol"
+			return nil
+		},
+	},
+	"fine": {
+		About: "production run: 1e7 points, keep 1000 OK / NG samples",
+		Apply: func(c *Config) error {
+			c.MaxIters = 10_000_000
+			c.MaxOKSave, c.MaxNGSave = 1000, 1000
+			return nil
+		},
+	},
+	"qi_band": {
+		About: "limit f to the Qi operating band (87-205 kHz)",
+		Apply: func(c *Config) error {
+			for i, p := range c.Params {
+				if p.Key == "f" {
+					c.Params[i].Min, c.Params[i].Max = 87e3, 205e3
+					c.Params[i].Center, c.Params[i].TolPercent, c.Params[i].Values = 0, 0, nil
+					return nil
+				}
+			}
+			return errors.New("no param f")
+		},
+	},
+}
+
+// RegisterPreset: 独自の preset を名前で選べるようにする（同じ名前があれば panic）
+func RegisterPreset(name string, p Preset) {
+	if _, dup := presets[name]; dup {
+		panic("RegisterPreset: duplicate name " + name)
+	}
+	if p.Apply == nil {
+		panic("RegisterPreset: Apply is nil for " + name)
+	}
+	presets[name] = p
+}
+
+// presetNames: 登録された preset と PresetDir にある preset の名前（アルファベット順）
+func presetNames() string {
+	seen := map[string]bool{}
+	for k := range presets {
+		seen[k] = true
+	}
+	files, _ := os.ReadDir(PresetDir)
+	for _, f := range files {
+		ext := filepath.Ext(f.Name())
+		if !f.IsDir() && presetExt(ext) {
+			seen[strings.TrimSuffix(f.Name(), ext)] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for k := range seen {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return strings.Join(names, " / ")
+}
+
+func presetExt(ext string) bool {
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml", ".toml":
+		return true
+	}
+	return false
+}
+
+// presetFile: PresetDir にある name の設定ファイル（なければ ""）
+func presetFile(name string) string {
+	for _, ext := range []string{".yaml", ".yml", ".toml"} {
+		path := filepath.Join(PresetDir, name+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// applyPresets: カンマで区切った preset を順に cfg に反映する
+func applyPresets(list string, cfg *Config) error {
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if path := presetFile(name); path != "" {
+			if err := loadConfigFile(path, cfg); err != nil {
+				return fmt.Errorf("preset %s: %w", name, err)
+			}
+			continue
+		}
+		p, ok := presets[name]
+		if !ok {
+			return fmt.Errorf("unknown preset %q (%s)", name, presetNames())
+		}
+		if err := p.Apply(cfg); err != nil {
+			return fmt.Errorf("preset %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+// prior.go
+// 事前分布で重み付けした OK 率（Config.Prior）
+//
+// 通常の OK 率は「探索範囲の箱のうち OK の割合」で、箱の端も中央も同じ重さで数える。
+// 実際の使用条件（位置ずれの分布など）が分かっているなら、Prior にその密度（定数倍は不要）を渡すと、
+// 各点を Prior(x) / q(x)（q はその点を引く密度）で重み付けして「実際の条件のうち OK の割合」も示す。
+// q は各パラメータの Scale / Dist から計算する（Linear は一様、Log は 1/x に比例、Dist はその密度）。
+// 箱の外にある Prior の重みは数えない（箱の中に切り詰めた分布での割合になる）。
+//
+// 箱を均等に覆う点列（random / sobol / lhs / grid）でのみ使える。評価結果を使う探索モード・
+// 多段探索・相関（Correlations）では q が分からないので組み合わせられない。
+
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// PriorStats: 事前分布で重み付けした OK 率
+type PriorStats struct {
+	N      int64   // 重みが正の点の数
+	OKProb float64 // Σ w·OK / Σ w
+	SE     float64 // その標準誤差（デルタ法）
+	ESS    float64 // 有効サンプル数 (Σ w)² / Σ w²
+	Bad    int64   // Prior が負・NaN・±Inf を返した点の数（数えない）
+}
+
+// priorAcc: 重みの集計
+type priorAcc struct {
+	prior      func(map[string]float64) float64
+	params     []ParamSpec
+	n, bad     int64
+	sw, swOK   float64 // Σ w, Σ w·OK
+	sw2, sw2OK float64 // Σ w², Σ w²·OK
+}
+
+// newPriorAcc: 使えない組み合わせなら panic（設定エラー）
+func newPriorAcc(cfg Config, s Sampler) *priorAcc {
+	if _, ok := s.(FeedbackSampler); ok {
+		panic(fmt.Sprintf("Prior cannot be combined with %T (use random / sobol / lhs / grid)", s))
+	}
+	if len(cfg.Correlations) > 0 {
+		panic("Prior cannot be combined with Correlations")
+	}
+	if cfg.Zoom.Phases > 1 {
+		panic("Prior cannot be combined with Zoom")
+	}
+	return &priorAcc{prior: cfg.Prior, params: cfg.Params}
+}
+
+func (a *priorAcc) add(s Sample) {
+	w := a.prior(s.Values)
+	if w < 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+		a.bad++
+		return
+	}
+	for _, p := range a.params {
+		w /= samplingDensity(p, s.Values[p.Key])
+	}
+	if w == 0 || math.IsInf(w, 0) || math.IsNaN(w) {
+		return
+	}
+	a.n++
+	a.sw += w
+	a.sw2 += w * w
+	if s.OK {
+		a.swOK += w
+		a.sw2OK += w * w
+	}
+}
+
+func (a *priorAcc) result() *PriorStats {
+	if a.sw == 0 {
+		return &PriorStats{Bad: a.bad}
+	}
+	p := a.swOK / a.sw
+	// Σ w²(OK − p)² = Σ w²·OK·(1 − 2p) + p²·Σ w²
+	v := (a.sw2OK*(1-2*p) + p*p*a.sw2) / (a.sw * a.sw)
+	return &PriorStats{
+		N:      a.n,
+		OKProb: p,
+		SE:     math.Sqrt(math.Max(v, 0)),
+		ESS:    a.sw * a.sw / a.sw2,
+		Bad:    a.bad,
+	}
+}
+
+// samplingDensity: p の値 v を引く密度（定数倍は除く。パラメータごとに一定の倍率は比を取ると消える）
+func samplingDensity(p ParamSpec, v float64) float64 {
+	if p.Min == p.Max || p.Type != Real || len(p.Values) > 0 {
+		return 1 // 固定値・離散値はどれも同じ確率
+	}
+	d := p.Dist
+	switch d.Kind {
+	case Normal:
+		z := (v - d.Mu) / d.Sigma
+		return math.Exp(-z * z / 2)
+	case LogNormal:
+		z := (math.Log(v) - d.Mu) / d.Sigma
+		return math.Exp(-z*z/2) / v
+	case Triangular:
+		if v < d.Mode {
+			return (v - p.Min) / (d.Mode - p.Min)
+		}
+		return (p.Max - v) / (p.Max - d.Mode)
+	case Beta:
+		t := (v - p.Min) / (p.Max - p.Min)
+		return math.Pow(t, d.A-1) * math.Pow(1-t, d.B-1)
+	}
+	if p.Scale == Log {
+		return 1 / v
+	}
+	return 1
+}
+
+// PrintPrior: 重み付けした OK 率を、箱の中の OK 率と並べて表示する
+func PrintPrior(ps *PriorStats, iters, okHits int64) {
+	fmt.Println("=== OK probability under the prior ===")
+	if ps != nil && ps.Bad > 0 {
+		fmt.Printf("(prior returned a negative or non-finite density at %d points; skipped)\n", ps.Bad)
+	}
+	if ps == nil || ps.N == 0 {
+		fmt.Println("(no samples with positive prior density)")
+		return
+	}
+	var box float64
+	if iters > 0 {
+		box = float64(okHits) / float64(iters)
+	}
+	fmt.Printf("prior-weighted=%s ± %s  (ESS=%.0f of %d)\n", fmt4(ps.OKProb), fmt4(ps.SE), ps.ESS, ps.N)
+	fmt.Printf("uniform in box=%s\n", fmt4(box))
+	if ps.ESS < 100 {
+		fmt.Println("(few effective samples: the prior is concentrated where few points were drawn)")
+	}
+}
@@ -0,0 +1,77 @@
+// profile.go
+// 探索ループのプロファイル（-cpuprofile / -memprofile / -trace）
+//
+// 目的関数の速さを調べるときに main.go を書き換えなくて済むように、探索（run）の間だけ記録する。
+// 見るときは `go tool pprof -http=: cpu.prof` / `go tool trace trace.out`。
+
+package main
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// profiler: 記録中のプロファイル
+type profiler struct {
+	cpu, tr *os.File
+	mem     string
+}
+
+// startProfile: 指定されたものの記録を始める（"" のものは記録しない）
+func startProfile(cpuFile, memFile, traceFile string) (*profiler, error) {
+	p := &profiler{mem: memFile}
+	if cpuFile != "" {
+		f, err := os.Create(cpuFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+		p.cpu = f
+	}
+	if traceFile != "" {
+		f, err := os.Create(traceFile)
+		if err != nil {
+			p.stop()
+			return nil, err
+		}
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			p.stop()
+			return nil, err
+		}
+		p.tr = f
+	}
+	return p, nil
+}
+
+// stop: 記録を止めてファイルを閉じ、メモリのプロファイルを書く
+func (p *profiler) stop() error {
+	var errs []error
+	if p.cpu != nil {
+		pprof.StopCPUProfile()
+		errs = append(errs, p.cpu.Close())
+		p.cpu = nil
+	}
+	if p.tr != nil {
+		trace.Stop()
+		errs = append(errs, p.tr.Close())
+		p.tr = nil
+	}
+	if p.mem != "" {
+		f, err := os.Create(p.mem)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			runtime.GC() // 最新の割り当て状況にする
+			errs = append(errs, pprof.Lookup("allocs").WriteTo(f, 0), f.Close())
+		}
+		p.mem = ""
+	}
+	return errors.Join(errs...)
+}
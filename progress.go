@@ -0,0 +1,56 @@
+// progress.go
+// 評価の速さに合わせた進行状況の表示間隔（Config.PrintEvery が負のとき）
+//
+// PrintEvery を固定すると、速い目的関数では表示が多すぎて遅くなり、外部プログラムを呼ぶような遅い目的関数では
+// 何分も表示が変わらず止まっているように見える。PrintEvery: AutoPrintEvery（負の値）なら、前回の表示からの
+// 速さを測って、次の表示までの回数をおよそ progressTarget ごとになるように決め直す。
+// 時刻を見るのは表示するときだけなので、1 回の評価ごとの負担は回数の比較だけ。
+
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// AutoPrintEvery: PrintEvery をこれ（負の値）にすると、評価の速さに合わせて表示する
+const AutoPrintEvery int64 = -1
+
+// progressTarget: PrintEvery が負のときの表示の間隔の目安
+const progressTarget = 500 * time.Millisecond
+
+// progressText: 進行状況を表示する間隔（PrintEvery・ProgressInterval の既定値や負の値を解いたもの）
+func progressText(cfg Config) string {
+	switch {
+	case cfg.ProgressInterval > 0:
+		return "every " + cfg.ProgressInterval.String()
+	case cfg.PrintEvery < 0:
+		return "auto (about every " + progressTarget.String() + ")"
+	case cfg.PrintEvery == 0:
+		return "off"
+	}
+	return fmt.Sprintf("every %d iters", cfg.PrintEvery)
+}
+
+// adaptiveProgress: n 回目の後、次に表示する回数に達していれば表示して、次の回数を決め直す
+func (e *engine) adaptiveProgress(n int64) {
+	if n < e.progressNext {
+		return
+	}
+	now := time.Now()
+	step := int64(1)
+	if !e.progressAt.IsZero() && n > e.progressN {
+		prev := max(n-e.progressN, 1)
+		dt := now.Sub(e.progressAt)
+		if dt > 0 {
+			step = int64(float64(n-e.progressN) * float64(progressTarget) / float64(dt))
+		} else {
+			step = prev * 4
+		}
+		// 最初の数回は測った時間が短くてばらつくので、一度に 4 倍までしか広げない
+		step = min(max(step, 1), prev*4)
+	}
+	e.printProgress(n)
+	e.progressN, e.progressAt = n, now
+	e.progressNext = n + step
+}
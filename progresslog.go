@@ -0,0 +1,115 @@
+// progresslog.go
+// 進行状況の表示ごとに、時刻・反復数・件数・速さを 1 行ずつファイルに書く（Config.ProgressLog）
+//
+// 長い探索の後で、どこで速さが落ちたか（CPU の温度による制限・ほかのジョブとの取り合い）や、
+// それが Zoom の段の切り替わりと重なるかを確かめるためのもの。表示と同じ間隔（PrintEvery / ProgressInterval）で
+// time・elapsed_s・phase・iters・OK・NG・INVALID・rate（前の行からの 1 秒あたりの評価数）の tsv を書き、
+// 探索の最後にも 1 行書く。表示しない設定（PrintEvery が 0 で ProgressInterval も 0）なら最後の 1 行だけになる。
+// 書いた分は 1 秒ごとに書き出すので、実行中でも tail -f で見られる。
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// progressLogFlushEvery: 書きためた行を書き出す間隔
+const progressLogFlushEvery = time.Second
+
+// progressLog: 書いている進行状況の記録
+type progressLog struct {
+	name    string
+	f       *os.File
+	w       *bufio.Writer
+	iters   int64     // 前の行の反復数
+	at      time.Time // 前の行の時刻
+	flushed time.Time
+	err     error
+}
+
+// openProgressLog: ProgressLog が有効なら記録するファイルを開く（探索の前に呼ぶ）
+func (e *engine) openProgressLog() error {
+	if e.cfg.ProgressLog == "" {
+		return nil
+	}
+	name, appendMode, err := resolveOutput(e.cfg.ProgressLog, e.cfg.OnExisting)
+	if err != nil {
+		return err
+	}
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendMode {
+		flag = os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(name, flag, 0o644)
+	if err != nil {
+		return err
+	}
+	l := &progressLog{name: name, f: f, w: bufio.NewWriter(f), iters: atomic.LoadInt64(&e.iters), flushed: time.Now()}
+	if !appendMode {
+		if _, err := l.w.WriteString("time\telapsed_s\tphase\titers\tOK\tNG\tINVALID\trate\n"); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	e.progLog = l
+	return nil
+}
+
+// logProgress: i 回目の時点の 1 行を書く
+func (e *engine) logProgress(i int64) {
+	l := e.progLog
+	if l == nil || l.f == nil || l.err != nil {
+		return
+	}
+	now := time.Now()
+	if l.at.IsZero() {
+		l.at = e.started
+	}
+	var rate float64
+	if dt := now.Sub(l.at).Seconds(); dt > 0 {
+		rate = float64(i-l.iters) / dt
+	}
+	_, l.err = fmt.Fprintf(l.w, "%s\t%.3f\t%d\t%d\t%d\t%d\t%d\t%.1f\n",
+		now.Format("2006-01-02T15:04:05.000"), (e.elapsed + now.Sub(e.started)).Seconds(), e.phase+1, i,
+		atomic.LoadInt64(&e.okHits), atomic.LoadInt64(&e.ngHits), atomic.LoadInt64(&e.invalidHits), rate)
+	l.iters, l.at = i, now
+	if l.err == nil && now.Sub(l.flushed) >= progressLogFlushEvery {
+		l.err = l.w.Flush()
+		l.flushed = now
+	}
+}
+
+// endProgressLog: 探索が終わった時点の 1 行を書く（前の行と同じ反復数なら書かない）
+func (e *engine) endProgressLog() {
+	l := e.progLog
+	if l == nil || e.started.IsZero() {
+		return
+	}
+	if n := atomic.LoadInt64(&e.iters); n != l.iters {
+		e.logProgress(n)
+	}
+}
+
+// closeProgressLog: 最後の 1 行を書いて閉じ、ファイル名と最初のエラーを返す（2 回目以降は何もしない）
+func (e *engine) closeProgressLog() (string, error) {
+	l := e.progLog
+	if l == nil {
+		return "", nil
+	}
+	if l.f == nil {
+		return l.name, l.err
+	}
+	e.endProgressLog()
+	if err := l.w.Flush(); l.err == nil {
+		l.err = err
+	}
+	if err := l.f.Close(); l.err == nil {
+		l.err = err
+	}
+	l.f = nil
+	return l.name, l.err
+}
@@ -0,0 +1,218 @@
+// provenance.go
+// 実際に使った設定を結果のファイルに残す（Config.ConfigJSON）
+//
+// 結果のファイルだけが手元に残っても、どの設定で出したものか分かり、同じ結果を出し直せるように、
+// preset・-config・-set・フラグをすべて当てた後の設定（範囲・seed・反復数・乱数・目的関数・コマンドライン・
+// プログラムの版）を次の 3 か所に書く。
+//   - xlsx の Config シート（項目と値の 2 列）
+//   - tsv の先頭の "# 項目: 値" の行（追記するときは書かない。読むときは # で始まる行を飛ばす）
+//   - ConfigJSON のファイル（既定は config.json。bundle にも入る）
+// Go の関数（F・Objective など）の中身は書けないので、目的関数はどこから取ったか（objectiveSource）だけを残す。
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RunConfig: 結果のファイルに残す設定
+type RunConfig struct {
+	Version        string        `json:"version"`   // プログラムの版（モジュールの版と VCS のリビジョン）
+	GoVersion      string        `json:"goVersion"` // ビルドした Go の版
+	Created        string        `json:"created"`
+	Args           []string      `json:"args"` // コマンドライン（プログラム名を除く）
+	Objective      string        `json:"objective"`
+	Seed           int64         `json:"seed"`
+	RNG            string        `json:"rng"`
+	SamplingMethod string        `json:"samplingMethod,omitempty"`
+	Search         string        `json:"search,omitempty"`
+	Workers        int           `json:"workers"`    // 実際に使ったワーカーの数（Workers: 0 なら CPU 数。1 つで動かすモードなら 1）
+	PrintEvery     string        `json:"printEvery"` // 進行状況の表示の間隔（progressText）
+	Deterministic  bool          `json:"deterministic"`
+	MaxIters       int64         `json:"maxIters"`
+	YRange         [2]jsonFloat  `json:"yRange"`
+	YEpsilon       float64       `json:"yEpsilon,omitempty"`
+	MaxOKSave      int           `json:"maxOKSave"`
+	MaxNGSave      int           `json:"maxNGSave"`
+	Params         []runParam    `json:"params"`
+	Derived        []string      `json:"derived,omitempty"`
+	Score          []ScoreTerm   `json:"score,omitempty"`
+	Metric         *MetricConfig `json:"metric,omitempty"`
+}
+
+type runParam struct {
+	Key          string    `json:"key"`
+	Label        string    `json:"label"`
+	Type         string    `json:"type"`
+	Min          jsonFloat `json:"min"`
+	Max          jsonFloat `json:"max"`
+	Scale        string    `json:"scale"`
+	DisplayScale float64   `json:"displayScale"`
+	Values       []float64 `json:"values,omitempty"`
+	Choices      []string  `json:"choices,omitempty"`
+}
+
+// newRunConfig: cfg から結果のファイルに残す設定を作る（workers は実際に使ったワーカーの数）
+func newRunConfig(cfg Config, workers int) *RunConfig {
+	version, goVersion := buildVersion()
+	rc := &RunConfig{
+		Version:        version,
+		GoVersion:      goVersion,
+		Created:        time.Now().Format(time.RFC3339),
+		Args:           os.Args[1:],
+		Objective:      objectiveSource(cfg),
+		Seed:           cfg.Seed,
+		RNG:            orDefault(cfg.RNG, "xoshiro"),
+		SamplingMethod: cfg.SamplingMethod,
+		Search:         cfg.Search,
+		Workers:        workers,
+		PrintEvery:     progressText(cfg),
+		Deterministic:  cfg.Deterministic,
+		MaxIters:       cfg.MaxIters,
+		YRange:         [2]jsonFloat{jsonFloat(cfg.YRange.Min), jsonFloat(cfg.YRange.Max)},
+		YEpsilon:       cfg.YEpsilon,
+		MaxOKSave:      cfg.MaxOKSave,
+		MaxNGSave:      cfg.MaxNGSave,
+		Score:          cfg.Score,
+	}
+	if cfg.Metric.Kind != "" || len(cfg.Metric.Weights) > 0 || len(cfg.Metric.Log) > 0 || len(cfg.Metric.Linear) > 0 {
+		m := cfg.Metric
+		rc.Metric = &m
+	}
+	for _, p := range cfg.Params {
+		rp := runParam{
+			Key: p.Key, Label: p.Label, Type: "real",
+			Min: jsonFloat(p.Min), Max: jsonFloat(p.Max), Scale: p.Scale.String(), DisplayScale: p.DisplayScale,
+			Values: p.Values,
+		}
+		switch p.Type {
+		case Int:
+			rp.Type = "int"
+		case Categorical:
+			rp.Type = "categorical"
+			for _, c := range p.Choices {
+				rp.Choices = append(rp.Choices, c.Name)
+			}
+		}
+		rc.Params = append(rc.Params, rp)
+	}
+	for _, d := range cfg.Derived {
+		rc.Derived = append(rc.Derived, d.Key)
+	}
+	return rc
+}
+
+// buildVersion: ビルド情報から版を読む（go run では "(devel)" とリビジョン）
+func buildVersion() (version, goVersion string) {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown", runtime.Version()
+	}
+	version = bi.Main.Version
+	var rev, dirty string
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			rev = s.Value
+		case "vcs.modified":
+			if s.Value == "true" {
+				dirty = "-dirty"
+			}
+		}
+	}
+	// 擬似版（v0.0.0-日時-リビジョン）にはリビジョンが入っている
+	if rev = rev[:min(12, len(rev))]; rev != "" && !strings.Contains(version, rev) {
+		version += " " + rev + dirty
+	}
+	return version, bi.GoVersion
+}
+
+// rows: xlsx の Config シートと tsv の先頭に書く「項目・値」
+func (rc *RunConfig) rows() [][2]string {
+	g := func(x float64) string { return strconv.FormatFloat(x, 'g', -1, 64) }
+	rows := [][2]string{
+		{"version", rc.Version},
+		{"go", rc.GoVersion},
+		{"created", rc.Created},
+		{"args", strings.Join(rc.Args, " ")},
+		{"objective", rc.Objective},
+		{"seed", strconv.FormatInt(rc.Seed, 10)},
+		{"rng", rc.RNG},
+		{"sampling", orDefault(rc.SamplingMethod, "random")},
+		{"search", rc.Search},
+		{"workers", strconv.Itoa(rc.Workers)},
+		{"printEvery", rc.PrintEvery},
+		{"deterministic", strconv.FormatBool(rc.Deterministic)},
+		{"maxIters", strconv.FormatInt(rc.MaxIters, 10)},
+		{"yRange", fmt.Sprintf("%s %s", g(float64(rc.YRange[0])), g(float64(rc.YRange[1])))},
+		{"yEpsilon", g(rc.YEpsilon)},
+		{"maxOKSave", strconv.Itoa(rc.MaxOKSave)},
+		{"maxNGSave", strconv.Itoa(rc.MaxNGSave)},
+	}
+	for _, p := range rc.Params {
+		v := fmt.Sprintf("%s %s %s %s ×%s %q", p.Type, g(float64(p.Min)), g(float64(p.Max)), p.Scale, g(p.DisplayScale), p.Label)
+		switch {
+		case len(p.Choices) > 0:
+			v += " choices=" + strings.Join(p.Choices, ",")
+		case len(p.Values) > 0:
+			vs := make([]string, len(p.Values))
+			for i, x := range p.Values {
+				vs[i] = g(x)
+			}
+			v += " values=" + strings.Join(vs, ",")
+		}
+		rows = append(rows, [2]string{"param." + p.Key, v})
+	}
+	if len(rc.Derived) > 0 {
+		rows = append(rows, [2]string{"derived", strings.Join(rc.Derived, ",")})
+	}
+	if len(rc.Score) > 0 {
+		rows = append(rows, [2]string{"score", scoreFlag{&rc.Score}.String()})
+	}
+	if rc.Metric != nil {
+		rows = append(rows, [2]string{"metric", orDefault(rc.Metric.Kind, "euclidean")})
+	}
+	return rows
+}
+
+// tsvComments: tsv の先頭に書く "# 項目: 値" の行
+func (rc *RunConfig) tsvComments() []string {
+	rows := rc.rows()
+	lines := make([]string, len(rows))
+	for i, r := range rows {
+		lines[i] = "# " + r[0] + ": " + r[1]
+	}
+	return lines
+}
+
+// writeTSVComments: rc があれば tsv の先頭の行を書く
+func writeTSVComments(f *os.File, rc *RunConfig) error {
+	if rc == nil {
+		return nil
+	}
+	_, err := f.WriteString(strings.Join(rc.tsvComments(), "\n") + "\n")
+	return err
+}
+
+// SaveRunConfig: 設定を JSON で保存し、実際に保存したファイル名を返す（追記はできないので別の名前にする）
+func SaveRunConfig(filename string, policy ExistPolicy, rc *RunConfig) (string, error) {
+	if policy == AppendToExisting {
+		policy = RenameWithSuffix
+	}
+	name, _, err := resolveOutput(filename, policy)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(rc, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return name, os.WriteFile(name, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,270 @@
+// recommend.go
+// 探索後の「推奨仕様」：OK サンプルから範囲を絞り、代表値と絞った範囲での OK 確率を示す
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// RecommendConfig: 推奨仕様の設定（Enabled が false なら何もしない）
+type RecommendConfig struct {
+	Enabled      bool
+	Trim         float64 // 両端から除く OK サンプルの割合（例: 0.05 なら 5%〜95% の範囲）
+	Clusters     int     // 代表値（クラスタ中心）の数（0 なら 1）
+	ConfirmIters int64   // 絞った範囲での確認探索の回数（0 なら確認しない）
+}
+
+// Recommendation: 推奨仕様
+type Recommendation struct {
+	Params  []ParamSpec          // 絞った範囲
+	Centers []map[string]float64 // 代表値（大きいクラスタ順）
+	Sizes   []int                // 各クラスタの OK サンプル数
+	Confirm *Result              // 確認探索の結果（ConfirmIters が 0 なら nil）
+}
+
+// Recommend: 保存された OK サンプルから推奨仕様を作る（OK が 0 件なら nil）
+func Recommend(ctx context.Context, cfg Config, okList []Sample) (*Recommendation, error) {
+	rc := cfg.Recommend
+	if len(okList) == 0 {
+		return nil, nil
+	}
+	params := cfg.Params
+
+	// 各パラメータを両端 Trim ずつ除いた範囲に絞る
+	narrowed := make([]ParamSpec, len(params))
+	for j, p := range params {
+		vs := make([]float64, len(okList))
+		for i, s := range okList {
+			vs[i] = s.Values[p.Key]
+		}
+		sort.Float64s(vs)
+		narrowed[j] = p
+		narrowed[j].Min = quantileSorted(vs, rc.Trim)
+		narrowed[j].Max = quantileSorted(vs, 1-rc.Trim)
+	}
+
+	m, err := newMetric(cfg.Metric, params)
+	if err != nil {
+		return nil, err
+	}
+	centers, sizes := kmeans(m, okList, max(rc.Clusters, 1), cfg.Seed)
+	rec := &Recommendation{Params: narrowed, Centers: centers, Sizes: sizes}
+
+	if rc.ConfirmIters > 0 {
+		e, err := newEngine(confirmConfig(cfg, narrowed, rc.ConfirmIters))
+		if err != nil {
+			return nil, err
+		}
+		if err := e.run(ctx); err != nil {
+			return nil, err
+		}
+		res := e.result()
+		rec.Confirm = &res
+	}
+	return rec, nil
+}
+
+// confirmConfig: 絞った範囲 params で OK 確率を確かめる探索の設定
+// cfg から、目的関数・OK の判定・点列の作り方に要るものだけを写す（ほかの機能は、後から足したものも含めて確認には持ち込まない）。
+// OK の領域に点を集める探索モード（cem・cmaes・ga など）や mcmc では OK 確率が高く出るので、
+// 確認は一様に引く（Sobol ならそのまま、それ以外は擬似乱数）。保存も進捗の表示もしない。
+func confirmConfig(cfg Config, params []ParamSpec, iters int64) Config {
+	cc := Config{
+		Params:   params,
+		MaxIters: iters,
+		Seed:     cfg.Seed,
+
+		// 目的関数（Model / Expr などは F・Objective に反映済み。表示用に名前だけ写す）
+		F:                cfg.F,
+		F2:               cfg.F2,
+		Objective:        cfg.Objective,
+		Model:            cfg.Model,
+		Plugin:           cfg.Plugin,
+		WASM:             cfg.WASM,
+		Exec:             cfg.Exec,
+		Expr:             cfg.Expr,
+		ExprCompensated:  cfg.ExprCompensated,
+		Derived:          cfg.Derived,
+		ParamConstraints: cfg.ParamConstraints,
+		Constraint:       cfg.Constraint,
+		ConstraintTries:  cfg.ConstraintTries,
+
+		// OK の判定
+		YRange:       cfg.YRange,
+		Boundary:     cfg.Boundary,
+		BoundaryBand: cfg.BoundaryBand,
+		YEpsilon:     cfg.YEpsilon,
+		MarginalNG:   cfg.MarginalNG,
+
+		// 点列
+		Correlations:  cfg.Correlations,
+		RNG:           cfg.RNG,
+		Source:        cfg.Source,
+		Workers:       cfg.Workers,
+		Deterministic: cfg.Deterministic,
+	}
+	if cfg.SamplingMethod == "sobol" {
+		cc.SamplingMethod = "sobol"
+	}
+	return cc
+}
+
+// quantileSorted: 昇順の vs の q 分位点（線形補間）
+func quantileSorted(vs []float64, q float64) float64 {
+	if len(vs) == 0 {
+		return math.NaN()
+	}
+	q = math.Max(0, math.Min(1, q))
+	pos := q * float64(len(vs)-1)
+	i := int(pos)
+	if i >= len(vs)-1 {
+		return vs[len(vs)-1]
+	}
+	t := pos - float64(i)
+	return vs[i]*(1-t) + vs[i+1]*t
+}
+
+// normalize: 値を探索範囲に対して [0,1] に正規化する（Log は対数軸。固定値は 0）
+func normalize(p ParamSpec, v float64) float64 {
+	if p.Max == p.Min {
+		return 0
+	}
+	if p.Scale == Log {
+		return (math.Log(v) - math.Log(p.Min)) / (math.Log(p.Max) - math.Log(p.Min))
+	}
+	return (v - p.Min) / (p.Max - p.Min)
+}
+
+// denormalize: normalize の逆
+func denormalize(p ParamSpec, t float64) float64 {
+	if p.Max == p.Min {
+		return p.Min
+	}
+	if p.Scale == Log {
+		return math.Exp(math.Log(p.Min) + t*(math.Log(p.Max)-math.Log(p.Min)))
+	}
+	return p.Min + t*(p.Max-p.Min)
+}
+
+// kmeans: Config.Metric の距離での k-means（k-means++ 初期化。metric.go）。中心を大きいクラスタ順に返す
+func kmeans(m metric, list []Sample, k int, seed int64) ([]map[string]float64, []int) {
+	n := len(list)
+	k = min(k, n)
+	pts := make([][]float64, n)
+	for i, s := range list {
+		pts[i] = m.coords(s.Values)
+	}
+	dist2 := func(a, b []float64) float64 {
+		d := m.dist(a, b)
+		return d * d
+	}
+
+	rng := newRand(seed)
+	centers := [][]float64{append([]float64{}, pts[rng.Intn(n)]...)}
+	for len(centers) < k {
+		ds := make([]float64, n)
+		var total float64
+		for i, pt := range pts {
+			ds[i] = math.Inf(1)
+			for _, c := range centers {
+				ds[i] = math.Min(ds[i], dist2(pt, c))
+			}
+			total += ds[i]
+		}
+		r := rng.Float64() * total
+		i := 0
+		for ; i < n-1 && r >= ds[i]; i++ {
+			r -= ds[i]
+		}
+		centers = append(centers, append([]float64{}, pts[i]...))
+	}
+
+	assign := make([]int, n)
+	sizes := make([]int, k)
+	for iter := 0; iter < 100; iter++ {
+		changed := iter == 0
+		for i, pt := range pts {
+			best := 0
+			for c := 1; c < k; c++ {
+				if dist2(pt, centers[c]) < dist2(pt, centers[best]) {
+					best = c
+				}
+			}
+			if assign[i] != best {
+				assign[i] = best
+				changed = true
+			}
+		}
+		for c := range centers {
+			for j := range centers[c] {
+				centers[c][j] = 0
+			}
+			sizes[c] = 0
+		}
+		for i, pt := range pts {
+			c := assign[i]
+			sizes[c]++
+			for j := range pt {
+				centers[c][j] += pt[j]
+			}
+		}
+		for c := range centers {
+			if sizes[c] > 0 {
+				for j := range centers[c] {
+					centers[c][j] /= float64(sizes[c])
+				}
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	order := make([]int, k)
+	for c := range order {
+		order[c] = c
+	}
+	sort.SliceStable(order, func(a, b int) bool { return sizes[order[a]] > sizes[order[b]] })
+
+	outC := make([]map[string]float64, 0, k)
+	outS := make([]int, 0, k)
+	for _, c := range order {
+		if sizes[c] == 0 {
+			continue
+		}
+		outC = append(outC, m.values(centers[c]))
+		outS = append(outS, sizes[c])
+	}
+	return outC, outS
+}
+
+// PrintRecommendation: 推奨仕様をコンソールに表示する
+func PrintRecommendation(rec *Recommendation) {
+	fmt.Println("=== Recommended spec ===")
+	if rec == nil {
+		fmt.Println("(no OK samples)")
+		fmt.Println()
+		return
+	}
+	fmt.Println("(narrowed range, then nominal values = cluster centers)")
+	for _, p := range rec.Params {
+		fmt.Printf("%-12s [%s, %s]", p.Label, fmt4(p.Min*p.DisplayScale), fmt4(p.Max*p.DisplayScale))
+		for _, c := range rec.Centers {
+			fmt.Printf("  %s", fmt4(c[p.Key]*p.DisplayScale))
+		}
+		fmt.Println()
+	}
+	fmt.Printf("%-12s %v\n", "cluster size", rec.Sizes)
+	if rec.Confirm != nil {
+		var ratio float64
+		if rec.Confirm.Iters > 0 {
+			ratio = float64(rec.Confirm.OKHits) / float64(rec.Confirm.Iters)
+		}
+		fmt.Printf("OK_ratio in narrowed box=%s  (confirm iters=%d)\n", fmt4(ratio), rec.Confirm.Iters)
+	}
+	fmt.Println()
+}
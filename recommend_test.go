@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// 確認探索の設定には、目的関数・OK の判定・点列に要るものしか写さない
+func TestConfirmConfigWhitelist(t *testing.T) {
+	cfg := exprConfig(t, "a+b")
+	cfg.Search = "cem"
+	cfg.SamplingMethod = "mcmc"
+	cfg.Score = []ScoreTerm{{Key: "a", Weight: 1}}
+	cfg.Scenario.Inner = 10
+	cfg.Anneal.Enabled = true
+	cfg.Importance.Enabled = true
+	cfg.Zoom = ZoomConfig{Phases: 3}
+	cfg.MaxOKSave, cfg.MaxNGSave = 100, 100
+	cfg.PrintEvery = 1000
+	cfg.XLSX = OutputSpec{Enabled: true, File: "result.xlsx"}
+	cfg.SQLite = "results.db"
+	cfg.JSONL.File = "evals.jsonl"
+	cfg.CheckpointEvery = 1000
+	cfg.StreamTSV = true
+	cfg.CompareYRanges = []Range{{Min: 0, Max: 1}}
+	cfg.OutputColumns = []DerivedSpec{{Key: "c"}}
+	cfg.Recommend = RecommendConfig{Enabled: true, ConfirmIters: 100}
+
+	params := []ParamSpec{{Key: "a", Min: 0.1, Max: 0.2, DisplayScale: 1}}
+	cc := confirmConfig(cfg, params, 500)
+
+	allowed := map[string]bool{}
+	for _, name := range []string{"Params", "MaxIters", "Seed", "F", "F2", "Objective", "Model", "Plugin", "WASM", "Exec",
+		"Expr", "ExprCompensated", "Derived", "ParamConstraints", "Constraint", "ConstraintTries",
+		"YRange", "Boundary", "BoundaryBand", "YEpsilon", "MarginalNG",
+		"Correlations", "RNG", "Source", "Workers", "Deterministic", "SamplingMethod"} {
+		allowed[name] = true
+	}
+	v, typ := reflect.ValueOf(cc), reflect.TypeOf(cc)
+	for i := range typ.NumField() {
+		if f := typ.Field(i); !allowed[f.Name] && !v.Field(i).IsZero() {
+			t.Errorf("confirm config carries %s", f.Name)
+		}
+	}
+	if cc.MaxIters != 500 || len(cc.Params) != 1 || cc.Expr != "a+b" || cc.F == nil || cc.YRange != cfg.YRange {
+		t.Errorf("confirm config lost the objective, range or budget: %+v", cc)
+	}
+	if cc.SamplingMethod != "" || cc.Search != "" {
+		t.Errorf("sampling %q / search %q: want uniform", cc.SamplingMethod, cc.Search)
+	}
+	cfg.SamplingMethod = "sobol"
+	if confirmConfig(cfg, params, 500).SamplingMethod != "sobol" {
+		t.Errorf("sobol should be kept")
+	}
+}
+
+func TestRecommendConfirm(t *testing.T) {
+	cfg := exprConfig(t, "a+b")
+	cfg.Score = []ScoreTerm{{Key: "a", Weight: 1}}
+	cfg.Recommend = RecommendConfig{Enabled: true, Trim: 0.05, ConfirmIters: 2000}
+	var ok []Sample
+	for i := range 50 {
+		a := 0.05 + 0.004*float64(i)
+		ok = append(ok, Sample{Values: map[string]float64{"a": a, "b": 0.3 - a/2}, Y: a + 0.3 - a/2, OK: true})
+	}
+	rec, err := Recommend(context.Background(), cfg, ok)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Confirm == nil || rec.Confirm.Iters != 2000 {
+		t.Fatalf("confirm = %+v, want 2000 iters", rec.Confirm)
+	}
+	if len(rec.Confirm.OKList) != 0 || len(rec.Confirm.NGList) != 0 {
+		t.Errorf("confirm saved %d OK / %d NG samples, want none", len(rec.Confirm.OKList), len(rec.Confirm.NGList))
+	}
+}
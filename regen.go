@@ -0,0 +1,235 @@
+// regen.go
+// 前の実行の一部の点だけを作り直す（`go run . regen -from 1001 -to 1010 config.json`）
+//
+// 古い結果のファイルの行を確かめたいとき、探索全体をやり直さずに、指定した番号の点（params の値と y、OK / NG）
+// だけを作り直して表示する。番号は進行状況表示の iters と同じ 1 始まりで、-from から -to まで（両端を含む）。
+// 実行の記録（ConfigJSON。provenance.go）から Seed・乱数・点列・範囲・YRange などを読み、
+// 目的関数やほかの設定は今のフラグ・設定ファイルのもの（記録した args と同じものを付けて実行する）を使う。
+//   - Deterministic の実行は番号ごとの乱数なので、その番号の点だけを評価する
+//   - そうでなければ 1 ワーカーで動かした実行だけ作り直せる。前の番号の点は Sampler を進めるだけで評価しない
+//     （ParamConstraints があれば、引き直しを数えるために制約だけは確かめる）
+// 評価結果で次の点を決める探索モード（Search / mcmc）、多段探索（Zoom）、制約の Repair の実行は作り直せない。
+// -resume で再開した実行は、ワーカーが 2 以上なら再開後の系列が変わるので、再開後の番号は一致しない。
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// regenIterKey: 作り直した点の番号の列のキー
+const regenIterKey = "iter"
+
+// LoadRunConfig: ConfigJSON のファイルを読む
+func LoadRunConfig(name string) (*RunConfig, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var rc RunConfig
+	if err := json.Unmarshal(data, &rc); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if len(rc.Params) == 0 {
+		return nil, fmt.Errorf("%s: no params (not a config.json written by this program?)", name)
+	}
+	return &rc, nil
+}
+
+// applyTo: 記録した点列の設定を cfg に当てる（params は同じキーが同じ順に並んでいること）
+func (rc *RunConfig) applyTo(cfg *Config) error {
+	keys := func(n int, key func(int) string) []string {
+		ks := make([]string, n)
+		for i := range ks {
+			ks[i] = key(i)
+		}
+		return ks
+	}
+	want := keys(len(rc.Params), func(i int) string { return rc.Params[i].Key })
+	have := keys(len(cfg.Params), func(i int) string { return cfg.Params[i].Key })
+	if !slices.Equal(want, have) {
+		return fmt.Errorf("params of the run are %s but the current config has %s (run with the recorded args)",
+			strings.Join(want, ","), strings.Join(have, ","))
+	}
+	for i, rp := range rc.Params {
+		p := &cfg.Params[i]
+		sc, err := parseScale(rp.Scale)
+		if err != nil {
+			return fmt.Errorf("param %s: %w", rp.Key, err)
+		}
+		p.Min, p.Max, p.Scale = float64(rp.Min), float64(rp.Max), sc
+		p.Center, p.TolPercent = 0, 0
+		if len(rp.Values) > 0 {
+			p.Values = rp.Values
+		}
+	}
+	cfg.Seed = rc.Seed
+	cfg.RNG = rc.RNG
+	cfg.SamplingMethod = rc.SamplingMethod
+	cfg.Search = rc.Search
+	cfg.Workers = rc.Workers
+	cfg.Deterministic = rc.Deterministic
+	cfg.MaxIters = rc.MaxIters
+	cfg.YRange = Range{Min: float64(rc.YRange[0]), Max: float64(rc.YRange[1])}
+	cfg.YEpsilon = rc.YEpsilon
+	return nil
+}
+
+// regenerable: 番号を指定して点を作り直せる実行か
+func regenerable(cfg Config) error {
+	switch {
+	case cfg.Search != "":
+		return fmt.Errorf("search %s chooses points from earlier results", cfg.Search)
+	case cfg.SamplingMethod == "mcmc":
+		return fmt.Errorf("sampling mcmc chooses points from earlier results")
+	case cfg.Zoom.Phases > 1:
+		return fmt.Errorf("zoom changes the ranges between phases")
+	case cfg.Constraint == Repair && len(cfg.ParamConstraints) > 0:
+		return fmt.Errorf("constraint repair depends on earlier points")
+	case !cfg.Deterministic && cfg.Workers != 1 && cfg.Sampler == nil && cfg.Source == nil:
+		return fmt.Errorf("the run used %s workers without Deterministic, so the order of points is not reproducible", workersText(cfg.Workers))
+	}
+	return nil
+}
+
+func workersText(n int) string {
+	if n <= 0 {
+		return "all CPU"
+	}
+	return fmt.Sprint(n)
+}
+
+// regen: 番号 [from, to) の点（0 始まり）を作り直す
+func (e *engine) regen(from, to int64) ([]Sample, error) {
+	params := e.params
+	u := make([]float64, len(params))
+	var list []Sample
+	add := func(i int64, s Sample) {
+		s = e.filled(s)
+		s.Values[regenIterKey] = float64(i + 1)
+		list = append(list, s)
+	}
+
+	if e.indexed() {
+		is := &IndexedSampler{Antithetic: e.cfg.Antithetic}
+		if err := is.Init(len(params), e.cfg.Seed); err != nil {
+			return nil, err
+		}
+		e.sampler = is
+		for i := from; i < to; i++ {
+			is.SetIndex(i)
+			s, err := e.draw(params, u)
+			if err != nil {
+				return list, err
+			}
+			add(i, s)
+		}
+		return list, nil
+	}
+
+	// 前の番号の点は評価せずに進める（引き直しは制約だけで決まる）
+	resample := e.cfg.Constraint == Resample && !e.finite && len(e.cfg.ParamConstraints) > 0
+	for i := int64(0); i < from; i++ {
+		for try := 0; ; try++ {
+			e.sampler.Next(u)
+			if !resample || !e.constrained(params, u) {
+				break
+			}
+			if try+1 >= e.cfg.constraintTries() {
+				return nil, fmt.Errorf("param constraints: no feasible point in %d draws (iteration %d)", try+1, i+1)
+			}
+		}
+	}
+	for i := from; i < to; i++ {
+		s, err := e.draw(params, u)
+		if err != nil {
+			return list, err
+		}
+		add(i, s)
+	}
+	return list, nil
+}
+
+// runRegen: regen サブコマンド
+func runRegen(cfg Config, args []string) int {
+	fs := flag.NewFlagSet("regen", flag.ContinueOnError)
+	from := fs.Int64("from", 1, "first iteration to regenerate (1-based, as in the progress lines)")
+	to := fs.Int64("to", 0, "last iteration to regenerate, inclusive (0: same as -from)")
+	tsv := fs.String("tsv", "", "also save the regenerated samples to this tsv")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: go run . [flags of the run] regen [-from N] [-to M] [-tsv file] config.json")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return ExitConfigError
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return ExitConfigError
+	}
+	if *to == 0 {
+		*to = *from
+	}
+
+	rc, err := LoadRunConfig(fs.Arg(0))
+	if err == nil {
+		err = rc.applyTo(&cfg)
+	}
+	if err == nil && (*from < 1 || *to < *from || *to > cfg.MaxIters) {
+		err = fmt.Errorf("want 1 <= -from <= -to <= %d (MaxIters of the run), got %d..%d", cfg.MaxIters, *from, *to)
+	}
+	if err == nil {
+		if e := regenerable(cfg); e != nil {
+			err = fmt.Errorf("cannot regenerate: %w", e)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error: regen:", err)
+		return ExitConfigError
+	}
+	if src := objectiveSource(cfg); src != rc.Objective {
+		fmt.Printf("warning: the run used objective %q but the current config uses %q\n", rc.Objective, src)
+	}
+	if version, _ := buildVersion(); version != rc.Version {
+		fmt.Printf("warning: the run was made by version %q (this is %q); y may differ if the objective changed\n", rc.Version, version)
+	}
+	if len(rc.Args) > 0 {
+		fmt.Printf("recorded args: %s\n", strings.Join(rc.Args, " "))
+	}
+
+	e, err := newEngine(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return ExitConfigError
+	}
+	list, err := e.regen(*from-1, *to)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error: regen:", err)
+		return ExitError
+	}
+
+	cols := append([]Column{{Key: regenIterKey, Label: regenIterKey, Type: Int, DisplayScale: 1}}, e.columns()...)
+	cols = append(cols, Column{Key: "ok", Label: "ok", Type: Int, DisplayScale: 1})
+	for _, s := range list {
+		s.Values["ok"] = 0
+		if s.OK {
+			s.Values["ok"] = 1
+		}
+	}
+	fmt.Println()
+	PrintSampleTable(fmt.Sprintf("=== regenerated iterations %d..%d (seed %d) ===", *from, *to, cfg.Seed), cols, list, 0)
+	if *tsv != "" {
+		name, err := SaveListToTSV(*tsv, cfg.OnExisting, cols, list, rc)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			return ExitError
+		}
+		fmt.Printf("tsv saved: %s\n", name)
+	}
+	return ExitOK
+}
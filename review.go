@@ -0,0 +1,473 @@
+// review.go
+// 保存したサンプルを見直して印・メモを付ける（`go run . review result.xlsx`）
+//
+// 探索の出力から試作する候補を選ぶまでの間の作業用。OK のサンプルをページごとに表示し、
+// 候補に星（star）とメモ（note）を付けて、元のファイルに "star" / "note" 列として書き戻す。
+// 読めるのは xlsx（SaveToXLSX の OK / NG シート）・tsv・-machine の JSON（"ok" / "ng"）。
+// 既に star / note 列があれば読み込むので、何度でも続きから見直せる。
+//
+// コマンド（1 行ずつ入力）:
+//   n / Enter: 次のページ   p: 前のページ   g 番号: その行のページへ
+//   s 番号...: 星を付ける・外す   a 番号 メモ: メモを付ける（メモを省くと消す）
+//   f: 星付きだけを表示する・やめる   w: 書き戻す   q: 終了（書き戻していなければ確認する）   h: ヘルプ
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// 書き戻す列の見出し
+const (
+	StarKey = "star"
+	NoteKey = "note"
+)
+
+// reviewTable: 見直す表（数値も文字列のまま持つ）
+type reviewTable struct {
+	Header []string
+	Rows   [][]string
+	Stars  []bool
+	Notes  []string
+
+	save func(t *reviewTable) error // 元のファイルに書き戻す
+}
+
+// runReview: review サブコマンド
+func runReview(args []string) int {
+	fs := flag.NewFlagSet("review", flag.ContinueOnError)
+	sheet := fs.String("sheet", "OK", "OK / NG (xlsx sheet, or \"ok\" / \"ng\" in JSON)")
+	page := fs.Int("page", 1, "page to show first (1-based)")
+	pageSize := fs.Int("page-size", 20, "rows per page")
+	if err := fs.Parse(args); err != nil {
+		return ExitConfigError
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: review [-sheet OK|NG] [-page N] [-page-size N] result.xlsx|ok.tsv|result.json")
+		return ExitConfigError
+	}
+	t, err := loadReview(fs.Arg(0), *sheet)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return ExitConfigError
+	}
+	size := max(*pageSize, 1)
+	if err := t.review(os.Stdin, os.Stdout, size, (max(*page, 1)-1)*size); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return ExitError
+	}
+	return ExitOK
+}
+
+// loadReview: 拡張子で形式を決めて読む
+func loadReview(name, sheet string) (*reviewTable, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".xlsx":
+		return loadReviewXLSX(name, sheet)
+	case ".tsv":
+		return loadReviewTSV(name)
+	case ".json":
+		return loadReviewJSON(name, strings.ToLower(sheet))
+	default:
+		return nil, fmt.Errorf("review: %s: use .xlsx, .tsv or .json", name)
+	}
+}
+
+// split: header / rows から star / note 列を取り出す（なければ空）
+func (t *reviewTable) split() {
+	si, ni := -1, -1
+	for j, h := range t.Header {
+		switch h {
+		case StarKey:
+			si = j
+		case NoteKey:
+			ni = j
+		}
+	}
+	t.Stars = make([]bool, len(t.Rows))
+	t.Notes = make([]string, len(t.Rows))
+	for i, r := range t.Rows {
+		if si >= 0 && si < len(r) {
+			t.Stars[i] = strings.TrimSpace(r[si]) != ""
+		}
+		if ni >= 0 && ni < len(r) {
+			t.Notes[i] = r[ni]
+		}
+	}
+	keep := func(r []string) []string {
+		out := make([]string, 0, len(r))
+		for j, v := range r {
+			if j != si && j != ni {
+				out = append(out, v)
+			}
+		}
+		return out
+	}
+	t.Header = keep(t.Header)
+	for i, r := range t.Rows {
+		t.Rows[i] = keep(r)
+	}
+}
+
+// starText: star 列に書く値
+func starText(b bool) string {
+	if b {
+		return "*"
+	}
+	return ""
+}
+
+func loadReviewXLSX(name, sheet string) (*reviewTable, error) {
+	f, err := excelize.OpenFile(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("review: sheet %s of %s is empty", sheet, name)
+	}
+	t := &reviewTable{Header: rows[0], Rows: rows[1:]}
+	t.split()
+	t.save = func(t *reviewTable) error {
+		f, err := excelize.OpenFile(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		header, err := f.GetRows(sheet)
+		if err != nil {
+			return err
+		}
+		// star / note 列（なければ右端に足す）
+		si, ni := -1, -1
+		for j, h := range header[0] {
+			switch h {
+			case StarKey:
+				si = j + 1
+			case NoteKey:
+				ni = j + 1
+			}
+		}
+		next := len(header[0]) + 1
+		if si < 0 {
+			si, next = next, next+1
+		}
+		if ni < 0 {
+			ni = next
+		}
+		set := func(col, row int, v string) {
+			cell, _ := excelize.CoordinatesToCellName(col, row)
+			f.SetCellValue(sheet, cell, v)
+		}
+		set(si, 1, StarKey)
+		set(ni, 1, NoteKey)
+		for i := range t.Rows {
+			set(si, i+2, starText(t.Stars[i]))
+			set(ni, i+2, t.Notes[i])
+		}
+		return replaceFile(name, func(w io.Writer) error { return f.Write(w) })
+	}
+	return t, nil
+}
+
+func loadReviewTSV(name string) (*reviewTable, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	// 先頭の "# 項目: 値"（実際に使った設定。provenance.go）はそのまま書き戻す
+	var comments []byte
+	for bytes.HasPrefix(b, []byte("#")) {
+		line, rest, _ := bytes.Cut(b, []byte("\n"))
+		comments = append(append(comments, line...), '\n')
+		b = rest
+	}
+	r := csv.NewReader(bytes.NewReader(b))
+	r.Comma = '\t'
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("review: %s is empty", name)
+	}
+	t := &reviewTable{Header: rows[0], Rows: rows[1:]}
+	t.split()
+	t.save = func(t *reviewTable) error {
+		return replaceFile(name, func(w io.Writer) error {
+			if _, err := w.Write(comments); err != nil {
+				return err
+			}
+			cw := csv.NewWriter(w)
+			cw.Comma = '\t'
+			cw.Write(append(append([]string(nil), t.Header...), StarKey, NoteKey))
+			for i, r := range t.Rows {
+				cw.Write(append(append([]string(nil), r...), starText(t.Stars[i]), t.Notes[i]))
+			}
+			cw.Flush()
+			return cw.Error()
+		})
+	}
+	return t, nil
+}
+
+func loadReviewJSON(name, key string) (*reviewTable, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("review: %s: %w", name, err)
+	}
+	list, _ := doc[key].([]any)
+	if list == nil {
+		return nil, fmt.Errorf("review: %s has no %q samples (write it with -machine -machine-samples)", name, key)
+	}
+	var cols []string
+	if cs, ok := doc["columns"].([]any); ok {
+		for _, c := range cs {
+			if s, ok := c.(string); ok {
+				cols = append(cols, s)
+			}
+		}
+	}
+	text := func(v any) string {
+		if f, ok := v.(float64); ok {
+			return strconv.FormatFloat(f, 'g', 10, 64)
+		}
+		return "NaN" // null（NaN / ±Inf）
+	}
+	t := &reviewTable{Header: append(cols, "y")}
+	for _, item := range list {
+		m, _ := item.(map[string]any)
+		vals, _ := m["values"].(map[string]any)
+		row := make([]string, 0, len(cols)+1)
+		for _, c := range cols {
+			row = append(row, text(vals[c]))
+		}
+		row = append(row, text(m["y"]))
+		star, _ := m[StarKey].(bool)
+		note, _ := m[NoteKey].(string)
+		t.Rows = append(t.Rows, row)
+		t.Stars = append(t.Stars, star)
+		t.Notes = append(t.Notes, note)
+	}
+	t.save = func(t *reviewTable) error {
+		for i, item := range list {
+			m, _ := item.(map[string]any)
+			if m == nil {
+				continue
+			}
+			m[StarKey] = t.Stars[i]
+			m[NoteKey] = t.Notes[i]
+		}
+		return replaceFile(name, func(w io.Writer) error {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(doc)
+		})
+	}
+	return t, nil
+}
+
+// replaceFile: 一時ファイルに書いてから name を置き換える
+func replaceFile(name string, write func(io.Writer) error) error {
+	tmp := name + ".tmp"
+	fp, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := write(fp); err != nil {
+		fp.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := fp.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// review: コマンドを読みながら表示・編集する
+func (t *reviewTable) review(in io.Reader, out io.Writer, pageSize, start int) error {
+	sc := bufio.NewScanner(in)
+	starred, dirty := false, false
+	show := func() {
+		idx := t.visible(starred)
+		start = max(min(start, len(idx)-1)/pageSize*pageSize, 0)
+		t.print(out, idx, start, pageSize)
+	}
+	fmt.Fprintf(out, "%d rows. h for help\n", len(t.Rows))
+	show()
+	for {
+		fmt.Fprint(out, "> ")
+		if !sc.Scan() {
+			fmt.Fprintln(out)
+			if dirty {
+				fmt.Fprintln(out, "(not written)")
+			}
+			return sc.Err()
+		}
+		f := strings.Fields(sc.Text())
+		cmd := ""
+		if len(f) > 0 {
+			cmd = f[0]
+		}
+		num := func(s string) (int, error) {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 || n > len(t.Rows) {
+				return 0, fmt.Errorf("no row %s (1..%d)", s, len(t.Rows))
+			}
+			return n - 1, nil
+		}
+		var err error
+		switch cmd {
+		case "", "n":
+			start += pageSize
+			show()
+		case "p":
+			start -= pageSize
+			show()
+		case "g":
+			if len(f) < 2 {
+				err = errors.New("usage: g No")
+				break
+			}
+			var i int
+			if i, err = num(f[1]); err == nil {
+				starred = false
+				start = i
+				show()
+			}
+		case "s":
+			for _, a := range f[1:] {
+				var i int
+				if i, err = num(a); err != nil {
+					break
+				}
+				t.Stars[i] = !t.Stars[i]
+				dirty = true
+			}
+			show()
+		case "a":
+			if len(f) < 2 {
+				err = errors.New("usage: a No note")
+				break
+			}
+			var i int
+			if i, err = num(f[1]); err == nil {
+				// "a 番号 " の後ろをそのままメモにする（空白も残す）
+				_, rest, _ := strings.Cut(strings.TrimSpace(sc.Text()), f[1])
+				t.Notes[i] = strings.TrimSpace(rest)
+				dirty = true
+				show()
+			}
+		case "f":
+			starred = !starred
+			start = 0
+			show()
+		case "w":
+			if err = t.save(t); err == nil {
+				dirty = false
+				fmt.Fprintf(out, "written (%d starred)\n", t.countStars())
+			}
+		case "q", "q!":
+			if dirty && cmd == "q" {
+				fmt.Fprintln(out, "not written: w to write, q! to quit anyway")
+				break
+			}
+			return nil
+		case "h":
+			fmt.Fprintln(out, "n/Enter next  p prev  g No  s No...  a No note  f starred only  w write  q quit")
+		default:
+			err = fmt.Errorf("unknown command %q (h for help)", cmd)
+		}
+		if err != nil {
+			fmt.Fprintln(out, "error:", err)
+		}
+	}
+}
+
+// visible: 表示する行（starred なら星付きだけ）
+func (t *reviewTable) visible(starred bool) []int {
+	idx := make([]int, 0, len(t.Rows))
+	for i := range t.Rows {
+		if !starred || t.Stars[i] {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+func (t *reviewTable) countStars() int {
+	n := 0
+	for _, s := range t.Stars {
+		if s {
+			n++
+		}
+	}
+	return n
+}
+
+// print: idx[start:] から 1 ページ分を表示する（数値は 4 桁）
+func (t *reviewTable) print(out io.Writer, idx []int, start, pageSize int) {
+	if len(idx) == 0 {
+		fmt.Fprintln(out, "(no rows)")
+		return
+	}
+	end := min(start+pageSize, len(idx))
+	cell := func(s string) string {
+		if v, err := strconv.ParseFloat(s, 64); err == nil {
+			return fmt4(v)
+		}
+		if len([]rune(s)) > 10 {
+			s = string([]rune(s)[:9]) + "…"
+		}
+		return fmt.Sprintf("%10s", s)
+	}
+	var sb strings.Builder
+	sb.WriteString("   No |  ")
+	for _, h := range t.Header {
+		sb.WriteString(cell(h) + " ")
+	}
+	sb.WriteString("| note")
+	fmt.Fprintln(out, sb.String())
+	for _, i := range idx[start:end] {
+		sb.Reset()
+		mark := " "
+		if t.Stars[i] {
+			mark = "*"
+		}
+		fmt.Fprintf(&sb, "%5d |%s ", i+1, mark)
+		for j := range t.Header {
+			v := ""
+			if j < len(t.Rows[i]) {
+				v = t.Rows[i][j]
+			}
+			sb.WriteString(cell(v) + " ")
+		}
+		sb.WriteString("| " + t.Notes[i])
+		fmt.Fprintln(out, sb.String())
+	}
+	fmt.Fprintf(out, "rows %d-%d of %d (page %d of %d)\n", start+1, end, len(idx), start/pageSize+1, (len(idx)+pageSize-1)/pageSize)
+}
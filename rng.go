@@ -0,0 +1,231 @@
+// rng.go
+// 擬似乱数の元（Config.RNG）
+//
+// 既定は xoshiro256**（Blackman–Vigna）を seed から splitmix64 で初期化したもの。このファイルだけで
+// 完全に決まるので、(Seed, 設定) が同じならどの版の Go でも同じ点列になる（math/rand の系列には
+// 版による保証がない）。ただし結果まで同じになるのは Workers が 1 か Deterministic のときだけで、
+// 並列で Deterministic でなければ、どのワーカーが先に記録するかで保存サンプルや件数が実行ごとに変わる。RandomSampler だけでなく、探索モード・推定・推薦などの乱数もすべて同じ元から作る。
+// Config.RNG で "pcg"（PCG64 DXSM）を選べるほか、"mathrand" で RandomSampler を以前の版の math/rand の系列に戻せる。
+// xoshiro / pcg は系列をまとめて先へ飛ばせる（Jump）ので、並列のワーカー w には同じ seed の系列を
+// w 回 Jump したものを渡す。seed を変える方法と違って、ワーカーどうしの系列が重ならないことが保証される。
+// RNG を変えると同じ Seed でも別の点列になる。
+//
+// 確かめるための系列（xoshiroVector。`validate` でも確かめる）: Seed 1 の Uint64 の最初の 4 つは
+//   0xc5883e370b0926c3 0x021b74b80f71f81c 0x268df06749e5c8ce 0xe052757d667afef2
+// で、RandomSampler の u（上位 53 ビット × 2^-53）は 0.7716101536161983 0.008231444298367196 …
+// Go から使うときは Config.Source に乱数の元そのものを渡せる。ScriptedSource は決めた u を順に返すので、
+// テストで Go の版（math/rand の系列）によらず、引く点・分類・保存されるサンプルを確かめられる。
+
+package main
+
+import (
+	"fmt"
+	"math/bits"
+	"math/rand"
+)
+
+// Source: 系列を先へ飛ばせる擬似乱数の元
+type Source interface {
+	rand.Source64
+	Jump() // PCG64 は 2^64 個、Xoshiro256 は 2^128 個先へ進める
+}
+
+// newSource: 名前から乱数の元を作る（"" は xoshiro、"mathrand" は math/rand）
+func newSource(name string, seed int64) (rand.Source, error) {
+	switch name {
+	case "", "xoshiro":
+		s := &Xoshiro256{}
+		s.Seed(seed)
+		return s, nil
+	case "pcg":
+		s := &PCG64{}
+		s.Seed(seed)
+		return s, nil
+	case "mathrand":
+		return rand.NewSource(seed), nil
+	default:
+		return nil, fmt.Errorf("unknown rng: %q (xoshiro / pcg / mathrand)", name)
+	}
+}
+
+// newRand: 探索モードや推定が使う乱数（RNG によらず xoshiro）
+func newRand(seed int64) *rand.Rand {
+	s := &Xoshiro256{}
+	s.Seed(seed)
+	return rand.New(s)
+}
+
+// jumpable: name の元が Jump できるか
+func jumpable(name string) bool {
+	return name == "" || name == "pcg" || name == "xoshiro"
+}
+
+// xoshiroVector: Seed 1 の Xoshiro256 の最初の Uint64
+var xoshiroVector = [...]uint64{0xc5883e370b0926c3, 0x021b74b80f71f81c, 0x268df06749e5c8ce, 0xe052757d667afef2}
+
+// checkRNG: 既定の乱数の元が xoshiroVector と同じ系列を出すか
+func checkRNG() error {
+	s := &Xoshiro256{}
+	s.Seed(1)
+	for i, want := range xoshiroVector {
+		if got := s.Uint64(); got != want {
+			return fmt.Errorf("xoshiro256** output #%d for seed 1 is %#016x, want %#016x", i+1, got, want)
+		}
+	}
+	return nil
+}
+
+// ScriptedSource: U の値を順に返す乱数の元（Config.Source に渡す。U を使い切ったら panic）
+// RandomSampler の Next は、i 番目の点の j 番目の座標に U[i*d+j] をそのまま使う（2^-53 の刻みに丸める）。
+type ScriptedSource struct {
+	U []float64
+	n int
+}
+
+// Seed は何もしない（最初から引き直すには Reset）
+func (s *ScriptedSource) Seed(int64) {}
+
+// Reset: 最初の値から引き直す
+func (s *ScriptedSource) Reset() { s.n = 0 }
+
+// Drawn: これまでに引いた値の数
+func (s *ScriptedSource) Drawn() int { return s.n }
+
+func (s *ScriptedSource) next() float64 {
+	if s.n >= len(s.U) {
+		panic(fmt.Sprintf("ScriptedSource: all %d values used", len(s.U)))
+	}
+	u := s.U[s.n]
+	s.n++
+	if !(u >= 0 && u < 1) {
+		panic(fmt.Sprintf("ScriptedSource: U[%d] = %g is outside [0, 1)", s.n-1, u))
+	}
+	return u
+}
+
+// Int63: rand.Rand.Float64 が u を返す値
+func (s *ScriptedSource) Int63() int64 { return int64(s.next() * (1 << 63)) }
+
+// Uint64: 上位 53 ビットが u になる値
+func (s *ScriptedSource) Uint64() uint64 { return uint64(s.next()*(1<<53)) << 11 }
+
+// PCG64: 128 ビットの線形合同法に DXSM の出力変換をかけたもの
+type PCG64 struct {
+	hi, lo uint64
+}
+
+// 乗数と増分（128 ビット）
+const (
+	pcgMulHi = 2549297995355413924
+	pcgMulLo = 4865540595714422341
+	pcgIncHi = 6364136223846793005
+	pcgIncLo = 1442695040888963407
+)
+
+func (p *PCG64) Seed(seed int64) {
+	p.hi = splitmix64(uint64(seed))
+	p.lo = splitmix64(p.hi)
+}
+
+func (p *PCG64) Uint64() uint64 {
+	// state = state*mul + inc
+	hi, lo := mul128(p.hi, p.lo, pcgMulHi, pcgMulLo)
+	p.hi, p.lo = add128(hi, lo, pcgIncHi, pcgIncLo)
+
+	// DXSM
+	const cheapMul = 0xda942042e4dd58b5
+	hi, lo = p.hi, p.lo
+	hi ^= hi >> 32
+	hi *= cheapMul
+	hi ^= hi >> 48
+	hi *= lo | 1
+	return hi
+}
+
+func (p *PCG64) Int63() int64 { return int64(p.Uint64() >> 1) }
+
+// Jump: 2^64 個先へ進める
+func (p *PCG64) Jump() { p.advance(1, 0) }
+
+// advance: (dHi, dLo) 個先へ進める（Brown の方法。state*A + C の A, C を 2 進で組み立てる）
+func (p *PCG64) advance(dHi, dLo uint64) {
+	accMulHi, accMulLo := uint64(0), uint64(1)
+	accIncHi, accIncLo := uint64(0), uint64(0)
+	curMulHi, curMulLo := uint64(pcgMulHi), uint64(pcgMulLo)
+	curIncHi, curIncLo := uint64(pcgIncHi), uint64(pcgIncLo)
+	for dHi != 0 || dLo != 0 {
+		if dLo&1 == 1 {
+			accMulHi, accMulLo = mul128(accMulHi, accMulLo, curMulHi, curMulLo)
+			h, l := mul128(accIncHi, accIncLo, curMulHi, curMulLo)
+			accIncHi, accIncLo = add128(h, l, curIncHi, curIncLo)
+		}
+		h, l := add128(curMulHi, curMulLo, 0, 1)
+		curIncHi, curIncLo = mul128(h, l, curIncHi, curIncLo)
+		curMulHi, curMulLo = mul128(curMulHi, curMulLo, curMulHi, curMulLo)
+		dLo = dLo>>1 | dHi<<63
+		dHi >>= 1
+	}
+	h, l := mul128(accMulHi, accMulLo, p.hi, p.lo)
+	p.hi, p.lo = add128(h, l, accIncHi, accIncLo)
+}
+
+// mul128: 128 ビットの積（下位 128 ビット）
+func mul128(aHi, aLo, bHi, bLo uint64) (hi, lo uint64) {
+	hi, lo = bits.Mul64(aLo, bLo)
+	hi += aHi*bLo + aLo*bHi
+	return hi, lo
+}
+
+// add128: 128 ビットの和
+func add128(aHi, aLo, bHi, bLo uint64) (hi, lo uint64) {
+	lo, c := bits.Add64(aLo, bLo, 0)
+	hi, _ = bits.Add64(aHi, bHi, c)
+	return hi, lo
+}
+
+// Xoshiro256: xoshiro256**（Blackman–Vigna）
+type Xoshiro256 struct {
+	s [4]uint64
+}
+
+func (x *Xoshiro256) Seed(seed int64) {
+	v := uint64(seed)
+	for i := range x.s {
+		v = splitmix64(v)
+		x.s[i] = v
+	}
+}
+
+func (x *Xoshiro256) Uint64() uint64 {
+	s := &x.s
+	r := bits.RotateLeft64(s[1]*5, 7) * 9
+	t := s[1] << 17
+	s[2] ^= s[0]
+	s[3] ^= s[1]
+	s[1] ^= s[2]
+	s[0] ^= s[3]
+	s[2] ^= t
+	s[3] = bits.RotateLeft64(s[3], 45)
+	return r
+}
+
+func (x *Xoshiro256) Int63() int64 { return int64(x.Uint64() >> 1) }
+
+// xoshiroJump: 2^128 個先へ進める多項式
+var xoshiroJump = [4]uint64{0x180ec6d33cfd0aba, 0xd5a61266f0c9392c, 0xa9582618e03fc9aa, 0x39abdc4529b1661c}
+
+// Jump: 2^128 個先へ進める
+func (x *Xoshiro256) Jump() {
+	var t [4]uint64
+	for _, j := range xoshiroJump {
+		for b := 0; b < 64; b++ {
+			if j&(1<<b) != 0 {
+				for i := range t {
+					t[i] ^= x.s[i]
+				}
+			}
+			x.Uint64()
+		}
+	}
+	x.s = t
+}
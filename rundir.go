@@ -0,0 +1,78 @@
+// rundir.go
+// 実行ごとのディレクトリに出力をまとめる（Config.OutDir・Config.RunTag）
+//
+// OutDir を決めると、実行ごとに OutDir/2026-02-19_1530_<RunTag>/ を作り、xlsx・tsv・config.json・
+// 交互作用・評価の記録・checkpoint・表示のログ（log.txt）をその中に書く。作業ディレクトリの result.xlsx を
+// 上書きせずに、実行の結果が日時とタグの付いたディレクトリに並ぶ。同じ分に同じタグで実行したら _2, _3 … を付ける。
+// 出力のファイル名が絶対パスならそのまま使う。OutDir にも {seed} {date} {time} を書ける。
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// runLogFile: 実行のディレクトリに書く表示のログ
+const runLogFile = "log.txt"
+
+// runDirName: 実行のディレクトリの名前（日時とタグ。タグのパス区切りや空白は "-" にする）
+func runDirName(now time.Time, tag string) string {
+	name := now.Format("2006-01-02_1504")
+	tag = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r == ' ' || r == '\t' {
+			return '-'
+		}
+		return r
+	}, strings.TrimSpace(tag))
+	if tag != "" {
+		name += "_" + tag
+	}
+	return name
+}
+
+// applyOutDir: OutDir があれば実行のディレクトリを決め、出力のファイル名をその中にする（ディレクトリはまだ作らない）
+func applyOutDir(cfg *Config, now time.Time) (string, error) {
+	if cfg.OutDir == "" {
+		return "", nil
+	}
+	root, err := expandFilename(cfg.OutDir, cfg.Seed, now)
+	if err != nil {
+		return "", fmt.Errorf("out-dir: %w", err)
+	}
+	base := filepath.Join(root, runDirName(now, cfg.RunTag))
+	dir := base
+	for i := 2; ; i++ {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			break
+		} else if err != nil {
+			return "", err
+		}
+		dir = fmt.Sprintf("%s_%d", base, i)
+	}
+
+	in := func(name string) string {
+		if name == "" || filepath.IsAbs(name) {
+			return name
+		}
+		return filepath.Join(dir, name)
+	}
+	for _, spec := range []*OutputSpec{&cfg.XLSX, &cfg.OKTSV, &cfg.NGTSV, &cfg.OKCSV, &cfg.NGCSV, &cfg.OKParquet, &cfg.NGParquet, &cfg.InteractionTSV, &cfg.ConfigJSON, &cfg.Bundle} {
+		spec.File = in(spec.File)
+	}
+	cfg.EvalLog.File = in(cfg.EvalLog.file())
+	cfg.ProgressLog = in(cfg.ProgressLog)
+	if cfg.JSONL.File != "-" {
+		cfg.JSONL.File = in(cfg.JSONL.File)
+	}
+	cfg.CheckpointFile = in(cfg.checkpointFile())
+	return dir, nil
+}
+
+// writeRunLog: 表示のログを実行のディレクトリに書く
+func writeRunLog(dir string, log []byte) error {
+	return os.WriteFile(filepath.Join(dir, runLogFile), log, 0o644)
+}
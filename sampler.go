@@ -0,0 +1,421 @@
+// sampler.go
+// 点列の生成（単位超立方体 [0,1)^d）
+//
+// Sampler は params の数だけの一様な値 u を作るだけで、Min/Max や Linear/Log への
+// 変換は sampleOne が行う。これにより擬似乱数と準乱数（Sobol など）を差し替えられる。
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Sampler: [0,1)^d の点を順に生成する
+type Sampler interface {
+	Init(dim int, seed int64) error // 探索開始前に 1 回だけ呼ばれる
+	Next(u []float64)               // len(u) == dim
+}
+
+// RandomSampler: 擬似乱数（デフォルト）。RNG は乱数の元の名前（rng.go。"" なら xoshiro）
+// Stream は Jump する回数（並列のワーカーごとに別の系列にする。Jump できない元では使わない）
+// Src があれば RNG と seed の代わりにそれを使う（Seed は呼ばず、渡したときの状態から引く）
+type RandomSampler struct {
+	RNG    string
+	Stream int
+	Src    rand.Source
+
+	rng *rand.Rand
+	src Source // Jump できる元なら直接使う（rand.Rand を通すより速い）
+}
+
+func (s *RandomSampler) Init(dim int, seed int64) error {
+	src := s.Src
+	if src == nil {
+		var err error
+		if src, err = newSource(s.RNG, seed); err != nil {
+			return err
+		}
+	}
+	s.src, _ = src.(Source)
+	if s.src != nil {
+		for range s.Stream {
+			s.src.Jump()
+		}
+	}
+	s.rng = rand.New(src)
+	return nil
+}
+
+func (s *RandomSampler) Next(u []float64) {
+	if s.src != nil {
+		for i := range u {
+			u[i] = float64(s.src.Uint64()>>11) * 0x1p-53
+		}
+		return
+	}
+	for i := range u {
+		u[i] = s.rng.Float64()
+	}
+}
+
+// sobolDirections: Joe–Kuo (new-joe-kuo-6.21201) の方向数（2 次元目以降）
+// {s, a, m_1, ..., m_s}
+var sobolDirections = [...][]uint32{
+	{1, 0, 1},
+	{2, 1, 1, 3},
+	{3, 1, 1, 3, 1},
+	{3, 2, 1, 1, 1},
+	{4, 1, 1, 1, 3, 3},
+	{4, 4, 1, 3, 5, 13},
+	{5, 2, 1, 1, 5, 5, 17},
+	{5, 4, 1, 1, 5, 5, 5},
+	{5, 7, 1, 1, 7, 11, 19},
+	{5, 11, 1, 1, 5, 1, 1},
+	{5, 13, 1, 1, 1, 3, 11},
+	{5, 14, 1, 3, 5, 5, 31},
+	{6, 1, 1, 3, 3, 9, 7, 49},
+	{6, 13, 1, 1, 1, 15, 21, 21},
+	{6, 16, 1, 3, 1, 13, 27, 49},
+	{6, 19, 1, 1, 1, 15, 7, 5},
+	{6, 22, 1, 3, 1, 15, 13, 25},
+	{6, 25, 1, 1, 5, 5, 19, 61},
+	{7, 1, 1, 3, 7, 11, 23, 15, 103},
+	{7, 4, 1, 3, 7, 13, 13, 15, 69},
+}
+
+const sobolBits = 32
+
+// SobolSampler: Sobol 低食い違い列（Gray code 法）
+//
+// seed からの乱数でデジタルシフト（XOR）をかけるので、seed を変えると別の点列になるが
+// 低食い違い性は保たれる。最初の点（原点）は飛ばす。
+type SobolSampler struct {
+	v     [][sobolBits]uint32 // 方向数 V_k（次元ごと）
+	x     []uint32            // 現在の点（シフト前）
+	shift []uint32
+	n     uint32
+}
+
+// SobolMaxDim: SobolSampler が扱える最大次元
+const SobolMaxDim = len(sobolDirections) + 1
+
+// SobolMaxPoints: SobolSampler が出せる点の数（添字が 32 ビットなので、これより先は同じ点列に戻ってしまう）
+const SobolMaxPoints = 1<<sobolBits - 1
+
+// sobolPointsError: MaxIters が SobolMaxPoints を超えるか
+func sobolPointsError(maxIters int64) error {
+	if maxIters > SobolMaxPoints {
+		return fmt.Errorf("sobol: MaxIters %d exceeds max %d points (use random or lhs, or split the run with different seeds)", maxIters, int64(SobolMaxPoints))
+	}
+	return nil
+}
+
+func (s *SobolSampler) Init(dim int, seed int64) error {
+	if dim > SobolMaxDim {
+		return fmt.Errorf("sobol: dim %d exceeds max %d", dim, SobolMaxDim)
+	}
+	s.v = make([][sobolBits]uint32, dim)
+	s.x = make([]uint32, dim)
+	s.shift = make([]uint32, dim)
+	s.n = 0
+
+	rng := newRand(seed)
+	for j := 0; j < dim; j++ {
+		s.shift[j] = rng.Uint32()
+
+		v := &s.v[j]
+		if j == 0 {
+			for k := 0; k < sobolBits; k++ {
+				v[k] = 1 << (sobolBits - 1 - k)
+			}
+			continue
+		}
+		d := sobolDirections[j-1]
+		deg, a, m := int(d[0]), d[1], d[2:]
+		for k := 0; k < deg && k < sobolBits; k++ {
+			v[k] = m[k] << (sobolBits - 1 - k)
+		}
+		for k := deg; k < sobolBits; k++ {
+			v[k] = v[k-deg] ^ (v[k-deg] >> deg)
+			for i := 1; i < deg; i++ {
+				if (a>>(deg-1-i))&1 == 1 {
+					v[k] ^= v[k-i]
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *SobolSampler) Next(u []float64) {
+	// n の最下位の 0 ビット位置 c で x ^= V_c
+	c := 0
+	for n := s.n; n&1 == 1; n >>= 1 {
+		c++
+	}
+	s.n++
+	for j := range u {
+		s.x[j] ^= s.v[j][c]
+		u[j] = float64(s.x[j]^s.shift[j]) / (1 << sobolBits)
+	}
+}
+
+// LatinHypercubeSampler: ラテン超方格サンプリング
+//
+// 各軸を N 等分した区間を 1 回ずつ使うので、少ない回数でも各パラメータの範囲全体を
+// 均等に覆う。区間の並べ替えは軸ごとの擬似乱数置換（Feistel 網）で行い、
+// N が大きくても置換表をメモリに持たない。N 点を使い切ったら新しい置換でやり直す。
+type LatinHypercubeSampler struct {
+	N int64 // 分割数（通常は MaxIters）
+
+	rng  *rand.Rand
+	keys [][4]uint64 // 軸ごとの置換の鍵
+	i    int64
+	half uint // Feistel の片側ビット数
+}
+
+func (s *LatinHypercubeSampler) Init(dim int, seed int64) error {
+	if s.N <= 0 {
+		return fmt.Errorf("lhs: N must be > 0")
+	}
+	s.rng = newRand(seed)
+	s.keys = make([][4]uint64, dim)
+	s.i = 0
+
+	bits := uint(1)
+	for int64(1)<<bits < s.N {
+		bits++
+	}
+	s.half = (bits + 1) / 2
+	s.newKeys()
+	return nil
+}
+
+func (s *LatinHypercubeSampler) newKeys() {
+	for j := range s.keys {
+		for r := range s.keys[j] {
+			s.keys[j][r] = s.rng.Uint64()
+		}
+	}
+}
+
+func (s *LatinHypercubeSampler) Next(u []float64) {
+	if s.i >= s.N {
+		s.i = 0
+		s.newKeys()
+	}
+	for j := range u {
+		bin := s.permute(uint64(s.i), s.keys[j])
+		u[j] = (float64(bin) + s.rng.Float64()) / float64(s.N)
+	}
+	s.i++
+}
+
+// permute: [0, N) 上の置換（Feistel 網 + cycle walking）
+func (s *LatinHypercubeSampler) permute(i uint64, key [4]uint64) uint64 {
+	mask := uint64(1)<<s.half - 1
+	for {
+		l, r := i>>s.half, i&mask
+		for _, k := range key {
+			l, r = r, l^(splitmix64(r^k)&mask)
+		}
+		i = l<<s.half | r
+		if i < uint64(s.N) {
+			return i
+		}
+	}
+}
+
+// splitmix64: 64 ビットのハッシュ（置換の丸め関数用）
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// FiniteSampler: 点の総数が決まっている Sampler（使い切ったら探索を終える）
+type FiniteSampler interface {
+	Sampler
+	Len() int64
+}
+
+// GridSampler: 各軸 Points[j] 点の格子を全列挙する（後ろの軸ほど速く変わる）
+// 格子点は端点を含む等間隔（Log の軸は sampleOne により対数等間隔になる）。
+// Points[j] <= 1 の軸は範囲の中央 1 点だけを使う。
+type GridSampler struct {
+	Points []int
+
+	idx []int
+}
+
+func (s *GridSampler) Init(dim int, seed int64) error {
+	if len(s.Points) != dim {
+		return fmt.Errorf("grid: %d points given for %d params", len(s.Points), dim)
+	}
+	if _, err := gridLen(s.Points); err != nil {
+		return err
+	}
+	s.idx = make([]int, dim)
+	return nil
+}
+
+func (s *GridSampler) Len() int64 {
+	n, _ := gridLen(s.Points)
+	return n
+}
+
+// gridLen: 格子点の総数（int64 に収まらなければエラーにし、math.MaxInt64 を返す）
+func gridLen(points []int) (int64, error) {
+	n := int64(1)
+	for _, p := range points {
+		k := int64(max(p, 1))
+		if n > math.MaxInt64/k {
+			return math.MaxInt64, fmt.Errorf("grid: the product of GridPoints over %d params overflows int64 (use fewer points or params)", len(points))
+		}
+		n *= k
+	}
+	return n, nil
+}
+
+func (s *GridSampler) Next(u []float64) {
+	for j := range u {
+		n := s.Points[j]
+		if n <= 1 {
+			u[j] = 0.5
+		} else {
+			u[j] = float64(s.idx[j]) / float64(n-1)
+		}
+	}
+	// 桁上がり（最後の軸から）
+	for j := len(s.idx) - 1; j >= 0; j-- {
+		s.idx[j]++
+		if s.idx[j] < max(s.Points[j], 1) {
+			break
+		}
+		s.idx[j] = 0
+	}
+}
+
+// FeedbackSampler: 評価結果を受け取って次の点を決める Sampler
+type FeedbackSampler interface {
+	Sampler
+	Observe(u []float64, s Sample) // Next で渡した u とその評価結果
+}
+
+// MCMCSampler: OK 領域に集中するマルコフ連鎖（Metropolis 法）
+//
+// OK が見つかるまでは一様に探す。OK が見つかったら、その点のまわりに
+// 正規分布の提案（幅 Steps[j]、u 空間＝Log の軸は対数軸）を出し、
+// 提案が OK なら移動、NG ならとどまる（OK 領域上の一様分布が定常分布になる）。
+// Global の確率で一様な提案を混ぜ、離れた OK 領域にも移れるようにする。
+//
+// 点は独立ではないので、OK_ratio は範囲全体での割合の推定にはならない。
+type MCMCSampler struct {
+	Steps  []float64 // 軸ごとの提案幅（u 空間）
+	Global float64   // 一様な提案の割合
+
+	rng *rand.Rand
+	cur []float64 // 現在の OK 点（nil なら未発見）
+}
+
+func (s *MCMCSampler) Init(dim int, seed int64) error {
+	if len(s.Steps) != dim {
+		return fmt.Errorf("mcmc: %d steps given for %d params", len(s.Steps), dim)
+	}
+	s.rng = newRand(seed)
+	s.cur = nil
+	return nil
+}
+
+func (s *MCMCSampler) Next(u []float64) {
+	if s.cur == nil || s.rng.Float64() < s.Global {
+		for j := range u {
+			u[j] = s.rng.Float64()
+		}
+	} else {
+		for j := range u {
+			u[j] = reflectUnit(s.cur[j] + s.Steps[j]*s.rng.NormFloat64())
+		}
+	}
+}
+
+func (s *MCMCSampler) Observe(u []float64, smp Sample) {
+	if smp.OK {
+		s.cur = append(s.cur[:0], u...)
+	}
+}
+
+// reflectUnit: [0,1) の外に出た値を端で折り返す
+func reflectUnit(x float64) float64 {
+	for x < 0 || x >= 1 {
+		if x < 0 {
+			x = -x
+		} else {
+			x = 2 - x
+		}
+	}
+	if x >= 1 {
+		x = math.Nextafter(1, 0)
+	}
+	return x
+}
+
+// mcmcSteps: ParamSpec.Step（Linear は元単位、Log は ln の幅）を u 空間の幅に直す
+func mcmcSteps(params []ParamSpec) []float64 {
+	steps := make([]float64, len(params))
+	for j, p := range params {
+		steps[j] = 0.05
+		if p.Step <= 0 || p.Max == p.Min {
+			continue
+		}
+		if p.Scale == Log {
+			steps[j] = p.Step / (math.Log(p.Max) - math.Log(p.Min))
+		} else {
+			steps[j] = p.Step / (p.Max - p.Min)
+		}
+	}
+	return steps
+}
+
+// newSampler: Config から Sampler を決める（Config.Antithetic なら対にする。variance.go）
+func newSampler(cfg Config) (Sampler, error) {
+	s, err := baseSampler(cfg)
+	if err != nil || !cfg.Antithetic {
+		return s, err
+	}
+	return newAntithetic(s)
+}
+
+// baseSampler: Sampler > Search > SamplingMethod > 擬似乱数 の順に決める
+func baseSampler(cfg Config) (Sampler, error) {
+	if cfg.Sampler != nil {
+		return cfg.Sampler, nil
+	}
+	if s, err := newSearch(cfg); s != nil || err != nil {
+		return s, err
+	}
+	switch cfg.SamplingMethod {
+	case "", "random":
+		return &RandomSampler{RNG: cfg.RNG, Src: cfg.Source}, nil
+	case "sobol":
+		if err := sobolPointsError(cfg.MaxIters); err != nil {
+			return nil, err
+		}
+		return &SobolSampler{}, nil
+	case "lhs":
+		return &LatinHypercubeSampler{N: cfg.MaxIters}, nil
+	case "grid":
+		points := make([]int, len(cfg.Params))
+		for j, p := range cfg.Params {
+			points[j] = p.GridPoints
+		}
+		return &GridSampler{Points: points}, nil
+	case "mcmc":
+		return &MCMCSampler{Steps: mcmcSteps(cfg.Params), Global: 0.05}, nil
+	default:
+		return nil, fmt.Errorf("unknown sampling method: %q", cfg.SamplingMethod)
+	}
+}
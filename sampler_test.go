@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Sobol の添字は 32 ビットなので、それを超える MaxIters は探索の前に断る
+func TestSobolMaxPoints(t *testing.T) {
+	cfg := Config{SamplingMethod: "sobol", MaxIters: SobolMaxPoints}
+	if _, err := newSampler(cfg); err != nil {
+		t.Errorf("MaxIters = SobolMaxPoints: %v", err)
+	}
+	cfg.MaxIters = 1 << 32
+	if _, err := newSampler(cfg); err == nil {
+		t.Errorf("MaxIters = 2^32: want an error")
+	}
+	found := false
+	for _, nt := range lintConfig(cfg) {
+		found = found || nt.Level == "error" && strings.Contains(nt.Msg, "sobol")
+	}
+	if !found {
+		t.Errorf("lintConfig: want a sobol error for MaxIters = 2^32")
+	}
+}
+
+// 格子点の総数が int64 に収まらなければ、0 回で終わらずに設定エラーにする
+func TestGridLenOverflow(t *testing.T) {
+	points := func(n int) []int {
+		p := make([]int, n)
+		for j := range p {
+			p[j] = 10
+		}
+		return p
+	}
+	if n, err := gridLen(points(18)); err != nil || n != 1e18 {
+		t.Errorf("10^18: %d, %v", n, err)
+	}
+	s := &GridSampler{Points: points(19)}
+	if err := s.Init(19, 1); err == nil {
+		t.Errorf("10^19: want an error from Init")
+	}
+	if s.Len() <= 0 {
+		t.Errorf("Len = %d: must not wrap to a non-positive count", s.Len())
+	}
+
+	cfg := Config{SamplingMethod: "grid", MaxIters: 1000, F: func(map[string]float64) float64 { return 0 }}
+	for j := range 19 {
+		cfg.Params = append(cfg.Params, ParamSpec{Key: fmt.Sprintf("p%d", j), Min: 0, Max: 1, DisplayScale: 1, GridPoints: 10})
+	}
+	if _, err := newEngine(cfg); err == nil {
+		t.Errorf("newEngine: want an error")
+	}
+	found := false
+	for _, nt := range lintConfig(cfg) {
+		found = found || nt.Level == "error" && nt.Where == "GridPoints"
+	}
+	if !found {
+		t.Errorf("lintConfig: want a GridPoints error")
+	}
+}
@@ -0,0 +1,349 @@
+// scaffold.go
+// 新しく使う人向けの設定の雛形（`go run . init`）
+//
+// 既存の設定をコピーして書き換えると、キーの打ち間違いや単位の取り違えが起きやすい。
+// パラメータ・単位・範囲・トポロジーを聞いて（またはフラグで受け取って）config_local.go を書き出す。
+//
+//	go run . init                                   （対話式）
+//	go run . init -topology ss -param f:kHz:50e3:100e3:log -yrange 0.1,0.5
+//	go run . init -topology custom -param x:mm:1:10 -param n::1:20 -out config_local.go
+//
+// -param は key[:unit]:min:max[:linear|log]。unit の接頭辞（k, M, m, u, µ, n, p）から DisplayScale を決める。
+// min / max に単位を付けてもよい（f:50kHz:100kHz:log。units.go）。
+// トポロジーの既定のパラメータと同じキーなら範囲を置き換え、違うキーなら追加する。
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// paramFlags: 繰り返し指定できる -param
+type paramFlags []string
+
+func (p *paramFlags) String() string     { return strings.Join(*p, " ") }
+func (p *paramFlags) Set(s string) error { *p = append(*p, s); return nil }
+
+// scaffold: 雛形の内容
+type scaffold struct {
+	Topology string // "ss" / "multirx" / "relay" / "custom"
+	Rx       int    // multirx の受信器の数
+	Params   []ParamSpec
+	YRange   Range
+	MaxIters int64
+}
+
+// topologyParams: トポロジーの既定のパラメータ（custom なら空）
+func topologyParams(topology string, rx int) ([]ParamSpec, error) {
+	log := func(key, unit string, v float64) ParamSpec {
+		return unitParam(key, unit, v, v, Log)
+	}
+	switch topology {
+	case "ss":
+		return []ParamSpec{
+			unitParam("k", "", 0.01, 1, Linear),
+			unitParam("f", "kHz", 10e3, 100e3, Log),
+			log("R1", "Ω", 1), log("R2", "Ω", 10),
+			log("L1", "µH", 140e-6), log("L2", "µH", 80e-6),
+			log("C1", "nF", 47e-9), log("C2", "nF", 47e-9),
+		}, nil
+	case "multirx":
+		if rx < 1 {
+			return nil, fmt.Errorf("multirx needs -rx >= 1")
+		}
+		ps := []ParamSpec{unitParam("f", "kHz", 10e3, 100e3, Log), log("R1", "Ω", 1), log("L1", "µH", 140e-6), log("C1", "nF", 47e-9)}
+		for i := 1; i <= rx; i++ {
+			ps = append(ps,
+				unitParam(RxKey("k", i), "", 0.01, 0.5, Linear),
+				log(RxKey("R2", i), "Ω", 10), log(RxKey("L2", i), "µH", 80e-6), log(RxKey("C2", i), "nF", 47e-9))
+		}
+		return ps, nil
+	case "relay":
+		return []ParamSpec{
+			unitParam("f", "kHz", 10e3, 100e3, Log),
+			log("R1", "Ω", 1), log("L1", "µH", 140e-6), log("C1", "nF", 47e-9),
+			log("Rr", "Ω", 0.1), log("Lr", "µH", 100e-6), log("Cr", "nF", 47e-9),
+			log("R2", "Ω", 10), log("L2", "µH", 80e-6), log("C2", "nF", 47e-9),
+			unitParam("k12", "", 0.01, 0.5, Linear), unitParam("k23", "", 0.01, 0.5, Linear),
+		}, nil
+	case "custom":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown topology %q (ss / multirx / relay / custom)", topology)
+	}
+}
+
+// unitScale: 単位の接頭辞から DisplayScale を決める（"kHz" → 1e-3。接頭辞がなければ 1。units.go）
+func unitScale(unit string) float64 {
+	scale, _ := splitUnit(unit)
+	return scale
+}
+
+// unitParam: 単位付きのパラメータ（Label は "key [unit]"）
+func unitParam(key, unit string, lo, hi float64, scale Scale) ParamSpec {
+	label := key
+	if unit != "" {
+		label = key + " [" + unit + "]"
+	}
+	return ParamSpec{Key: key, Label: label, Min: lo, Max: hi, Scale: scale, DisplayScale: unitScale(unit)}
+}
+
+// parseParamFlag: key[:unit]:min:max[:linear|log]（min / max は "50kHz" のように単位付きでもよい。units.go）
+func parseParamFlag(s string) (ParamSpec, error) {
+	f := strings.Split(s, ":")
+	bad := fmt.Errorf("param %q: want key[:unit]:min:max[:linear|log]", s)
+	if len(f) < 3 || len(f) > 5 || f[0] == "" {
+		return ParamSpec{}, bad
+	}
+	key, unit := f[0], ""
+	rest := f[1:]
+	// 2 つ目が値として読めなければ単位
+	if _, _, err := parseQuantity(rest[0]); err != nil || len(f) == 5 {
+		unit, rest = rest[0], rest[1:]
+	}
+	if len(rest) < 2 {
+		return ParamSpec{}, bad
+	}
+	var lo, hi quantity
+	err1 := lo.set(rest[0])
+	err2 := hi.set(rest[1])
+	if err1 != nil || err2 != nil || hi.V < lo.V {
+		return ParamSpec{}, bad
+	}
+	if unit == "" {
+		u, err := paramUnit(lo, hi)
+		if err != nil {
+			return ParamSpec{}, fmt.Errorf("param %q: %w", s, err)
+		}
+		unit = u
+	}
+	scale := Linear
+	if len(rest) == 3 {
+		switch strings.ToLower(rest[2]) {
+		case "linear":
+		case "log":
+			scale = Log
+		default:
+			return ParamSpec{}, bad
+		}
+	}
+	if scale == Log && lo.V <= 0 {
+		return ParamSpec{}, fmt.Errorf("param %q: log scale requires min > 0", s)
+	}
+	return unitParam(key, unit, lo.V, hi.V, scale), nil
+}
+
+// mergeParams: 同じキーは置き換え、違うキーは後ろに足す
+func mergeParams(base, extra []ParamSpec) []ParamSpec {
+	out := append([]ParamSpec(nil), base...)
+	for _, p := range extra {
+		replaced := false
+		for i := range out {
+			if out[i].Key == p.Key {
+				out[i], replaced = p, true
+			}
+		}
+		if !replaced {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// promptScaffold: 対話式で雛形の内容を聞く
+func promptScaffold(in io.Reader, out io.Writer) (scaffold, error) {
+	sc := bufio.NewScanner(in)
+	ask := func(q, def string) string {
+		fmt.Fprintf(out, "%s [%s]: ", q, def)
+		if !sc.Scan() {
+			return def
+		}
+		if a := strings.TrimSpace(sc.Text()); a != "" {
+			return a
+		}
+		return def
+	}
+
+	s := scaffold{Topology: ask("topology (ss / multirx / relay / custom)", "ss")}
+	if s.Topology == "multirx" {
+		n, err := strconv.Atoi(ask("number of receivers", "2"))
+		if err != nil {
+			return s, err
+		}
+		s.Rx = n
+	}
+	base, err := topologyParams(s.Topology, s.Rx)
+	if err != nil {
+		return s, err
+	}
+	fmt.Fprintln(out, "params to add or override, one per line as key[:unit]:min:max[:linear|log] (empty line to finish)")
+	var extra []ParamSpec
+	for {
+		a := ask("param", "")
+		if a == "" {
+			break
+		}
+		p, err := parseParamFlag(a)
+		if err != nil {
+			fmt.Fprintln(out, err)
+			continue
+		}
+		extra = append(extra, p)
+	}
+	s.Params = mergeParams(base, extra)
+	if err := parseYRange(ask("yRange min,max", defaultYRange(s.Topology)), &s.YRange); err != nil {
+		return s, err
+	}
+	s.MaxIters, err = strconv.ParseInt(strings.ReplaceAll(ask("MaxIters", "1_000_000"), "_", ""), 10, 64)
+	return s, err
+}
+
+func defaultYRange(topology string) string {
+	if topology == "multirx" {
+		return "0,1" // MultiRx の y は余裕（範囲内なら 0 以上）
+	}
+	return "0.1,0.5"
+}
+
+func parseYRange(s string, r *Range) error {
+	a, b, ok := strings.Cut(s, ",")
+	if !ok {
+		a, b, ok = strings.Cut(s, ":") // analyze -yrange 0.2:0.4
+	}
+	if !ok {
+		return fmt.Errorf("yRange %q: want min,max", s)
+	}
+	lo, err1 := strconv.ParseFloat(strings.TrimSpace(a), 64)
+	hi, err2 := strconv.ParseFloat(strings.TrimSpace(b), 64)
+	if err1 != nil || err2 != nil || hi < lo {
+		return fmt.Errorf("yRange %q: want min,max with min <= max", s)
+	}
+	*r = Range{Min: lo, Max: hi}
+	return nil
+}
+
+// render: config_local.go の中身（gofmt 済み）
+func (s scaffold) render() ([]byte, error) {
+	var b strings.Builder
+	g := func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
+	b.WriteString("// config_local.go\n")
+	b.WriteString("// `go run . init` で作った設定（config.go の既定値を上書きする）。自由に書き換えてよい\n\n")
+	b.WriteString("package main\n\n")
+	b.WriteString("func init() {\n\tLocalOverride = func(c *Config) {\n")
+	fmt.Fprintf(&b, "c.YRange = Range{Min: %s, Max: %s}\n", g(s.YRange.Min), g(s.YRange.Max))
+	fmt.Fprintf(&b, "c.MaxIters = %d\n\n", s.MaxIters)
+	b.WriteString("c.Params = []ParamSpec{\n")
+	for _, p := range s.Params {
+		scale := "Linear"
+		if p.Scale == Log {
+			scale = "Log"
+		}
+		fmt.Fprintf(&b, "{Key: %q, Label: %q, Min: %s, Max: %s, Scale: %s, DisplayScale: %s},\n",
+			p.Key, p.Label, g(p.Min), g(p.Max), scale, g(p.DisplayScale))
+	}
+	b.WriteString("}\n\n")
+
+	switch s.Topology {
+	case "ss":
+		b.WriteString("// SS 方式の正規化電力（Losses で部品損失を指定できる）\nobj := SSPN(Losses{})\nc.Objective = &obj\n")
+	case "multirx":
+		b.WriteString("// 受信器ごとの正規化電力の範囲（y はその余裕の最小値）\nobj := MultiRx([]Range{\n")
+		for i := 0; i < s.Rx; i++ {
+			b.WriteString("{Min: 0.1, Max: 0.5},\n")
+		}
+		b.WriteString("})\nc.Objective = &obj\n")
+	case "relay":
+		b.WriteString("// 送信–中継–受信の 3 コイルモデル\nobj := Relay()\nc.Objective = &obj\n")
+	default:
+		b.WriteString("c.Objective = nil\nc.F = func(x map[string]float64) float64 {\n")
+		for _, p := range s.Params {
+			fmt.Fprintf(&b, "_ = Get(x, %q)\n", p.Key)
+		}
+		b.WriteString("// TODO: y を計算して返す\nreturn 0\n}\n")
+	}
+	b.WriteString("}\n}\n")
+	return format.Source([]byte(b.String()))
+}
+
+// runInit: `init` サブコマンド
+func runInit(args []string) int {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	topology := fs.String("topology", "ss", "ss / multirx / relay / custom")
+	rx := fs.Int("rx", 2, "number of receivers for -topology multirx")
+	var params paramFlags
+	fs.Var(&params, "param", "key[:unit]:min:max[:linear|log] (repeatable)")
+	yRange := fs.String("yrange", "", "min,max (default depends on topology)")
+	iters := fs.Int64("iters", 1_000_000, "MaxIters")
+	out := fs.String("out", "config_local.go", "file to write")
+	force := fs.Bool("force", false, "overwrite an existing file")
+	if err := fs.Parse(args); err != nil {
+		return ExitConfigError
+	}
+
+	if _, err := os.Stat(*out); err == nil && !*force {
+		fmt.Fprintf(os.Stderr, "error: %s already exists (use -force to overwrite)\n", *out)
+		return ExitConfigError
+	}
+
+	// 内容を決めるフラグが 1 つもなければ対話式（-out / -force だけなら対話式）
+	interactive := true
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name != "out" && f.Name != "force" {
+			interactive = false
+		}
+	})
+	var s scaffold
+	var err error
+	if interactive {
+		s, err = promptScaffold(os.Stdin, os.Stdout)
+	} else {
+		s, err = flagScaffold(*topology, *rx, params, *yRange, *iters)
+	}
+	if err == nil && len(s.Params) == 0 {
+		err = fmt.Errorf("no params: give at least one param for topology custom")
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return ExitConfigError
+	}
+	src, err := s.render()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return ExitError
+	}
+
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return ExitError
+	}
+	fmt.Printf("wrote %s (%d params, topology=%s). check it with: go run . lint\n", *out, len(s.Params), s.Topology)
+	return ExitOK
+}
+
+func flagScaffold(topology string, rx int, params []string, yRange string, iters int64) (scaffold, error) {
+	s := scaffold{Topology: topology, Rx: rx, MaxIters: iters}
+	base, err := topologyParams(topology, rx)
+	if err != nil {
+		return s, err
+	}
+	var extra []ParamSpec
+	for _, a := range params {
+		p, err := parseParamFlag(a)
+		if err != nil {
+			return s, err
+		}
+		extra = append(extra, p)
+	}
+	s.Params = mergeParams(base, extra)
+	if yRange == "" {
+		yRange = defaultYRange(topology)
+	}
+	return s, parseYRange(yRange, &s.YRange)
+}
@@ -0,0 +1,63 @@
+// scale.go
+// Linear / Log の選び方（Scale: Auto と、選び方の提案）
+//
+// 何桁にもわたる範囲を Linear にすると、ほとんどの点が上の 1 桁に集まり、下の方はほぼ調べられない。
+// 逆に Max/Min が 1 に近い範囲では Log にしても Linear とほとんど同じで、読みにくくなるだけ。
+// Scale: Auto は Max/Min が autoLogRatio 以上なら Log、そうでなければ Linear にする。
+// 明示した Scale が合っていなさそうなときは、起動時と lint で提案を表示する。
+
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	autoLogRatio   = 10 // Max/Min がこれ以上なら Log がよい
+	narrowLogRatio = 2  // Max/Min がこれ未満なら Linear で十分
+)
+
+// autoScale: Max/Min から Linear / Log を選ぶ（Min <= 0 なら Linear）
+func autoScale(p ParamSpec) Scale {
+	if p.Min > 0 && p.Max/p.Min >= autoLogRatio {
+		return Log
+	}
+	return Linear
+}
+
+// resolveScale: Scale: Auto を Linear / Log に決める
+func resolveScale(p ParamSpec) ParamSpec {
+	if p.Scale == Auto {
+		p.Scale = autoScale(p)
+	}
+	return p
+}
+
+// scaleSuggestion: Scale が範囲に合っていなさそうなら、その説明と直し方（合っていれば ""）
+func scaleSuggestion(p ParamSpec) (msg, fix string) {
+	if p.Type != Real || len(p.Values) > 0 || p.Dist.Kind != Uniform || !(p.Min < p.Max) || p.Min <= 0 {
+		return "", ""
+	}
+	r := p.Max / p.Min
+	switch {
+	case p.Scale == Linear && r >= autoLogRatio:
+		// 一様なら上の 1 桁 [Max/10, Max] に入る割合は (Max − Max/10)/(Max − Min)
+		top := (p.Max - p.Max/10) / (p.Max - p.Min) * 100
+		return fmt.Sprintf("Linear over %.2g decades: %.0f%% of samples fall in the top decade", math.Log10(r), top),
+			"use Scale: Log (or Auto) for ranges over a decade or more"
+	case p.Scale == Log && r < narrowLogRatio:
+		return fmt.Sprintf("Log over a narrow range (Max/Min=%.3g): almost the same as Linear", r),
+			"Scale: Linear (or Auto) is easier to read here"
+	}
+	return "", ""
+}
+
+// printScaleSuggestions: 起動時に Scale の提案を表示する
+func printScaleSuggestions(params []ParamSpec) {
+	for _, p := range params {
+		if msg, fix := scaleSuggestion(p); msg != "" {
+			fmt.Printf("[scale] param %s: %s -> %s\n", p.Key, msg, fix)
+		}
+	}
+}
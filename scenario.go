@@ -0,0 +1,223 @@
+// scenario.go
+// 設計パラメータと環境パラメータの入れ子の探索（ParamSpec.Env）
+//
+// k や負荷のように、使っているうちに変わる値は「選ぶ」ものではなく「起こる」もの。
+// Env: true のパラメータを環境パラメータとし、残りの設計パラメータの点ごとに、環境パラメータの
+// Scenario.Inner 通りの組で目的関数を評価して、YRange に入る割合（歩留まり）を求める。
+// 探索は設計パラメータだけで行い、y は歩留まり、OK は歩留まりが Scenario.MinYield 以上のもの。
+// 歩留まりの高い設計を上位 MaxBestSave 件（0 なら 10）覚えておき、"Best" として示す。
+//
+// 環境の組は Seed から 1 回だけ作り、すべての設計で同じものを使う（共通乱数）。そのため
+// 設計どうしの歩留まりの差には環境の引き方のばらつきが乗らない。
+// Derived は環境パラメータまで決めてから計算する。ParamConstraints は設計パラメータだけで判定する。
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ScenarioConfig: 入れ子の探索の設定（Env のパラメータがなければ使わない）
+type ScenarioConfig struct {
+	Inner    int     // 設計 1 点あたりの環境の組の数（0 なら 200）
+	MinYield float64 // OK とする歩留まりの下限（0 なら 0.9）
+}
+
+func (c ScenarioConfig) inner() int {
+	if c.Inner > 0 {
+		return c.Inner
+	}
+	return 200
+}
+
+func (c ScenarioConfig) minYield() float64 {
+	if c.MinYield > 0 {
+		return c.MinYield
+	}
+	return 0.9
+}
+
+// 歩留まりの補助出力（環境の組での y の平均・最小・最大）
+const (
+	YMeanKey = "y_mean"
+	YMinKey  = "y_min"
+	YMaxKey  = "y_max"
+)
+
+// scenario: 環境の組と、元の設定での 1 回分の評価
+type scenario struct {
+	inner  Config      // 元の設定（YRange・目的関数・Derived）
+	design []ParamSpec // 設計パラメータ
+	env    []ParamSpec // 環境パラメータ
+	cases  [][]float64 // 環境の組（env の順の値）
+	obj    Objective   // 元の目的関数（map を受け取る形）
+
+	mu   sync.Mutex // F は並列に呼ばれる
+	top  []Sample   // 歩留まりの高い設計（高い順）
+	keep int
+}
+
+// hasEnv: 環境パラメータがあるか
+func hasEnv(params []ParamSpec) bool {
+	for _, p := range params {
+		if p.Env {
+			return true
+		}
+	}
+	return false
+}
+
+// applyScenario: cfg を設計パラメータの探索に置き換える（環境パラメータがなければ nil）
+func applyScenario(cfg *Config) (*scenario, error) {
+	if !hasEnv(cfg.Params) {
+		return nil, nil
+	}
+	sc := &scenario{inner: *cfg, keep: cfg.MaxBestSave}
+	if sc.keep <= 0 {
+		sc.keep = 10
+	}
+	for _, p := range cfg.Params {
+		if p.Env {
+			sc.env = append(sc.env, p)
+		} else {
+			sc.design = append(sc.design, p)
+		}
+	}
+	if len(sc.design) == 0 {
+		return nil, fmt.Errorf("scenario: every param is Env; at least one design param is needed")
+	}
+
+	switch {
+	case cfg.Objective != nil:
+		sc.obj = *cfg.Objective
+	case cfg.F2 != nil:
+		sc.obj = sliceObjective(cfg.Params, cfg.F2)
+	default:
+		sc.obj = funcObjective(cfg.F)
+	}
+
+	src := cfg.Source
+	if src == nil {
+		var err error
+		if src, err = newSource(cfg.RNG, cfg.Seed); err != nil {
+			return nil, err
+		}
+	}
+	rng := rand.New(src)
+	sc.cases = make([][]float64, cfg.Scenario.inner())
+	for i := range sc.cases {
+		c := make([]float64, len(sc.env))
+		for j, p := range sc.env {
+			v, err := sampleOne(rng.Float64(), p)
+			if err != nil {
+				return nil, err
+			}
+			c[j] = v
+		}
+		sc.cases[i] = c
+	}
+
+	cfg.Params = sc.design
+	cfg.Derived = nil
+	cfg.F, cfg.F2 = nil, nil
+	cfg.Objective = &Objective{
+		Aux: []Column{
+			{Key: YMeanKey, Label: YMeanKey, DisplayScale: 1},
+			{Key: YMinKey, Label: YMinKey, DisplayScale: 1},
+			{Key: YMaxKey, Label: YMaxKey, DisplayScale: 1},
+		},
+		Eval: sc.eval,
+	}
+	cfg.YRange = Range{Min: cfg.Scenario.minYield(), Max: 1}
+	cfg.Boundary = Inclusive
+	cfg.YEpsilon = 0
+	cfg.CompareYRanges = nil
+	return sc, nil
+}
+
+// eval: 設計 x の歩留まり（環境の組のうち元の YRange に入る割合）
+func (sc *scenario) eval(x map[string]float64) (float64, map[string]float64) {
+	vals := make(map[string]float64, len(x)+len(sc.env)+len(sc.inner.Derived))
+	var ok, n int
+	var sum float64
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, c := range sc.cases {
+		for k, v := range x {
+			vals[k] = v
+		}
+		for j, p := range sc.env {
+			vals[p.Key] = c[j]
+		}
+		for _, d := range sc.inner.Derived {
+			vals[d.Key] = d.Func(vals)
+		}
+		y, _ := sc.obj.Eval(vals)
+		if yOK(y, sc.inner) {
+			ok++
+		}
+		if !math.IsNaN(y) && !math.IsInf(y, 0) {
+			n++
+			sum += y
+			lo, hi = math.Min(lo, y), math.Max(hi, y)
+		}
+	}
+	yield := float64(ok) / float64(len(sc.cases))
+	mean := math.NaN()
+	if n > 0 {
+		mean = sum / float64(n)
+	} else {
+		lo, hi = math.NaN(), math.NaN()
+	}
+	aux := map[string]float64{YMeanKey: mean, YMinKey: lo, YMaxKey: hi}
+	sc.remember(x, yield, aux)
+	return yield, aux
+}
+
+// remember: 歩留まりが上位なら覚える
+func (sc *scenario) remember(x map[string]float64, yield float64, aux map[string]float64) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if len(sc.top) == sc.keep && yield <= sc.top[len(sc.top)-1].Y {
+		return
+	}
+	vals := make(map[string]float64, len(x)+len(aux))
+	for k, v := range x {
+		vals[k] = v
+	}
+	for k, v := range aux {
+		vals[k] = v
+	}
+	s := Sample{Values: vals, Y: yield, OK: yield >= sc.inner.Scenario.minYield()}
+	i := sort.Search(len(sc.top), func(i int) bool { return sc.top[i].Y < yield })
+	sc.top = append(sc.top, Sample{})
+	copy(sc.top[i+1:], sc.top[i:])
+	sc.top[i] = s
+	if len(sc.top) > sc.keep {
+		sc.top = sc.top[:sc.keep]
+	}
+}
+
+// best: 歩留まりの高い設計（高い順）
+func (sc *scenario) best() []Sample {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return append([]Sample(nil), sc.top...)
+}
+
+// PrintScenario: 入れ子の探索の設定を 1 行で示す
+func (sc *scenario) PrintScenario() {
+	keys := func(ps []ParamSpec) string {
+		ks := make([]string, len(ps))
+		for j, p := range ps {
+			ks[j] = p.Key
+		}
+		return strings.Join(ks, ",")
+	}
+	fmt.Printf("[scenario] design=%s  environment=%s  inner=%d (same cases for every design)  OK if yield >= %s\n",
+		keys(sc.design), keys(sc.env), len(sc.cases), strings.TrimSpace(fmt4(sc.inner.Scenario.minYield())))
+}
@@ -0,0 +1,194 @@
+// score.go
+// 複数の量を重み付きで足した評価値（Config.Score・-score・設定ファイルの score）
+//
+// YRange は OK / NG を分けるだけなので、OK の中で「効率が高く、余裕もある」ものを選ぶには別の物差しがいる。
+// "eta=0.7,margin=0.3" のように、量のキーと重みを並べて score = Σ 重み × 量 を決める（大きいほど良い）。
+// 量は params・派生パラメータ・目的関数の補助出力のキーと、次の 2 つ。
+//   - y       目的関数の値
+//   - margin  YRange の近い方の端までの距離を幅で割ったもの（中央で 0.5、外なら負）
+// Score を決めると
+//   - 保存する OK サンプルは、見つかった順の先頭 MaxOKSave 件ではなく score の上位 MaxOKSave 件になり、score の順に並ぶ
+//     （StreamTSV なら tsv は見つかった順のまま、表示などに使う分だけ並べ替える）
+//   - 最適化型の探索モード（cem / cmaes / ga / gp）は、YRange に入った点どうしを score で比べる
+//     （入らない点は今まで通り YRange までの距離。cmaes は YTarget の代わりに YRange を目指す）
+//   - すべての出力に score 列が付き、bundle の manifest.json に重みが残る
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ScoreKey: score の列のキー
+const ScoreKey = "score"
+
+// ScoreTerm: score の 1 項
+type ScoreTerm struct {
+	Key    string  `json:"key"`    // 量のキー（"y" / "margin" / params・派生・補助出力のキー）
+	Weight float64 `json:"weight"` // 重み（負なら小さいほど良い量）
+}
+
+// scorer: Score を計算する
+type scorer struct {
+	terms  []ScoreTerm
+	yRange Range
+}
+
+// newScorer: Score が空なら nil
+func newScorer(cfg Config) *scorer {
+	if len(cfg.Score) == 0 {
+		return nil
+	}
+	return &scorer{terms: cfg.Score, yRange: cfg.YRange}
+}
+
+// of: サンプルの score（量がなければ NaN）
+func (sc *scorer) of(s Sample) float64 {
+	var total float64
+	for _, t := range sc.terms {
+		var v float64
+		switch t.Key {
+		case "y":
+			v = s.Y
+		case "margin":
+			v = yMargin(s.Y, sc.yRange)
+		default:
+			x, ok := s.Values[t.Key]
+			if !ok {
+				return math.NaN()
+			}
+			v = x
+		}
+		total += t.Weight * v
+	}
+	return total
+}
+
+// yMargin: y から YRange の近い方の端までの距離を幅で割ったもの（外なら負。幅が 0 なら割らない）
+func yMargin(y float64, r Range) float64 {
+	m := math.Min(y-r.Min, r.Max-y)
+	if w := r.Max - r.Min; w > 0 {
+		m /= w
+	}
+	return m
+}
+
+// searchFitness: 最適化型の探索モードが最小化する値。YRange の外は距離（> 0）、中は score が大きいほど小さい負の値
+// （sc が nil なら中はすべて 0）。atan で (−π, 0) に収めるので、どんな score でも外の点より良い
+func searchFitness(s Sample, r Range, sc *scorer) float64 {
+	d := rangeDistance(s.Y, r)
+	if d > 0 || sc == nil {
+		return d
+	}
+	score := sc.of(s)
+	if math.IsNaN(score) {
+		return 0
+	}
+	return -math.Atan(score) - math.Pi/2
+}
+
+// saveOK: OK のサンプルを保存する（Score があれば score の上位 MaxOKSave 件を score の順に残す。StreamTSV なら見つかった順）
+func (e *engine) saveOK(s Sample) {
+	if e.score == nil || e.okStream != nil {
+		e.save(&e.okList, e.okStream, e.cfg.MaxOKSave, s)
+		return
+	}
+	max := e.cfg.MaxOKSave
+	if max <= 0 {
+		return
+	}
+	rank := func(s Sample) float64 {
+		if v := s.Values[ScoreKey]; !math.IsNaN(v) {
+			return v
+		}
+		return math.Inf(-1)
+	}
+	r := rank(s)
+	list := e.okList
+	if len(list) == max && r <= rank(list[len(list)-1]) {
+		return
+	}
+	// 同じ点（最適化型の探索モードが繰り返し出す）は 1 件だけ
+	for _, it := range list {
+		if it.Y == s.Y && sameValues(it.Values, s.Values) {
+			return
+		}
+	}
+	i := sort.Search(len(list), func(i int) bool { return rank(list[i]) < r })
+	if len(list) < max {
+		list = append(list, Sample{})
+	}
+	copy(list[i+1:], list[i:])
+	list[i] = s
+	e.okList = list
+}
+
+// rankedOK: 保存した OK サンプル（Score があれば score の大きい順。StreamTSV のときはここで並べる）
+func (e *engine) rankedOK() []Sample {
+	if e.score == nil || e.okStream == nil {
+		return e.okList
+	}
+	list := slices.Clone(e.okList)
+	sort.SliceStable(list, func(a, b int) bool { return list[a].Values[ScoreKey] > list[b].Values[ScoreKey] })
+	return list
+}
+
+// checkScore: Score の量のキーが、記録するときに分かるものか確かめる
+func checkScore(cfg Config, obj Objective) error {
+	known := map[string]bool{"y": true, "margin": true}
+	for _, p := range cfg.Params {
+		known[p.Key] = true
+	}
+	for _, d := range cfg.Derived {
+		known[d.Key] = true
+	}
+	for _, c := range obj.Aux {
+		known[c.Key] = true
+	}
+	for _, t := range cfg.Score {
+		if !known[t.Key] {
+			return fmt.Errorf("score: unknown quantity %q (y, margin, or a key of the params, Derived or the objective's aux outputs)", t.Key)
+		}
+		if math.IsNaN(t.Weight) || math.IsInf(t.Weight, 0) {
+			return fmt.Errorf("score: weight of %s is %g", t.Key, t.Weight)
+		}
+	}
+	return nil
+}
+
+// scoreFlag: Score を "eta=0.7,margin=0.3" で指定する（"" なら使わない）
+type scoreFlag struct{ terms *[]ScoreTerm }
+
+func (f scoreFlag) String() string {
+	if f.terms == nil {
+		return ""
+	}
+	parts := make([]string, len(*f.terms))
+	for i, t := range *f.terms {
+		parts[i] = t.Key + "=" + strconv.FormatFloat(t.Weight, 'g', -1, 64)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (f scoreFlag) Set(v string) error {
+	var terms []ScoreTerm
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, w, ok := strings.Cut(part, "=")
+		weight, err := strconv.ParseFloat(strings.TrimSpace(w), 64)
+		if !ok || err != nil || strings.TrimSpace(key) == "" {
+			return fmt.Errorf("score: want key=weight,... (e.g. eta=0.7,margin=0.3), got %q", part)
+		}
+		terms = append(terms, ScoreTerm{Key: strings.TrimSpace(key), Weight: weight})
+	}
+	*f.terms = terms
+	return nil
+}
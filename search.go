@@ -0,0 +1,643 @@
+// search.go
+// 最適化型の探索モード（Config.Search）
+//
+// どれも FeedbackSampler として実装し、評価結果を見ながら次の点を u 空間で決める。
+// u 空間では Log の軸が対数軸になっているので、正規分布は元の値では対数正規分布になる。
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// rangeDistance: y が r に入っていれば 0、外れていれば範囲までの距離（NaN/Inf は +Inf）
+func rangeDistance(y float64, r Range) float64 {
+	if math.IsNaN(y) || math.IsInf(y, 0) {
+		return math.Inf(1)
+	}
+	return math.Max(0, math.Max(r.Min-y, y-r.Max))
+}
+
+// CEMConfig: 交差エントロピー法の設定
+type CEMConfig struct {
+	Batch     int     // 1 世代の評価回数（0 なら 1000）
+	EliteFrac float64 // エリートの割合（0 なら 0.1）
+	Smoothing float64 // 分布の更新率 α（新 = α·エリート + (1−α)·旧。0 なら 0.7）
+}
+
+// CEMSampler: 交差エントロピー法（エリートに正規分布を当てはめて再サンプリング）
+//
+// 最初の世代は一様に探す。各世代の評価が終わるたびに、y が YRange に近い順
+// （OK を優先）に EliteFrac の割合を選び、その平均・標準偏差（軸ごと）に分布を寄せる。
+type CEMSampler struct {
+	CEMConfig
+	YRange Range
+	Score  *scorer // あれば OK どうしを score で比べる（score.go）
+
+	rng   *rand.Rand
+	gen   int
+	mu    []float64
+	sigma []float64
+	batch []cemPoint
+}
+
+type cemPoint struct {
+	u    []float64
+	dist float64
+}
+
+func (s *CEMSampler) Init(dim int, seed int64) error {
+	if s.Batch <= 0 {
+		s.Batch = 1000
+	}
+	if s.EliteFrac <= 0 || s.EliteFrac > 1 {
+		s.EliteFrac = 0.1
+	}
+	if s.Smoothing <= 0 || s.Smoothing > 1 {
+		s.Smoothing = 0.7
+	}
+	s.rng = newRand(seed)
+	s.gen = 0
+	s.mu = make([]float64, dim)
+	s.sigma = make([]float64, dim)
+	for j := range s.mu {
+		s.mu[j] = 0.5
+		s.sigma[j] = 0.3
+	}
+	s.batch = s.batch[:0]
+	return nil
+}
+
+func (s *CEMSampler) Next(u []float64) {
+	for j := range u {
+		if s.gen == 0 {
+			u[j] = s.rng.Float64()
+		} else {
+			u[j] = reflectUnit(s.mu[j] + s.sigma[j]*s.rng.NormFloat64())
+		}
+	}
+}
+
+func (s *CEMSampler) Observe(u []float64, smp Sample) {
+	s.batch = append(s.batch, cemPoint{u: append([]float64{}, u...), dist: searchFitness(smp, s.YRange, s.Score)})
+	if len(s.batch) < s.Batch {
+		return
+	}
+
+	sort.SliceStable(s.batch, func(a, b int) bool { return s.batch[a].dist < s.batch[b].dist })
+	n := max(int(float64(len(s.batch))*s.EliteFrac), 2)
+	elite := s.batch[:n]
+
+	a := s.Smoothing
+	for j := range s.mu {
+		var mean, sq float64
+		for _, p := range elite {
+			mean += p.u[j]
+		}
+		mean /= float64(n)
+		for _, p := range elite {
+			sq += (p.u[j] - mean) * (p.u[j] - mean)
+		}
+		sd := math.Sqrt(sq / float64(n-1))
+
+		s.mu[j] = a*mean + (1-a)*s.mu[j]
+		s.sigma[j] = math.Max(a*sd+(1-a)*s.sigma[j], 1e-3)
+	}
+	s.gen++
+	s.batch = s.batch[:0]
+}
+
+// BestReporter: 評価値の良い順にサンプルを覚えている探索モード
+type BestReporter interface {
+	Best() []Sample
+}
+
+// bestList: 評価値（小さいほど良い）の上位 n 件
+type bestList struct {
+	n      int
+	items  []Sample
+	scores []float64
+}
+
+func (b *bestList) add(s Sample, score float64) {
+	if b.n <= 0 || math.IsNaN(score) {
+		return
+	}
+	if len(b.items) == b.n && score >= b.scores[len(b.scores)-1] {
+		return
+	}
+	// 同じ点（収束後に繰り返し出る）は 1 件だけ
+	for _, it := range b.items {
+		if it.Y == s.Y && sameValues(it.Values, s.Values) {
+			return
+		}
+	}
+	i := sort.SearchFloat64s(b.scores, score)
+	b.items = append(b.items, Sample{})
+	b.scores = append(b.scores, 0)
+	copy(b.items[i+1:], b.items[i:])
+	copy(b.scores[i+1:], b.scores[i:])
+	b.items[i] = s
+	b.scores[i] = score
+	if len(b.items) > b.n {
+		b.items = b.items[:b.n]
+		b.scores = b.scores[:b.n]
+	}
+}
+
+func sameValues(a, b map[string]float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
+
+// CMAESConfig: CMA-ES の設定
+type CMAESConfig struct {
+	Lambda int     // 1 世代の個体数（0 なら 4 + 3·ln(次元)）
+	Sigma0 float64 // 初期ステップ幅（u 空間。0 なら 0.3）
+}
+
+// CMAESSampler: CMA-ES で |y − Target| を最小化する
+//
+// 探索範囲（ParamSpec の Min/Max）は u ∈ [0,1) の箱として扱い、外に出た候補は折り返す。
+// 更新には折り返し後の点を使う。ステップ幅が十分小さくなったら一様な点から再出発する。
+type CMAESSampler struct {
+	CMAESConfig
+	Target float64
+	YRange Range   // Score があるときだけ使う
+	Score  *scorer // あれば |y − Target| の代わりに YRange までの距離と、OK どうしは score で比べる（score.go）
+	BestN  int
+
+	rng                              *rand.Rand
+	n                                int
+	lam                              int
+	mu                               int
+	w                                []float64
+	mueff, cs, ds, cc, c1, cmu, chiN float64
+
+	mean   []float64
+	sigma  float64
+	C      [][]float64
+	B      [][]float64
+	D      []float64
+	pc, ps []float64
+	gen    int
+
+	pop     [][]float64 // 今の世代の候補（u 空間）
+	fit     []float64
+	next    int // 次に Next で渡す候補
+	waiting int // Observe を待っている数
+
+	best bestList
+}
+
+func (s *CMAESSampler) Init(dim int, seed int64) error {
+	if dim == 0 {
+		return fmt.Errorf("cmaes: no params")
+	}
+	n := dim
+	s.n = n
+	s.rng = newRand(seed)
+	s.lam = s.Lambda
+	if s.lam <= 0 {
+		s.lam = 4 + int(3*math.Log(float64(n)))
+	}
+	s.mu = s.lam / 2
+	s.w = make([]float64, s.mu)
+	var sw, sw2 float64
+	for i := range s.w {
+		s.w[i] = math.Log(float64(s.mu)+0.5) - math.Log(float64(i+1))
+		sw += s.w[i]
+	}
+	for i := range s.w {
+		s.w[i] /= sw
+		sw2 += s.w[i] * s.w[i]
+	}
+	s.mueff = 1 / sw2
+
+	fn := float64(n)
+	s.cs = (s.mueff + 2) / (fn + s.mueff + 5)
+	s.ds = 1 + 2*math.Max(0, math.Sqrt((s.mueff-1)/(fn+1))-1) + s.cs
+	s.cc = (4 + s.mueff/fn) / (fn + 4 + 2*s.mueff/fn)
+	s.c1 = 2 / ((fn+1.3)*(fn+1.3) + s.mueff)
+	s.cmu = math.Min(1-s.c1, 2*(s.mueff-2+1/s.mueff)/((fn+2)*(fn+2)+s.mueff))
+	s.chiN = math.Sqrt(fn) * (1 - 1/(4*fn) + 1/(21*fn*fn))
+
+	s.best = bestList{n: s.BestN}
+	s.restart(false)
+	return nil
+}
+
+// restart: 分布を初期化する（random なら平均を一様に選ぶ）
+func (s *CMAESSampler) restart(random bool) {
+	n := s.n
+	s.mean = make([]float64, n)
+	for j := range s.mean {
+		s.mean[j] = 0.5
+		if random {
+			s.mean[j] = s.rng.Float64()
+		}
+	}
+	s.sigma = s.Sigma0
+	if s.sigma <= 0 {
+		s.sigma = 0.3
+	}
+	s.C = identity(n)
+	s.B = identity(n)
+	s.D = make([]float64, n)
+	for j := range s.D {
+		s.D[j] = 1
+	}
+	s.pc = make([]float64, n)
+	s.ps = make([]float64, n)
+	s.gen = 0
+	s.sampleGeneration()
+}
+
+func identity(n int) [][]float64 {
+	m := make([][]float64, n)
+	for i := range m {
+		m[i] = make([]float64, n)
+		m[i][i] = 1
+	}
+	return m
+}
+
+// sampleGeneration: x = m + σ·B·D·z を λ 個作る（箱の外は折り返す）
+func (s *CMAESSampler) sampleGeneration() {
+	n := s.n
+	s.pop = make([][]float64, s.lam)
+	s.fit = make([]float64, s.lam)
+	z := make([]float64, n)
+	for k := range s.pop {
+		for j := range z {
+			z[j] = s.D[j] * s.rng.NormFloat64()
+		}
+		x := make([]float64, n)
+		for i := 0; i < n; i++ {
+			var v float64
+			for j := 0; j < n; j++ {
+				v += s.B[i][j] * z[j]
+			}
+			x[i] = reflectUnit(s.mean[i] + s.sigma*v)
+		}
+		s.pop[k] = x
+	}
+	s.next = 0
+	s.waiting = 0
+}
+
+func (s *CMAESSampler) Next(u []float64) {
+	if s.next >= s.lam {
+		// 世代の結果待ち中に呼ばれたら、同じ分布から追加で出す（評価は更新に使わない）
+		s.sampleExtra(u)
+		return
+	}
+	copy(u, s.pop[s.next])
+	s.next++
+	s.waiting++
+}
+
+func (s *CMAESSampler) sampleExtra(u []float64) {
+	for j := range u {
+		u[j] = reflectUnit(s.mean[j] + s.sigma*s.rng.NormFloat64())
+	}
+}
+
+func (s *CMAESSampler) Observe(u []float64, smp Sample) {
+	f := math.Abs(smp.Y - s.Target)
+	if s.Score != nil {
+		f = searchFitness(smp, s.YRange, s.Score)
+	}
+	if math.IsNaN(f) {
+		f = math.Inf(1)
+	}
+	s.best.add(smp, f)
+
+	// Next で渡した順に結果が返る前提で、今の世代の候補に対応づける
+	k := s.next - s.waiting
+	if s.waiting == 0 || k < 0 || k >= s.lam {
+		return
+	}
+	s.fit[k] = f
+	s.waiting--
+	if s.next == s.lam && s.waiting == 0 {
+		s.update()
+	}
+}
+
+// update: 1 世代分の評価から平均・進化パス・共分散・ステップ幅を更新する
+func (s *CMAESSampler) update() {
+	n := s.n
+	idx := make([]int, s.lam)
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool { return s.fit[idx[a]] < s.fit[idx[b]] })
+
+	old := append([]float64{}, s.mean...)
+	for j := 0; j < n; j++ {
+		s.mean[j] = 0
+		for i := 0; i < s.mu; i++ {
+			s.mean[j] += s.w[i] * s.pop[idx[i]][j]
+		}
+	}
+	dm := make([]float64, n)
+	for j := range dm {
+		dm[j] = (s.mean[j] - old[j]) / s.sigma
+	}
+
+	// C^{-1/2}·dm = B·D^{-1}·Bᵀ·dm
+	bt := make([]float64, n)
+	for j := 0; j < n; j++ {
+		for i := 0; i < n; i++ {
+			bt[j] += s.B[i][j] * dm[i]
+		}
+		bt[j] /= s.D[j]
+	}
+	a := math.Sqrt(s.cs * (2 - s.cs) * s.mueff)
+	var psNorm float64
+	for i := 0; i < n; i++ {
+		var v float64
+		for j := 0; j < n; j++ {
+			v += s.B[i][j] * bt[j]
+		}
+		s.ps[i] = (1-s.cs)*s.ps[i] + a*v
+		psNorm += s.ps[i] * s.ps[i]
+	}
+	psNorm = math.Sqrt(psNorm)
+
+	s.gen++
+	hsig := 0.0
+	if psNorm/math.Sqrt(1-math.Pow(1-s.cs, float64(2*s.gen)))/s.chiN < 1.4+2/float64(n+1) {
+		hsig = 1
+	}
+	b := math.Sqrt(s.cc * (2 - s.cc) * s.mueff)
+	for j := range s.pc {
+		s.pc[j] = (1-s.cc)*s.pc[j] + hsig*b*dm[j]
+	}
+
+	for i := 0; i < n; i++ {
+		for j := 0; j <= i; j++ {
+			v := (1-s.c1-s.cmu)*s.C[i][j] +
+				s.c1*(s.pc[i]*s.pc[j]+(1-hsig)*s.cc*(2-s.cc)*s.C[i][j])
+			for k := 0; k < s.mu; k++ {
+				x := s.pop[idx[k]]
+				v += s.cmu * s.w[k] * (x[i] - old[i]) / s.sigma * (x[j] - old[j]) / s.sigma
+			}
+			s.C[i][j] = v
+			s.C[j][i] = v
+		}
+	}
+	s.sigma *= math.Exp((s.cs / s.ds) * (psNorm/s.chiN - 1))
+	s.sigma = math.Min(s.sigma, 1)
+
+	vals, vecs := jacobiEigen(s.C)
+	for j := range vals {
+		s.D[j] = math.Sqrt(math.Max(vals[j], 1e-20))
+	}
+	s.B = vecs
+
+	// 収束したら（範囲に比べて十分小さく）別の場所から再出発
+	maxD := 0.0
+	for _, d := range s.D {
+		maxD = math.Max(maxD, d)
+	}
+	if s.sigma*maxD < 1e-9 {
+		s.restart(true)
+		return
+	}
+	s.sampleGeneration()
+}
+
+// Best: |y − Target| の小さい順
+func (s *CMAESSampler) Best() []Sample { return s.best.items }
+
+// jacobiEigen: 対称行列の固有値・固有ベクトル（列）を Jacobi 法で求める
+func jacobiEigen(a [][]float64) ([]float64, [][]float64) {
+	n := len(a)
+	m := make([][]float64, n)
+	for i := range m {
+		m[i] = append([]float64{}, a[i]...)
+	}
+	v := identity(n)
+	for sweep := 0; sweep < 100; sweep++ {
+		var off float64
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				off += m[i][j] * m[i][j]
+			}
+		}
+		if off < 1e-30 {
+			break
+		}
+		for p := 0; p < n; p++ {
+			for q := p + 1; q < n; q++ {
+				if m[p][q] == 0 {
+					continue
+				}
+				theta := (m[q][q] - m[p][p]) / (2 * m[p][q])
+				t := math.Copysign(1, theta) / (math.Abs(theta) + math.Sqrt(theta*theta+1))
+				c := 1 / math.Sqrt(t*t+1)
+				sn := t * c
+				for k := 0; k < n; k++ {
+					mkp, mkq := m[k][p], m[k][q]
+					m[k][p] = c*mkp - sn*mkq
+					m[k][q] = sn*mkp + c*mkq
+				}
+				for k := 0; k < n; k++ {
+					mpk, mqk := m[p][k], m[q][k]
+					m[p][k] = c*mpk - sn*mqk
+					m[q][k] = sn*mpk + c*mqk
+				}
+				for k := 0; k < n; k++ {
+					vkp, vkq := v[k][p], v[k][q]
+					v[k][p] = c*vkp - sn*vkq
+					v[k][q] = sn*vkp + c*vkq
+				}
+			}
+		}
+	}
+	vals := make([]float64, n)
+	for i := range vals {
+		vals[i] = m[i][i]
+	}
+	return vals, v
+}
+
+// GAConfig: 遺伝的アルゴリズムの設定
+type GAConfig struct {
+	Population int     // 個体数（0 なら 100）
+	Elite      int     // そのまま次世代に残す上位の数（0 なら 2）
+	Crossover  float64 // 交叉の確率（0 なら 0.9）
+	Mutation   float64 // 遺伝子ごとの突然変異の確率（0 なら 1/次元）
+	Scale      float64 // 突然変異の幅（u 空間の標準偏差。0 なら 0.1）
+}
+
+// GASampler: 遺伝的アルゴリズム（YRange までの距離を最小化）
+//
+// 選択は 2 個体のトーナメント、交叉は BLX-α（α = 0.5）、突然変異は正規分布の摂動。
+// どれも u 空間で行うので、Log の軸の突然変異は対数軸（比率）での摂動になる。
+// 上位 Elite 個体は評価し直さずに次世代へ残す。
+type GASampler struct {
+	GAConfig
+	YRange Range
+	Score  *scorer // あれば OK どうしを score で比べる（score.go）
+	BestN  int
+
+	rng  *rand.Rand
+	dim  int
+	pop  [][]float64
+	fit  []float64
+	next int // 次に Next で渡す個体
+	done int // 評価済みの個体数
+
+	best bestList
+}
+
+func (s *GASampler) Init(dim int, seed int64) error {
+	if s.Population <= 0 {
+		s.Population = 100
+	}
+	if s.Elite <= 0 {
+		s.Elite = 2
+	}
+	s.Elite = min(s.Elite, s.Population-1)
+	if s.Crossover <= 0 {
+		s.Crossover = 0.9
+	}
+	if s.Mutation <= 0 {
+		s.Mutation = 1 / float64(max(dim, 1))
+	}
+	if s.Scale <= 0 {
+		s.Scale = 0.1
+	}
+	s.rng = newRand(seed)
+	s.dim = dim
+	s.best = bestList{n: s.BestN}
+
+	s.pop = make([][]float64, s.Population)
+	s.fit = make([]float64, s.Population)
+	for i := range s.pop {
+		s.pop[i] = make([]float64, dim)
+		for j := range s.pop[i] {
+			s.pop[i][j] = s.rng.Float64()
+		}
+	}
+	s.next, s.done = 0, 0
+	return nil
+}
+
+func (s *GASampler) Next(u []float64) {
+	if s.next >= len(s.pop) {
+		// 世代の結果待ち中に呼ばれたら、一様な点を出す（世代には入れない）
+		for j := range u {
+			u[j] = s.rng.Float64()
+		}
+		return
+	}
+	copy(u, s.pop[s.next])
+	s.next++
+}
+
+func (s *GASampler) Observe(u []float64, smp Sample) {
+	d := searchFitness(smp, s.YRange, s.Score)
+	s.best.add(smp, d)
+
+	if s.done >= s.next {
+		return
+	}
+	s.fit[s.done] = d
+	s.done++
+	if s.done == len(s.pop) {
+		s.evolve()
+	}
+}
+
+// evolve: 次世代を作る（先頭 Elite 個体は評価済みのまま残し、残りを評価待ちにする）
+func (s *GASampler) evolve() {
+	n := len(s.pop)
+	idx := make([]int, n)
+	for i := range idx {
+		idx[i] = i
+	}
+	// 距離が同じ（OK どうし）なら順序をばらす
+	s.rng.Shuffle(n, func(a, b int) { idx[a], idx[b] = idx[b], idx[a] })
+	sort.SliceStable(idx, func(a, b int) bool { return s.fit[idx[a]] < s.fit[idx[b]] })
+
+	tournament := func() []float64 {
+		a, b := s.rng.Intn(n), s.rng.Intn(n)
+		if s.fit[b] < s.fit[a] {
+			a = b
+		}
+		return s.pop[a]
+	}
+
+	pop := make([][]float64, n)
+	fit := make([]float64, n)
+	for i := 0; i < s.Elite; i++ {
+		pop[i] = s.pop[idx[i]]
+		fit[i] = s.fit[idx[i]]
+	}
+	for i := s.Elite; i < n; i++ {
+		p1, p2 := tournament(), tournament()
+		child := make([]float64, s.dim)
+		for j := range child {
+			v := p1[j]
+			if s.rng.Float64() < s.Crossover {
+				lo, hi := math.Min(p1[j], p2[j]), math.Max(p1[j], p2[j])
+				ext := 0.5 * (hi - lo)
+				v = lo - ext + s.rng.Float64()*(hi-lo+2*ext)
+			}
+			if s.rng.Float64() < s.Mutation {
+				v += s.Scale * s.rng.NormFloat64()
+			}
+			child[j] = reflectUnit(v)
+		}
+		pop[i] = child
+	}
+	s.pop, s.fit = pop, fit
+	s.next, s.done = s.Elite, s.Elite
+}
+
+// Best: YRange までの距離の小さい順（OK どうしは Score があれば score の大きい順、なければ見つかった順）
+func (s *GASampler) Best() []Sample { return s.best.items }
+
+// bestN: 上位として覚えておく件数（MaxBestSave が 0 なら 10）
+func bestN(cfg Config) int {
+	if cfg.MaxBestSave > 0 {
+		return cfg.MaxBestSave
+	}
+	return 10
+}
+
+// newSearch: Config.Search から最適化型の Sampler を作る（"" なら nil）
+func newSearch(cfg Config) (Sampler, error) {
+	switch cfg.Search {
+	case "":
+		return nil, nil
+	case "cem":
+		return &CEMSampler{CEMConfig: cfg.CEM, YRange: cfg.YRange, Score: newScorer(cfg)}, nil
+	case "cmaes":
+		return &CMAESSampler{CMAESConfig: cfg.CMAES, Target: cfg.YTarget, YRange: cfg.YRange, Score: newScorer(cfg), BestN: bestN(cfg)}, nil
+	case "ga":
+		return &GASampler{GAConfig: cfg.GA, YRange: cfg.YRange, Score: newScorer(cfg), BestN: bestN(cfg)}, nil
+	case "gp":
+		fixed := make([]bool, len(cfg.Params))
+		for j, p := range cfg.Params {
+			fixed[j] = isFixed(p)
+		}
+		return &GPSampler{GPConfig: cfg.GP, YRange: cfg.YRange, Score: newScorer(cfg), BestN: bestN(cfg), Fixed: fixed}, nil
+	default:
+		return nil, fmt.Errorf("unknown search mode: %q", cfg.Search)
+	}
+}
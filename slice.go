@@ -0,0 +1,79 @@
+// slice.go
+// map を作らない評価（Config.F2）
+//
+// F は map[string]float64 を受け取るので、1 回の評価ごとに map を 1 つ作る。SS の PN の式のように
+// 目的関数が軽いと、その map の確保が実行時間の大半になる。F2 は params の順の []float64 を受け取り、
+// 探索ループでは再利用するバッファに値を入れて呼ぶだけにする。Sample.Values は、保存する・集計に使う
+// ときにだけ作る（filled）。キーから添字への変換は起動時に 1 回だけ行う（ParamIndex）。
+//
+// F2 は Derived / ParamConstraints とは組み合わせられない（どちらも map を受け取るため）。
+
+package main
+
+// ParamIndex: params の中での key の添字（F2 の中で x[i] を引くため。起動前に 1 回だけ呼ぶ）
+// 例: iK, iF := ParamIndex(cfg.Params, "k"), ParamIndex(cfg.Params, "f")
+func ParamIndex(params []ParamSpec, key string) int {
+	for j, p := range params {
+		if p.Key == key {
+			return j
+		}
+	}
+	panic("missing key in params: " + key)
+}
+
+// sliceObjective: F2 を map を受け取る Objective としても使えるようにする（確認・推奨などの探索以外の評価用）
+func sliceObjective(params []ParamSpec, f2 func(x []float64) float64) Objective {
+	return Objective{
+		Eval: func(m map[string]float64) (float64, map[string]float64) {
+			x := make([]float64, len(params))
+			for j, p := range params {
+				x[j] = Get(m, p.Key)
+			}
+			return f2(x), nil
+		},
+	}
+}
+
+// evaluateSlice: evaluate の F2 版（Values は必要なときだけ作り、それ以外は x に params の値を残す）
+// x は e.xbuf を指すので、次の評価の前に filled で map にするか、コピーしておくこと。
+func (e *engine) evaluateSlice(params []ParamSpec, u []float64) (Sample, error) {
+	if e.copula != nil {
+		u = e.copula.apply(u)
+	}
+	x := e.xbuf
+	for j, p := range params {
+		v, err := sampleOne(u[j], p)
+		if err != nil {
+			return Sample{}, err
+		}
+		x[j] = v
+	}
+	y := e.cfg.F2(x)
+	s := Sample{Y: y, OK: yOK(y, e.cfg), x: x}
+	if e.cfg.YEpsilon > 0 || e.dist != nil {
+		s = e.filled(s)
+		if e.cfg.YEpsilon > 0 {
+			s.Values[MarginalKey] = 0
+			if isMarginal(y, e.cfg) {
+				s.Values[MarginalKey] = 1
+			}
+		}
+		if e.dist != nil {
+			s.Values[DistanceKey] = normDistance(y, e.cfg.YRange)
+		}
+	}
+	return s, nil
+}
+
+// filled: F2 で評価した s の Values を作る（作成済みなら何もしない）
+func (e *engine) filled(s Sample) Sample {
+	if s.Values != nil || s.x == nil {
+		return s
+	}
+	s.Values = make(map[string]float64, len(e.cfg.Params)+2)
+	for j, p := range e.cfg.Params {
+		s.Values[p.Key] = s.x[j]
+	}
+	s.x = nil
+	return s
+}
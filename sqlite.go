@@ -0,0 +1,234 @@
+// sqlite.go
+// 実行・パラメータ・保存したサンプルを SQLite のデータベースに足していく（Config.SQLite）
+//
+// 実行ごとのファイルを並べても、「最近 10 回の実行のうち f が 79〜90 kHz の OK の設計」のような問いには答えにくい。
+// SQLite を決めると、実行のたびに次の表へ行を足す（なければ作る）。値は xlsx と同じ元の単位。
+//   - runs:          実行 1 回（日時・Seed・目的関数・版・コマンドライン・件数・YRange・タグ・設定の JSON）
+//   - params:        実行ごとのパラメータの範囲（run_id, key, label, min, max, scale）
+//   - samples:       保存した OK / NG のサンプル（id, run_id, ok, y）
+//   - sample_values: サンプルの列の値（sample_id, key, value。params・派生・補助出力の列）
+// 例: SELECT s.* FROM samples s JOIN sample_values v ON v.sample_id = s.id AND v.key = 'f'
+//     WHERE s.ok = 1 AND v.value BETWEEN 79e3 AND 90e3 AND s.run_id IN (SELECT id FROM runs ORDER BY id DESC LIMIT 10);
+// 書き込みは sqlite3 コマンドに SQL を渡して行う（Go の SQLite ドライバを使わない）。sqlite3 が見つからなければエラー。
+// 名前が .sql で終わるならデータベースではなく同じ SQL をそのファイルに書くので、sqlite3 のない環境では
+// `-sqlite results.sql` として、後で `sqlite3 results.db < results.sql` で読み込める。
+// 既存のファイルはほかの出力と同じく OnExisting に従う。実行を 1 つのデータベースに足していくには append にする
+// （overwrite なら作り直し、rename なら results_1.db のような新しい名前、error なら書かない）。
+// 1 回分は 1 つのトランザクションなので、途中で失敗しても半端な実行は残らない。NaN は NULL になる。
+// 実行ごとのディレクトリ（OutDir）を使っても、データベースは実行をまたいで使うのでその中には置かない。
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// sqliteCommand: SQL を渡すコマンド
+const sqliteCommand = "sqlite3"
+
+// sqliteBatch: INSERT 1 文にまとめるサンプルの数
+const sqliteBatch = 500
+
+// sqliteSchema: 表がなければ作る
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS runs (
+  id INTEGER PRIMARY KEY,
+  created TEXT NOT NULL,
+  tag TEXT,
+  seed INTEGER,
+  objective TEXT,
+  version TEXT,
+  args TEXT,
+  iters INTEGER,
+  ok_hits INTEGER,
+  ng_hits INTEGER,
+  invalid_hits INTEGER,
+  ymin REAL,
+  ymax REAL,
+  config TEXT
+);
+CREATE TABLE IF NOT EXISTS params (
+  run_id INTEGER NOT NULL REFERENCES runs(id),
+  key TEXT NOT NULL,
+  label TEXT,
+  min REAL,
+  max REAL,
+  scale TEXT,
+  PRIMARY KEY (run_id, key)
+);
+CREATE TABLE IF NOT EXISTS samples (
+  id INTEGER PRIMARY KEY,
+  run_id INTEGER NOT NULL REFERENCES runs(id),
+  ok INTEGER NOT NULL,
+  y REAL
+);
+CREATE TABLE IF NOT EXISTS sample_values (
+  sample_id INTEGER NOT NULL REFERENCES samples(id),
+  key TEXT NOT NULL,
+  value REAL,
+  PRIMARY KEY (sample_id, key)
+) WITHOUT ROWID;
+CREATE INDEX IF NOT EXISTS samples_run ON samples(run_id, ok);
+CREATE INDEX IF NOT EXISTS sample_values_key ON sample_values(key, value);
+`
+
+// sqlText: SQL の文字列
+func sqlText(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// sqlReal: SQL の実数（NaN は NULL、±Inf は SQLite が Inf と読む 9e999）
+func sqlReal(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NULL"
+	case math.IsInf(v, 1):
+		return "9e999"
+	case math.IsInf(v, -1):
+		return "-9e999"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// writeSQLiteScript: res を足す SQL を書く
+func writeSQLiteScript(w io.Writer, res Result, tag string) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("BEGIN;\n")
+	bw.WriteString(sqliteSchema)
+	// この実行の id とサンプルの id の始まりを先に決める
+	bw.WriteString("CREATE TEMP TABLE _run AS SELECT (SELECT coalesce(max(id), 0) + 1 FROM runs) AS id, (SELECT coalesce(max(id), 0) FROM samples) AS base;\n")
+
+	rc := res.Config
+	if rc == nil {
+		rc = &RunConfig{}
+	}
+	js, err := json.Marshal(rc)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(bw, "INSERT INTO runs (id, created, tag, seed, objective, version, args, iters, ok_hits, ng_hits, invalid_hits, ymin, ymax, config)\n"+
+		"  SELECT id, %s, %s, %d, %s, %s, %s, %d, %d, %d, %d, %s, %s, %s FROM _run;\n",
+		sqlText(rc.Created), sqlText(tag), res.Seed, sqlText(rc.Objective), sqlText(rc.Version), sqlText(strings.Join(rc.Args, " ")),
+		res.Iters, res.OKHits, res.NGHits, res.InvalidHits, sqlReal(res.YRange.Min), sqlReal(res.YRange.Max), sqlText(string(js)))
+	for _, p := range res.Params {
+		fmt.Fprintf(bw, "INSERT INTO params (run_id, key, label, min, max, scale) SELECT id, %s, %s, %s, %s, %s FROM _run;\n",
+			sqlText(p.Key), sqlText(p.Label), sqlReal(p.Min), sqlReal(p.Max), sqlText(p.Scale.String()))
+	}
+
+	// サンプルは OK・NG の順に base+1 から番号を振る
+	list := append(append([]Sample(nil), res.OKList...), res.NGList...)
+	for lo := 0; lo < len(list); lo += sqliteBatch {
+		batch := list[lo:min(lo+sqliteBatch, len(list))]
+		bw.WriteString("INSERT INTO samples (id, run_id, ok, y) SELECT _run.base + column1, _run.id, column2, column3 FROM _run, (VALUES ")
+		for i, s := range batch {
+			ok := 0
+			if s.OK {
+				ok = 1
+			}
+			if i > 0 {
+				bw.WriteString(", ")
+			}
+			fmt.Fprintf(bw, "(%d, %d, %s)", lo+i+1, ok, sqlReal(s.Y))
+		}
+		bw.WriteString(");\n")
+
+		bw.WriteString("INSERT INTO sample_values (sample_id, key, value) SELECT _run.base + column1, column2, column3 FROM _run, (VALUES ")
+		first := true
+		for i, s := range batch {
+			for _, c := range res.Columns {
+				if !first {
+					bw.WriteString(", ")
+				}
+				first = false
+				fmt.Fprintf(bw, "(%d, %s, %s)", lo+i+1, sqlText(c.Key), sqlReal(s.Values[c.Key]))
+			}
+		}
+		bw.WriteString(");\n")
+	}
+	bw.WriteString("DROP TABLE _run;\nCOMMIT;\n")
+	return bw.Flush()
+}
+
+// sqliteScript: db が SQL を書くファイル（.sql）か
+func sqliteScript(db string) bool {
+	return strings.EqualFold(filepath.Ext(db), ".sql")
+}
+
+// checkSQLite: db に書けるか（sqlite3 があるか）を探索の前に確かめる
+func checkSQLite(db string) error {
+	if db == "" || sqliteScript(db) {
+		return nil
+	}
+	if _, err := exec.LookPath(sqliteCommand); err != nil {
+		return fmt.Errorf("%s not found (install it, or give a name ending in .sql to write the SQL instead)", sqliteCommand)
+	}
+	return nil
+}
+
+// SaveToSQLite: res をデータベース db に書き、書いたファイル名を返す（既存のファイルは policy に従う）
+// db が .sql で終わるなら、sqlite3 に渡す SQL をそのファイルに書く。
+func SaveToSQLite(db string, policy ExistPolicy, res Result, tag string) (string, error) {
+	if db == "" {
+		return "", nil
+	}
+	var script bytes.Buffer
+	if err := writeSQLiteScript(&script, res, tag); err != nil {
+		return "", err
+	}
+	script.WriteString("\n")
+
+	var bin string
+	if !sqliteScript(db) {
+		if err := checkSQLite(db); err != nil {
+			return "", err
+		}
+		bin, _ = exec.LookPath(sqliteCommand)
+	}
+	name, appendMode, err := resolveOutput(db, policy)
+	if err != nil {
+		return "", err
+	}
+
+	if bin == "" {
+		flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if appendMode {
+			flag = os.O_WRONLY | os.O_APPEND
+		}
+		f, err := os.OpenFile(name, flag, 0o644)
+		if err != nil {
+			return "", err
+		}
+		if _, err := f.Write(script.Bytes()); err != nil {
+			f.Close()
+			return "", err
+		}
+		return name, f.Close()
+	}
+
+	if !appendMode {
+		// overwrite: 既存のデータベースを作り直す
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	cmd := exec.Command(bin, "-bail", name)
+	cmd.Stdin = &script
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s %s: %w: %s", sqliteCommand, name, err, msg)
+		}
+		return "", fmt.Errorf("%s %s: %w", sqliteCommand, name, err)
+	}
+	return name, nil
+}
@@ -0,0 +1,332 @@
+// stats.go
+// Package stats: 1 回ずつ値を足していく（ストリーミング）統計量
+//
+// 全部の値を覚えずに、平均・分散（Welford 法）、共分散・相関、ヒストグラム、
+// 分位点の近似（P² 法）を求める。探索後の解析（重要度・交互作用など）で使うほか、
+// ライブラリとしても使えるように公開している。
+package stats
+
+import (
+	"math"
+	"sort"
+)
+
+// Moments: 平均と分散（Welford 法。値が大きくても桁落ちしにくい）
+type Moments struct {
+	N    int64
+	mean float64
+	m2   float64
+	min  float64
+	max  float64
+}
+
+// Add: 値を 1 つ足す（NaN も足すので、必要なら呼ぶ側で除く）
+func (m *Moments) Add(x float64) {
+	m.N++
+	if m.N == 1 {
+		m.min, m.max = x, x
+	} else {
+		m.min = math.Min(m.min, x)
+		m.max = math.Max(m.max, x)
+	}
+	d := x - m.mean
+	m.mean += d / float64(m.N)
+	m.m2 += d * (x - m.mean)
+}
+
+// Merge: 別に集計した o を合わせる（並列に集計したものをまとめるとき）
+func (m *Moments) Merge(o Moments) {
+	if o.N == 0 {
+		return
+	}
+	if m.N == 0 {
+		*m = o
+		return
+	}
+	n := m.N + o.N
+	d := o.mean - m.mean
+	m.mean += d * float64(o.N) / float64(n)
+	m.m2 += o.m2 + d*d*float64(m.N)*float64(o.N)/float64(n)
+	m.min = math.Min(m.min, o.min)
+	m.max = math.Max(m.max, o.max)
+	m.N = n
+}
+
+// Mean: 平均（0 件なら NaN）
+func (m *Moments) Mean() float64 {
+	if m.N == 0 {
+		return math.NaN()
+	}
+	return m.mean
+}
+
+// Var: 母分散（n で割る。0 件なら NaN）
+func (m *Moments) Var() float64 {
+	if m.N == 0 {
+		return math.NaN()
+	}
+	return m.m2 / float64(m.N)
+}
+
+// SampleVar: 不偏分散（n−1 で割る。1 件以下なら NaN）
+func (m *Moments) SampleVar() float64 {
+	if m.N < 2 {
+		return math.NaN()
+	}
+	return m.m2 / float64(m.N-1)
+}
+
+// Std: 母標準偏差
+func (m *Moments) Std() float64 { return math.Sqrt(m.Var()) }
+
+// Min / Max: 最小値・最大値（0 件なら NaN）
+func (m *Moments) Min() float64 {
+	if m.N == 0 {
+		return math.NaN()
+	}
+	return m.min
+}
+
+func (m *Moments) Max() float64 {
+	if m.N == 0 {
+		return math.NaN()
+	}
+	return m.max
+}
+
+// Cov: 2 変数の共分散・相関（Welford 法を 2 変数に広げたもの）
+type Cov struct {
+	X, Y Moments
+	cxy  float64
+}
+
+// Add: 組 (x, y) を 1 つ足す
+func (c *Cov) Add(x, y float64) {
+	dx := x - c.X.mean
+	c.X.Add(x)
+	c.Y.Add(y)
+	c.cxy += dx * (y - c.Y.mean)
+}
+
+// Cov: 母共分散（0 件なら NaN）
+func (c *Cov) Cov() float64 {
+	if c.X.N == 0 {
+		return math.NaN()
+	}
+	return c.cxy / float64(c.X.N)
+}
+
+// Corr: 相関係数（どちらかが一定なら 0、0 件なら NaN）
+func (c *Cov) Corr() float64 {
+	if c.X.N == 0 {
+		return math.NaN()
+	}
+	if c.X.m2 <= 0 || c.Y.m2 <= 0 {
+		return 0
+	}
+	return c.cxy / math.Sqrt(c.X.m2*c.Y.m2)
+}
+
+// CovMatrix: d 変数の共分散行列
+type CovMatrix struct {
+	N    int64
+	mean []float64
+	c    [][]float64 // 偏差積和
+}
+
+func NewCovMatrix(d int) *CovMatrix {
+	c := make([][]float64, d)
+	for i := range c {
+		c[i] = make([]float64, d)
+	}
+	return &CovMatrix{mean: make([]float64, d), c: c}
+}
+
+// Add: ベクトル x を 1 つ足す（長さは NewCovMatrix の d）
+func (m *CovMatrix) Add(x []float64) {
+	m.N++
+	d := make([]float64, len(x))
+	for i := range x {
+		d[i] = x[i] - m.mean[i]
+		m.mean[i] += d[i] / float64(m.N)
+	}
+	for i := range x {
+		for j := range x {
+			m.c[i][j] += d[i] * (x[j] - m.mean[j])
+		}
+	}
+}
+
+// Mean: 各変数の平均
+func (m *CovMatrix) Mean() []float64 { return append([]float64(nil), m.mean...) }
+
+// Cov: 母共分散行列（0 件なら nil）
+func (m *CovMatrix) Cov() [][]float64 {
+	if m.N == 0 {
+		return nil
+	}
+	out := make([][]float64, len(m.c))
+	for i := range m.c {
+		out[i] = make([]float64, len(m.c))
+		for j := range m.c {
+			out[i][j] = m.c[i][j] / float64(m.N)
+		}
+	}
+	return out
+}
+
+// Histogram: [Min, Max) を等間隔に分けたヒストグラム（範囲外は Under / Over に数える）
+type Histogram struct {
+	Min, Max    float64
+	Counts      []int64
+	Under, Over int64
+}
+
+func NewHistogram(min, max float64, bins int) *Histogram {
+	return &Histogram{Min: min, Max: max, Counts: make([]int64, bins)}
+}
+
+// Bin: x が入るマスの番号（範囲外は -1 / len(Counts)）
+func (h *Histogram) Bin(x float64) int {
+	if x < h.Min {
+		return -1
+	}
+	if x >= h.Max {
+		return len(h.Counts)
+	}
+	i := int((x - h.Min) / (h.Max - h.Min) * float64(len(h.Counts)))
+	return min(i, len(h.Counts)-1)
+}
+
+// Add: 値を 1 つ足す（NaN は数えない）
+func (h *Histogram) Add(x float64) {
+	if math.IsNaN(x) {
+		return
+	}
+	switch i := h.Bin(x); {
+	case i < 0:
+		h.Under++
+	case i >= len(h.Counts):
+		h.Over++
+	default:
+		h.Counts[i]++
+	}
+}
+
+// Total: 数えた値の数（範囲外も含む）
+func (h *Histogram) Total() int64 {
+	n := h.Under + h.Over
+	for _, c := range h.Counts {
+		n += c
+	}
+	return n
+}
+
+// Edges: マスの境界（len(Counts)+1 個）
+func (h *Histogram) Edges() []float64 {
+	out := make([]float64, len(h.Counts)+1)
+	for i := range out {
+		out[i] = h.Min + (h.Max-h.Min)*float64(i)/float64(len(h.Counts))
+	}
+	return out
+}
+
+// Quantile: P² 法による p 分位点の近似（Jain & Chlamtac, 1985）
+// 覚えるのは 5 つの目印だけで、値の数によらずメモリは一定。
+type Quantile struct {
+	P  float64
+	n  int64
+	q  [5]float64 // 目印の高さ
+	k  [5]float64 // 目印の位置
+	d  [5]float64 // 目印の理想の位置
+	dd [5]float64 // 理想の位置の増分
+}
+
+func NewQuantile(p float64) *Quantile {
+	return &Quantile{P: p}
+}
+
+// Add: 値を 1 つ足す（NaN は数えない）
+func (s *Quantile) Add(x float64) {
+	if math.IsNaN(x) {
+		return
+	}
+	if s.n < 5 {
+		s.q[s.n] = x
+		s.n++
+		if s.n == 5 {
+			sort.Float64s(s.q[:])
+			p := s.P
+			s.k = [5]float64{1, 2, 3, 4, 5}
+			s.d = [5]float64{1, 1 + 2*p, 1 + 4*p, 3 + 2*p, 5}
+			s.dd = [5]float64{0, p / 2, p, (1 + p) / 2, 1}
+		}
+		return
+	}
+	s.n++
+
+	var c int
+	switch {
+	case x < s.q[0]:
+		s.q[0] = x
+		c = 0
+	case x >= s.q[4]:
+		s.q[4] = x
+		c = 3
+	default:
+		for c = 0; c < 3 && x >= s.q[c+1]; c++ {
+		}
+	}
+	for i := c + 1; i < 5; i++ {
+		s.k[i]++
+	}
+	for i := range s.d {
+		s.d[i] += s.dd[i]
+	}
+
+	for i := 1; i <= 3; i++ {
+		delta := s.d[i] - s.k[i]
+		if (delta >= 1 && s.k[i+1]-s.k[i] > 1) || (delta <= -1 && s.k[i-1]-s.k[i] < -1) {
+			sign := math.Copysign(1, delta)
+			q := s.parabolic(i, sign)
+			if !(s.q[i-1] < q && q < s.q[i+1]) {
+				q = s.linear(i, sign)
+			}
+			s.q[i] = q
+			s.k[i] += sign
+		}
+	}
+}
+
+func (s *Quantile) parabolic(i int, d float64) float64 {
+	return s.q[i] + d/(s.k[i+1]-s.k[i-1])*
+		((s.k[i]-s.k[i-1]+d)*(s.q[i+1]-s.q[i])/(s.k[i+1]-s.k[i])+
+			(s.k[i+1]-s.k[i]-d)*(s.q[i]-s.q[i-1])/(s.k[i]-s.k[i-1]))
+}
+
+func (s *Quantile) linear(i int, d float64) float64 {
+	j := i + int(d)
+	return s.q[i] + d*(s.q[j]-s.q[i])/(s.k[j]-s.k[i])
+}
+
+// N: 数えた値の数
+func (s *Quantile) N() int64 { return s.n }
+
+// Value: p 分位点の推定値（5 件未満ならその値から直接求める。0 件なら NaN）
+func (s *Quantile) Value() float64 {
+	if s.n == 0 {
+		return math.NaN()
+	}
+	if s.n < 5 {
+		vs := append([]float64(nil), s.q[:s.n]...)
+		sort.Float64s(vs)
+		pos := s.P * float64(len(vs)-1)
+		i := int(pos)
+		if i >= len(vs)-1 {
+			return vs[len(vs)-1]
+		}
+		t := pos - float64(i)
+		return vs[i]*(1-t) + vs[i+1]*t
+	}
+	return s.q[2]
+}
@@ -0,0 +1,213 @@
+package stats
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func near(t *testing.T, name string, got, want, tol float64) {
+	t.Helper()
+	if math.Abs(got-want) > tol {
+		t.Errorf("%s = %.17g, want %.17g (tol %g)", name, got, want, tol)
+	}
+}
+
+func TestMoments(t *testing.T) {
+	var m Moments
+	for _, x := range []float64{2, 4, 4, 4, 5, 5, 7, 9} {
+		m.Add(x)
+	}
+	near(t, "Mean", m.Mean(), 5, 0)
+	near(t, "Var", m.Var(), 4, 0)
+	near(t, "SampleVar", m.SampleVar(), 32.0/7, 1e-15)
+	near(t, "Std", m.Std(), 2, 0)
+	near(t, "Min", m.Min(), 2, 0)
+	near(t, "Max", m.Max(), 9, 0)
+
+	var empty Moments
+	if !math.IsNaN(empty.Mean()) || !math.IsNaN(empty.Var()) || !math.IsNaN(empty.Min()) {
+		t.Errorf("empty Moments: want NaN")
+	}
+	var one Moments
+	one.Add(3)
+	if !math.IsNaN(one.SampleVar()) {
+		t.Errorf("SampleVar of 1 value = %g, want NaN", one.SampleVar())
+	}
+}
+
+// 大きな値に小さなばらつきが乗ったもの。素朴な Σx² − (Σx)²/n では桁落ちで分散が崩れる
+func TestMomentsLargeOffset(t *testing.T) {
+	for _, offset := range []float64{0, 1e9, 1e12, 1e15} {
+		var m Moments
+		for _, d := range []float64{4, 7, 13, 16} {
+			m.Add(offset + d)
+		}
+		near(t, "Mean", m.Mean()-offset, 10, 0)
+		near(t, "SampleVar", m.SampleVar(), 30, 1e-9)
+	}
+
+	// 1e6 件: 1e9 に 0 と 1 を交互に足したもの（平均 0.5 + 1e9、母分散 0.25）
+	var m Moments
+	for i := 0; i < 1_000_000; i++ {
+		m.Add(1e9 + float64(i%2))
+	}
+	near(t, "Mean", m.Mean(), 1e9+0.5, 1e-6)
+	near(t, "Var", m.Var(), 0.25, 1e-9)
+}
+
+func TestMomentsMerge(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	xs := make([]float64, 1000)
+	for i := range xs {
+		xs[i] = 1e6 + r.NormFloat64()*3
+	}
+	var all, a, b Moments
+	for i, x := range xs {
+		all.Add(x)
+		if i < 300 {
+			a.Add(x)
+		} else {
+			b.Add(x)
+		}
+	}
+	a.Merge(b)
+	near(t, "Mean", a.Mean(), all.Mean(), 1e-9)
+	near(t, "Var", a.Var(), all.Var(), 1e-9)
+	near(t, "Min", a.Min(), all.Min(), 0)
+	near(t, "Max", a.Max(), all.Max(), 0)
+	if a.N != all.N {
+		t.Errorf("N = %d, want %d", a.N, all.N)
+	}
+
+	var e Moments
+	e.Merge(all)
+	near(t, "Merge into empty", e.Mean(), all.Mean(), 0)
+}
+
+func TestCov(t *testing.T) {
+	// x = 1..5, y = 2, 4, 5, 4, 5: Σ(x−3)(y−4) = 6 なので母共分散 1.2、var x = 2、var y = 1.2
+	var c Cov
+	xs := []float64{1, 2, 3, 4, 5}
+	ys := []float64{2, 4, 5, 4, 5}
+	for i := range xs {
+		c.Add(xs[i], ys[i])
+	}
+	near(t, "Cov", c.Cov(), 1.2, 1e-15)
+	near(t, "Corr", c.Corr(), 1.2/math.Sqrt(2*1.2), 1e-15)
+
+	var lin Cov
+	for i := 0; i < 100; i++ {
+		x := 1e8 + float64(i)
+		lin.Add(x, 3-2*x)
+	}
+	near(t, "Corr (y = 3 − 2x)", lin.Corr(), -1, 1e-12)
+
+	var flat Cov
+	for _, x := range xs {
+		flat.Add(x, 7)
+	}
+	near(t, "Corr (constant y)", flat.Corr(), 0, 0)
+}
+
+func TestCovMatrix(t *testing.T) {
+	m := NewCovMatrix(2)
+	xs := []float64{1, 2, 3, 4, 5}
+	ys := []float64{2, 4, 5, 4, 5}
+	for i := range xs {
+		m.Add([]float64{xs[i], ys[i]})
+	}
+	want := [][]float64{{2, 1.2}, {1.2, 1.2}}
+	got := m.Cov()
+	for i := range want {
+		for j := range want[i] {
+			near(t, "Cov", got[i][j], want[i][j], 1e-15)
+		}
+	}
+	mean := m.Mean()
+	near(t, "Mean[0]", mean[0], 3, 0)
+	near(t, "Mean[1]", mean[1], 4, 0)
+	if NewCovMatrix(3).Cov() != nil {
+		t.Errorf("empty CovMatrix: want nil")
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram(0, 1, 4)
+	wantEdges := []float64{0, 0.25, 0.5, 0.75, 1}
+	for i, e := range h.Edges() {
+		near(t, "Edge", e, wantEdges[i], 0)
+	}
+	for _, x := range []float64{-0.1, 0, 0.25, 0.3, 0.74, 0.75, 0.99, 1, math.NaN()} {
+		h.Add(x)
+	}
+	want := []int64{1, 2, 1, 2}
+	for i, c := range h.Counts {
+		if c != want[i] {
+			t.Errorf("Counts[%d] = %d, want %d", i, c, want[i])
+		}
+	}
+	if h.Under != 1 || h.Over != 1 || h.Total() != 8 {
+		t.Errorf("Under, Over, Total = %d, %d, %d, want 1, 1, 8", h.Under, h.Over, h.Total())
+	}
+
+	// 端が 2 進で割り切れない範囲でも、Max の直前の値は最後のマスに入る
+	h = NewHistogram(0.1, 0.7, 3)
+	if i := h.Bin(math.Nextafter(0.7, 0)); i != 2 {
+		t.Errorf("Bin(just below Max) = %d, want 2", i)
+	}
+	near(t, "Edge[1]", h.Edges()[1], 0.3, 1e-15)
+}
+
+// Jain & Chlamtac (1985) の表 1 の 20 件。中央値の推定は 4.44
+func TestQuantilePaper(t *testing.T) {
+	q := NewQuantile(0.5)
+	for _, x := range []float64{0.02, 0.15, 0.74, 3.39, 0.83, 22.37, 10.15, 15.43, 38.62, 15.92,
+		34.60, 10.28, 1.47, 0.40, 0.05, 11.39, 0.27, 0.42, 0.09, 11.37} {
+		q.Add(x)
+	}
+	near(t, "Value", q.Value(), 4.44, 0.005)
+	if q.N() != 20 {
+		t.Errorf("N = %d, want 20", q.N())
+	}
+}
+
+func TestQuantileSmall(t *testing.T) {
+	q := NewQuantile(0.5)
+	if !math.IsNaN(q.Value()) {
+		t.Errorf("empty: want NaN")
+	}
+	for _, x := range []float64{3, 1, 2} {
+		q.Add(x)
+	}
+	near(t, "median of 3", q.Value(), 2, 0)
+	q = NewQuantile(0.25)
+	for _, x := range []float64{4, 1, 3, 2} {
+		q.Add(x)
+	}
+	near(t, "p25 of 4", q.Value(), 1.75, 1e-15)
+}
+
+// 分布の分位点との差（1e5 件なら P² の誤差は分布の幅の 1% 程度より小さい）
+func TestQuantileAccuracy(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	const n = 100_000
+	for _, p := range []float64{0.05, 0.5, 0.9, 0.99} {
+		u := NewQuantile(p)
+		e := NewQuantile(p)
+		xs := make([]float64, n)
+		for i := range xs {
+			u.Add(r.Float64())
+			xs[i] = r.ExpFloat64()
+			e.Add(xs[i])
+		}
+		near(t, "uniform", u.Value(), p, 0.01)
+		near(t, "exponential", e.Value(), -math.Log(1-p), 0.02*(-math.Log(1-p))+0.01)
+
+		// 同じ値を並べ替えた経験分位点とも比べる
+		sort.Float64s(xs)
+		emp := xs[int(p*float64(n-1))]
+		near(t, "exponential vs empirical", e.Value(), emp, 0.02*emp+0.01)
+	}
+}
@@ -0,0 +1,106 @@
+// status.go
+// 実行中の探索の状態を表示する（SIGQUIT。`kill -QUIT <pid>` や端末の Ctrl-\）
+//
+// nohup や tmux で裏で回している探索が、今どこまで進んでいて OK が出ているかを、止めずに確かめるため。
+// SIGQUIT を受けると、次に記録する点の後で、段・反復数・速さ・OK / NG の数・今までで一番良い点を stderr に書く。
+// Go の既定の SIGQUIT（全ゴルーチンのスタックを書いて終了）は探索中だけ無効になる。
+// 一番良い点は、最適化型の探索モードならその上位の 1 件、そうでなければ y が YRange の中央に最も近い点。
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// watchStatus: SIGQUIT を受けたら statusDue を立てる（戻り値で止める）
+func (e *engine) watchStatus() (stop func()) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGQUIT)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ch:
+				atomic.StoreInt32(&e.statusDue, 1)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
+
+// trackClosest: y が YRange の中央に最も近い点を覚えておく（記録する goroutine から呼ぶ）
+func (e *engine) trackClosest(s Sample) {
+	if math.IsNaN(s.Y) {
+		return
+	}
+	d := math.Abs(s.Y - (e.cfg.YRange.Min+e.cfg.YRange.Max)/2)
+	if e.closest.Values != nil || e.closest.x != nil {
+		if d >= e.closestD {
+			return
+		}
+	}
+	e.closest, e.closestD = e.filled(s), d
+}
+
+// status: 今の状態（複数行。n は記録した点の数）
+func (e *engine) status(n int64) string {
+	elapsed := e.elapsed + time.Since(e.started)
+	okh := atomic.LoadInt64(&e.okHits)
+	ngh := atomic.LoadInt64(&e.ngHits)
+	inv := atomic.LoadInt64(&e.invalidHits)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "=== status (pid %d) ===\n", os.Getpid())
+	fmt.Fprintf(&b, "phase      %d / %d\n", e.phase+1, len(e.cfg.Zoom.phaseEnds(e.maxIters)))
+	var pct float64
+	if e.maxIters > 0 {
+		pct = float64(n) / float64(e.maxIters) * 100
+	}
+	fmt.Fprintf(&b, "iter       %d / %d (%.2f%%)\n", n, e.maxIters, pct)
+	fmt.Fprintf(&b, "elapsed    %s\n", elapsed.Round(time.Second))
+	if s := elapsed.Seconds(); s > 0 && n > 0 {
+		rate := float64(n) / s
+		fmt.Fprintf(&b, "rate       %.0f /s (remaining %s)\n", rate, benchETA(max(e.maxIters-n, 0), rate))
+	}
+	fmt.Fprintf(&b, "OK_hits    %d", okh)
+	if okh+ngh > 0 {
+		fmt.Fprintf(&b, " (%.4g%%)", float64(okh)/float64(okh+ngh)*100)
+	}
+	fmt.Fprintf(&b, "\nNG_hits    %d\n", ngh)
+	if inv > 0 {
+		fmt.Fprintf(&b, "INVALID    %d\n", inv)
+	}
+	fmt.Fprintf(&b, "saved      OK=%d NG=%d\n", len(e.okList), len(e.ngList))
+
+	best := e.closest
+	what := "closest to the YRange center"
+	if bs := e.best(); len(bs) > 0 {
+		best, what = e.filled(bs[0]), "best of the search"
+	}
+	if best.Values != nil {
+		fmt.Fprintf(&b, "best       y=%.6g (%s)\n", best.Y, what)
+		for _, p := range e.cfg.Params {
+			fmt.Fprintf(&b, "           %s = %.6g\n", p.Label, best.Values[p.Key]*p.DisplayScale)
+		}
+	}
+	return b.String()
+}
+
+// statusIfDue: SIGQUIT を受けていれば状態を stderr に書く
+func (e *engine) statusIfDue(n int64) {
+	if atomic.CompareAndSwapInt32(&e.statusDue, 1, 0) {
+		fmt.Fprint(os.Stderr, "\n"+e.status(n))
+	}
+}
@@ -0,0 +1,228 @@
+// strata.go
+// パラメータの範囲を区間（層）に分けた OK 率（Config.Strata）
+//
+// 周波数や結合係数のどのあたりで歩留まりが良いかを見るためのもの。
+// 層の境目は Strata.Edges で元の単位で指定する（例: "f": {40e3, 60e3, 80e3}）。
+// 指定のないパラメータは、サンプリングの軸（Scale / Dist）で Bins 等分する。カテゴリは選択肢ごとに 1 層。
+// 評価したすべての点を数えるので（抜き出しはしない）、iters が多ければ小さな層でも率は正確になる。
+// Pair に 2 つのパラメータを指定すると、その組の 2 次元の表も出す。
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// StrataConfig: 層ごとの集計の設定（Enabled が false なら何もしない）
+type StrataConfig struct {
+	Enabled bool
+	Edges   map[string][]float64 // パラメータごとの層の境目（元の単位、昇順。Min / Max は含めない）
+	Bins    int                  // Edges の指定がないパラメータの分割数（0 なら 4）
+	Pair    [2]string            // 2 次元の表を出すパラメータの組（"" なら出さない）
+}
+
+func (c StrataConfig) bins() int {
+	if c.Bins > 0 {
+		return c.Bins
+	}
+	return 4
+}
+
+// StrataParam: 1 つのパラメータの層ごとの数
+type StrataParam struct {
+	Param  ParamSpec
+	Labels []string // 層の表示（"[a, b)" または選択肢の名前）
+	N      []int64  // 評価した数
+	OK     []int64  // OK の数
+}
+
+// StrataPair: 2 つのパラメータの層の組ごとの数（[a の層][b の層]）
+type StrataPair struct {
+	A, B *StrataParam
+	N    [][]int64
+	OK   [][]int64
+}
+
+// Strata: 層ごとの集計結果
+type Strata struct {
+	Params []*StrataParam
+	Pair   *StrataPair // Pair の指定がなければ nil
+}
+
+// strataAcc: 評価した点を層ごとに数える
+type strataAcc struct {
+	res   *Strata
+	edges [][]float64 // 層の境目（カテゴリなら nil）
+	pa    int         // Pair の添字（なければ -1）
+	pb    int
+}
+
+// newStrataAcc: 値が変わらないパラメータは除いて層を作る（境目の指定がおかしければ panic）
+func newStrataAcc(cfg Config) *strataAcc {
+	sc := cfg.Strata
+	acc := &strataAcc{res: &Strata{}, pa: -1, pb: -1}
+	for _, p := range cfg.Params {
+		if isFixed(p) {
+			continue
+		}
+		sp := &StrataParam{Param: p}
+		var edges []float64
+		switch {
+		case p.Type == Categorical:
+			for _, ch := range p.Choices {
+				sp.Labels = append(sp.Labels, ch.Name)
+			}
+		case sc.Edges[p.Key] != nil:
+			edges = sc.Edges[p.Key]
+			if !sort.Float64sAreSorted(edges) {
+				panic("strata edges for " + p.Key + " must be in ascending order")
+			}
+		default:
+			n := sc.bins()
+			for k := 1; k < n; k++ {
+				v, err := sampleOne(float64(k)/float64(n), p)
+				if err != nil {
+					panic(err)
+				}
+				edges = append(edges, v)
+			}
+		}
+		if p.Type != Categorical {
+			sp.Labels = strataLabels(p, edges)
+		}
+		sp.N = make([]int64, len(sp.Labels))
+		sp.OK = make([]int64, len(sp.Labels))
+
+		j := len(acc.res.Params)
+		if p.Key == sc.Pair[0] {
+			acc.pa = j
+		}
+		if p.Key == sc.Pair[1] {
+			acc.pb = j
+		}
+		acc.res.Params = append(acc.res.Params, sp)
+		acc.edges = append(acc.edges, edges)
+	}
+	for k := range sc.Edges {
+		if !acc.has(k) {
+			panic("strata edges for unknown or fixed param: " + k)
+		}
+	}
+	if sc.Pair != [2]string{} {
+		if acc.pa < 0 || acc.pb < 0 || acc.pa == acc.pb {
+			panic("strata pair needs two different varying params: " + sc.Pair[0] + ", " + sc.Pair[1])
+		}
+		a, b := acc.res.Params[acc.pa], acc.res.Params[acc.pb]
+		pr := &StrataPair{A: a, B: b}
+		for range a.Labels {
+			pr.N = append(pr.N, make([]int64, len(b.Labels)))
+			pr.OK = append(pr.OK, make([]int64, len(b.Labels)))
+		}
+		acc.res.Pair = pr
+	}
+	return acc
+}
+
+func (a *strataAcc) has(key string) bool {
+	for _, sp := range a.res.Params {
+		if sp.Param.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// strataLabels: 境目 edges で分けた層の表示（DisplayScale をかけた値）
+func strataLabels(p ParamSpec, edges []float64) []string {
+	bounds := append(append([]float64{p.Min}, edges...), p.Max)
+	labels := make([]string, 0, len(bounds)-1)
+	for k := 0; k+1 < len(bounds); k++ {
+		lo, hi := bounds[k]*p.DisplayScale, bounds[k+1]*p.DisplayScale
+		closing := ")"
+		if k+2 == len(bounds) {
+			closing = "]"
+		}
+		labels = append(labels, fmt.Sprintf("[%.4g, %.4g%s", lo, hi, closing))
+	}
+	return labels
+}
+
+// index: 値 v の層の番号
+func (a *strataAcc) index(j int, v float64) int {
+	sp := a.res.Params[j]
+	if sp.Param.Type == Categorical {
+		for i, ch := range sp.Param.Choices {
+			if ch.Value == v {
+				return i
+			}
+		}
+		return 0
+	}
+	// 境目ちょうどは上の層に入れる
+	return sort.Search(len(a.edges[j]), func(i int) bool { return a.edges[j][i] > v })
+}
+
+func (a *strataAcc) add(s Sample) {
+	var ia, ib int
+	for j, sp := range a.res.Params {
+		i := a.index(j, s.Values[sp.Param.Key])
+		sp.N[i]++
+		if s.OK {
+			sp.OK[i]++
+		}
+		if j == a.pa {
+			ia = i
+		}
+		if j == a.pb {
+			ib = i
+		}
+	}
+	if pr := a.res.Pair; pr != nil {
+		pr.N[ia][ib]++
+		if s.OK {
+			pr.OK[ia][ib]++
+		}
+	}
+}
+
+// okRatio: OK の数 / 評価した数（評価した数が 0 なら NaN）
+func okRatio(ok, n int64) float64 {
+	if n == 0 {
+		return math.NaN()
+	}
+	return float64(ok) / float64(n)
+}
+
+// PrintStrata: 層ごとの OK 率を表示する
+func PrintStrata(st *Strata) {
+	fmt.Println("=== OK ratio by stratum ===")
+	if st == nil || len(st.Params) == 0 {
+		fmt.Println("(no varying params)")
+		fmt.Println()
+		return
+	}
+	for _, sp := range st.Params {
+		fmt.Println(sp.Param.Label)
+		for i, l := range sp.Labels {
+			fmt.Printf("  %-24s n=%12d  OK=%12d  OK_ratio=%s\n", l, sp.N[i], sp.OK[i], fmt4(okRatio(sp.OK[i], sp.N[i])))
+		}
+	}
+	if pr := st.Pair; pr != nil {
+		fmt.Printf("\nOK_ratio: %s (rows) x %s (columns)\n", pr.A.Param.Label, pr.B.Param.Label)
+		fmt.Printf("%-24s", "")
+		for _, l := range pr.B.Labels {
+			fmt.Printf(" %16s", l)
+		}
+		fmt.Println()
+		for i, la := range pr.A.Labels {
+			fmt.Printf("%-24s", la)
+			for k := range pr.B.Labels {
+				fmt.Printf(" %16s", fmt4(okRatio(pr.OK[i][k], pr.N[i][k])))
+			}
+			fmt.Println()
+		}
+	}
+	fmt.Println()
+}
@@ -0,0 +1,158 @@
+// stream.go
+// OK / NG の tsv を探索中に書き足す（Config.StreamTSV）
+//
+// 通常は保存するサンプルを MaxOKSave / MaxNGSave 件までメモリに持ち、最後にまとめて書く。
+// 1 億回の探索で OK をすべて残したいときなどは、件数に比例してメモリが要る。StreamTSV なら、
+// 記録した順に tsv に 1 行ずつ書き足し、メモリには表示・xlsx・推奨などに使う最初の streamKeep 件だけ残す。
+// 書き足した分は途中で止まってもファイルに残るので、tsv の途中結果（.partial）は作らない。
+//
+// 焼きなまし（refined）・検証（y_err / unsure）の列は探索の後で決まるので、書き足した行では 0 になる。
+
+package main
+
+import (
+	"encoding/csv"
+	"os"
+)
+
+// streamKeep: StreamTSV のとき、メモリに残す件数（MaxPrint が 0 でなければその件数）
+const streamKeep = 100
+
+// tsvStream: 書き足している tsv 1 つ
+type tsvStream struct {
+	name string
+	f    *os.File
+	w    *csv.Writer
+	cols []Column
+	max  int   // 書く件数の上限（MaxOKSave / MaxNGSave）
+	n    int   // 書いた件数
+	keep int   // メモリに残す件数
+	err  error // 最初の書き込みエラー（以降は書かない）
+}
+
+// openTSVStream: filename を開いて設定と見出しを書く（policy は最後にまとめて書くときと同じ）
+func openTSVStream(filename string, policy ExistPolicy, cols []Column, max, keep int, rc *RunConfig) (*tsvStream, error) {
+	name, appendMode, err := resolveAppend(filename, policy, tsvHeader(cols), textHeader('\t'))
+	if err != nil {
+		return nil, err
+	}
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendMode {
+		flag = os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(name, flag, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	w := csv.NewWriter(f)
+	w.Comma = '\t'
+	if !appendMode {
+		if err := writeTSVComments(f, rc); err != nil {
+			f.Close()
+			return nil, err
+		}
+		if err := w.Write(tsvHeader(cols)); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return &tsvStream{name: name, f: f, w: w, cols: cols, max: max, keep: min(keep, max)}, nil
+}
+
+// full: 上限まで書いたか
+func (t *tsvStream) full() bool {
+	return t.n >= t.max || t.err != nil
+}
+
+func (t *tsvStream) write(s Sample) {
+	if t.full() {
+		return
+	}
+	t.err = t.w.Write(tsvRow(t.cols, s))
+	t.n++
+}
+
+// close: 書き残しを書いて閉じ、ファイル名と最初のエラーを返す（2 回目以降は何もしない）
+func (t *tsvStream) close() (string, error) {
+	if t.f == nil {
+		return t.name, t.err
+	}
+	t.w.Flush()
+	if t.err == nil {
+		t.err = t.w.Error()
+	}
+	if err := t.f.Close(); t.err == nil {
+		t.err = err
+	}
+	t.f = nil
+	return t.name, t.err
+}
+
+// closeStreams: 開いている tsv を閉じる
+func (e *engine) closeStreams() {
+	for _, t := range []*tsvStream{e.okStream, e.ngStream} {
+		if t != nil {
+			t.close()
+		}
+	}
+}
+
+// openStreams: 有効な OK / NG の tsv を書き足し用に開く（探索の前に呼ぶ）
+func (e *engine) openStreams(files outputFiles) error {
+	keep := streamKeep
+	if e.cfg.MaxPrint > 0 {
+		keep = e.cfg.MaxPrint
+	}
+	cols := e.columns()
+	rc := newRunConfig(e.cfg, e.workers)
+	if files.OKTSV != "" {
+		t, err := openTSVStream(files.OKTSV, e.cfg.OnExisting, cols, e.cfg.MaxOKSave, keep, rc)
+		if err != nil {
+			return err
+		}
+		e.okStream = t
+	}
+	if files.NGTSV != "" {
+		t, err := openTSVStream(files.NGTSV, e.cfg.OnExisting, cols, e.cfg.MaxNGSave, keep, rc)
+		if err != nil {
+			if e.okStream != nil {
+				e.okStream.close()
+			}
+			return err
+		}
+		e.ngStream = t
+	}
+	return nil
+}
+
+// save: 枠が空いていれば s を list に保存する（t があれば tsv に書き足し、メモリには t.keep 件だけ残す）
+func (e *engine) save(list *[]Sample, t *tsvStream, max int, s Sample) {
+	if t == nil {
+		if max > 0 && len(*list) < max {
+			*list = append(*list, s)
+		}
+		return
+	}
+	if t.full() {
+		return
+	}
+	outputValues(e.cfg.OutputColumns, []Sample{s}, nil, nil)
+	t.write(s)
+	if len(*list) < t.keep {
+		*list = append(*list, s)
+	}
+}
+
+// saving: OK（ok が false なら NG）のサンプルをまだ保存するか
+func (e *engine) saving(ok bool) bool {
+	if ok {
+		if e.okStream != nil {
+			return !e.okStream.full()
+		}
+		return len(e.okList) < e.cfg.MaxOKSave
+	}
+	if e.ngStream != nil {
+		return !e.ngStream.full()
+	}
+	return len(e.ngList) < e.cfg.MaxNGSave
+}
@@ -0,0 +1,44 @@
+// tolerance.go
+// 中心値 ± 許容差 [%] による範囲の指定（ParamSpec.Center / TolPercent）
+//
+// 部品のデータシートの許容差は「140 µH ±20%」のように書かれているので、そのまま書けるようにする。
+// Min / Max への換算は設定を読んだ直後に 1 回だけ行い、以降のコードは Min / Max だけを見る。
+
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// hasTolerance: Center / TolPercent で範囲を指定しているか
+func hasTolerance(p ParamSpec) bool {
+	return p.Center != 0 || p.TolPercent != 0
+}
+
+// resolveTolerance: Center / TolPercent から Min / Max を計算する（Min / Max と食い違えば panic）
+func resolveTolerance(p ParamSpec) ParamSpec {
+	if !hasTolerance(p) {
+		return p
+	}
+	if p.TolPercent < 0 {
+		panic(fmt.Sprintf("param %s: TolPercent must be >= 0 (got %g)", p.Key, p.TolPercent))
+	}
+	d := math.Abs(p.Center) * p.TolPercent / 100
+	lo, hi := p.Center-d, p.Center+d
+	// 2 回目以降の呼び出し（換算済み）と、Min / Max を書いていない場合だけ受け付ける
+	if (p.Min != 0 || p.Max != 0) && (p.Min != lo || p.Max != hi) {
+		panic(fmt.Sprintf("param %s: give either Min/Max or Center/TolPercent, not both", p.Key))
+	}
+	p.Min, p.Max = lo, hi
+	return p
+}
+
+// resolveParams: すべてのパラメータの Center / TolPercent を Min / Max に換算し、Scale: Auto を決める
+func resolveParams(ps []ParamSpec) []ParamSpec {
+	out := make([]ParamSpec, len(ps))
+	for j, p := range ps {
+		out[j] = resolveScale(resolveTolerance(p))
+	}
+	return out
+}
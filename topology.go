@@ -0,0 +1,116 @@
+// topology.go
+// 組み込み目的関数（複数コイルのトポロジー）
+//
+// objective.go の SS モデルを拡張したもの。受信側が複数ある場合や中継コイルがある場合を扱う。
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+)
+
+// RxKey: 受信器 i（1 始まり）のパラメータのキー（例: RxKey("L2", 1) = "L2_1"）
+func RxKey(base string, i int) string {
+	return fmt.Sprintf("%s_%d", base, i)
+}
+
+// MultiRx: 1 送信器・N 受信器（SS 方式、受信器間の結合は無視）
+//
+// 受信器 i のキーは k_i, R2_i, L2_i, C2_i（RxKey を参照）。送信側は f, R1, L1, C1。
+// 受信器 i の正規化電力 PN_i = P_i / (V²/(4·R1)) を補助出力とし、
+// ranges[i-1] に対する余裕 min(PN_i − Min, Max − PN_i)/(Max − Min) の最小値を y とする。
+// すべての受信器が範囲内のとき y >= 0 になるので、YRange は {Min: 0, Max: 1} とする。
+func MultiRx(ranges []Range) Objective {
+	n := len(ranges)
+	if n == 0 {
+		panic("MultiRx: ranges is empty")
+	}
+	for i, r := range ranges {
+		if !(r.Min < r.Max) {
+			panic(fmt.Sprintf("MultiRx: ranges[%d] must satisfy Min < Max", i))
+		}
+	}
+	aux := make([]Column, 0, n)
+	for i := 1; i <= n; i++ {
+		key := RxKey("PN", i)
+		aux = append(aux, Column{Key: key, Label: key, DisplayScale: 1.0})
+	}
+
+	return Objective{
+		Aux: aux,
+		Eval: func(x map[string]float64) (float64, map[string]float64) {
+			w := 2 * math.Pi * Get(x, "f")
+			R1 := Get(x, "R1")
+			L1 := Get(x, "L1")
+			z1 := complex(R1, w*L1-1.0/(w*Get(x, "C1")))
+
+			// 受信器ごとのインピーダンスと相互インダクタンス
+			zs := make([]complex128, n)
+			ms := make([]float64, n)
+			zin := z1
+			for i := 0; i < n; i++ {
+				L2 := Get(x, RxKey("L2", i+1))
+				zs[i] = complex(Get(x, RxKey("R2", i+1)), w*L2-1.0/(w*Get(x, RxKey("C2", i+1))))
+				ms[i] = Get(x, RxKey("k", i+1)) * math.Sqrt(L1*L2)
+				zin += complex(w*w*ms[i]*ms[i], 0) / zs[i]
+			}
+			if zin == 0 {
+				return math.NaN(), nil
+			}
+
+			// V = 1 として I1, I_i を求め、有能電力 1/(4·R1) で正規化
+			i1 := 1 / zin
+			out := make(map[string]float64, n)
+			y := math.Inf(1)
+			for i := 0; i < n; i++ {
+				ii := complex(0, -w*ms[i]) * i1 / zs[i]
+				r2 := real(zs[i])
+				pn := cmplx.Abs(ii) * cmplx.Abs(ii) * r2 * 4 * R1
+				out[RxKey("PN", i+1)] = pn
+
+				r := ranges[i]
+				margin := math.Min(pn-r.Min, r.Max-pn) / (r.Max - r.Min)
+				y = math.Min(y, margin)
+			}
+			return y, out
+		},
+	}
+}
+
+// Relay: 送信–中継–受信の 3 コイルモデル（SS 方式、送信と受信の直接結合は無視）
+//
+// キー: f, R1, L1, C1（送信）、Rr, Lr, Cr（中継共振器。Rr はコイルの損失抵抗）、
+// R2, L2, C2（受信）、k12（送信–中継）、k23（中継–受信）。
+// y は負荷 R2 の正規化電力 PN = P2 / (V²/(4·R1))。
+func Relay() Objective {
+	return Objective{
+		Eval: func(x map[string]float64) (float64, map[string]float64) {
+			w := 2 * math.Pi * Get(x, "f")
+			R1 := Get(x, "R1")
+			L1 := Get(x, "L1")
+			Lr := Get(x, "Lr")
+			L2 := Get(x, "L2")
+			R2 := Get(x, "R2")
+
+			z1 := complex(R1, w*L1-1.0/(w*Get(x, "C1")))
+			zr := complex(Get(x, "Rr"), w*Lr-1.0/(w*Get(x, "Cr")))
+			z2 := complex(R2, w*L2-1.0/(w*Get(x, "C2")))
+			m12 := Get(x, "k12") * math.Sqrt(L1*Lr)
+			m23 := Get(x, "k23") * math.Sqrt(Lr*L2)
+
+			// 受信側から順に反射インピーダンスをたたみ込む（V = 1）
+			zr2 := zr + complex(w*w*m23*m23, 0)/z2
+			zin := z1 + complex(w*w*m12*m12, 0)/zr2
+			if zin == 0 || zr2 == 0 {
+				return math.NaN(), nil
+			}
+			i1 := 1 / zin
+			ir := complex(0, -w*m12) * i1 / zr2
+			i2 := complex(0, -w*m23) * ir / z2
+
+			return cmplx.Abs(i2) * cmplx.Abs(i2) * R2 * 4 * R1, nil
+		},
+	}
+}
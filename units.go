@@ -0,0 +1,97 @@
+// units.go
+// 単位付きの値（"47nF", "140µH", "85kHz", "10Ω"）を読む
+//
+// 設定ファイルの min / max や init の -param に 1e-9 や 1e6 を手で書くと、桁の取り違えが起きやすい。
+// 数値の後ろに SI 接頭辞（p, n, u, µ, m, k, M, G）と単位を書けば、元の単位（F, H, Hz, Ω）の値にする。
+// 接頭辞だけ（"100u"・"85k"）でもよく、その倍率をかけた値にする（"5m" は 5e-3、"1M" は 1e6）。
+// 設定ファイル・init の -param・-set のどこでも同じ読み方をする。
+// 単位を書いたパラメータは、DisplayScale と Label の " [unit]" を単位から決める（明示した方が優先）。
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// siPrefixes: 接頭辞と、元の単位の値から表示の値への倍率（DisplayScale）
+var siPrefixes = []struct {
+	p string
+	s float64
+}{{"k", 1e-3}, {"M", 1e-6}, {"G", 1e-9}, {"m", 1e3}, {"u", 1e6}, {"µ", 1e6}, {"μ", 1e6}, {"n", 1e9}, {"p", 1e12}}
+
+// splitUnit: 単位を DisplayScale と接頭辞を除いた単位に分ける（"kHz" → 1e-3, "Hz"）
+func splitUnit(unit string) (scale float64, base string) {
+	for _, pr := range siPrefixes {
+		if rest, ok := strings.CutPrefix(unit, pr.p); ok && rest != "" {
+			return pr.s, rest
+		}
+	}
+	return 1, unit
+}
+
+// parseQuantity: "47nF" を 47e-9 と "nF" にする（単位がなければ "" で、値はそのまま。
+// "100u" のように接頭辞だけなら 100e-6 と ""）
+func parseQuantity(s string) (float64, string, error) {
+	s = strings.TrimSpace(s)
+	// 数値として読める一番長い先頭を値にする
+	for i := len(s); i > 0; i-- {
+		v, err := strconv.ParseFloat(strings.ReplaceAll(s[:i], "_", ""), 64)
+		if err != nil {
+			continue
+		}
+		unit := strings.TrimSpace(s[i:])
+		if unit == "" {
+			return v, "", nil
+		}
+		if strings.ContainsAny(unit[:1], "0123456789.+-_") {
+			break
+		}
+		for _, pr := range siPrefixes {
+			if unit == pr.p {
+				return v / pr.s, "", nil
+			}
+		}
+		scale, _ := splitUnit(unit)
+		return v / scale, unit, nil
+	}
+	return 0, "", fmt.Errorf("%q is not a number with an optional unit (e.g. 47nF, 85kHz, 10Ω)", s)
+}
+
+// parseNumber: 数値（-set の値）。parseQuantity と同じく接頭辞だけ・単位付きでもよく、元の単位の値にする
+func parseNumber(s string) (float64, error) {
+	v, _, err := parseQuantity(s)
+	return v, err
+}
+
+// quantity: 設定ファイルの単位を書ける値（数値でも "47nF" のような文字列でもよい）
+type quantity struct {
+	V    float64 // 元の単位の値
+	Unit string  // 書いた単位（なければ ""）
+}
+
+func (q *quantity) set(s string) error {
+	v, unit, err := parseQuantity(s)
+	if err != nil {
+		return err
+	}
+	*q = quantity{V: v, Unit: unit}
+	return nil
+}
+
+// paramUnit: min と max の単位から、パラメータの単位を決める（片方だけなら書いた方。違う単位ならエラー）
+func paramUnit(lo, hi quantity) (string, error) {
+	switch {
+	case lo.Unit == "":
+		return hi.Unit, nil
+	case hi.Unit == "":
+		return lo.Unit, nil
+	}
+	_, a := splitUnit(lo.Unit)
+	_, b := splitUnit(hi.Unit)
+	if a != b {
+		return "", fmt.Errorf("min is in %s but max is in %s", lo.Unit, hi.Unit)
+	}
+	return hi.Unit, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// 接頭辞だけの値は、設定ファイル・init -param・-set のどれでも同じ倍率の値になる
+func TestBarePrefix(t *testing.T) {
+	for _, c := range []struct {
+		in   string
+		want float64
+		unit string
+	}{
+		{"100u", 100e-6, ""},
+		{"100µ", 100e-6, ""},
+		{"85k", 85e3, ""},
+		{"5m", 5e-3, ""},
+		{"1M", 1e6, ""},
+		{"47nF", 47e-9, "nF"},
+		{"140µH", 140e-6, "µH"},
+		{"10Ω", 10, "Ω"},
+		{"1e3", 1e3, ""},
+	} {
+		v, unit, err := parseQuantity(c.in)
+		if err != nil || math.Abs(v-c.want) > 1e-12*math.Abs(c.want) || unit != c.unit {
+			t.Errorf("parseQuantity(%q) = %g, %q, %v; want %g, %q", c.in, v, unit, err, c.want, c.unit)
+		}
+		n, err := parseNumber(c.in)
+		if err != nil || n != v {
+			t.Errorf("parseNumber(%q) = %g, %v; want %g (same as parseQuantity)", c.in, n, err, v)
+		}
+	}
+}
+
+func TestParamFlagBarePrefix(t *testing.T) {
+	p, err := parseParamFlag("L1:µH:100u:200u:log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(p.Min-100e-6) > 1e-18 || math.Abs(p.Max-200e-6) > 1e-18 {
+		t.Errorf("Min, Max = %g, %g; want 1e-4, 2e-4", p.Min, p.Max)
+	}
+	if p.Label != "L1 [µH]" || p.DisplayScale != 1e6 {
+		t.Errorf("Label, DisplayScale = %q, %g; want \"L1 [µH]\", 1e6", p.Label, p.DisplayScale)
+	}
+}
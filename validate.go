@@ -0,0 +1,233 @@
+// validate.go
+// 設定の確認だけをして探索しない（`go run . validate`・`-dry-run`）
+//
+// 長い探索を始めてから、キーの打ち間違いで F が panic したり、Min と Max の取り違えに気づいたりしないように、
+// 探索の前に次を確かめて、実際に使う設定（preset・-config・-set・フラグをすべて当てた後のもの）を表示する。
+//   - すべての ParamSpec（Max >= Min、Log なら Min > 0、キーの重なり。lint.go の error と同じもの）
+//   - 探索を始められるか（列の名前の重なり・Score・Metric など、newEngine が受け付けるか）
+//   - 各パラメータを Min にした点・Max にした点・中央の点と、乱数の点を数点だけ評価する
+//     （F が知らないキーを Get して panic する・NaN を返すなどを見つける）
+// 問題があれば ExitConfigError、なければ ExitOK で終わる。lint と違って範囲の選び方の提案はしない。
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// validateRandom: 端と中央の点のほかに試し評価する乱数の点の数
+const validateRandom = 5
+
+// validateConfig: 設定を確かめて、見つかった問題を返す（"error" があれば探索できない）
+func validateConfig(cfg Config) []lintNote {
+	var notes []lintNote
+	for _, nt := range lintConfig(cfg) {
+		if nt.Level == "error" {
+			notes = append(notes, nt)
+		}
+	}
+	seen := map[string]bool{}
+	for _, p := range cfg.Params {
+		if seen[p.Key] {
+			notes = append(notes, lintNote{Level: "error", Where: "param " + p.Key, Msg: "duplicate key", Fix: "give each param its own Key"})
+		}
+		seen[p.Key] = true
+	}
+	if err := checkRNG(); err != nil {
+		notes = append(notes, lintNote{Level: "error", Where: "rng", Msg: err.Error(), Fix: "the same seed would not reproduce earlier runs; report this with your Go version"})
+	}
+	if len(notes) > 0 {
+		return notes
+	}
+	return append(notes, trialEvaluate(cfg)...)
+}
+
+// trialEvaluate: 端・中央・乱数の点を評価してみる（newEngine や F の panic もここで受け止める）
+func trialEvaluate(cfg Config) (notes []lintNote) {
+	add := func(level, where, msg, fix string) {
+		notes = append(notes, lintNote{Level: level, Where: where, Msg: msg, Fix: fix})
+	}
+	where := "engine"
+	defer func() {
+		if r := recover(); r != nil {
+			add("error", where, fmt.Sprint(r), "")
+		}
+	}()
+	cc := cfg
+	cc.Sampler, cc.Search, cc.SamplingMethod = nil, "", "random"
+	e, err := newEngine(cc)
+	if err != nil {
+		add("error", where, err.Error(), "")
+		return notes
+	}
+
+	d := len(e.params)
+	points := []struct {
+		name string
+		u    []float64
+	}{
+		{"all Min", make([]float64, d)},
+		{"all Max", fill(d, math.Nextafter(1, 0))},
+		{"center", fill(d, 0.5)},
+	}
+	rng := newRand(cfg.Seed)
+	for i := 0; i < validateRandom; i++ {
+		u := make([]float64, d)
+		for j := range u {
+			u[j] = rng.Float64()
+		}
+		points = append(points, struct {
+			name string
+			u    []float64
+		}{fmt.Sprintf("random #%d", i+1), u})
+	}
+
+	var nan []string
+	for _, pt := range points {
+		where = "F at " + pt.name
+		s, err := e.evaluate(e.params, pt.u)
+		if err != nil {
+			add("error", where, err.Error(), "")
+			continue
+		}
+		if !s.Invalid && (math.IsNaN(s.Y) || math.IsInf(s.Y, 0)) {
+			nan = append(nan, pt.name)
+		}
+	}
+	if len(nan) > 0 {
+		add("warn", "F", fmt.Sprintf("returned NaN/Inf at %s", strings.Join(nan, ", ")),
+			"check for division by zero or log of a negative value near the range ends")
+	}
+	return notes
+}
+
+func fill(n int, v float64) []float64 {
+	u := make([]float64, n)
+	for j := range u {
+		u[j] = v
+	}
+	return u
+}
+
+// objectiveSource: 目的関数をどこから取るか（表示用）
+func objectiveSource(cfg Config) string {
+	switch {
+	case cfg.Model != "":
+		return "model " + cfg.Model
+	case cfg.Plugin != "":
+		return "plugin " + cfg.Plugin
+	case cfg.WASM != "":
+		return "wasm " + cfg.WASM
+	case len(cfg.Exec.Command) > 0:
+		return "exec " + strings.Join(cfg.Exec.Command, " ")
+	case cfg.Expr != "" && cfg.ExprCompensated:
+		return "expr " + cfg.Expr + " (compensated sums)"
+	case cfg.Expr != "":
+		return "expr " + cfg.Expr
+	case cfg.Objective != nil:
+		return "Objective (Go)"
+	case cfg.F2 != nil:
+		return "F2 (Go)"
+	case cfg.F != nil:
+		return "F (Go)"
+	}
+	return "(none)"
+}
+
+// printEffectiveConfig: 実際に使う設定を表示する
+func printEffectiveConfig(cfg Config) {
+	fmt.Println("=== effective config ===")
+	fmt.Printf("%-12s %-12s %12s %12s %-7s %s\n", "key", "label", "min", "max", "scale", "display")
+	for _, p := range cfg.Params {
+		scale := p.Scale.String()
+		switch {
+		case p.Type == Categorical:
+			scale = fmt.Sprintf("%d choices", len(p.Choices))
+		case p.Type == Int:
+			scale = "int"
+		case len(p.Values) > 0:
+			scale = fmt.Sprintf("%d values", len(p.Values))
+		}
+		fmt.Printf("%-12s %-12s %12.6g %12.6g %-7s ×%g\n", p.Key, p.Label, p.Min, p.Max, scale, p.DisplayScale)
+	}
+	for _, d := range cfg.Derived {
+		fmt.Printf("%-12s %-12s (derived)\n", d.Key, d.Label)
+	}
+	fmt.Printf("objective    %s\n", objectiveSource(cfg))
+	fmt.Printf("yRange       [%g, %g]\n", cfg.YRange.Min, cfg.YRange.Max)
+	fmt.Printf("iters        %d\n", cfg.MaxIters)
+	fmt.Printf("seed         %d\n", cfg.Seed)
+	sampling := cfg.SamplingMethod
+	if cfg.Search != "" {
+		sampling = "search " + cfg.Search
+	}
+	if sampling == "" {
+		sampling = "random"
+	}
+	fmt.Printf("sampling     %s (rng %s, workers %d, deterministic %v)\n", sampling, orDefault(cfg.RNG, "xoshiro"), resolvedWorkers(cfg), cfg.Deterministic)
+	fmt.Printf("progress     %s\n", progressText(cfg))
+	fmt.Printf("save         OK %d, NG %d\n", cfg.MaxOKSave, cfg.MaxNGSave)
+	if len(cfg.Score) > 0 {
+		fmt.Printf("score        %s\n", scoreFlag{&cfg.Score}.String())
+	}
+	for _, o := range []struct {
+		name string
+		spec OutputSpec
+	}{{"xlsx", cfg.XLSX}, {"ok-tsv", cfg.OKTSV}, {"ng-tsv", cfg.NGTSV}, {"ok-csv", cfg.OKCSV}, {"ng-csv", cfg.NGCSV}, {"ok-parquet", cfg.OKParquet}, {"ng-parquet", cfg.NGParquet}, {"config-json", cfg.ConfigJSON}, {"bundle", cfg.Bundle}} {
+		file := "(off)"
+		if o.spec.Enabled {
+			file = o.spec.File
+		}
+		fmt.Printf("%-12s %s\n", o.name, file)
+	}
+	if cfg.ProgressLog != "" {
+		fmt.Printf("progress-log %s\n", cfg.ProgressLog)
+	}
+	if cfg.JSONL.File != "" {
+		which := "all evaluations"
+		if cfg.JSONL.Saved {
+			which = "saved samples"
+		}
+		fmt.Printf("jsonl        %s (%s)\n", cfg.JSONL.File, which)
+	}
+	if cfg.SQLite != "" {
+		fmt.Printf("sqlite       %s\n", cfg.SQLite)
+	}
+	if cfg.OutDir != "" {
+		fmt.Printf("out-dir      %s\n", filepath.Join(cfg.OutDir, runDirName(time.Now(), cfg.RunTag)))
+	}
+	fmt.Printf("on-existing  %s\n", cfg.OnExisting)
+	fmt.Println()
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// runValidate: 設定を確かめて表示し、終了コードを返す（`validate` と -dry-run）
+func runValidate(cfg Config) int {
+	printEffectiveConfig(cfg)
+	notes := validateConfig(cfg)
+	fmt.Println("=== validate ===")
+	hasErr := false
+	for _, nt := range notes {
+		hasErr = hasErr || nt.Level == "error"
+		fmt.Printf("[%s] %s: %s\n", nt.Level, nt.Where, nt.Msg)
+		if nt.Fix != "" {
+			fmt.Printf("       -> %s\n", nt.Fix)
+		}
+	}
+	if hasErr {
+		fmt.Println("problems found: the search would not run as configured")
+		return ExitConfigError
+	}
+	fmt.Printf("ok: %d params, %d trial points evaluated\n", len(cfg.Params), 3+validateRandom)
+	return ExitOK
+}
@@ -0,0 +1,105 @@
+// values.go
+// 離散値のパラメータ（ParamSpec.Values）と E 系列（標準数）の値の生成
+//
+// コンデンサやインダクタは市販の値しか使えないので、連続値ではなく E12 / E24 / E96 などの
+// 値の中から選ぶ。Values を指定すると Scale / Dist は使わず、[Min, Max] の中の値を等確率で選ぶ。
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// e24: E24 系列の 1 桁分（E3 / E6 / E12 はこの間引き）
+var e24 = []float64{
+	1.0, 1.1, 1.2, 1.3, 1.5, 1.6, 1.8, 2.0, 2.2, 2.4, 2.7, 3.0,
+	3.3, 3.6, 3.9, 4.3, 4.7, 5.1, 5.6, 6.2, 6.8, 7.5, 8.2, 9.1,
+}
+
+// eMantissas: E 系列の 1 桁分の値（1 ≤ m < 10）
+func eMantissas(n int) []float64 {
+	switch n {
+	case 3, 6, 12, 24:
+		step := 24 / n
+		out := make([]float64, 0, n)
+		for i := 0; i < 24; i += step {
+			out = append(out, e24[i])
+		}
+		return out
+	case 48, 96, 192:
+		// 10^(i/n) を有効数字 3 桁に丸めたもの（E192 の 9.19 だけは規格表では 9.20）
+		out := make([]float64, n)
+		for i := range out {
+			out[i] = math.Round(math.Pow(10, float64(i)/float64(n))*100) / 100
+		}
+		if n == 192 {
+			out[185] = 9.20
+		}
+		return out
+	default:
+		panic(fmt.Sprintf("unknown E series: E%d", n))
+	}
+}
+
+// ESeries: [min, max] に入る E{n} 系列の値（n は 3, 6, 12, 24, 48, 96, 192）を昇順で返す
+func ESeries(n int, min, max float64) []float64 {
+	if min <= 0 || max < min {
+		panic(fmt.Sprintf("E%d series: requires 0 < min <= max (got min=%g max=%g)", n, min, max))
+	}
+	ms := eMantissas(n)
+	var out []float64
+	for dec := int(math.Floor(math.Log10(min))); dec <= int(math.Ceil(math.Log10(max))); dec++ {
+		for _, m := range ms {
+			// 10 進で組み立てて 4.7e-9 などをきっちり表す
+			v, _ := strconv.ParseFloat(fmt.Sprintf("%ge%d", m, dec), 64)
+			if v >= min*(1-1e-12) && v <= max*(1+1e-12) {
+				out = append(out, v)
+			}
+		}
+	}
+	return out
+}
+
+func E12(min, max float64) []float64 { return ESeries(12, min, max) }
+func E24(min, max float64) []float64 { return ESeries(24, min, max) }
+func E96(min, max float64) []float64 { return ESeries(96, min, max) }
+
+// valuesInRange: p.Values のうち [Min, Max] に入る範囲 [i0, i1)（Values は昇順）
+func valuesInRange(p ParamSpec) (int, int) {
+	i0 := sort.SearchFloat64s(p.Values, p.Min)
+	i1 := sort.Search(len(p.Values), func(i int) bool { return p.Values[i] > p.Max })
+	return i0, i1
+}
+
+// valueQuantile: u ∈ [0,1) を [Min, Max] に入る Values の 1 つに変換する（等確率）
+func valueQuantile(u float64, p ParamSpec) (float64, error) {
+	i0, i1 := valuesInRange(p)
+	if i1 <= i0 {
+		return 0, fmt.Errorf("param %s: no Values in [%g, %g]", p.Key, p.Min, p.Max)
+	}
+	i := i0 + min(int(u*float64(i1-i0)), i1-i0-1)
+	return p.Values[i], nil
+}
+
+// valueCDF: valueQuantile の逆（値 v に最も近い Values のマスの中央）
+func valueCDF(v float64, p ParamSpec) float64 {
+	i0, i1 := valuesInRange(p)
+	if i1 <= i0 {
+		return 0
+	}
+	i := sort.SearchFloat64s(p.Values[i0:i1], v)
+	if i == i1-i0 || (i > 0 && v-p.Values[i0+i-1] < p.Values[i0+i]-v) {
+		i--
+	}
+	return (float64(i) + 0.5) / float64(i1-i0)
+}
+
+// checkValues: Values が昇順かを確かめる（設定ミスは panic）
+func checkValues(p ParamSpec) {
+	if !sort.Float64sAreSorted(p.Values) {
+		panic("param " + p.Key + ": Values must be sorted in ascending order")
+	}
+}
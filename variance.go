@@ -0,0 +1,151 @@
+// variance.go
+// OK 率の推定のばらつきを減らす工夫
+//
+// Config.Antithetic: u を引いたら次は 1 − u を使う（対称な対）。OK 率が u に対して単調に近いと、
+// 対の片方が OK なら他方は NG になりやすく、平均のばらつきが打ち消し合って小さくなる。
+//
+// Config.CompareYRanges: YRange の候補を同じサンプルで判定する（共通乱数）。別々に走らせると
+// 乱数の違いが差に乗るが、同じ y で数えれば差には YRange の違いしか出ない。差の標準誤差も対ごとに計算する。
+// なお、探索モードが評価結果を使わない（random / sobol / lhs / grid）なら、Seed が同じ実行どうしも
+// 同じ u の列を使うので、YRange だけ変えた別々の実行も共通乱数になっている。
+
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/ichijohodaka/wpt-parameter-search2/stats"
+)
+
+// AntitheticSampler: 元の Sampler の点 u と 1 − u を交互に返す
+type AntitheticSampler struct {
+	base Sampler
+	prev []float64
+	half bool // 次は prev の反対側を返す
+}
+
+// newAntithetic: 評価結果を使う探索モードや格子では対にしても意味がないのでエラー
+func newAntithetic(s Sampler) (Sampler, error) {
+	if _, ok := s.(FeedbackSampler); ok {
+		return nil, fmt.Errorf("antithetic: not available with %T", s)
+	}
+	if _, ok := s.(FiniteSampler); ok {
+		return nil, fmt.Errorf("antithetic: not available with %T", s)
+	}
+	return &AntitheticSampler{base: s}, nil
+}
+
+func (s *AntitheticSampler) Init(dim int, seed int64) error {
+	s.prev = make([]float64, dim)
+	s.half = false
+	return s.base.Init(dim, seed)
+}
+
+func (s *AntitheticSampler) Next(u []float64) {
+	if !s.half {
+		s.base.Next(u)
+		copy(s.prev, u)
+	} else {
+		for i := range u {
+			// u = 0 の反対は 1 になるので [0,1) に収める
+			u[i] = math.Min(1-s.prev[i], math.Nextafter(1, 0))
+		}
+	}
+	s.half = !s.half
+}
+
+// YRangeStat: 1 つの YRange での OK 率と、元の YRange との差（標準誤差付き）
+type YRangeStat struct {
+	YRange  Range
+	OKRatio float64
+	SE      float64
+	Diff    float64 // OKRatio − 元の YRange の OKRatio（元の YRange 自身は 0）
+	DiffSE  float64
+}
+
+// yrangeCmp: YRange の候補ごとの OK を同じサンプルで数える（対にしているなら対の平均を 1 つと数える）
+type yrangeCmp struct {
+	cfg   Config
+	group int // 1 つにまとめるサンプル数（対なら 2）
+
+	cur  []float64 // まとめている途中の OK の数（[0] は元の YRange）
+	curN int
+
+	ok   []stats.Moments // まとめた OK 率
+	diff []stats.Moments // まとめた OK 率の元の YRange との差
+}
+
+func newYRangeCmp(cfg Config) *yrangeCmp {
+	n := len(cfg.CompareYRanges) + 1
+	c := &yrangeCmp{cfg: cfg, group: 1, cur: make([]float64, n), ok: make([]stats.Moments, n), diff: make([]stats.Moments, n)}
+	if cfg.Antithetic {
+		c.group = 2
+	}
+	return c
+}
+
+func (c *yrangeCmp) add(s Sample) {
+	if s.OK {
+		c.cur[0]++
+	}
+	for i, r := range c.cfg.CompareYRanges {
+		cc := c.cfg
+		cc.YRange = r
+		if yOK(s.Y, cc) {
+			c.cur[i+1]++
+		}
+	}
+	c.curN++
+	if c.curN < c.group {
+		return
+	}
+	base := c.cur[0] / float64(c.curN)
+	for i := range c.cur {
+		v := c.cur[i] / float64(c.curN)
+		c.ok[i].Add(v)
+		c.diff[i].Add(v - base)
+		c.cur[i] = 0
+	}
+	c.curN = 0
+}
+
+func (c *yrangeCmp) result() []YRangeStat {
+	se := func(m stats.Moments) float64 {
+		if m.N < 2 {
+			return math.NaN()
+		}
+		return math.Sqrt(m.SampleVar() / float64(m.N))
+	}
+	out := make([]YRangeStat, len(c.ok))
+	for i := range c.ok {
+		r := c.cfg.YRange
+		if i > 0 {
+			r = c.cfg.CompareYRanges[i-1]
+		}
+		out[i] = YRangeStat{YRange: r, OKRatio: c.ok[i].Mean(), SE: se(c.ok[i]), Diff: c.diff[i].Mean(), DiffSE: se(c.diff[i])}
+	}
+	return out
+}
+
+// PrintYRangeComparison: YRange ごとの OK 率と差を表示する（± は標準誤差）
+func PrintYRangeComparison(list []YRangeStat, antithetic bool) {
+	fmt.Println("=== OK ratio by yRange (same samples, ± standard error) ===")
+	if len(list) == 0 || math.IsNaN(list[0].OKRatio) {
+		fmt.Println("(no samples)")
+		fmt.Println()
+		return
+	}
+	for i, st := range list {
+		fmt.Printf("yRange=[%s, %s]  OK_ratio=%s ± %s", fmt4(st.YRange.Min), fmt4(st.YRange.Max), fmt4(st.OKRatio), fmt4(st.SE))
+		if i == 0 {
+			fmt.Println("  (configured)")
+		} else {
+			fmt.Printf("  diff=%s ± %s\n", fmt4(st.Diff), fmt4(st.DiffSE))
+		}
+	}
+	if antithetic {
+		fmt.Println("(antithetic pairs are counted as one observation)")
+	}
+	fmt.Println()
+}
@@ -0,0 +1,75 @@
+// verify.go
+// 保存した OK サンプルの浮動小数点誤差の確認（Config.Verify）
+//
+// F は任意の Go のコードなので、区間演算でそのまま評価し直すことはできない。代わりに、
+// 各パラメータを数 ulp 程度（RelPert）ずつランダムにずらして何度か評価し直し、y のばらつきの
+// 最大値 δ を丸め誤差の目安とする（条件数が悪い式ほど δ が大きくなる）。
+// 区間 [y − δ, y + δ] が YRange からはみ出すサンプルは、誤差しだいで NG になりうるので印を付ける。
+
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// 出力列のキー
+const (
+	YErrKey   = "y_err"  // 誤差の目安 δ
+	UnsureKey = "unsure" // [y − δ, y + δ] が YRange からはみ出すなら 1
+)
+
+// VerifyConfig: 誤差の確認の設定（Enabled が false なら何もしない）
+type VerifyConfig struct {
+	Enabled bool
+	Trials  int     // 1 サンプルあたりの評価し直しの回数（0 なら 32）
+	RelPert float64 // パラメータをずらす相対幅（0 なら 64 ulp 相当の 64·2⁻⁵²）
+}
+
+// verify: 保存した OK サンプルを評価し直して誤差の目安を書き込み、はみ出す数を返す
+func (e *engine) verify() int {
+	vc := e.cfg.Verify
+	trials := vc.Trials
+	if trials <= 0 {
+		trials = 32
+	}
+	rel := vc.RelPert
+	if rel <= 0 {
+		rel = 64 * math.Pow(2, -52)
+	}
+	rng := newRand(e.cfg.Seed)
+
+	unsure := 0
+	for _, s := range e.okList {
+		var delta float64
+		for t := 0; t < trials; t++ {
+			x := make(map[string]float64, len(e.cfg.Params)+len(e.cfg.Derived))
+			for _, p := range e.cfg.Params {
+				v := s.Values[p.Key]
+				// 整数・カテゴリ・離散値はずらさない（値そのものが意味を持つ）
+				if p.Type == Real && len(p.Values) == 0 {
+					v *= 1 + rel*(2*rng.Float64()-1)
+				}
+				x[p.Key] = v
+			}
+			for _, d := range e.cfg.Derived {
+				x[d.Key] = d.Func(x)
+			}
+			y, _ := e.obj.Eval(x)
+			if math.IsNaN(y) || math.IsInf(y, 0) {
+				delta = math.Inf(1)
+				break
+			}
+			delta = math.Max(delta, math.Abs(y-s.Y))
+		}
+		s.Values[YErrKey] = delta
+		s.Values[UnsureKey] = 0
+		if !inYRange(s.Y-delta, e.cfg) || !inYRange(s.Y+delta, e.cfg) {
+			s.Values[UnsureKey] = 1
+			unsure++
+		}
+	}
+	fmt.Printf("[verify] %d of %d saved OK samples may cross the yRange boundary within floating-point error\n",
+		unsure, len(e.okList))
+	return unsure
+}
@@ -0,0 +1,154 @@
+// wasm.go
+// 目的関数を WebAssembly のモジュールから読む（Config.WASM・-wasm・設定ファイルの wasm）
+//
+// Rust / C / AssemblyScript などで書いた目的関数を、OS を問わず、ファイルやネットワークに触れない状態で使えるように、
+// wazero（cgo 不要の WebAssembly ランタイム）で .wasm を実行する。モジュールは次の関数を公開する。
+//
+//	eval(p1 f64, p2 f64, ..., pn f64) -> f64
+//
+// 引数は params の順（派生パラメータがあればその後ろに Derived の順）で、元の単位の値。返り値が y。
+// 引数の数と型は読み込むときに確かめる。WASI の関数は使えるが、ファイル・環境変数・時計の入力は与えない。
+// _initialize があれば最初に呼ぶ（Go の -buildmode=c-shared や WASI の reactor）。
+// 並列のワーカーごとに別のインスタンスを使うので、モジュールの大域変数はワーカー間で共有されない。
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// wasmObjective: コンパイルしたモジュールと、使っていないインスタンス
+type wasmObjective struct {
+	rt   wazero.Runtime
+	mod  wazero.CompiledModule
+	keys []string
+
+	mu   sync.Mutex
+	free []wasmInstance
+}
+
+// wasmInstance: 1 つのワーカーが使うインスタンス
+type wasmInstance struct {
+	mod  api.Module
+	eval api.Function
+}
+
+// loadWASM: path のモジュールを読み、keys の順の値を渡す eval を F にする
+func loadWASM(path string, keys []string) (func(map[string]float64) float64, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	rt := wazero.NewRuntime(ctx)
+	wasi_snapshot_preview1.MustInstantiate(ctx, rt)
+	mod, err := rt.CompileModule(ctx, src)
+	if err != nil {
+		rt.Close(ctx)
+		return nil, fmt.Errorf("wasm %s: %w", path, err)
+	}
+	def, ok := mod.ExportedFunctions()["eval"]
+	if !ok {
+		rt.Close(ctx)
+		return nil, fmt.Errorf("wasm %s: no exported function eval", path)
+	}
+	if err := checkWASMSignature(def, len(keys)); err != nil {
+		rt.Close(ctx)
+		return nil, fmt.Errorf("wasm %s: %w", path, err)
+	}
+	w := &wasmObjective{rt: rt, mod: mod, keys: keys}
+
+	// 最初のインスタンスで動くことを確かめておく
+	in, err := w.get()
+	if err != nil {
+		rt.Close(ctx)
+		return nil, fmt.Errorf("wasm %s: %w", path, err)
+	}
+	w.put(in)
+	return w.eval, nil
+}
+
+// checkWASMSignature: eval が f64 を n 個受け取って f64 を 1 つ返すか
+func checkWASMSignature(def api.FunctionDefinition, n int) error {
+	params, results := def.ParamTypes(), def.ResultTypes()
+	ok := len(params) == n && len(results) == 1 && results[0] == api.ValueTypeF64
+	for _, t := range params {
+		ok = ok && t == api.ValueTypeF64
+	}
+	if !ok {
+		return fmt.Errorf("eval must be (f64 × %d) -> f64 (one argument per param, in order); got %d params, %d results",
+			n, len(params), len(results))
+	}
+	return nil
+}
+
+// get: 使っていないインスタンスを取り出す（なければ作る）
+func (w *wasmObjective) get() (wasmInstance, error) {
+	w.mu.Lock()
+	if n := len(w.free); n > 0 {
+		in := w.free[n-1]
+		w.free = w.free[:n-1]
+		w.mu.Unlock()
+		return in, nil
+	}
+	w.mu.Unlock()
+	cfg := wazero.NewModuleConfig().WithName("").WithStartFunctions("_initialize")
+	m, err := w.rt.InstantiateModule(context.Background(), w.mod, cfg)
+	if err != nil {
+		return wasmInstance{}, err
+	}
+	return wasmInstance{mod: m, eval: m.ExportedFunction("eval")}, nil
+}
+
+func (w *wasmObjective) put(in wasmInstance) {
+	w.mu.Lock()
+	w.free = append(w.free, in)
+	w.mu.Unlock()
+}
+
+// eval: x を keys の順に渡して y を返す（モジュールの実行に失敗したら NaN）
+func (w *wasmObjective) eval(x map[string]float64) float64 {
+	in, err := w.get()
+	if err != nil {
+		return math.NaN()
+	}
+	args := make([]uint64, len(w.keys))
+	for j, k := range w.keys {
+		args[j] = api.EncodeF64(Get(x, k))
+	}
+	out, err := in.eval.Call(context.Background(), args...)
+	if err != nil {
+		in.mod.Close(context.Background()) // trap したインスタンスは使い回さない
+		return math.NaN()
+	}
+	w.put(in)
+	return api.DecodeF64(out[0])
+}
+
+// applyWASM: Config.WASM があればモジュールの eval を F にする（F2・Objective より優先）
+func applyWASM(cfg *Config) error {
+	if cfg.WASM == "" {
+		return nil
+	}
+	var keys []string
+	for _, p := range cfg.Params {
+		keys = append(keys, p.Key)
+	}
+	for _, d := range cfg.Derived {
+		keys = append(keys, d.Key)
+	}
+	f, err := loadWASM(cfg.WASM, keys)
+	if err != nil {
+		return err
+	}
+	cfg.F, cfg.F2, cfg.Objective = f, nil, nil
+	return nil
+}
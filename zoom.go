@@ -0,0 +1,108 @@
+// zoom.go
+// 多段探索（OK サンプルの範囲に探索範囲を絞り込む）
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// ZoomConfig: 多段探索のスケジュール（ゼロ値なら 1 段のみ＝従来通り）
+type ZoomConfig struct {
+	Phases int       // 段数（1 以下なら絞り込みなし）
+	Budget []float64 // 各段に割り当てる MaxIters の比率（nil なら均等。長さは Phases）
+	Margin float64   // OK の範囲に加える余白（範囲の幅に対する比、両側）
+	Shrink float64   // 新しい範囲の幅の下限（前段の幅に対する比。0 なら下限なし）
+}
+
+// phaseEnds: 各段の終了時点の累積反復数
+func (z ZoomConfig) phaseEnds(maxIters int64) []int64 {
+	n := max(z.Phases, 1)
+	weights := z.Budget
+	if len(weights) != n {
+		weights = make([]float64, n)
+		for i := range weights {
+			weights[i] = 1
+		}
+	}
+	var total float64
+	for _, w := range weights {
+		total += w
+	}
+
+	ends := make([]int64, n)
+	var acc float64
+	for i, w := range weights {
+		acc += w
+		ends[i] = int64(math.Round(float64(maxIters) * acc / total))
+	}
+	ends[n-1] = maxIters
+	return ends
+}
+
+// okBox: OK サンプルの各パラメータの最小・最大
+type okBox struct {
+	n      int64
+	lo, hi []float64
+}
+
+func newOKBox(dim int) okBox {
+	b := okBox{lo: make([]float64, dim), hi: make([]float64, dim)}
+	for j := range b.lo {
+		b.lo[j] = math.Inf(1)
+		b.hi[j] = math.Inf(-1)
+	}
+	return b
+}
+
+func (b *okBox) add(params []ParamSpec, vals map[string]float64) {
+	b.n++
+	for j, p := range params {
+		v := vals[p.Key]
+		b.lo[j] = math.Min(b.lo[j], v)
+		b.hi[j] = math.Max(b.hi[j], v)
+	}
+}
+
+// shrink: OK の範囲（+ 余白）に探索範囲を絞る。OK が 1 件もなければそのまま。
+// 幅は Linear ならそのまま、Log なら対数軸で測る。前段の範囲の外には広げない。
+func (z ZoomConfig) shrink(params []ParamSpec, box okBox) []ParamSpec {
+	if box.n == 0 {
+		return params
+	}
+	out := make([]ParamSpec, len(params))
+	for j, p := range params {
+		out[j] = p
+		if p.Min == p.Max {
+			continue
+		}
+		to, from := func(v float64) float64 { return v }, func(v float64) float64 { return v }
+		if p.Scale == Log {
+			to, from = math.Log, math.Exp
+		}
+
+		lo, hi := to(box.lo[j]), to(box.hi[j])
+		m := z.Margin * (hi - lo)
+		lo, hi = lo-m, hi+m
+
+		pMin, pMax := to(p.Min), to(p.Max)
+		if minW := z.Shrink * (pMax - pMin); hi-lo < minW {
+			c := (lo + hi) / 2
+			lo, hi = c-minW/2, c+minW/2
+		}
+		out[j].Min = from(math.Max(lo, pMin))
+		out[j].Max = from(math.Min(hi, pMax))
+	}
+	return out
+}
+
+// formatRanges: 探索範囲を表示単位で 1 行にまとめる
+func formatRanges(params []ParamSpec) string {
+	var sb strings.Builder
+	for _, p := range params {
+		fmt.Fprintf(&sb, "  %s=[%.4g, %.4g]", p.Label, p.Min*p.DisplayScale, p.Max*p.DisplayScale)
+	}
+	return sb.String()
+}